@@ -0,0 +1,216 @@
+// Command replay re-sends stored signals to the backend, e.g. after an
+// outage or to reprocess them. It reads newline-delimited JSON signals, as
+// written by the file exporter and the dead-letter queue, from files or
+// directories and sends them through the SignalSender with its batching,
+// retry, failover and Retry-After handling.
+//
+// Usage:
+//
+//	replay [--dry-run] [--backend-url URL] [--batch-size N] [--rate N] PATH...
+//
+// Don't point AXOM_DLQ_PATH at a file being replayed: batches that still
+// fail are dead-lettered there.
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+
+	"axom-observer/pkg/models"
+	"axom-observer/pkg/observer"
+)
+
+// maxLineBytes bounds a single signal line
+const maxLineBytes = 16 << 20
+
+// getEnvWithDefault gets environment variable with fallback
+func getEnvWithDefault(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+func main() {
+	var (
+		backendURL = flag.String("backend-url", getEnvWithDefault("BACKEND_URL", ""), "Backend URL for signals (default AXOM_BACKEND_URL or AXOM_BACKEND_URLS)")
+		apiKey     = flag.String("api-key", getEnvWithDefault("AGENT_SECRET", os.Getenv("AXOM_API_KEY")), "API key for backend authentication")
+		batchSize  = flag.Int("batch-size", 0, "Signals per batch (default AXOM_BATCH_SIZE or 50)")
+		rate       = flag.Float64("rate", 0, "Maximum batches per second; 0 means no limit")
+		dryRun     = flag.Bool("dry-run", false, "Validate and count signals without sending them")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] PATH...\n\nPATH is an NDJSON signal file or a directory of them.\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	logger := log.New(os.Stdout, "replay: ", log.LstdFlags)
+
+	files, err := signalFiles(flag.Args())
+	if err != nil {
+		logger.Fatalf("❌ %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var sender *observer.SignalSender
+	if !*dryRun {
+		sender = observer.NewSignalSender(*apiKey, *backendURL, *batchSize, 0)
+		if err := sender.ConfigError(); err != nil {
+			logger.Fatalf("❌ Invalid backend TLS configuration: %v", err)
+		}
+	}
+	r := &replayer{
+		sender:    sender,
+		logger:    logger,
+		batchSize: *batchSize,
+	}
+	if r.batchSize <= 0 {
+		r.batchSize = 50
+		if sender != nil {
+			r.batchSize = sender.BatchSize()
+		}
+	}
+	if *rate > 0 {
+		r.interval = time.Duration(float64(time.Second) / *rate)
+	}
+
+	for _, path := range files {
+		if err := r.replayFile(ctx, path); err != nil {
+			logger.Printf("❌ %s: %v", path, err)
+			r.failed++
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	if *dryRun {
+		logger.Printf("✅ Dry run: %d valid signals, %d invalid lines in %d files", r.valid, r.invalid, len(files))
+	} else {
+		logger.Printf("📊 Replayed %d of %d valid signals (%d invalid lines) from %d files", r.sent, r.valid, r.invalid, len(files))
+	}
+	if r.invalid > 0 || r.failed > 0 || (!*dryRun && r.sent < r.valid) {
+		os.Exit(1)
+	}
+}
+
+// signalFiles expands the paths to the files to replay: files as given and
+// the regular files directly inside directories, in name order
+func signalFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		var names []string
+		for _, entry := range entries {
+			if entry.Type().IsRegular() {
+				names = append(names, filepath.Join(path, entry.Name()))
+			}
+		}
+		sort.Strings(names)
+		files = append(files, names...)
+	}
+	return files, nil
+}
+
+// replayer sends the signals of one or more files in batches, counting
+// what it saw and sent. A nil sender makes it a dry run.
+type replayer struct {
+	sender    *observer.SignalSender
+	logger    *log.Logger
+	batchSize int
+	interval  time.Duration // minimum time between batches
+	lastSend  time.Time
+
+	valid   int
+	invalid int
+	sent    int
+	failed  int
+}
+
+// replayFile reads one NDJSON file and sends its signals
+func (r *replayer) replayFile(ctx context.Context, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxLineBytes)
+	batch := make([]models.Signal, 0, r.batchSize)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var sig models.Signal
+		if err := json.Unmarshal(scanner.Bytes(), &sig); err != nil {
+			r.logger.Printf("⚠️ %s:%d: invalid signal: %v", path, line, err)
+			r.invalid++
+			continue
+		}
+		r.valid++
+		batch = append(batch, sig)
+		if len(batch) >= r.batchSize {
+			r.send(ctx, batch)
+			batch = batch[:0]
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	r.send(ctx, batch)
+	return nil
+}
+
+// send sends a batch, pacing batches to the configured rate
+func (r *replayer) send(ctx context.Context, batch []models.Signal) {
+	if r.sender == nil || len(batch) == 0 {
+		return
+	}
+	if wait := r.interval - time.Since(r.lastSend); r.interval > 0 && wait > 0 {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+	r.lastSend = time.Now()
+	sent, err := r.sender.SendBatches(ctx, batch)
+	r.sent += sent
+	if err != nil {
+		r.logger.Printf("❌ Failed to send %d signals: %v", len(batch)-sent, err)
+	}
+}
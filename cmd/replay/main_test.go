@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"axom-observer/pkg/models"
+	"axom-observer/pkg/observer"
+)
+
+// writeSignalFile writes lines as an NDJSON file in dir
+func writeSignalFile(t *testing.T, dir, name string, lines ...string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	var data []byte
+	for _, line := range lines {
+		data = append(data, line+"\n"...)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+	return path
+}
+
+// ingestServer records the signal IDs of each batch it receives
+type ingestServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	batches [][]string
+}
+
+func newIngestServer(t *testing.T) *ingestServer {
+	t.Helper()
+	s := &ingestServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			return
+		}
+		var batch []models.Signal
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			t.Errorf("invalid batch: %v", err)
+		}
+		var ids []string
+		for _, sig := range batch {
+			ids = append(ids, sig.ID)
+		}
+		s.mu.Lock()
+		s.batches = append(s.batches, ids)
+		s.mu.Unlock()
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+const (
+	signalLine1 = `{"id":"sig-1","timestamp":"2025-01-02T15:04:05Z","operation":"chat_completion"}`
+	signalLine2 = `{"id":"sig-2","timestamp":"2025-01-02T15:04:06Z","operation":"chat_completion"}`
+	signalLine3 = `{"id":"sig-3","timestamp":"2025-01-02T15:04:07Z","operation":"embedding"}`
+)
+
+func TestReplayFile(t *testing.T) {
+	t.Setenv("AXOM_DLQ_PATH", "")
+	t.Setenv("AXOM_STARTUP_GRACE", "0")
+	backend := newIngestServer(t)
+	path := writeSignalFile(t, t.TempDir(), "signals.ndjson", signalLine1, "not json", "", signalLine2, signalLine3)
+
+	r := &replayer{
+		sender:    observer.NewSignalSender("test-key", backend.URL, 2, 0),
+		logger:    log.New(io.Discard, "", 0),
+		batchSize: 2,
+	}
+	if err := r.replayFile(context.Background(), path); err != nil {
+		t.Fatalf("replayFile: %v", err)
+	}
+
+	if r.valid != 3 || r.invalid != 1 || r.sent != 3 {
+		t.Errorf("replayed %d valid, %d invalid, %d sent, want 3, 1 and 3", r.valid, r.invalid, r.sent)
+	}
+	want := [][]string{{"sig-1", "sig-2"}, {"sig-3"}}
+	if !reflect.DeepEqual(backend.batches, want) {
+		t.Errorf("backend received %v, want %v", backend.batches, want)
+	}
+}
+
+func TestReplayDryRun(t *testing.T) {
+	path := writeSignalFile(t, t.TempDir(), "signals.ndjson", signalLine1, `{"id":`, signalLine2)
+	r := &replayer{logger: log.New(io.Discard, "", 0), batchSize: 50}
+	if err := r.replayFile(context.Background(), path); err != nil {
+		t.Fatalf("replayFile: %v", err)
+	}
+	if r.valid != 2 || r.invalid != 1 || r.sent != 0 {
+		t.Errorf("dry run counted %d valid, %d invalid, %d sent, want 2, 1 and 0", r.valid, r.invalid, r.sent)
+	}
+}
+
+func TestReplayRate(t *testing.T) {
+	t.Setenv("AXOM_DLQ_PATH", "")
+	t.Setenv("AXOM_STARTUP_GRACE", "0")
+	backend := newIngestServer(t)
+	path := writeSignalFile(t, t.TempDir(), "signals.ndjson", signalLine1, signalLine2, signalLine3)
+
+	r := &replayer{
+		sender:    observer.NewSignalSender("test-key", backend.URL, 1, 0),
+		logger:    log.New(io.Discard, "", 0),
+		batchSize: 1,
+		interval:  100 * time.Millisecond,
+	}
+	start := time.Now()
+	if err := r.replayFile(context.Background(), path); err != nil {
+		t.Fatalf("replayFile: %v", err)
+	}
+	// Three batches, the second and third each held back by the interval
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("3 batches at 10/s took %v, want at least 200ms", elapsed)
+	}
+	if r.sent != 3 {
+		t.Errorf("sent %d signals, want 3", r.sent)
+	}
+}
+
+func TestSignalFiles(t *testing.T) {
+	dir := t.TempDir()
+	b := writeSignalFile(t, dir, "b.ndjson", signalLine1)
+	a := writeSignalFile(t, dir, "a.ndjson", signalLine2)
+	os.Mkdir(filepath.Join(dir, "nested"), 0o755)
+	single := writeSignalFile(t, t.TempDir(), "dlq.ndjson", signalLine3)
+
+	got, err := signalFiles([]string{single, dir})
+	if err != nil {
+		t.Fatalf("signalFiles: %v", err)
+	}
+	if want := []string{single, a, b}; !reflect.DeepEqual(got, want) {
+		t.Errorf("signalFiles = %v, want %v", got, want)
+	}
+
+	if _, err := signalFiles([]string{filepath.Join(dir, "missing.ndjson")}); err == nil {
+		t.Errorf("signalFiles of a missing path succeeded")
+	}
+}
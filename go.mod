@@ -5,13 +5,17 @@ go 1.24.2
 require (
 	github.com/AdguardTeam/gomitmproxy v0.2.1
 	github.com/prometheus/client_golang v1.22.0
+	github.com/segmentio/kafka-go v0.4.47
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/AdguardTeam/golibs v0.4.0 // indirect
 	github.com/beorn7/perks v1.0.1 // indirect
 	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/klauspost/compress v1.18.0 // indirect
 	github.com/munnerz/goautoneg v0.0.0-20191010083416-a7dc8b61c822 // indirect
+	github.com/pierrec/lz4/v4 v4.1.15 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/prometheus/client_model v0.6.1 // indirect
 	github.com/prometheus/common v0.62.0 // indirect
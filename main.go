@@ -3,12 +3,17 @@ package main
 import (
 	"context"
 	"flag"
+	"io"
 	"log"
 	"os"
 	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
+	"axom-observer/pkg/export"
 	"axom-observer/pkg/models"
 	"axom-observer/pkg/observer"
 )
@@ -21,6 +26,16 @@ func getEnvWithDefault(key, defaultValue string) string {
 	return defaultValue
 }
 
+// getEnvIntWithDefault gets an integer environment variable with fallback
+func getEnvIntWithDefault(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+	}
+	return defaultValue
+}
+
 func main() {
 	// Parse command line flags
 	var (
@@ -32,6 +47,8 @@ func main() {
 		backendURL   = flag.String("backend-url", getEnvWithDefault("BACKEND_URL", "http://localhost:8080/api/v1/signals"), "Backend URL for signals")
 		httpPort     = flag.String("http-port", "8888", "HTTP proxy port")
 		httpsPort    = flag.String("https-port", "8443", "HTTPS proxy port")
+		healthPort   = flag.String("health-port", getEnvWithDefault("AXOM_HEALTH_PORT", "8081"), "Port for /healthz and /readyz probes")
+		signalBuffer = flag.Int("signal-buffer", getEnvIntWithDefault("AXOM_SIGNAL_BUFFER", 100), "Signal channel buffer size")
 	)
 	flag.Parse()
 
@@ -66,14 +83,39 @@ func main() {
 	logger.Printf("📡 Customer ID: %s", *customerID)
 	logger.Printf("🤖 Agent ID: %s", *agentID)
 	logger.Printf("🔑 Client ID: %s", *clientID)
-	logger.Printf("🔐 Client Secret: %s", maskSecret(*clientSecret))
-	logger.Printf("🔑 Agent Secret: %s", maskSecret(*agentSecret))
+	logger.Printf("🔐 Client Secret: %s", observer.MaskSecret(*clientSecret))
+	logger.Printf("🔑 Agent Secret: %s", observer.MaskSecret(*agentSecret))
 	logger.Printf("🌐 Backend URL: %s", *backendURL)
 	logger.Printf("🔗 HTTP Port: %s", *httpPort)
 	logger.Printf("🔒 HTTPS Port: %s", *httpsPort)
 
+	// Aggregate per-customer/agent usage for the /billing view
+	billing := observer.NewBillingAggregator("", logger)
+
+	// Keep the most recent signals, redacted, for the /debug/signals view
+	recentSignals := observer.NewSignalRing(0, logger)
+
+	// Effective configuration for the /config view, filled in as it is resolved
+	runtimeConfig := observer.NewRuntimeConfig()
+
+	// Start Prometheus metrics server
+	var metricsServer *observer.MetricsServer
+	if os.Getenv("AXOM_METRICS_ENABLED") != "0" {
+		metricsServer = observer.NewMetricsServer("", logger)
+		metricsServer.Handle("/billing", billing)
+		metricsServer.Handle("/config", runtimeConfig)
+		metricsServer.Handle("/debug/signals", recentSignals)
+		if err := metricsServer.Start(ctx); err != nil {
+			logger.Printf("Failed to start metrics server: %v", err)
+			metricsServer = nil
+		}
+	}
+
 	// Create signal channel
-	signalCh := make(chan models.Signal, 100)
+	if *signalBuffer <= 0 {
+		*signalBuffer = 100
+	}
+	signalCh := make(chan models.Signal, *signalBuffer)
 
 	// Create comprehensive AI traffic monitor
 	aiMonitor := observer.NewAITrafficMonitor(signalCh, logger, *customerID, *agentID)
@@ -85,76 +127,277 @@ func main() {
 		10,            // Batch size
 		5*time.Second, // Flush interval
 	)
+	if err := signalSender.ConfigError(); err != nil {
+		logger.Fatalf("Invalid backend TLS configuration: %v", err)
+	}
+
+	// Select export paths: any of "backend", "otlp", "kafka" and "file", comma
+	// separated ("both" means backend and OTLP). Backend signals are queued
+	// for the sender's batching loop, which retries, fails over and
+	// dead-letters them.
+	backendCh := make(chan models.Signal, *signalBuffer)
+	backendQueue := senderQueue(backendCh)
+	exporters := buildExporters(getEnvWithDefault("AXOM_EXPORTER", "backend"), backendQueue, logger)
+	if len(exporters) == 0 {
+		logger.Fatalf("No signal exporters configured")
+	}
+	exporters = append(exporters, billing, recentSignals)
+
+	// Per-agent usage budgets, alerting on the signal that exceeds one
+	budgets, err := observer.NewBudgetMonitor("")
+	if err != nil {
+		logger.Printf("Failed to load budgets: %v", err)
+	}
+	// Per-agent token spikes, alerting on requests far above the agent's norm
+	spikes := observer.NewTokenSpikeDetector()
+
+	runtimeConfig.Set("customer_id", *customerID)
+	runtimeConfig.Set("agent_id", *agentID)
+	runtimeConfig.Set("client_id", *clientID)
+	runtimeConfig.SetSecret("client_secret", *clientSecret)
+	runtimeConfig.SetSecret("agent_secret", *agentSecret)
+	runtimeConfig.Set("backend_url", *backendURL)
+	runtimeConfig.Set("http_port", *httpPort)
+	runtimeConfig.Set("https_port", *httpsPort)
+	runtimeConfig.Set("health_port", *healthPort)
+	runtimeConfig.Set("signal_buffer", *signalBuffer)
+	runtimeConfig.Set("exporter", getEnvWithDefault("AXOM_EXPORTER", "backend"))
+	runtimeConfig.Set("sender", signalSender.EffectiveConfig())
+
+	// Start health and readiness probes (not ready until the monitor has started)
+	healthServer := observer.NewHealthServer(":"+*healthPort, logger, aiMonitor, signalSender)
+	if err := healthServer.Start(ctx); err != nil {
+		logger.Printf("Failed to start health server: %v", err)
+	}
 
 	// Start AI traffic monitor
 	if err := aiMonitor.Start(ctx); err != nil {
 		logger.Fatalf("Failed to start AI traffic monitor: %v", err)
 	}
 
-	// Start signal processing
-	go processSignals(ctx, signalCh, signalSender)
+	// Start the backend sender if it is one of the exporters. It outlives
+	// signal processing so that it can send what processing still queues.
+	senderCtx, stopSender := context.WithCancel(context.Background())
+	senderDone := make(chan struct{})
+	if slices.Contains(exporters, export.SignalExporter(backendQueue)) {
+		go func() {
+			defer close(senderDone)
+			signalSender.StartPrepared(senderCtx, backendCh)
+		}()
+	} else {
+		close(senderDone)
+	}
+
+	// Start signal processing. It outlives ctx so that signals still in the
+	// channel at shutdown are exported once the proxies have stopped.
+	processCtx, stopProcessing := context.WithCancel(context.Background())
+	processed := make(chan struct{})
+	go func() {
+		defer close(processed)
+		processSignals(processCtx, signalCh, signalSender, exporters, budgets, spikes)
+	}()
 
 	logger.Println("✅ Observer started successfully")
 	logger.Printf("📡 Listening for AI API traffic on HTTP port %s and HTTPS port %s", *httpPort, *httpsPort)
 	logger.Printf("📊 Sending signals to backend at %s", *backendURL)
-	logger.Println("🔍 Monitoring all major AI providers: OpenAI, Anthropic, Google AI, Cohere, Hugging Face, Azure OpenAI")
+	logger.Println("🔍 Monitoring all major AI providers: OpenAI, Anthropic, Google AI, Vertex AI, Amazon Bedrock, Mistral, DeepSeek, xAI, Cohere, Hugging Face, Azure OpenAI")
 
 	<-ctx.Done()
 	logger.Println("🛑 Shutdown initiated...")
 
-	// Stop AI traffic monitor
-	if err := aiMonitor.Stop(ctx); err != nil {
+	// Stop AI traffic monitor first so no new signals are produced
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := aiMonitor.Stop(shutdownCtx); err != nil {
 		logger.Printf("Error stopping AI traffic monitor: %v", err)
 	}
 
-	time.Sleep(1 * time.Second) // Allow final flush
+	// Export what is left in the channel
+	stopProcessing()
+	select {
+	case <-processed:
+	case <-shutdownCtx.Done():
+		logger.Printf("⚠️ Timed out draining signals (%d left)", len(signalCh))
+	}
+
+	// Give the backend sender's final batches one attempt
+	stopSender()
+	select {
+	case <-senderDone:
+	case <-shutdownCtx.Done():
+		logger.Printf("⚠️ Timed out sending final batches (%d signals left)", len(backendCh))
+	}
+
+	// Stop metrics server
+	if metricsServer != nil {
+		if err := metricsServer.Stop(shutdownCtx); err != nil {
+			logger.Printf("Error stopping metrics server: %v", err)
+		}
+	}
+
+	// Flush and close exporters that hold connections
+	for _, exporter := range exporters {
+		if closer, ok := exporter.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				logger.Printf("Error closing exporter: %v", err)
+			}
+		}
+	}
 }
 
+// shutdownTimeout bounds stopping the proxies and draining the signal channel
+const shutdownTimeout = 10 * time.Second
+
+// processSignals exports signals from signalCh until ctx is cancelled, then
+// exports whatever is still buffered in the channel and returns. Cancelling
+// ctx doesn't abandon an export in progress, which would drop its signal.
 func processSignals(
 	ctx context.Context,
 	signalCh <-chan models.Signal,
 	sender *observer.SignalSender,
+	exporters []export.SignalExporter,
+	budgets *observer.BudgetMonitor,
+	spikes *observer.TokenSpikeDetector,
 ) {
+	exportCtx := context.WithoutCancel(ctx)
 	for {
 		select {
 		case <-ctx.Done():
+			drainCtx, cancel := context.WithTimeout(exportCtx, shutdownTimeout)
+			defer cancel()
+			for drainCtx.Err() == nil {
+				select {
+				case sig := <-signalCh:
+					processSignal(drainCtx, sig, sender, exporters, budgets, spikes)
+				default:
+					return
+				}
+			}
 			return
 		case sig := <-signalCh:
-			log.Printf("📡 Processing signal: %s %s -> %s (latency: %.2fms)",
-				sig.Protocol, sig.Operation, sig.Destination.IP, sig.LatencyMS)
+			processSignal(exportCtx, sig, sender, exporters, budgets, spikes)
+		}
+	}
+}
 
-			// Extract provider information
-			if provider, ok := sig.Metadata["provider"].(string); ok {
-				log.Printf("🤖 AI Provider: %s", provider)
-			}
+// processSignal logs a signal, runs the sender's hooks and redaction on a
+// copy of it, applies budgets and spike detection and hands the copy to
+// every exporter
+func processSignal(
+	ctx context.Context,
+	sig models.Signal,
+	sender *observer.SignalSender,
+	exporters []export.SignalExporter,
+	budgets *observer.BudgetMonitor,
+	spikes *observer.TokenSpikeDetector,
+) {
+	destination := sig.Destination.Hostname
+	if destination == "" {
+		destination = sig.Destination.IP
+	}
+	log.Printf("📡 Processing signal: %s %s -> %s (latency: %.2fms)",
+		sig.Protocol, sig.Operation, destination, sig.LatencyMS)
 
-			// Extract model information
-			if model, ok := sig.Metadata["model"].(string); ok {
-				log.Printf("🧠 Model: %s", model)
-			}
+	// Extract provider information
+	if provider, ok := sig.Metadata["provider"].(string); ok {
+		log.Printf("🤖 AI Provider: %s", provider)
+	}
 
-			// Extract token usage
-			if totalTokens, ok := sig.Metadata["total_tokens"].(int); ok {
-				log.Printf("🔢 Total Tokens: %d", totalTokens)
-			}
+	// Extract model information
+	if model, ok := sig.Metadata["model"].(string); ok {
+		log.Printf("🧠 Model: %s", model)
+	}
 
-			if sig.IsTaskComplete() {
-				log.Printf("✅ Task completed: %s - Outcome: %s", sig.TaskID, sig.Outcome)
-			}
+	// Extract token usage
+	if totalTokens, ok := sig.Metadata["total_tokens"].(int); ok {
+		log.Printf("🔢 Total Tokens: %d", totalTokens)
+	}
 
-			if err := sender.Send(sig); err != nil {
-				log.Printf("❌ Failed to send signal: %v", err)
-			} else {
-				log.Printf("✅ Signal sent successfully")
-			}
+	// Redact once for all exporters, on a copy: the proxies' task detector
+	// still holds the original
+	sig, ok := sender.Prepare(sig)
+	if !ok {
+		return
+	}
+
+	budgets.Apply(&sig)
+	for _, alert := range sig.Alerts {
+		if alert.Metadata["budget"] != nil {
+			log.Printf("💸 Budget exceeded: %s", alert.Message)
+		}
+	}
+	spikes.Apply(&sig)
+	for _, alert := range sig.Alerts {
+		if alert.Metadata["anomaly"] == "token_spike" {
+			log.Printf("📈 Token spike: %s", alert.Message)
+		}
+	}
+
+	if sig.IsTaskComplete() {
+		log.Printf("✅ Task completed: %s - Outcome: %s", sig.TaskID, sig.Outcome)
+	}
+
+	for _, exporter := range exporters {
+		if err := exporter.Export(ctx, []models.Signal{sig}); err != nil {
+			log.Printf("❌ Failed to export signal: %v", err)
+		} else {
+			log.Printf("✅ Signal sent successfully")
 		}
 	}
 }
 
-// maskSecret masks sensitive information for logging
-func maskSecret(secret string) string {
-	if len(secret) <= 8 {
-		return "***"
+// senderQueue hands signals to the batching loop of observer.SignalSender.Start
+type senderQueue chan<- models.Signal
+
+// Export queues signals for the backend, waiting for room while the sender
+// is busy retrying
+func (q senderQueue) Export(ctx context.Context, signals []models.Signal) error {
+	for _, sig := range signals {
+		select {
+		case q <- sig:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// buildExporters creates the signal exporters named in the comma-separated
+// AXOM_EXPORTER value, with backend as the "backend" exporter. Exporters that
+// fail to configure are skipped.
+func buildExporters(names string, backend export.SignalExporter, logger *log.Logger) []export.SignalExporter {
+	var exporters []export.SignalExporter
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		switch name {
+		case "backend":
+			exporters = append(exporters, backend)
+		case "otlp", "both":
+			if name == "both" {
+				exporters = append(exporters, backend)
+			}
+			otlpExporter := export.NewOTLPExporter("", logger)
+			logger.Printf("🔭 Exporting signals as OTLP spans to %s", otlpExporter.Endpoint())
+			exporters = append(exporters, otlpExporter)
+		case "kafka":
+			kafkaExporter, err := export.NewKafkaExporter(nil, "", logger)
+			if err != nil {
+				logger.Printf("Failed to configure Kafka exporter: %v", err)
+				continue
+			}
+			logger.Printf("📨 Publishing signals to Kafka topic %s", kafkaExporter.Topic())
+			exporters = append(exporters, kafkaExporter)
+		case "file":
+			fileExporter, err := export.NewFileExporter("", logger)
+			if err != nil {
+				logger.Printf("Failed to configure file exporter: %v", err)
+				continue
+			}
+			logger.Printf("💾 Writing signals to files under %s", fileExporter.Dir())
+			exporters = append(exporters, fileExporter)
+		default:
+			logger.Printf("Unknown exporter %q, ignoring", name)
+		}
 	}
-	return secret[:4] + "***" + secret[len(secret)-4:]
+	return exporters
 }
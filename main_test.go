@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"axom-observer/pkg/export"
+	"axom-observer/pkg/models"
+	"axom-observer/pkg/observer"
+)
+
+// countingBackend is an ingest server counting the signals it accepts
+type countingBackend struct {
+	*httptest.Server
+	mu  sync.Mutex
+	ids map[string]bool
+}
+
+func newCountingBackend(t *testing.T) *countingBackend {
+	t.Helper()
+	b := &countingBackend{ids: make(map[string]bool)}
+	b.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			return
+		}
+		var batch []models.Signal
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			t.Errorf("backend received invalid batch: %v", err)
+		}
+		b.mu.Lock()
+		for _, sig := range batch {
+			b.ids[sig.ID] = true
+		}
+		b.mu.Unlock()
+	}))
+	t.Cleanup(b.Close)
+	return b
+}
+
+func (b *countingBackend) count() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.ids)
+}
+
+func TestShutdownDrainsBufferedSignals(t *testing.T) {
+	t.Setenv("AXOM_DLQ_PATH", "")
+	backend := newCountingBackend(t)
+	sender := observer.NewSignalSender("test-key", backend.URL, 10, time.Hour)
+
+	const n = 25
+	signalCh := make(chan models.Signal, n)
+	for i := 0; i < n; i++ {
+		signalCh <- models.Signal{ID: fmt.Sprintf("sig-%d", i), Timestamp: time.Now(), Operation: "chat_completion"}
+	}
+
+	// Wire up the sender and processing as main does
+	backendCh := make(chan models.Signal, 1)
+	exporters := []export.SignalExporter{senderQueue(backendCh)}
+	senderCtx, stopSender := context.WithCancel(context.Background())
+	senderDone := make(chan struct{})
+	go func() {
+		defer close(senderDone)
+		sender.StartPrepared(senderCtx, backendCh)
+	}()
+
+	// Shutdown has begun before processing sees any of the signals
+	processCtx, stopProcessing := context.WithCancel(context.Background())
+	stopProcessing()
+	processed := make(chan struct{})
+	go func() {
+		defer close(processed)
+		processSignals(processCtx, signalCh, sender, exporters, nil, nil)
+	}()
+
+	select {
+	case <-processed:
+	case <-time.After(shutdownTimeout):
+		t.Fatalf("processing did not drain the signal channel")
+	}
+	if len(signalCh) != 0 {
+		t.Errorf("%d signals left in the channel after draining", len(signalCh))
+	}
+
+	stopSender()
+	select {
+	case <-senderDone:
+	case <-time.After(shutdownTimeout):
+		t.Fatalf("sender did not send its final batches")
+	}
+	if got := backend.count(); got != n {
+		t.Errorf("backend received %d signals before shutdown returned, want %d", got, n)
+	}
+}
@@ -0,0 +1,15 @@
+package export
+
+import (
+	"context"
+
+	"axom-observer/pkg/models"
+)
+
+// SignalExporter ships signals to a destination. observer.SignalSender (the
+// Axom HTTP backend), OTLPExporter, KafkaExporter and FileExporter all
+// implement it, as does observer.BillingAggregator, which keeps usage
+// totals in process.
+type SignalExporter interface {
+	Export(ctx context.Context, signals []models.Signal) error
+}
@@ -0,0 +1,153 @@
+package export
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"axom-observer/pkg/models"
+)
+
+// Environment variables:
+//   AXOM_SIGNAL_DIR            - Optional. Directory signal files are written to. Default: ./signals
+//   AXOM_SIGNAL_FILE_MAX_BYTES - Optional. Rotate the current file once it reaches this size. Default: 67108864 (64MiB)
+//   AXOM_SIGNAL_FILE_MAX_AGE   - Optional. Rotate the current file after this duration (e.g. "1h"). Default: 1h
+
+// FileExporter appends signals as newline-delimited JSON to timestamped files
+// under a directory, for deployments with no reachable backend. The current
+// file is rotated once it grows past a size limit or gets too old. Files
+// hold prompts and responses, so only the observer's user can read them.
+type FileExporter struct {
+	dir      string
+	maxBytes int64
+	maxAge   time.Duration
+	logger   *log.Logger
+
+	mu       sync.Mutex
+	file     *os.File
+	writer   *bufio.Writer
+	size     int64
+	openedAt time.Time
+}
+
+// NewFileExporter creates a file exporter. If dir is empty it is read from
+// AXOM_SIGNAL_DIR.
+func NewFileExporter(dir string, logger *log.Logger) (*FileExporter, error) {
+	if dir == "" {
+		dir = os.Getenv("AXOM_SIGNAL_DIR")
+		if dir == "" {
+			dir = "signals"
+		}
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create signal directory %s: %w", dir, err)
+	}
+
+	e := &FileExporter{
+		dir:      dir,
+		maxBytes: 64 << 20,
+		maxAge:   time.Hour,
+		logger:   logger,
+	}
+	if v := os.Getenv("AXOM_SIGNAL_FILE_MAX_BYTES"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
+			e.maxBytes = n
+		}
+	}
+	if v := os.Getenv("AXOM_SIGNAL_FILE_MAX_AGE"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			e.maxAge = d
+		}
+	}
+	return e, nil
+}
+
+// Dir returns the directory signal files are written to
+func (e *FileExporter) Dir() string {
+	return e.dir
+}
+
+// Export appends one JSON line per signal to the current file, rotating it
+// first if needed. Signals that fail to marshal are skipped.
+func (e *FileExporter) Export(ctx context.Context, signals []models.Signal) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, sig := range signals {
+		line, err := json.Marshal(sig)
+		if err != nil {
+			e.logger.Printf("Failed to marshal signal %s for file export: %v", sig.ID, err)
+			continue
+		}
+		line = append(line, '\n')
+
+		if err := e.rotateIfNeeded(); err != nil {
+			return err
+		}
+		n, err := e.writer.Write(line)
+		e.size += int64(n)
+		if err != nil {
+			return fmt.Errorf("failed to write signal %s: %w", sig.ID, err)
+		}
+	}
+
+	if e.writer == nil {
+		return nil
+	}
+	if err := e.writer.Flush(); err != nil {
+		return fmt.Errorf("failed to flush signal file: %w", err)
+	}
+	return nil
+}
+
+// rotateIfNeeded opens a new file when there is none or the current one is
+// over its size or age limit
+func (e *FileExporter) rotateIfNeeded() error {
+	if e.file != nil && e.size < e.maxBytes && time.Since(e.openedAt) < e.maxAge {
+		return nil
+	}
+	if err := e.closeFile(); err != nil {
+		e.logger.Printf("Failed to close signal file: %v", err)
+	}
+
+	now := time.Now().UTC()
+	name := filepath.Join(e.dir, fmt.Sprintf("signals-%s.ndjson", now.Format("20060102T150405.000000000Z")))
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open signal file %s: %w", name, err)
+	}
+	e.file = file
+	e.writer = bufio.NewWriter(file)
+	e.size = 0
+	e.openedAt = now
+	return nil
+}
+
+// closeFile flushes and closes the current file, if any
+func (e *FileExporter) closeFile() error {
+	if e.file == nil {
+		return nil
+	}
+	flushErr := e.writer.Flush()
+	closeErr := e.file.Close()
+	e.file = nil
+	e.writer = nil
+	if flushErr != nil {
+		return flushErr
+	}
+	return closeErr
+}
+
+// Close flushes buffered signals and closes the current file
+func (e *FileExporter) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.closeFile()
+}
@@ -0,0 +1,135 @@
+package export
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"axom-observer/pkg/models"
+)
+
+// readSignalFiles returns the signal files in dir, oldest first, and the
+// signals they hold in order
+func readSignalFiles(t *testing.T, dir string) ([]string, []models.Signal) {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "signals-*.ndjson"))
+	if err != nil {
+		t.Fatalf("list signal files: %v", err)
+	}
+	sort.Strings(files)
+
+	var signals []models.Signal
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			t.Fatalf("open %s: %v", name, err)
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			var sig models.Signal
+			if err := json.Unmarshal(scanner.Bytes(), &sig); err != nil {
+				t.Fatalf("%s holds an invalid line: %v", name, err)
+			}
+			signals = append(signals, sig)
+		}
+		f.Close()
+	}
+	return files, signals
+}
+
+func TestFileExporterWritesAndReadsBack(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "signals")
+	exporter, err := NewFileExporter(dir, testLogger())
+	if err != nil {
+		t.Fatalf("NewFileExporter: %v", err)
+	}
+
+	batches := [][]models.Signal{
+		{
+			{ID: "sig-1", CustomerID: "cust", Operation: "chat_completion", Metadata: map[string]interface{}{"model": "gpt-4o"}},
+			{ID: "sig-2", CustomerID: "cust", Operation: "embedding"},
+		},
+		{
+			{ID: "sig-3", CustomerID: "cust", Operation: "chat_completion", Status: 429},
+		},
+	}
+	for _, batch := range batches {
+		if err := exporter.Export(context.Background(), batch); err != nil {
+			t.Fatalf("Export: %v", err)
+		}
+	}
+	if err := exporter.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	files, signals := readSignalFiles(t, dir)
+	if len(files) != 1 {
+		t.Errorf("wrote %d files, want 1", len(files))
+	}
+	if len(signals) != 3 {
+		t.Fatalf("read back %d signals, want 3", len(signals))
+	}
+	for i, id := range []string{"sig-1", "sig-2", "sig-3"} {
+		if signals[i].ID != id {
+			t.Errorf("signal %d = %s, want %s", i, signals[i].ID, id)
+		}
+	}
+	if signals[0].Metadata["model"] != "gpt-4o" || signals[2].Status != 429 {
+		t.Errorf("signal fields not preserved: %+v", signals)
+	}
+
+	info, err := os.Stat(files[0])
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("signal file mode = %o, want 600", perm)
+	}
+}
+
+func TestFileExporterRotatesBySize(t *testing.T) {
+	t.Setenv("AXOM_SIGNAL_FILE_MAX_BYTES", "1")
+	dir := t.TempDir()
+	exporter, err := NewFileExporter(dir, testLogger())
+	if err != nil {
+		t.Fatalf("NewFileExporter: %v", err)
+	}
+	defer exporter.Close()
+
+	for _, id := range []string{"sig-1", "sig-2", "sig-3"} {
+		if err := exporter.Export(context.Background(), []models.Signal{{ID: id}}); err != nil {
+			t.Fatalf("Export: %v", err)
+		}
+	}
+
+	files, signals := readSignalFiles(t, dir)
+	if len(files) != 3 {
+		t.Errorf("wrote %d files, want one per signal over the size limit", len(files))
+	}
+	if len(signals) != 3 || signals[0].ID != "sig-1" || signals[2].ID != "sig-3" {
+		t.Errorf("rotated files hold %+v, want sig-1..sig-3 in order", signals)
+	}
+}
+
+func TestFileExporterRotatesByAge(t *testing.T) {
+	t.Setenv("AXOM_SIGNAL_FILE_MAX_AGE", "10ms")
+	dir := t.TempDir()
+	exporter, err := NewFileExporter(dir, testLogger())
+	if err != nil {
+		t.Fatalf("NewFileExporter: %v", err)
+	}
+	defer exporter.Close()
+
+	exporter.Export(context.Background(), []models.Signal{{ID: "sig-1"}, {ID: "sig-2"}})
+	time.Sleep(20 * time.Millisecond)
+	exporter.Export(context.Background(), []models.Signal{{ID: "sig-3"}})
+
+	if files, _ := readSignalFiles(t, dir); len(files) != 2 {
+		t.Errorf("wrote %d files, want a new one after the age limit", len(files))
+	}
+}
@@ -0,0 +1,131 @@
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"axom-observer/pkg/models"
+)
+
+// Environment variables:
+//   AXOM_KAFKA_BROKERS      - Required when AXOM_EXPORTER=kafka. Comma-separated broker addresses
+//   AXOM_KAFKA_TOPIC        - Optional. Topic to publish signals to. Default: axom-signals
+//   AXOM_KAFKA_MAX_ATTEMPTS - Optional. Producer attempts per batch before giving up. Default: 6 (matches the HTTP sender's 5 retries)
+
+// kafkaProducer is the subset of *kafka.Writer used by KafkaExporter
+type kafkaProducer interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// KafkaExporter publishes one JSON message per signal, keyed by customer ID
+// so a customer's signals stay ordered within a partition. Batching and
+// retries are handled by the producer, which writes asynchronously so that
+// Export never waits on the brokers; failed writes are logged.
+type KafkaExporter struct {
+	producer kafkaProducer
+	topic    string
+	logger   *log.Logger
+}
+
+// NewKafkaExporter creates a Kafka exporter. If brokers or topic are empty
+// they are read from AXOM_KAFKA_BROKERS and AXOM_KAFKA_TOPIC.
+func NewKafkaExporter(brokers []string, topic string, logger *log.Logger) (*KafkaExporter, error) {
+	if len(brokers) == 0 {
+		for _, broker := range strings.Split(os.Getenv("AXOM_KAFKA_BROKERS"), ",") {
+			if broker = strings.TrimSpace(broker); broker != "" {
+				brokers = append(brokers, broker)
+			}
+		}
+	}
+	if len(brokers) == 0 {
+		return nil, fmt.Errorf("no Kafka brokers configured (set AXOM_KAFKA_BROKERS)")
+	}
+	if topic == "" {
+		topic = os.Getenv("AXOM_KAFKA_TOPIC")
+		if topic == "" {
+			topic = "axom-signals"
+		}
+	}
+	maxAttempts := 6
+	if v := os.Getenv("AXOM_KAFKA_MAX_ATTEMPTS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			maxAttempts = n
+		}
+	}
+
+	writer := &kafka.Writer{
+		Addr:            kafka.TCP(brokers...),
+		Topic:           topic,
+		Balancer:        &kafka.Hash{},
+		RequiredAcks:    kafka.RequireAll,
+		MaxAttempts:     maxAttempts,
+		WriteBackoffMin: 2 * time.Second,
+		WriteBackoffMax: 30 * time.Second,
+		BatchTimeout:    100 * time.Millisecond,
+		Async:           true,
+	}
+	exporter := newKafkaExporter(writer, topic, logger)
+	writer.Completion = exporter.completed
+	return exporter, nil
+}
+
+// newKafkaExporter creates an exporter around an existing producer
+func newKafkaExporter(producer kafkaProducer, topic string, logger *log.Logger) *KafkaExporter {
+	return &KafkaExporter{
+		producer: producer,
+		topic:    topic,
+		logger:   logger,
+	}
+}
+
+// Topic returns the topic signals are published to
+func (e *KafkaExporter) Topic() string {
+	return e.topic
+}
+
+// Export publishes signals as JSON messages keyed by customer ID. Signals
+// that fail to marshal are skipped.
+func (e *KafkaExporter) Export(ctx context.Context, signals []models.Signal) error {
+	msgs := make([]kafka.Message, 0, len(signals))
+	for _, sig := range signals {
+		value, err := json.Marshal(sig)
+		if err != nil {
+			e.logger.Printf("Failed to marshal signal %s for Kafka: %v", sig.ID, err)
+			continue
+		}
+		msgs = append(msgs, kafka.Message{
+			Key:   []byte(sig.CustomerID),
+			Value: value,
+			Time:  sig.Timestamp,
+		})
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	if err := e.producer.WriteMessages(ctx, msgs...); err != nil {
+		return fmt.Errorf("failed to publish %d signals to %s: %w", len(msgs), e.topic, err)
+	}
+	return nil
+}
+
+// completed logs the messages the producer gave up on. It runs on the
+// producer's goroutine once each asynchronous batch is written or fails.
+func (e *KafkaExporter) completed(msgs []kafka.Message, err error) {
+	if err != nil {
+		e.logger.Printf("Failed to publish %d signals to %s: %v", len(msgs), e.topic, err)
+	}
+}
+
+// Close flushes pending messages and closes the producer
+func (e *KafkaExporter) Close() error {
+	return e.producer.Close()
+}
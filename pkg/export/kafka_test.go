@@ -0,0 +1,98 @@
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+
+	"axom-observer/pkg/models"
+)
+
+// mockProducer records the messages written to it
+type mockProducer struct {
+	msgs   []kafka.Message
+	err    error
+	closed bool
+}
+
+func (p *mockProducer) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.msgs = append(p.msgs, msgs...)
+	return nil
+}
+
+func (p *mockProducer) Close() error {
+	p.closed = true
+	return nil
+}
+
+func TestKafkaExportKeysMessagesByCustomer(t *testing.T) {
+	producer := &mockProducer{}
+	exporter := newKafkaExporter(producer, "signals", testLogger())
+
+	signals := []models.Signal{
+		{ID: "sig-1", CustomerID: "cust-a", Operation: "chat_completion"},
+		{ID: "sig-2", CustomerID: "cust-b", Operation: "embedding"},
+		{ID: "bad", CustomerID: "cust-a", LatencyMS: math.NaN()},
+	}
+	if err := exporter.Export(context.Background(), signals); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	if len(producer.msgs) != 2 {
+		t.Fatalf("producer received %d messages, want 2 (the unmarshalable signal skipped)", len(producer.msgs))
+	}
+	for i, msg := range producer.msgs {
+		if got, want := string(msg.Key), signals[i].CustomerID; got != want {
+			t.Errorf("message %d key = %q, want %q", i, got, want)
+		}
+		var sig models.Signal
+		if err := json.Unmarshal(msg.Value, &sig); err != nil {
+			t.Fatalf("message %d is not a JSON signal: %v", i, err)
+		}
+		if sig.ID != signals[i].ID || sig.Operation != signals[i].Operation {
+			t.Errorf("message %d = %s/%s, want %s/%s", i, sig.ID, sig.Operation, signals[i].ID, signals[i].Operation)
+		}
+	}
+
+	if err := exporter.Close(); err != nil || !producer.closed {
+		t.Errorf("Close did not close the producer (err %v)", err)
+	}
+}
+
+func TestKafkaExportProducerError(t *testing.T) {
+	producer := &mockProducer{err: errors.New("broker unavailable")}
+	exporter := newKafkaExporter(producer, "signals", testLogger())
+
+	err := exporter.Export(context.Background(), []models.Signal{{ID: "sig-1"}})
+	if err == nil || !errors.Is(err, producer.err) {
+		t.Errorf("Export error = %v, want it to wrap the producer error", err)
+	}
+	if err := exporter.Export(context.Background(), nil); err != nil {
+		t.Errorf("Export of no signals: %v", err)
+	}
+}
+
+func TestNewKafkaExporterFromEnv(t *testing.T) {
+	t.Setenv("AXOM_KAFKA_BROKERS", "")
+	if _, err := NewKafkaExporter(nil, "", testLogger()); err == nil {
+		t.Errorf("NewKafkaExporter succeeded without brokers")
+	}
+
+	t.Setenv("AXOM_KAFKA_BROKERS", "localhost:9092, localhost:9093")
+	t.Setenv("AXOM_KAFKA_TOPIC", "")
+	exporter, err := NewKafkaExporter(nil, "", testLogger())
+	if err != nil {
+		t.Fatalf("NewKafkaExporter: %v", err)
+	}
+	defer exporter.Close()
+	if exporter.Topic() != "axom-signals" {
+		t.Errorf("Topic() = %q, want default axom-signals", exporter.Topic())
+	}
+}
@@ -0,0 +1,288 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"axom-observer/pkg/models"
+)
+
+// Environment variables:
+//   AXOM_EXPORTER        - Optional. "otlp" to export signals as OTLP spans instead of the Axom backend, "both" for both. Default: backend
+//   AXOM_OTLP_ENDPOINT   - Optional. OTLP/HTTP traces endpoint. Default: http://localhost:4318/v1/traces
+//   AXOM_OTLP_HEADERS    - Optional. Extra request headers as comma-separated key=value pairs (e.g. collector auth)
+//   AXOM_OTLP_SERVICE    - Optional. service.name resource attribute. Default: axom-observer
+
+// OTLP span kind and status codes
+const (
+	spanKindClient  = 3
+	statusCodeUnset = 0
+	statusCodeOK    = 1
+	statusCodeError = 2
+)
+
+// OTLPExporter exports signals as spans to an OpenTelemetry collector using
+// the OTLP/HTTP JSON encoding
+type OTLPExporter struct {
+	endpoint    string
+	headers     map[string]string
+	serviceName string
+	client      *http.Client
+	logger      *log.Logger
+}
+
+// NewOTLPExporter creates an OTLP exporter. If endpoint is empty it is read
+// from AXOM_OTLP_ENDPOINT.
+func NewOTLPExporter(endpoint string, logger *log.Logger) *OTLPExporter {
+	if endpoint == "" {
+		endpoint = os.Getenv("AXOM_OTLP_ENDPOINT")
+		if endpoint == "" {
+			endpoint = "http://localhost:4318/v1/traces"
+		}
+	}
+	serviceName := os.Getenv("AXOM_OTLP_SERVICE")
+	if serviceName == "" {
+		serviceName = "axom-observer"
+	}
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(os.Getenv("AXOM_OTLP_HEADERS"), ",") {
+		if key, value, ok := strings.Cut(pair, "="); ok && strings.TrimSpace(key) != "" {
+			headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return &OTLPExporter{
+		endpoint:    endpoint,
+		headers:     headers,
+		serviceName: serviceName,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		logger:      logger,
+	}
+}
+
+// Endpoint returns the OTLP traces endpoint
+func (e *OTLPExporter) Endpoint() string {
+	return e.endpoint
+}
+
+// Export sends signals to the collector as a single ExportTraceServiceRequest
+func (e *OTLPExporter) Export(ctx context.Context, signals []models.Signal) error {
+	if len(signals) == 0 {
+		return nil
+	}
+	spans := make([]Span, 0, len(signals))
+	for _, sig := range signals {
+		spans = append(spans, SignalToSpan(sig))
+	}
+
+	body, err := json.Marshal(e.traceRequest(spans))
+	if err != nil {
+		return fmt.Errorf("failed to marshal OTLP request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range e.headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("OTLP collector returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// traceRequest wraps spans in the resource/scope envelope
+func (e *OTLPExporter) traceRequest(spans []Span) map[string]interface{} {
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{
+					"attributes": []KeyValue{stringAttr("service.name", e.serviceName)},
+				},
+				"scopeSpans": []interface{}{
+					map[string]interface{}{
+						"scope": map[string]interface{}{"name": "axom-observer"},
+						"spans": spans,
+					},
+				},
+			},
+		},
+	}
+}
+
+// Span is an OTLP span in the JSON encoding
+type Span struct {
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	Name              string     `json:"name"`
+	Kind              int        `json:"kind"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+	Attributes        []KeyValue `json:"attributes"`
+	Status            SpanStatus `json:"status"`
+}
+
+// SpanStatus is an OTLP span status
+type SpanStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// KeyValue is an OTLP attribute
+type KeyValue struct {
+	Key   string   `json:"key"`
+	Value AnyValue `json:"value"`
+}
+
+// AnyValue is an OTLP attribute value; exactly one field is set. Integers are
+// encoded as strings per the OTLP JSON mapping.
+type AnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+}
+
+// spanMetadataAttributes maps signal metadata keys to span attribute names,
+// following the OpenTelemetry GenAI semantic conventions where they exist
+var spanMetadataAttributes = []struct {
+	metadataKey string
+	attribute   string
+}{
+	{"provider", "gen_ai.system"},
+	{"model", "gen_ai.request.model"},
+	{"prompt_tokens", "gen_ai.usage.input_tokens"},
+	{"completion_tokens", "gen_ai.usage.output_tokens"},
+	{"total_tokens", "axom.total_tokens"},
+	{"estimated_cost_usd", "axom.estimated_cost_usd"},
+	{"endpoint", "url.path"},
+	{"failure_class", "axom.failure_class"},
+}
+
+// SignalToSpan maps a signal to an OTLP span. The span ends at the signal
+// timestamp and starts LatencyMS earlier. Signals in the same task share a
+// trace ID derived from the task ID.
+func SignalToSpan(sig models.Signal) Span {
+	end := sig.Timestamp
+	if end.IsZero() {
+		end = time.Now()
+	}
+	start := end.Add(-time.Duration(sig.LatencyMS * float64(time.Millisecond)))
+
+	name := sig.Operation
+	if name == "" {
+		name = sig.Protocol
+	}
+
+	attrs := []KeyValue{
+		stringAttr("axom.signal_id", sig.ID),
+		stringAttr("axom.customer_id", sig.CustomerID),
+		stringAttr("axom.agent_id", sig.AgentID),
+		stringAttr("network.protocol.name", sig.Protocol),
+	}
+	if sig.Destination.Hostname != "" {
+		attrs = append(attrs, stringAttr("server.address", sig.Destination.Hostname))
+	} else if sig.Destination.IP != "" {
+		attrs = append(attrs, stringAttr("server.address", sig.Destination.IP))
+	}
+	if sig.Destination.Port != 0 {
+		attrs = append(attrs, intAttr("server.port", int64(sig.Destination.Port)))
+	}
+	if sig.Status != 0 {
+		attrs = append(attrs, intAttr("http.response.status_code", int64(sig.Status)))
+	}
+	if sig.TaskID != "" {
+		attrs = append(attrs, stringAttr("axom.task_id", sig.TaskID))
+	}
+	if sig.Outcome != "" {
+		attrs = append(attrs, stringAttr("axom.outcome", sig.Outcome))
+	}
+	for _, m := range spanMetadataAttributes {
+		if attr, ok := metadataAttr(m.attribute, sig.Metadata[m.metadataKey]); ok {
+			attrs = append(attrs, attr)
+		}
+	}
+
+	status := SpanStatus{Code: statusCodeUnset}
+	switch {
+	case sig.Status >= 400:
+		status = SpanStatus{Code: statusCodeError, Message: http.StatusText(sig.Status)}
+	case sig.Status > 0:
+		status.Code = statusCodeOK
+	}
+
+	return Span{
+		TraceID:           traceID(sig),
+		SpanID:            randomHex(8),
+		Name:              name,
+		Kind:              spanKindClient,
+		StartTimeUnixNano: strconv.FormatInt(start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(end.UnixNano(), 10),
+		Attributes:        attrs,
+		Status:            status,
+	}
+}
+
+// traceID derives a trace ID from the task ID so a task's signals group into
+// one trace, or generates a random one
+func traceID(sig models.Signal) string {
+	if sig.TaskID == "" {
+		return randomHex(16)
+	}
+	sum := sha256.Sum256([]byte(sig.TaskID))
+	return hex.EncodeToString(sum[:16])
+}
+
+// randomHex returns n random bytes hex-encoded
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// metadataAttr converts a metadata value to an attribute, if it has a
+// supported type
+func metadataAttr(key string, value interface{}) (KeyValue, bool) {
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return KeyValue{}, false
+		}
+		return stringAttr(key, v), true
+	case int:
+		return intAttr(key, int64(v)), true
+	case int64:
+		return intAttr(key, v), true
+	case float64:
+		return KeyValue{Key: key, Value: AnyValue{DoubleValue: &v}}, true
+	case bool:
+		return KeyValue{Key: key, Value: AnyValue{BoolValue: &v}}, true
+	}
+	return KeyValue{}, false
+}
+
+func stringAttr(key, value string) KeyValue {
+	return KeyValue{Key: key, Value: AnyValue{StringValue: &value}}
+}
+
+func intAttr(key string, value int64) KeyValue {
+	s := strconv.FormatInt(value, 10)
+	return KeyValue{Key: key, Value: AnyValue{IntValue: &s}}
+}
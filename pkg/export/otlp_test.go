@@ -0,0 +1,230 @@
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"axom-observer/pkg/models"
+)
+
+// testLogger returns a logger that discards its output
+func testLogger() *log.Logger {
+	return log.New(io.Discard, "", 0)
+}
+
+// spanRecorder is an OTLP/HTTP collector that keeps the spans and headers
+// of each export request in memory
+type spanRecorder struct {
+	mu      sync.Mutex
+	spans   []Span
+	service string
+	headers http.Header
+}
+
+func newSpanRecorder(t *testing.T) (*spanRecorder, *httptest.Server) {
+	t.Helper()
+	r := &spanRecorder{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var body struct {
+			ResourceSpans []struct {
+				Resource struct {
+					Attributes []KeyValue `json:"attributes"`
+				} `json:"resource"`
+				ScopeSpans []struct {
+					Spans []Span `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.headers = req.Header.Clone()
+		for _, rs := range body.ResourceSpans {
+			for _, attr := range rs.Resource.Attributes {
+				if attr.Key == "service.name" && attr.Value.StringValue != nil {
+					r.service = *attr.Value.StringValue
+				}
+			}
+			for _, ss := range rs.ScopeSpans {
+				r.spans = append(r.spans, ss.Spans...)
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return r, srv
+}
+
+// attrs indexes a span's attributes by key, rendering each value as a string
+func attrs(span Span) map[string]string {
+	m := make(map[string]string, len(span.Attributes))
+	for _, kv := range span.Attributes {
+		v := kv.Value
+		switch {
+		case v.StringValue != nil:
+			m[kv.Key] = *v.StringValue
+		case v.IntValue != nil:
+			m[kv.Key] = *v.IntValue
+		case v.DoubleValue != nil:
+			m[kv.Key] = strconv.FormatFloat(*v.DoubleValue, 'f', -1, 64)
+		case v.BoolValue != nil:
+			m[kv.Key] = strconv.FormatBool(*v.BoolValue)
+		}
+	}
+	return m
+}
+
+func TestOTLPExportMapsSignalToSpan(t *testing.T) {
+	t.Setenv("AXOM_OTLP_SERVICE", "test-service")
+	t.Setenv("AXOM_OTLP_HEADERS", "Authorization=Bearer collector-token, X-Tenant = acme")
+	recorder, srv := newSpanRecorder(t)
+
+	end := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	sig := models.Signal{
+		ID:          "sig-1",
+		CustomerID:  "cust",
+		AgentID:     "agent",
+		TaskID:      "task-1",
+		Timestamp:   end,
+		LatencyMS:   250,
+		Protocol:    "https",
+		Destination: models.Endpoint{IP: "10.0.0.1", Port: 443, Hostname: "api.openai.com"},
+		Operation:   "chat_completion",
+		Status:      200,
+		Outcome:     "success",
+		Metadata: map[string]interface{}{
+			"provider":           "openai",
+			"model":              "gpt-4o",
+			"prompt_tokens":      12,
+			"completion_tokens":  int64(30),
+			"total_tokens":       42,
+			"estimated_cost_usd": 0.0125,
+			"endpoint":           "/v1/chat/completions",
+			"failure_class":      "",
+			"prompt_preview":     "not exported",
+		},
+	}
+
+	exporter := NewOTLPExporter(srv.URL, testLogger())
+	if err := exporter.Export(context.Background(), []models.Signal{sig}); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	if len(recorder.spans) != 1 {
+		t.Fatalf("collector received %d spans, want 1", len(recorder.spans))
+	}
+	span := recorder.spans[0]
+	if span.Name != "chat_completion" {
+		t.Errorf("Name = %q, want chat_completion", span.Name)
+	}
+	if span.Kind != spanKindClient {
+		t.Errorf("Kind = %d, want %d", span.Kind, spanKindClient)
+	}
+	if want := strconv.FormatInt(end.UnixNano(), 10); span.EndTimeUnixNano != want {
+		t.Errorf("EndTimeUnixNano = %s, want %s", span.EndTimeUnixNano, want)
+	}
+	if want := strconv.FormatInt(end.Add(-250*time.Millisecond).UnixNano(), 10); span.StartTimeUnixNano != want {
+		t.Errorf("StartTimeUnixNano = %s, want %s", span.StartTimeUnixNano, want)
+	}
+	if span.Status.Code != statusCodeOK {
+		t.Errorf("Status.Code = %d, want %d", span.Status.Code, statusCodeOK)
+	}
+	if len(span.TraceID) != 32 || len(span.SpanID) != 16 {
+		t.Errorf("TraceID/SpanID = %q/%q, want 16 and 8 hex-encoded bytes", span.TraceID, span.SpanID)
+	}
+
+	got := attrs(span)
+	want := map[string]string{
+		"axom.signal_id":             "sig-1",
+		"axom.customer_id":           "cust",
+		"axom.agent_id":              "agent",
+		"axom.task_id":               "task-1",
+		"axom.outcome":               "success",
+		"network.protocol.name":      "https",
+		"server.address":             "api.openai.com",
+		"server.port":                "443",
+		"http.response.status_code":  "200",
+		"gen_ai.system":              "openai",
+		"gen_ai.request.model":       "gpt-4o",
+		"gen_ai.usage.input_tokens":  "12",
+		"gen_ai.usage.output_tokens": "30",
+		"axom.total_tokens":          "42",
+		"axom.estimated_cost_usd":    "0.0125",
+		"url.path":                   "/v1/chat/completions",
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("attribute %s = %q, want %q", key, got[key], value)
+		}
+	}
+	if _, ok := got["axom.failure_class"]; ok {
+		t.Errorf("empty failure_class exported as an attribute")
+	}
+	if len(got) != len(want) {
+		t.Errorf("span has %d attributes, want %d: %v", len(got), len(want), got)
+	}
+
+	if recorder.service != "test-service" {
+		t.Errorf("service.name = %q, want test-service", recorder.service)
+	}
+	if h := recorder.headers.Get("Authorization"); h != "Bearer collector-token" {
+		t.Errorf("Authorization header = %q, want the AXOM_OTLP_HEADERS value", h)
+	}
+	if h := recorder.headers.Get("X-Tenant"); h != "acme" {
+		t.Errorf("X-Tenant header = %q, want acme", h)
+	}
+}
+
+func TestSignalToSpanErrorStatus(t *testing.T) {
+	span := SignalToSpan(models.Signal{Protocol: "https", Status: 429})
+	if span.Name != "https" {
+		t.Errorf("Name = %q, want the protocol when Operation is empty", span.Name)
+	}
+	if span.Status.Code != statusCodeError || span.Status.Message != "Too Many Requests" {
+		t.Errorf("Status = %+v, want error with the status text", span.Status)
+	}
+
+	if unset := SignalToSpan(models.Signal{Protocol: "https"}); unset.Status.Code != statusCodeUnset {
+		t.Errorf("Status.Code = %d for a signal without a status, want unset", unset.Status.Code)
+	}
+}
+
+func TestSignalToSpanTraceIDFromTask(t *testing.T) {
+	a := SignalToSpan(models.Signal{TaskID: "task-1"})
+	b := SignalToSpan(models.Signal{TaskID: "task-1"})
+	c := SignalToSpan(models.Signal{TaskID: "task-2"})
+	if a.TraceID != b.TraceID {
+		t.Errorf("signals in the same task have trace IDs %s and %s", a.TraceID, b.TraceID)
+	}
+	if a.TraceID == c.TraceID {
+		t.Errorf("signals in different tasks share trace ID %s", a.TraceID)
+	}
+	if a.SpanID == b.SpanID {
+		t.Errorf("signals share span ID %s", a.SpanID)
+	}
+}
+
+func TestOTLPExportCollectorError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	exporter := NewOTLPExporter(srv.URL, testLogger())
+	if err := exporter.Export(context.Background(), []models.Signal{{ID: "sig-1"}}); err == nil {
+		t.Errorf("Export succeeded against a collector returning 503")
+	}
+	if err := exporter.Export(context.Background(), nil); err != nil {
+		t.Errorf("Export of no signals: %v", err)
+	}
+}
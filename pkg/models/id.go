@@ -0,0 +1,81 @@
+package models
+
+import (
+	"crypto/rand"
+	"sync"
+	"time"
+)
+
+// crockford is the ULID base32 alphabet (no I, L, O or U)
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ulidState makes IDs from one process strictly increasing: within the same
+// millisecond the random part is incremented instead of redrawn
+var ulidState struct {
+	mu      sync.Mutex
+	lastMS  uint64
+	entropy [10]byte
+}
+
+// NewULID returns a ULID: a 26-character, lexicographically time-sortable
+// identifier made of a 48-bit millisecond timestamp and 80 random bits
+func NewULID() string {
+	ms := uint64(time.Now().UnixMilli())
+
+	ulidState.mu.Lock()
+	if ms <= ulidState.lastMS {
+		// Same millisecond (or the clock stepped back): keep ordering by
+		// incrementing the previous entropy
+		ms = ulidState.lastMS
+		for i := len(ulidState.entropy) - 1; i >= 0; i-- {
+			ulidState.entropy[i]++
+			if ulidState.entropy[i] != 0 {
+				break
+			}
+		}
+	} else {
+		ulidState.lastMS = ms
+		if _, err := rand.Read(ulidState.entropy[:]); err != nil {
+			panic("models: crypto/rand failed: " + err.Error())
+		}
+	}
+	var id [16]byte
+	id[0] = byte(ms >> 40)
+	id[1] = byte(ms >> 32)
+	id[2] = byte(ms >> 24)
+	id[3] = byte(ms >> 16)
+	id[4] = byte(ms >> 8)
+	id[5] = byte(ms)
+	copy(id[6:], ulidState.entropy[:])
+	ulidState.mu.Unlock()
+
+	return encodeULID(id)
+}
+
+// encodeULID renders 128 bits as 26 Crockford base32 characters
+func encodeULID(id [16]byte) string {
+	var out [26]byte
+	// 130 bits of output for 128 bits of input: the first character holds
+	// the top 3 bits
+	var hi, lo uint64
+	for i := 0; i < 8; i++ {
+		hi = hi<<8 | uint64(id[i])
+		lo = lo<<8 | uint64(id[i+8])
+	}
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+// NewSignalID returns a unique signal ID
+func NewSignalID() string {
+	return "signal_" + NewULID()
+}
+
+// NewTaskID returns a unique task ID
+func NewTaskID() string {
+	return "task_" + NewULID()
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestNewULIDUniqueUnderConcurrency(t *testing.T) {
+	const workers, perWorker = 16, 2000
+	ids := make(chan string, workers*perWorker)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perWorker; j++ {
+				ids <- NewSignalID()
+			}
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	seen := make(map[string]bool, workers*perWorker)
+	for id := range ids {
+		if seen[id] {
+			t.Fatalf("duplicate signal ID %s", id)
+		}
+		seen[id] = true
+	}
+	if len(seen) != workers*perWorker {
+		t.Errorf("generated %d unique IDs, want %d", len(seen), workers*perWorker)
+	}
+}
+
+func TestNewULIDSortable(t *testing.T) {
+	prev := NewULID()
+	for i := 0; i < 10000; i++ {
+		id := NewULID()
+		if len(id) != 26 || strings.Trim(id, crockford) != "" {
+			t.Fatalf("ULID %q is not 26 Crockford base32 characters", id)
+		}
+		if id <= prev {
+			t.Fatalf("ULID %s does not sort after %s", id, prev)
+		}
+		prev = id
+	}
+}
+
+func TestSignalIDsSerialize(t *testing.T) {
+	if id := NewTaskID(); !strings.HasPrefix(id, "task_") {
+		t.Errorf("task ID %q missing task_ prefix", id)
+	}
+	sig := Signal{ID: NewSignalID()}
+	data, err := json.Marshal(sig)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var decoded Signal
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded.ID != sig.ID {
+		t.Errorf("ID round-tripped as %q (%v), want %q", decoded.ID, err, sig.ID)
+	}
+}
@@ -1,14 +1,24 @@
 package models
 
-import "time"
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// SignalSchemaVersion identifies the Signal layout below. Bump it on
+// breaking changes (renamed, removed or retyped fields) so the backend can
+// handle mixed-version fleets during rollouts.
+const SignalSchemaVersion = "v1"
 
 // Signal represents a captured AI API interaction for billing and monitoring
 type Signal struct {
 	// Core identification
-	ID         string `json:"id"`                // Unique signal identifier
-	CustomerID string `json:"customer_id"`       // Customer identifier
-	AgentID    string `json:"agent_id"`          // AI agent identifier
-	TaskID     string `json:"task_id,omitempty"` // Business task identifier for outcome-based billing
+	ID            string `json:"id"`                // Unique signal identifier
+	SchemaVersion string `json:"schema_version"`    // Schema the signal conforms to (SignalSchemaVersion)
+	CustomerID    string `json:"customer_id"`       // Customer identifier
+	AgentID       string `json:"agent_id"`          // AI agent identifier
+	TaskID        string `json:"task_id,omitempty"` // Business task identifier for outcome-based billing
 
 	// Timing and performance
 	Timestamp time.Time `json:"timestamp"`  // When the signal was captured
@@ -110,8 +120,12 @@ type BillingMetrics struct {
 	Metadata map[string]interface{} `json:"metadata"`
 }
 
-// Redact sensitive fields from the signal before export
+// Redact sensitive fields from the signal before export. Credentials in
+// RawRequest and RawResponse are always masked.
 func (s *Signal) Redact(fields ...string) {
+	s.RawRequest = RedactRawSecrets(s.RawRequest)
+	s.RawResponse = RedactRawSecrets(s.RawResponse)
+
 	if s.Metadata != nil {
 		for _, field := range fields {
 			if _, ok := s.Metadata[field]; ok {
@@ -128,6 +142,121 @@ func (s *Signal) Redact(fields ...string) {
 	}
 }
 
+// rawSecretPatterns mask credentials in raw HTTP bytes, keeping the header
+// name, field name or parameter name that precedes each secret
+var rawSecretPatterns = []struct {
+	pattern     *regexp.Regexp
+	replacement []byte
+}{
+	// Credential headers
+	{regexp.MustCompile(`(?im)^((?:proxy-)?authorization|x-api-key|api-key|x-goog-api-key|cookie|set-cookie)([ \t]*:[ \t]*)[^\r\n]+`), []byte("${1}${2}[REDACTED]")},
+	// JSON string fields holding keys, tokens and secrets
+	{regexp.MustCompile(`(?i)("(?:api_?key|api-key|token|access_token|refresh_token|id_token|secret|client_secret|password)"\s*:\s*)"(?:[^"\\]|\\.)*"`), []byte(`${1}"[REDACTED]"`)},
+	// Query parameters
+	{regexp.MustCompile(`(?i)([?&](?:api_?key|key|token|access_token)=)[^&\s]+`), []byte("${1}[REDACTED]")},
+	// Bearer tokens anywhere else
+	{regexp.MustCompile(`(?i)(\bbearer\s+)[A-Za-z0-9._~+/=-]+`), []byte("${1}[REDACTED]")},
+}
+
+// RedactRawSecrets masks authorization headers, API key and token JSON
+// fields, key query parameters and bearer tokens in raw request/response
+// bytes, leaving everything else intact
+func RedactRawSecrets(raw []byte) []byte {
+	if len(raw) == 0 {
+		return raw
+	}
+	for _, p := range rawSecretPatterns {
+		raw = p.pattern.ReplaceAll(raw, p.replacement)
+	}
+	return raw
+}
+
+// piiPatterns are applied in order, so SSNs and card numbers are masked
+// before the looser phone pattern can match parts of them
+var piiPatterns = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`), "[EMAIL]"},
+	{regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`), "[SSN]"},
+	{regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`), "[CARD]"},
+	// Separators are required so bare 10-digit numbers (timestamps) don't match
+	{regexp.MustCompile(`(?:\+\d{1,3}[ .-]?)?(?:\(\d{3}\) ?|\b\d{3}[ .-])\d{3}[ .-]\d{4}\b|\+\d{10,14}\b`), "[PHONE]"},
+}
+
+// RedactPII masks emails, SSNs, card numbers and phone numbers in all string
+// metadata, including strings nested in maps and slices such as messages
+func (s *Signal) RedactPII() {
+	for k, v := range s.Metadata {
+		s.Metadata[k] = redactPIIValue(v)
+	}
+	for k, v := range s.OutcomeData {
+		s.OutcomeData[k] = redactPIIValue(v)
+	}
+}
+
+// RedactPIIString masks PII in a single string
+func RedactPIIString(text string) string {
+	for _, p := range piiPatterns {
+		if p.replacement == "[CARD]" {
+			text = p.pattern.ReplaceAllStringFunc(text, func(match string) string {
+				if luhnValid(match) {
+					return p.replacement
+				}
+				return match
+			})
+			continue
+		}
+		text = p.pattern.ReplaceAllString(text, p.replacement)
+	}
+	return text
+}
+
+// redactPIIValue masks PII in strings within v
+func redactPIIValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case string:
+		return RedactPIIString(val)
+	case map[string]interface{}:
+		for k, item := range val {
+			val[k] = redactPIIValue(item)
+		}
+		return val
+	case []interface{}:
+		for i, item := range val {
+			val[i] = redactPIIValue(item)
+		}
+		return val
+	default:
+		return v
+	}
+}
+
+// luhnValid reports whether the digits in number pass the Luhn checksum, so
+// arbitrary long numbers (IDs, timestamps) aren't masked as cards
+func luhnValid(number string) bool {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, number)
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return len(digits) >= 13 && sum%10 == 0
+}
+
 // SetOutcome updates the signal with task outcome information
 func (s *Signal) SetOutcome(outcome string, outcomeData map[string]interface{}) {
 	s.Outcome = outcome
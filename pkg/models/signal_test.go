@@ -0,0 +1,133 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedactPIIString(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"email", "Contact jane.doe+billing@example.co.uk today", "Contact [EMAIL] today"},
+		{"ssn", "SSN 123-45-6789 on file", "SSN [SSN] on file"},
+		{"card with spaces", "Card 4111 1111 1111 1111 expires soon", "Card [CARD] expires soon"},
+		{"card with dashes", "Card 5500-0000-0000-0004", "Card [CARD]"},
+		{"card failing luhn", "Order 4111111111111112 shipped", "Order 4111111111111112 shipped"},
+		{"us phone", "Call (415) 555-2671 now", "Call [PHONE] now"},
+		{"dotted phone", "Call 415.555.2671", "Call [PHONE]"},
+		{"international phone", "Call +442071838750", "Call [PHONE]"},
+		{"bare timestamp", "Created at 1700000000", "Created at 1700000000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RedactPIIString(tt.in); got != tt.want {
+				t.Errorf("RedactPIIString(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignalRedactPIINested(t *testing.T) {
+	s := Signal{
+		Metadata: map[string]interface{}{
+			"prompt_preview": "My email is bob@example.com",
+			"messages": []interface{}{
+				map[string]interface{}{"role": "user", "content": "My SSN is 078-05-1120"},
+			},
+			"total_tokens": 12,
+		},
+		OutcomeData: map[string]interface{}{"note": "call 212-555-0147"},
+	}
+	s.RedactPII()
+
+	if got := s.Metadata["prompt_preview"]; got != "My email is [EMAIL]" {
+		t.Errorf("prompt_preview = %q", got)
+	}
+	message := s.Metadata["messages"].([]interface{})[0].(map[string]interface{})
+	if got := message["content"]; got != "My SSN is [SSN]" {
+		t.Errorf("nested message content = %q", got)
+	}
+	if got := s.Metadata["total_tokens"]; got != 12 {
+		t.Errorf("non-string total_tokens = %v, want it untouched", got)
+	}
+	if got := s.OutcomeData["note"]; got != "call [PHONE]" {
+		t.Errorf("outcome note = %q", got)
+	}
+}
+
+func TestSignalRedactFields(t *testing.T) {
+	s := Signal{
+		Metadata:    map[string]interface{}{"authorization": "Bearer sk-123", "model": "gpt-4"},
+		OutcomeData: map[string]interface{}{"api_key": "sk-456"},
+	}
+	s.Redact("authorization", "api_key")
+	if s.Metadata["authorization"] != "[REDACTED]" || s.OutcomeData["api_key"] != "[REDACTED]" {
+		t.Errorf("fields not redacted: %v %v", s.Metadata, s.OutcomeData)
+	}
+	if s.Metadata["model"] != "gpt-4" {
+		t.Errorf("model = %v, want it untouched", s.Metadata["model"])
+	}
+}
+
+func TestSignalRedactRawBytes(t *testing.T) {
+	rawRequest := "POST /v1/chat/completions HTTP/1.1\r\n" +
+		"Host: api.openai.com\r\n" +
+		"Authorization: Bearer sk-proj-abc123\r\n" +
+		"X-Api-Key: sk-ant-456\r\n" +
+		"Content-Type: application/json\r\n" +
+		"\r\n" +
+		`{"model": "gpt-4", "api_key": "sk-789", "messages": [{"role": "user", "content": "Hi"}]}`
+	rawResponse := "HTTP/1.1 200 OK\r\n" +
+		"Set-Cookie: session=s3cret; Path=/\r\n" +
+		"Content-Type: application/json\r\n" +
+		"\r\n" +
+		`{"access_token": "tok-1", "id": "chatcmpl-1"}`
+
+	s := Signal{RawRequest: []byte(rawRequest), RawResponse: []byte(rawResponse)}
+	s.Redact()
+
+	gotRequest, gotResponse := string(s.RawRequest), string(s.RawResponse)
+	for _, secret := range []string{"sk-proj-abc123", "sk-ant-456", "sk-789"} {
+		if strings.Contains(gotRequest, secret) {
+			t.Errorf("raw request still holds %s:\n%s", secret, gotRequest)
+		}
+	}
+	for _, secret := range []string{"s3cret", "tok-1"} {
+		if strings.Contains(gotResponse, secret) {
+			t.Errorf("raw response still holds %s:\n%s", secret, gotResponse)
+		}
+	}
+
+	for _, kept := range []string{
+		"POST /v1/chat/completions HTTP/1.1\r\n",
+		"Host: api.openai.com\r\n",
+		"Authorization: [REDACTED]\r\n",
+		"X-Api-Key: [REDACTED]\r\n",
+		"Content-Type: application/json\r\n",
+		`"api_key": "[REDACTED]"`,
+		`"messages": [{"role": "user", "content": "Hi"}]`,
+	} {
+		if !strings.Contains(gotRequest, kept) {
+			t.Errorf("raw request missing %q:\n%s", kept, gotRequest)
+		}
+	}
+	for _, kept := range []string{"Set-Cookie: [REDACTED]\r\n", `"access_token": "[REDACTED]"`, `"id": "chatcmpl-1"`} {
+		if !strings.Contains(gotResponse, kept) {
+			t.Errorf("raw response missing %q:\n%s", kept, gotResponse)
+		}
+	}
+}
+
+func TestRedactRawSecretsQueryAndBearer(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"GET /v1beta/models?key=AIzaSy123&alt=sse HTTP/1.1", "GET /v1beta/models?key=[REDACTED]&alt=sse HTTP/1.1"},
+		{`{"note": "use bearer abc.def-ghi"}`, `{"note": "use bearer [REDACTED]"}`},
+		{"no secrets here", "no secrets here"},
+	}
+	for _, tt := range tests {
+		if got := string(RedactRawSecrets([]byte(tt.in))); got != tt.want {
+			t.Errorf("RedactRawSecrets(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
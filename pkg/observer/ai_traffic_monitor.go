@@ -1,43 +1,51 @@
 package observer
 
 import (
-	"bytes"
 	"context"
-	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"axom-observer/pkg/models"
+	"axom-observer/pkg/pricing"
 )
 
 // AITrafficMonitor provides comprehensive AI traffic monitoring
 type AITrafficMonitor struct {
-	httpProxy       *HTTPProxy
-	productionProxy *ProductionProxy
-	taskDetector    *TaskDetector
-	logger          *log.Logger
-	signalCh        chan<- models.Signal
-	customerID      string
-	agentID         string
-	logAllTraffic   bool
-	mainContainer   string
-	dashboardUser   string
-	dashboardPass   string
+	httpProxy         *HTTPProxy
+	productionProxy   *ProductionProxy
+	httpsProxy        *HTTPSProxy
+	tlsIntercept      bool
+	taskDetector      *TaskDetector
+	logger            *log.Logger
+	signalCh          chan<- models.Signal
+	customerID        string
+	agentID           string
+	logAllTraffic     bool
+	mainContainer     string
+	dashboardUser     string
+	dashboardPass     string
+	httpStarted       atomic.Bool
+	productionStarted atomic.Bool // the HTTPS port's proxy, whichever it is
 }
 
 // AIProvider represents an AI service provider
 type AIProvider struct {
-	Name        string
-	Domains     []string
-	APIPatterns []string
-	Models      []string
-	TaskTypes   []string
+	Name        string   `yaml:"name"`
+	Domains     []string `yaml:"domains"`
+	APIPatterns []string `yaml:"api_patterns"`
+	Models      []string `yaml:"models"`
+	TaskTypes   []string `yaml:"task_types"`
+	// Parser selects the body parser: "openai", "anthropic", "google",
+	// "bedrock" or "generic" (the default, common fields only)
+	Parser string `yaml:"parser"`
 }
 
 // Known AI providers and their patterns
@@ -45,6 +53,7 @@ var knownAIProviders = []AIProvider{
 	// LLM Providers
 	{
 		Name:    "OpenAI",
+		Parser:  "openai",
 		Domains: []string{"api.openai.com"},
 		APIPatterns: []string{
 			"/v1/chat/completions", "/v1/completions", "/v1/embeddings",
@@ -54,6 +63,7 @@ var knownAIProviders = []AIProvider{
 	},
 	{
 		Name:    "Anthropic",
+		Parser:  "anthropic",
 		Domains: []string{"api.anthropic.com"},
 		APIPatterns: []string{
 			"/v1/messages", "/v1/complete",
@@ -61,9 +71,22 @@ var knownAIProviders = []AIProvider{
 	},
 	{
 		Name:    "Google AI",
-		Domains: []string{"generativelanguage.googleapis.com", "aiplatform.googleapis.com"},
+		Parser:  "google",
+		Domains: []string{"generativelanguage.googleapis.com"},
 		APIPatterns: []string{
-			"/v1beta/models", "/v1/projects",
+			"/v1beta/models", "/v1/models",
+		},
+	},
+	{
+		Name:    "Vertex AI",
+		Parser:  "google",
+		Domains: []string{"aiplatform.googleapis.com", "*-aiplatform.googleapis.com"},
+		APIPatterns: []string{
+			"/v1/projects/*/locations/*/publishers/*/models/*:predict",
+			"/v1/projects/*/locations/*/publishers/*/models/*:rawPredict",
+			"/v1/projects/*/locations/*/publishers/*/models/*:streamRawPredict",
+			"/v1/projects/*/locations/*/publishers/*/models/*:generateContent",
+			"/v1/projects/*/locations/*/publishers/*/models/*:streamGenerateContent",
 		},
 	},
 	{
@@ -87,6 +110,33 @@ var knownAIProviders = []AIProvider{
 			"/openai/v1/chat/completions",
 		},
 	},
+	{
+		Name:    "Mistral",
+		Parser:  "openai",
+		Domains: []string{"api.mistral.ai"},
+		APIPatterns: []string{
+			"/v1/chat/completions", "/v1/fim/completions", "/v1/embeddings",
+		},
+	},
+	{
+		// DeepSeek serves its OpenAI-compatible API with and without /v1
+		Name:    "DeepSeek",
+		Parser:  "openai",
+		Domains: []string{"api.deepseek.com"},
+		APIPatterns: []string{
+			"/chat/completions", "/v1/chat/completions",
+			"/beta/completions", "/completions", "/v1/completions",
+		},
+	},
+	{
+		Name:    "xAI",
+		Parser:  "openai",
+		Domains: []string{"api.x.ai"},
+		APIPatterns: []string{
+			"/v1/chat/completions", "/v1/completions", "/v1/embeddings",
+			"/v1/images/generations",
+		},
+	},
 	{
 		Name:    "Hugging Face",
 		Domains: []string{"api-inference.huggingface.co"},
@@ -101,6 +151,17 @@ var knownAIProviders = []AIProvider{
 			"/openai/deployments/",
 		},
 	},
+	{
+		// Bedrock signs requests with SigV4, so detection is by host and
+		// path only
+		Name:    "Amazon Bedrock",
+		Parser:  "bedrock",
+		Domains: []string{"bedrock-runtime.*.amazonaws.com"},
+		APIPatterns: []string{
+			"/model/*/invoke", "/model/*/invoke-with-response-stream",
+			"/model/*/converse", "/model/*/converse-stream",
+		},
+	},
 	// STT (Speech-to-Text) Providers
 	{
 		Name:    "Deepgram",
@@ -214,6 +275,9 @@ var knownAIProviders = []AIProvider{
 	},
 }
 
+// Environment variables:
+//   AXOM_TLS_INTERCEPT - Optional. Set to 1 to serve the HTTPS port with HTTPSProxy, which terminates TLS to AI provider hosts with the certs/ca.crt CA so bodies and WebSocket frames are captured, instead of ProductionProxy, which only tunnels CONNECT. Default: disabled
+
 // NewAITrafficMonitor creates a new AI traffic monitor
 func NewAITrafficMonitor(signalCh chan<- models.Signal, logger *log.Logger, customerID, agentID string) *AITrafficMonitor {
 	logAll := os.Getenv("LOG_ALL_TRAFFIC") == "true"
@@ -230,6 +294,7 @@ func NewAITrafficMonitor(signalCh chan<- models.Signal, logger *log.Logger, cust
 		mainContainer: mainContainer,
 		dashboardUser: dashboardUser,
 		dashboardPass: dashboardPass,
+		tlsIntercept:  os.Getenv("AXOM_TLS_INTERCEPT") == "1",
 	}
 }
 
@@ -242,20 +307,46 @@ func (m *AITrafficMonitor) Start(ctx context.Context) error {
 	if err := m.httpProxy.Start(ctx); err != nil {
 		return fmt.Errorf("failed to start HTTP proxy: %w", err)
 	}
+	m.httpStarted.Store(true)
 
-	// Start Production MITM proxy (replaces old HTTPS proxy)
-	m.productionProxy = NewProductionProxy("8443", m.signalCh, m.logger, m.customerID, m.agentID)
-	if err := m.productionProxy.Start(ctx); err != nil {
-		return fmt.Errorf("failed to start Production MITM proxy: %w", err)
+	if m.tlsIntercept {
+		// Terminate TLS to AI providers with our own CA
+		m.httpsProxy = NewHTTPSProxy("8443", m.signalCh, m.logger, m.customerID, m.agentID)
+		if err := m.httpsProxy.Start(ctx); err != nil {
+			return fmt.Errorf("failed to start HTTPS proxy: %w", err)
+		}
+	} else {
+		// Start Production MITM proxy (replaces old HTTPS proxy)
+		m.productionProxy = NewProductionProxy("8443", m.signalCh, m.logger, m.customerID, m.agentID)
+		if err := m.productionProxy.Start(ctx); err != nil {
+			return fmt.Errorf("failed to start Production MITM proxy: %w", err)
+		}
 	}
+	m.productionStarted.Store(true)
 
 	m.logger.Println("✅ AI Traffic Monitor started successfully")
 	return nil
 }
 
+// ProxiesStarted reports whether both the HTTP and HTTPS proxies are up
+func (m *AITrafficMonitor) ProxiesStarted() bool {
+	return m.httpStarted.Load() && m.productionStarted.Load()
+}
+
+// CACheck returns the HTTPS proxy's CA self-check result, or nil when TLS
+// interception is disabled or the check has not run
+func (m *AITrafficMonitor) CACheck() *CACheck {
+	if m.httpsProxy == nil {
+		return nil
+	}
+	return m.httpsProxy.CACheck()
+}
+
 // Stop stops the AI traffic monitor
 func (m *AITrafficMonitor) Stop(ctx context.Context) error {
 	m.logger.Println("🛑 Stopping AI Traffic Monitor")
+	m.httpStarted.Store(false)
+	m.productionStarted.Store(false)
 
 	if m.httpProxy != nil {
 		m.httpProxy.Stop(ctx)
@@ -263,34 +354,79 @@ func (m *AITrafficMonitor) Stop(ctx context.Context) error {
 	if m.productionProxy != nil {
 		m.productionProxy.Stop(ctx)
 	}
+	if m.httpsProxy != nil {
+		m.httpsProxy.Stop(ctx)
+	}
 
 	return nil
 }
 
 // HTTPProxy handles HTTP traffic
 type HTTPProxy struct {
-	port          string
-	signalCh      chan<- models.Signal
-	logger        *log.Logger
-	customerID    string
-	agentID       string
-	taskDetector  *TaskDetector
-	server        *http.Server
-	logAllTraffic bool
-	mainContainer string
+	port                string
+	signalCh            chan<- models.Signal
+	logger              *log.Logger
+	customerID          string
+	agentID             string
+	taskDetector        *TaskDetector
+	server              *http.Server
+	logAllTraffic       bool
+	mainContainer       string
+	moderator           *LocalModerator
+	costEstimator       *pricing.CostEstimator
+	identity            identityHeaders
+	metadataLimits      metadataLimits
+	metadataFilter      metadataFilter
+	duplicates          *duplicateDetector
+	resolver            *hostResolver
+	intercept           interceptAllowlist
+	detectDebug         bool
+	enqueuer            signalEnqueuer
+	forwarder           Forwarder
+	inflight            *inflightLimiter
+	sampler             signalSampler
+	azureModels         azureDeploymentModels
+	bodyLimit           bodyLimit
+	extractRules        *extractRules
+	providers           *ProviderRegistry
+	conversationSummary bool
+	estimateTokens      bool
+	preview             previewLimit
+	upstreamOverrides   upstreamOverrides
 }
 
 // NewHTTPProxy creates a new HTTP proxy
 func NewHTTPProxy(port string, signalCh chan<- models.Signal, logger *log.Logger, customerID, agentID string, logAllTraffic bool, mainContainer string) *HTTPProxy {
 	return &HTTPProxy{
-		port:          port,
-		signalCh:      signalCh,
-		logger:        logger,
-		customerID:    customerID,
-		agentID:       agentID,
-		taskDetector:  NewTaskDetector(signalCh, logger, customerID, agentID),
-		logAllTraffic: logAllTraffic,
-		mainContainer: mainContainer,
+		port:                port,
+		signalCh:            signalCh,
+		logger:              logger,
+		customerID:          customerID,
+		agentID:             agentID,
+		taskDetector:        NewTaskDetector(signalCh, logger, customerID, agentID),
+		logAllTraffic:       logAllTraffic,
+		mainContainer:       mainContainer,
+		moderator:           newLocalModeratorFromEnv(),
+		costEstimator:       pricing.NewCostEstimator("", logger),
+		identity:            identityHeadersFromEnv(),
+		metadataLimits:      metadataLimitsFromEnv(),
+		metadataFilter:      metadataFilterFromEnv(),
+		duplicates:          duplicateDetectorFromEnv(),
+		resolver:            hostResolverFromEnv(),
+		intercept:           interceptAllowlistFromEnv(),
+		detectDebug:         detectDebugEnabled(),
+		enqueuer:            signalEnqueuerFromEnv(),
+		forwarder:           newUpstreamForwarder(logger),
+		inflight:            inflightLimiterFromEnv("http"),
+		sampler:             signalSamplerFromEnv(),
+		azureModels:         azureDeploymentModelsFromEnv(),
+		bodyLimit:           bodyLimitFromEnv(),
+		extractRules:        extractRulesFromEnv(logger),
+		providers:           NewProviderRegistry("", logger),
+		conversationSummary: conversationSummaryFromEnv(),
+		estimateTokens:      tokenEstimationFromEnv(),
+		preview:             previewLimitFromEnv(),
+		upstreamOverrides:   upstreamOverridesFromEnv(logger),
 	}
 }
 
@@ -298,6 +434,9 @@ func NewHTTPProxy(port string, signalCh chan<- models.Signal, logger *log.Logger
 func (p *HTTPProxy) Start(ctx context.Context) error {
 	p.logger.Printf("Starting HTTP proxy on port %s", p.port)
 
+	// Expire tasks that stop receiving signals
+	go p.taskDetector.Run(ctx)
+
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", p.handleRequest)
 
@@ -323,6 +462,12 @@ func (p *HTTPProxy) Stop(ctx context.Context) error {
 	return nil
 }
 
+// SetForwarder replaces how requests are sent upstream. It must be called
+// before Start.
+func (p *HTTPProxy) SetForwarder(forwarder Forwarder) {
+	p.forwarder = forwarder
+}
+
 // handleRequest handles incoming HTTP requests
 func (p *HTTPProxy) handleRequest(w http.ResponseWriter, r *http.Request) {
 	startTime := time.Now()
@@ -331,14 +476,16 @@ func (p *HTTPProxy) handleRequest(w http.ResponseWriter, r *http.Request) {
 
 	// Log all traffic if enabled
 	if p.logAllTraffic {
-		bodyBytes, _ := io.ReadAll(r.Body)
-		r.Body.Close()
-		p.logger.Printf("[ALL-TRAFFIC] %s %s Host: %s Container: %s Body: %s", r.Method, r.URL.Path, r.Host, p.mainContainer, string(bodyBytes))
-		r.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+		body, _ := p.bodyLimit.capture(r.Body)
+		p.logger.Printf("[ALL-TRAFFIC] %s %s Host: %s Container: %s Body: %s", r.Method, r.URL.Path, r.Host, p.mainContainer, string(body.data))
+		r.Body = body.readCloser()
 	}
 
 	// Check if this is an AI API call
 	aiProvider := p.detectAIProvider(r.Host, r.URL.Path)
+	if aiProvider == nil && p.intercept.allows(r.Host) && detectMCPRequest(r, p.bodyLimit) {
+		aiProvider = &mcpProvider
+	}
 	if aiProvider == nil {
 		p.logger.Printf("❌ Not an AI API call: %s %s (Host: %s)", r.Method, r.URL.Path, r.Host)
 		// Not an AI API call, forward as-is
@@ -348,20 +495,21 @@ func (p *HTTPProxy) handleRequest(w http.ResponseWriter, r *http.Request) {
 
 	p.logger.Printf("✅ AI API call detected: %s %s -> %s", aiProvider.Name, r.Method, r.URL.String())
 
-	// Capture request body
-	bodyBytes, err := io.ReadAll(r.Body)
+	// Capture request body, up to the body limit
+	reqBody, err := p.bodyLimit.captureRequest(r)
 	if err != nil {
 		p.logger.Printf("Failed to read request body: %v", err)
 		http.Error(w, "Bad request", http.StatusBadRequest)
 		return
 	}
-	r.Body.Close()
 
 	// Parse AI request
-	aiRequest := p.parseAIRequest(r, bodyBytes, aiProvider)
+	aiRequest := p.parseAIRequest(r, reqBody.data, aiProvider)
+	reqBody.mark(aiRequest)
+	p.extractRules.apply(aiRequest, "request", r.URL.Path, reqBody.data, r.Header)
 
 	// Forward request to actual AI service
-	resp, err := p.forwardAIRequest(r, bodyBytes)
+	resp, err := p.forwardAIRequest(r, reqBody, aiProvider)
 	if err != nil {
 		p.logger.Printf("Failed to forward AI request: %v", err)
 		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
@@ -369,14 +517,25 @@ func (p *HTTPProxy) handleRequest(w http.ResponseWriter, r *http.Request) {
 	}
 	defer resp.Body.Close()
 
-	// Capture response body
-	respBodyBytes, err := io.ReadAll(resp.Body)
+	// Capture response body, up to the body limit, noting when it starts
+	ttfb := timeFirstByte(resp)
+	respBody, err := p.bodyLimit.capture(resp.Body)
 	if err != nil {
 		p.logger.Printf("Failed to read response body: %v", err)
 	}
 
-	// Parse AI response
-	aiResponse := p.parseAIResponse(respBodyBytes, aiProvider)
+	// Parse AI response, decompressed; the client still gets the bytes as sent
+	respData, decodeErr := decodeResponseBody(respBody, resp.Header, p.bodyLimit)
+	aiResponse := p.parseAIResponse(respData, resp.Header.Get("Content-Type"), aiProvider)
+	respBody.mark(aiResponse)
+	recordContentEncoding(aiResponse, resp.Header, decodeErr)
+	p.extractRules.apply(aiResponse, "response", r.URL.Path, respData, resp.Header)
+	recordTTSResponseAudio(aiResponse, aiProvider, resp.Header, respData)
+	recordRateLimitHeaders(aiResponse, resp.Header)
+	reqBody.recordDigest(aiRequest)
+	recordBytes(aiRequest, "request", reqBody, r.ContentLength, r.Header)
+	recordBytes(aiResponse, "response", respBody, resp.ContentLength, resp.Header)
+	recordTTFB(aiResponse, resp.Header, startTime, ttfb)
 
 	// Calculate latency
 	latency := time.Since(startTime)
@@ -384,6 +543,9 @@ func (p *HTTPProxy) handleRequest(w http.ResponseWriter, r *http.Request) {
 	// Create signal
 	signal := p.createSignal(r, aiRequest, aiResponse, resp.StatusCode, latency, aiProvider)
 
+	// Flag repeats of a recent identical request from the same agent
+	p.duplicates.check(&signal, r.Method, r.URL.Path, reqBody, aiProvider.Name)
+
 	// Detect task if this is a new task
 	if task := p.taskDetector.DetectTask(signal); task != nil {
 		signal.TaskID = task.ID
@@ -391,18 +553,28 @@ func (p *HTTPProxy) handleRequest(w http.ResponseWriter, r *http.Request) {
 		signal.Metadata["task_confidence"] = task.Metadata["confidence"]
 	}
 
-	// Send signal
-	select {
-	case p.signalCh <- signal:
+	// Drop governed keys and bound metadata size now that nothing else adds
+	// to it
+	p.metadataFilter.apply(signal.Metadata)
+	p.metadataLimits.apply(signal.Metadata)
+
+	// Send signal unless sampled out
+	if !p.sampler.keep(&signal, aiProvider.Name) {
+		p.logger.Printf("Signal sampled out: %s %s", aiProvider.Name, signal.Operation)
+	} else if p.enqueuer.send(p.signalCh, signal, aiProvider.Name) {
 		p.logger.Printf("📡 AI signal captured: %s %s -> %s (latency: %.2fms)",
 			aiProvider.Name, signal.Operation, r.URL.Host, signal.LatencyMS)
-	default:
+	} else {
 		p.logger.Printf("Signal channel full, dropping signal")
 	}
 
-	// Return response to client
+	// Return response to client with the upstream headers
+	copyResponseHeaders(w.Header(), resp.Header)
+	if n := respBody.length(resp.ContentLength); n >= 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(n, 10))
+	}
 	w.WriteHeader(resp.StatusCode)
-	w.Write(respBodyBytes)
+	io.Copy(w, respBody.reader())
 }
 
 // detectAIProvider detects which AI provider this request is for
@@ -413,7 +585,7 @@ func (p *HTTPProxy) detectAIProvider(host, path string) *AIProvider {
 	// In this case, we detect based on path patterns only
 	if strings.Contains(host, "localhost") && (strings.Contains(host, "8888") || strings.Contains(host, "8443")) {
 		p.logger.Printf("✅ Localhost detection passed for host: %s", host)
-		for _, provider := range knownAIProviders {
+		for _, provider := range p.providers.Providers() {
 			for _, pattern := range provider.APIPatterns {
 				if strings.Contains(path, pattern) {
 					p.logger.Printf("✅ Found AI provider: %s with pattern: %s", provider.Name, pattern)
@@ -426,7 +598,7 @@ func (p *HTTPProxy) detectAIProvider(host, path string) *AIProvider {
 
 	// Check for localhost:8888 or localhost:8443 specifically
 	if host == "localhost:8888" || host == "localhost:8443" {
-		for _, provider := range knownAIProviders {
+		for _, provider := range p.providers.Providers() {
 			for _, pattern := range provider.APIPatterns {
 				if strings.Contains(path, pattern) {
 					return &provider
@@ -435,17 +607,15 @@ func (p *HTTPProxy) detectAIProvider(host, path string) *AIProvider {
 		}
 	}
 
-	// Original logic for direct AI provider detection
-	for _, provider := range knownAIProviders {
-		for _, domain := range provider.Domains {
-			if strings.Contains(host, strings.ReplaceAll(domain, "*", "")) {
-				for _, pattern := range provider.APIPatterns {
-					if strings.Contains(path, pattern) {
-						return &provider
-					}
-				}
-			}
-		}
+	// Direct AI provider detection by domain and path, for allowed hosts
+	if !p.intercept.allows(host) {
+		return nil
+	}
+	if provider := p.providers.Match(host, path); provider != nil {
+		return provider
+	}
+	if p.detectDebug {
+		p.providers.logDetectionMiss(p.logger, host, path)
 	}
 	return nil
 }
@@ -458,10 +628,11 @@ func (p *HTTPProxy) parseAIRequest(r *http.Request, bodyBytes []byte, provider *
 	request["provider"] = provider.Name
 	request["endpoint"] = r.URL.Path
 	request["method"] = r.Method
+	request["correlation_id"] = ensureCorrelationID(r)
 
 	// Parse JSON body if available
+	var jsonData map[string]interface{}
 	if len(bodyBytes) > 0 {
-		var jsonData map[string]interface{}
 		if err := json.Unmarshal(bodyBytes, &jsonData); err == nil {
 			// Extract model
 			if model, ok := jsonData["model"].(string); ok {
@@ -471,41 +642,99 @@ func (p *HTTPProxy) parseAIRequest(r *http.Request, bodyBytes []byte, provider *
 			// Extract messages for chat completions
 			if messages, ok := jsonData["messages"].([]interface{}); ok {
 				request["messages"] = messages
-				if len(messages) > 0 {
-					if msg, ok := messages[0].(map[string]interface{}); ok {
-						if content, ok := msg["content"].(string); ok {
-							request["prompt_preview"] = p.truncateString(content, 100)
-						}
-					}
+				// The latest user message is the prompt, not the first one
+				if content, ok := latestPrompt(messages); ok {
+					p.preview.set(request, "prompt_preview", content)
 				}
+				summarizeConversation(request, messages, jsonData, p.conversationSummary)
 			}
 
-			// Extract other common fields
-			for _, field := range []string{"max_tokens", "temperature", "top_p", "frequency_penalty", "presence_penalty"} {
-				if value, ok := jsonData[field]; ok {
-					request[field] = value
-				}
-			}
+			// Extract sampling params with normalized types
+			normalizeSamplingParams(request, jsonData)
 
 			// Provider-specific parsing
-			switch provider.Name {
-			case "OpenAI":
+			switch provider.Parser {
+			case "openai":
 				p.parseOpenAIRequest(request, jsonData)
-			case "Anthropic":
+			case "anthropic":
 				p.parseAnthropicRequest(request, jsonData)
-			case "Google AI":
+			case "google":
 				p.parseGoogleAIRequest(request, jsonData)
+			case "bedrock":
+				p.parseBedrockRequest(request, jsonData)
+			case "mcp":
+				parseMCPRequest(request, jsonData)
+			}
+
+			// Embedding input counts, for billing by vector
+			if isEmbeddingPath(r.URL.Path) {
+				parseEmbeddingRequest(request, jsonData)
+			}
+
+			// Image generation options, for billing by image
+			if isImageGenerationPath(r.URL.Path) {
+				parseImageGenerationRequest(request, jsonData)
+			}
+
+			// Prompt size, in case the provider reports no usage
+			if p.estimateTokens {
+				recordPromptEstimate(request, jsonData)
 			}
 		}
 	}
 
+	// Multipart and raw audio uploads carry their metadata outside a JSON body
+	parseUploadRequest(request, r, bodyBytes)
+
+	// Bedrock, Vertex AI and Azure OpenAI name the model in the URL path
+	if provider.Name == "Azure OpenAI" {
+		p.azureModels.apply(request, r)
+	} else if _, ok := request["model"]; !ok {
+		if model := modelFromPath(provider.Name, r.URL.Path); model != "" {
+			request["model"] = model
+		}
+	}
+
+	// Capture requested audio format for TTS billing
+	if isTTSProvider(provider.Name) {
+		extractTTSRequestFormat(request, r, jsonData, provider)
+	}
+
 	return request
 }
 
 // parseAIResponse parses the AI response based on provider
-func (p *HTTPProxy) parseAIResponse(bodyBytes []byte, provider *AIProvider) map[string]interface{} {
+func (p *HTTPProxy) parseAIResponse(bodyBytes []byte, contentType string, provider *AIProvider) map[string]interface{} {
 	response := make(map[string]interface{})
 
+	// MCP answers are JSON-RPC, as a JSON body or SSE events
+	if provider.Parser == "mcp" {
+		parseMCPResponse(response, bodyBytes, contentType)
+		return response
+	}
+
+	// Streamed responses are a series of SSE chunks rather than one JSON body
+	if isEventStream(contentType) {
+		stream := parseEventStream(bodyBytes)
+		if stream.Content != "" {
+			p.preview.set(response, "response_preview", stream.Content)
+		}
+		if stream.Usage != nil {
+			response["usage"] = stream.Usage
+		}
+		if stream.ID != "" {
+			response["id"] = stream.ID
+		}
+		if stream.FinishReason != "" {
+			response["finish_reason"] = stream.FinishReason
+		}
+		if p.estimateTokens {
+			recordCompletionEstimate(response, "", stream.Content)
+		}
+		response["stream_chunks"] = stream.Chunks
+		return response
+	}
+
 	if len(bodyBytes) > 0 {
 		var jsonData map[string]interface{}
 		if err := json.Unmarshal(bodyBytes, &jsonData); err == nil {
@@ -514,23 +743,42 @@ func (p *HTTPProxy) parseAIResponse(bodyBytes []byte, provider *AIProvider) map[
 				response["usage"] = usage
 			}
 
+			// Extract provider error details
+			extractProviderError(response, jsonData)
+
+			// Embedding vector count and dimensions
+			parseEmbeddingResponse(response, jsonData)
+
+			// Generated image count
+			parseImageGenerationResponse(response, jsonData)
+
+			// Completion size, in case the provider reports no usage
+			if p.estimateTokens {
+				model, _ := jsonData["model"].(string)
+				recordCompletionEstimate(response, model, completionText(jsonData))
+			}
+
 			// Extract choices/response
 			if choices, ok := jsonData["choices"].([]interface{}); ok && len(choices) > 0 {
 				if choice, ok := choices[0].(map[string]interface{}); ok {
 					if message, ok := choice["message"].(map[string]interface{}); ok {
 						if content, ok := message["content"].(string); ok {
-							response["response_preview"] = p.truncateString(content, 100)
+							p.preview.set(response, "response_preview", content)
 						}
 					}
 				}
 			}
 
 			// Provider-specific parsing
-			switch provider.Name {
-			case "OpenAI":
+			switch provider.Parser {
+			case "openai":
 				p.parseOpenAIResponse(response, jsonData)
-			case "Anthropic":
+			case "anthropic":
 				p.parseAnthropicResponse(response, jsonData)
+			case "google":
+				p.parseGoogleAIResponse(response, jsonData)
+			case "bedrock":
+				p.parseBedrockResponse(response, jsonData)
 			}
 		}
 	}
@@ -552,12 +800,15 @@ func (p *HTTPProxy) parseOpenAIRequest(request map[string]interface{}, jsonData
 // parseAnthropicRequest parses Anthropic-specific request fields
 func (p *HTTPProxy) parseAnthropicRequest(request map[string]interface{}, jsonData map[string]interface{}) {
 	// Anthropic-specific fields
-	if max_tokens, ok := jsonData["max_tokens"].(float64); ok {
-		request["max_tokens"] = int(max_tokens)
-	}
 	if system, ok := jsonData["system"].(string); ok {
 		request["system"] = system
 	}
+	// Customer-provided attribution dimension
+	if metadata, ok := jsonData["metadata"].(map[string]interface{}); ok {
+		if userID, ok := metadata["user_id"].(string); ok && userID != "" {
+			request["provider_user"] = userID
+		}
+	}
 }
 
 // parseGoogleAIRequest parses Google AI-specific request fields
@@ -566,6 +817,16 @@ func (p *HTTPProxy) parseGoogleAIRequest(request map[string]interface{}, jsonDat
 	if generationConfig, ok := jsonData["generationConfig"].(map[string]interface{}); ok {
 		request["generation_config"] = generationConfig
 	}
+	// Customer-provided attribution labels
+	if labels, ok := jsonData["labels"].(map[string]interface{}); ok && len(labels) > 0 {
+		providerLabels := make(map[string]string, len(labels))
+		for k, v := range labels {
+			if value, ok := v.(string); ok {
+				providerLabels[k] = value
+			}
+		}
+		request["provider_labels"] = providerLabels
+	}
 }
 
 // parseOpenAIResponse parses OpenAI-specific response fields
@@ -574,6 +835,10 @@ func (p *HTTPProxy) parseOpenAIResponse(response map[string]interface{}, jsonDat
 	if id, ok := jsonData["id"].(string); ok {
 		response["id"] = id
 	}
+
+	// Record why generation stopped, and tool calls
+	extractOpenAIFinishReason(response, jsonData)
+	extractOpenAIToolCalls(response, jsonData)
 }
 
 // parseAnthropicResponse parses Anthropic-specific response fields
@@ -582,10 +847,67 @@ func (p *HTTPProxy) parseAnthropicResponse(response map[string]interface{}, json
 	if content, ok := jsonData["content"].([]interface{}); ok && len(content) > 0 {
 		if contentItem, ok := content[0].(map[string]interface{}); ok {
 			if text, ok := contentItem["text"].(string); ok {
-				response["response_preview"] = p.truncateString(text, 100)
+				p.preview.set(response, "response_preview", text)
 			}
 		}
 	}
+
+	// Record why generation stopped, and tool_use blocks
+	extractAnthropicStopReason(response, jsonData)
+	extractAnthropicToolUse(response, jsonData)
+}
+
+// parseGoogleAIResponse parses Gemini candidates and usageMetadata
+func (p *HTTPProxy) parseGoogleAIResponse(response map[string]interface{}, jsonData map[string]interface{}) {
+	if candidates, ok := jsonData["candidates"].([]interface{}); ok && len(candidates) > 0 {
+		if candidate, ok := candidates[0].(map[string]interface{}); ok {
+			if content, ok := candidate["content"].(map[string]interface{}); ok {
+				if parts, ok := content["parts"].([]interface{}); ok {
+					var text strings.Builder
+					for _, part := range parts {
+						if partMap, ok := part.(map[string]interface{}); ok {
+							if partText, ok := partMap["text"].(string); ok {
+								text.WriteString(partText)
+							}
+						}
+					}
+					if text.Len() > 0 {
+						p.preview.set(response, "response_preview", text.String())
+					}
+				}
+			}
+		}
+	}
+
+	// Token counts are normalized in createSignal
+	if usageMetadata, ok := jsonData["usageMetadata"].(map[string]interface{}); ok {
+		response["usageMetadata"] = usageMetadata
+	}
+}
+
+// parseBedrockRequest parses the model-specific Bedrock request bodies
+func (p *HTTPProxy) parseBedrockRequest(request map[string]interface{}, jsonData map[string]interface{}) {
+	if _, ok := request["prompt_preview"]; !ok {
+		if text := bedrockPromptText(jsonData); text != "" {
+			p.preview.set(request, "prompt_preview", text)
+		}
+	}
+	if maxTokens, ok := bedrockMaxTokens(jsonData); ok {
+		request["max_tokens"] = maxTokens
+	}
+}
+
+// parseBedrockResponse parses Bedrock InvokeModel and Converse responses
+func (p *HTTPProxy) parseBedrockResponse(response map[string]interface{}, jsonData map[string]interface{}) {
+	if text := bedrockResponseText(jsonData); text != "" {
+		p.preview.set(response, "response_preview", text)
+	}
+	if usage := bedrockUsage(jsonData); usage != nil {
+		response["usage"] = usage
+	}
+
+	// Record tool_use blocks from Anthropic models
+	extractAnthropicToolUse(response, jsonData)
 }
 
 // createSignal creates a signal from the AI request/response
@@ -600,6 +922,10 @@ func (p *HTTPProxy) createSignal(
 
 	// Determine operation type
 	operation := p.determineOperation(r.URL.Path, request, provider)
+	// Responses that invoke tools are tool calls regardless of endpoint
+	if hasToolCalls(response) {
+		operation = "tool_call"
+	}
 
 	// Extract metadata
 	metadata := make(map[string]interface{})
@@ -614,36 +940,56 @@ func (p *HTTPProxy) createSignal(
 	metadata["provider"] = provider.Name
 	metadata["endpoint"] = r.URL.Path
 
-	// Extract usage information
-	if usage, ok := response["usage"].(map[string]interface{}); ok {
-		if promptTokens, ok := usage["prompt_tokens"].(float64); ok {
-			metadata["prompt_tokens"] = int(promptTokens)
-		}
-		if completionTokens, ok := usage["completion_tokens"].(float64); ok {
-			metadata["completion_tokens"] = int(completionTokens)
-		}
-		if totalTokens, ok := usage["total_tokens"].(float64); ok {
-			metadata["total_tokens"] = int(totalTokens)
-		}
+	// Normalize token usage across provider shapes, falling back to the
+	// estimate from the captured text when there is none
+	estimate, estimated := takeTokenEstimate(metadata)
+	usage, ok := normalizeUsage(provider.Name, response)
+	if !ok && estimated {
+		usage, ok = estimate, true
+		metadata["tokens_estimated"] = true
+	}
+	if ok {
+		metadata["prompt_tokens"] = usage.Prompt
+		metadata["completion_tokens"] = usage.Completion
+		metadata["total_tokens"] = usage.Total
 	}
 
-	return models.Signal{
-		ID:          p.generateSignalID(),
-		CustomerID:  p.customerID,
-		AgentID:     p.agentID,
-		Timestamp:   time.Now(),
-		Protocol:    "http",
-		LatencyMS:   float64(latency.Milliseconds()),
-		Metadata:    metadata,
-		Source:      models.Endpoint{IP: "127.0.0.1", Port: 0},
-		Destination: models.Endpoint{IP: r.URL.Host, Port: 443},
-		Operation:   operation,
-		Status:      statusCode,
+	// Estimate cost from token usage
+	p.costEstimator.Annotate(provider.Name, metadata)
+
+	// Per-request identity headers override the configured defaults
+	customerID, agentID := p.identity.resolve(r, p.customerID, p.agentID)
+
+	signal := models.Signal{
+		ID:            models.NewSignalID(),
+		SchemaVersion: models.SignalSchemaVersion,
+		CustomerID:    customerID,
+		AgentID:       agentID,
+		Timestamp:     time.Now(),
+		Protocol:      "http",
+		LatencyMS:     float64(latency.Milliseconds()),
+		Metadata:      metadata,
+		Source:        endpointFromAddr(r.RemoteAddr),
+		Destination:   p.resolver.enrich(destinationEndpoint(r)),
+		Operation:     operation,
+		Status:        statusCode,
 	}
+	applyStatusOutcome(&signal, provider.Name, r.URL.Path)
+	signal.Alerts = append(signal.Alerts, failureClassAlerts(provider.Name, r.URL.Path, metadata)...)
+	signal.Alerts = append(signal.Alerts, p.moderator.Apply(metadata)...)
+
+	recordSignalMetrics(signal, provider.Name)
+
+	return signal
 }
 
 // determineOperation determines the operation type
 func (p *HTTPProxy) determineOperation(path string, request map[string]interface{}, provider *AIProvider) string {
+	// MCP calls are named by their JSON-RPC method, e.g. tools/call
+	if method, ok := request["mcp_method"].(string); ok {
+		return method
+	}
+
 	// Check path patterns
 	if strings.Contains(path, "/chat/completions") || strings.Contains(path, "/messages") {
 		return "chat_completion"
@@ -672,51 +1018,39 @@ func (p *HTTPProxy) determineOperation(path string, request map[string]interface
 }
 
 // forwardAIRequest forwards the request to the actual AI service
-func (p *HTTPProxy) forwardAIRequest(r *http.Request, bodyBytes []byte) (*http.Response, error) {
-	// Determine the actual AI service URL based on the request
-	var targetURL string
-
-	// For localhost requests, forward to the mock AI provider
-	if strings.Contains(r.Host, "localhost") || strings.Contains(r.Host, "127.0.0.1") {
-		// Forward to mock AI provider for testing
-		targetURL = fmt.Sprintf("http://127.0.0.1:9999%s", r.URL.Path)
-	} else {
-		// For external services, use the original URL
-		targetURL = r.URL.String()
+func (p *HTTPProxy) forwardAIRequest(r *http.Request, body capturedBody, provider *AIProvider) (*http.Response, error) {
+	// Forward to the original URL unless its provider or host is redirected
+	targetURL, err := p.upstreamOverrides.target(r, provider.Name)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create new request to actual AI service
-	req, err := http.NewRequest(r.Method, targetURL, bytes.NewReader(bodyBytes))
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, targetURL.String(), body.reader())
 	if err != nil {
 		return nil, err
 	}
+	req.ContentLength = body.length(r.ContentLength)
 
-	// Copy headers
+	// Copy headers, only accepting encodings the response can be parsed in
 	req.Header = r.Header
+	restrictAcceptEncoding(req.Header)
 
-	// Create HTTP client
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: false},
-		},
-	}
-
-	return client.Do(req)
+	return p.inflight.forward(p.forwarder, req)
 }
 
 // forwardRequest forwards non-AI requests
 func (p *HTTPProxy) forwardRequest(w http.ResponseWriter, r *http.Request) {
-	// Forward to mock AI provider for testing
-	targetURL := "http://127.0.0.1:9999" + r.URL.Path
-	if r.URL.RawQuery != "" {
-		targetURL += "?" + r.URL.RawQuery
+	targetURL, err := p.upstreamOverrides.target(r, "")
+	if err != nil {
+		http.Error(w, "Not an AI API endpoint", http.StatusNotFound)
+		return
 	}
 
-	p.logger.Printf("🔄 Forwarding request to mock AI provider: %s", targetURL)
+	p.logger.Printf("🔄 Forwarding request to %s", targetURL)
 
 	// Create new request
-	req, err := http.NewRequest(r.Method, targetURL, r.Body)
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, targetURL.String(), r.Body)
 	if err != nil {
 		http.Error(w, "Failed to create request", http.StatusInternalServerError)
 		return
@@ -729,9 +1063,7 @@ func (p *HTTPProxy) forwardRequest(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Make request
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := p.inflight.forward(p.forwarder, req)
 	if err != nil {
 		http.Error(w, "Failed to forward request", http.StatusBadGateway)
 		return
@@ -749,16 +1081,3 @@ func (p *HTTPProxy) forwardRequest(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(resp.StatusCode)
 	io.Copy(w, resp.Body)
 }
-
-// generateSignalID generates a unique signal ID
-func (p *HTTPProxy) generateSignalID() string {
-	return fmt.Sprintf("signal_%d", time.Now().UnixNano())
-}
-
-// truncateString truncates a string to max length
-func (p *HTTPProxy) truncateString(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
-	}
-	return s[:maxLen] + "..."
-}
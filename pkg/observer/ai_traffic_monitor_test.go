@@ -0,0 +1,130 @@
+package observer
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"axom-observer/pkg/models"
+)
+
+// cannedResponse returns a Forwarder that answers every request with the
+// given status, content type and body
+func cannedResponse(status int, contentType, body string) Forwarder {
+	return ForwarderFunc(func(req *http.Request) (*http.Response, error) {
+		header := make(http.Header)
+		if contentType != "" {
+			header.Set("Content-Type", contentType)
+		}
+		return &http.Response{
+			StatusCode:    status,
+			Status:        http.StatusText(status),
+			Header:        header,
+			Body:          io.NopCloser(strings.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	})
+}
+
+// newTestHTTPProxy creates an HTTPProxy, configured from the current
+// environment, whose upstream requests are answered by forwarder. Signals
+// it emits are buffered on the returned channel.
+func newTestHTTPProxy(t *testing.T, forwarder Forwarder) (*HTTPProxy, chan models.Signal) {
+	t.Helper()
+	signalCh := make(chan models.Signal, 16)
+	p := NewHTTPProxy("0", signalCh, testLogger(), "test-customer", "test-agent", false, "")
+	p.SetForwarder(forwarder)
+	return p, signalCh
+}
+
+// proxySignal sends req through p and returns the request signal it emitted,
+// skipping task completion signals
+func proxySignal(t *testing.T, p *HTTPProxy, signalCh chan models.Signal, req *http.Request) models.Signal {
+	t.Helper()
+	p.handleRequest(httptest.NewRecorder(), req)
+	for {
+		select {
+		case signal := <-signalCh:
+			if signal.Protocol == "internal" {
+				continue
+			}
+			return signal
+		case <-time.After(time.Second):
+			t.Fatalf("no signal emitted for %s %s", req.Method, req.URL)
+			return models.Signal{}
+		}
+	}
+}
+
+// jsonRequest returns a POST of a JSON body to url
+func jsonRequest(url, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+// chatRequest returns a request for an OpenAI chat completion with body
+func chatRequest(body string) *http.Request {
+	return jsonRequest("http://api.openai.com/v1/chat/completions", body)
+}
+
+func TestAnthropicMetadataUserID(t *testing.T) {
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "application/json",
+		`{"id":"msg_1","type":"message","content":[{"type":"text","text":"Hi"}],"usage":{"input_tokens":5,"output_tokens":1}}`))
+
+	signal := proxySignal(t, p, signalCh, jsonRequest("http://api.anthropic.com/v1/messages",
+		`{"model":"claude-3-5-sonnet-20241022","max_tokens":64,"metadata":{"user_id":"user-42"},"messages":[{"role":"user","content":"Hello"}]}`))
+
+	if got := signal.Metadata["provider_user"]; got != "user-42" {
+		t.Errorf("Metadata[provider_user] = %v, want user-42", got)
+	}
+}
+
+func TestGoogleRequestLabels(t *testing.T) {
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "application/json",
+		`{"candidates":[{"content":{"parts":[{"text":"Hi"}],"role":"model"}}]}`))
+
+	signal := proxySignal(t, p, signalCh, jsonRequest("http://generativelanguage.googleapis.com/v1beta/models/gemini-1.5-pro:generateContent",
+		`{"contents":[{"role":"user","parts":[{"text":"Hello"}]}],"labels":{"team":"search","env":"prod","ignored":7}}`))
+
+	labels, ok := signal.Metadata["provider_labels"].(map[string]string)
+	if !ok {
+		t.Fatalf("Metadata[provider_labels] = %T, want map[string]string", signal.Metadata["provider_labels"])
+	}
+	if len(labels) != 2 || labels["team"] != "search" || labels["env"] != "prod" {
+		t.Errorf("provider_labels = %v, want team=search env=prod", labels)
+	}
+}
+
+const geminiResponse = `{
+	"candidates": [{
+		"content": {"role": "model", "parts": [{"text": "Paris is the capital "}, {"text": "of France."}]},
+		"finishReason": "STOP",
+		"index": 0
+	}],
+	"usageMetadata": {"promptTokenCount": 8, "candidatesTokenCount": 7, "totalTokenCount": 15},
+	"modelVersion": "gemini-1.5-pro-002"
+}`
+
+func TestGeminiResponse(t *testing.T) {
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "application/json", geminiResponse))
+
+	signal := proxySignal(t, p, signalCh, jsonRequest("http://generativelanguage.googleapis.com/v1beta/models/gemini-1.5-pro:generateContent",
+		`{"contents":[{"role":"user","parts":[{"text":"What is the capital of France?"}]}]}`))
+
+	if got := signal.Metadata["provider"]; got != "Google AI" {
+		t.Errorf("provider = %v, want Google AI", got)
+	}
+	if got := signal.Metadata["response_preview"]; got != "Paris is the capital of France." {
+		t.Errorf("response_preview = %q, want the first candidate's parts joined", got)
+	}
+	for key, want := range map[string]int{"prompt_tokens": 8, "completion_tokens": 7, "total_tokens": 15} {
+		if got := signal.Metadata[key]; got != want {
+			t.Errorf("%s = %v, want %d", key, got, want)
+		}
+	}
+}
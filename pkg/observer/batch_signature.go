@@ -0,0 +1,81 @@
+package observer
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Environment variables:
+//   AXOM_SIGN_BATCHES - Optional. Set to "1" to sign each batch with HMAC-SHA256 under the agent secret, sent as X-Axom-Signature. Default: disabled
+
+// batchSignatureHeader carries "t=<unix seconds>,v1=<hex HMAC-SHA256>",
+// where the HMAC is over "<unix seconds>.<body>"
+const batchSignatureHeader = "X-Axom-Signature"
+
+// signBatchesFromEnv reports whether AXOM_SIGN_BATCHES enables signing
+func signBatchesFromEnv() bool {
+	return os.Getenv("AXOM_SIGN_BATCHES") == "1"
+}
+
+// batchSignature returns the signature header value for body at t. The
+// timestamp is covered by the HMAC so a captured batch can't be replayed
+// later under a fresh one.
+func batchSignature(secret string, body []byte, t time.Time) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(batchMAC(secret, ts, body))
+}
+
+func batchMAC(secret, ts string, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// signBatch sets the signature header on a batch request
+func signBatch(req *http.Request, secret string, body []byte) {
+	req.Header.Set(batchSignatureHeader, batchSignature(secret, body, time.Now()))
+}
+
+// VerifyBatchSignature checks an X-Axom-Signature header value against the
+// batch body and the agent secret, as the backend does. Signatures older
+// (or further in the future) than maxAge are rejected as replays.
+func VerifyBatchSignature(secret, header string, body []byte, maxAge time.Duration) error {
+	var ts, sig string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			sig = value
+		}
+	}
+	if ts == "" || sig == "" {
+		return errors.New("malformed signature header")
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return fmt.Errorf("malformed signature timestamp: %w", err)
+	}
+	if age := time.Since(time.Unix(unix, 0)); age > maxAge || age < -maxAge {
+		return fmt.Errorf("signature timestamp outside the allowed window of %s", maxAge)
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return fmt.Errorf("malformed signature: %w", err)
+	}
+	if !hmac.Equal(got, batchMAC(secret, ts, body)) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package observer
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"axom-observer/pkg/models"
+)
+
+func TestSignedBatchVerifiesServerSide(t *testing.T) {
+	t.Setenv("AXOM_SIGN_BATCHES", "1")
+	var mu sync.Mutex
+	var verified []error
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		err := VerifyBatchSignature("agent-secret", r.Header.Get(batchSignatureHeader), body, 5*time.Minute)
+		mu.Lock()
+		verified = append(verified, err)
+		mu.Unlock()
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer backend.Close()
+
+	s := NewSignalSender("agent-secret", backend.URL, 10, time.Hour)
+	sent, err := s.SendBatches(context.Background(), []models.Signal{testSignal("sig-1", nil), testSignal("sig-2", nil)})
+	if err != nil || sent != 2 {
+		t.Fatalf("SendBatches = %d, %v, want both signals accepted", sent, err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(verified) != 1 || verified[0] != nil {
+		t.Errorf("backend verification = %v, want one valid signature", verified)
+	}
+}
+
+func TestUnsignedByDefault(t *testing.T) {
+	backend := newTestBackend(t, nil)
+	s := NewSignalSender("agent-secret", backend.URL, 10, time.Hour)
+	if _, err := s.SendBatches(context.Background(), []models.Signal{testSignal("sig-1", nil)}); err != nil {
+		t.Fatalf("SendBatches: %v", err)
+	}
+	if got := backend.headers[0].Get(batchSignatureHeader); got != "" {
+		t.Errorf("%s = %q without AXOM_SIGN_BATCHES, want it absent", batchSignatureHeader, got)
+	}
+}
+
+func TestVerifyBatchSignatureRejects(t *testing.T) {
+	body := []byte(`[{"id":"sig-1"}]`)
+	now := time.Now()
+	valid := batchSignature("agent-secret", body, now)
+	if err := VerifyBatchSignature("agent-secret", valid, body, time.Minute); err != nil {
+		t.Fatalf("valid signature rejected: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		secret string
+		header string
+		body   []byte
+		want   string
+	}{
+		{"tampered body", "agent-secret", valid, []byte(`[{"id":"sig-2"}]`), "mismatch"},
+		{"wrong secret", "other-secret", valid, body, "mismatch"},
+		{"replayed", "agent-secret", batchSignature("agent-secret", body, now.Add(-10*time.Minute)), body, "window"},
+		{"future", "agent-secret", batchSignature("agent-secret", body, now.Add(10*time.Minute)), body, "window"},
+		// A fresh timestamp on an old signature doesn't verify, as the
+		// timestamp is signed too
+		{"retimed", "agent-secret", strings.Replace(batchSignature("agent-secret", body, now.Add(-10*time.Minute)),
+			"t="+timestamp(now.Add(-10*time.Minute)), "t="+timestamp(now), 1), body, "mismatch"},
+		{"missing", "agent-secret", "", body, "malformed"},
+		{"bad hex", "agent-secret", "t=" + timestamp(now) + ",v1=zz", body, "malformed"},
+	}
+	for _, tt := range tests {
+		err := VerifyBatchSignature(tt.secret, tt.header, tt.body, time.Minute)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: VerifyBatchSignature = %v, want an error containing %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+// timestamp formats t as a signature header timestamp
+func timestamp(t time.Time) string {
+	return strconv.FormatInt(t.Unix(), 10)
+}
@@ -0,0 +1,112 @@
+package observer
+
+// bedrockPromptText returns the prompt from the non-chat Bedrock body
+// shapes: Amazon Titan ("inputText") and Meta Llama / Mistral ("prompt").
+// Anthropic and Converse bodies use "messages" and are handled by the
+// common message parsing.
+func bedrockPromptText(jsonData map[string]interface{}) string {
+	if text, ok := jsonData["inputText"].(string); ok {
+		return text
+	}
+	if text, ok := jsonData["prompt"].(string); ok {
+		return text
+	}
+	return ""
+}
+
+// bedrockMaxTokens returns the output token limit from the model-specific
+// Bedrock request fields
+func bedrockMaxTokens(jsonData map[string]interface{}) (int, bool) {
+	if config, ok := jsonData["textGenerationConfig"].(map[string]interface{}); ok {
+		if n, ok := config["maxTokenCount"].(float64); ok {
+			return int(n), true
+		}
+	}
+	if config, ok := jsonData["inferenceConfig"].(map[string]interface{}); ok {
+		if n, ok := config["maxTokens"].(float64); ok {
+			return int(n), true
+		}
+	}
+	if n, ok := jsonData["max_gen_len"].(float64); ok {
+		return int(n), true
+	}
+	return 0, false
+}
+
+// bedrockResponseText returns the generated text from a Bedrock response:
+// Converse ("output.message.content"), Anthropic ("content"), Titan
+// ("results[].outputText") or Llama ("generation")
+func bedrockResponseText(jsonData map[string]interface{}) string {
+	var content []interface{}
+	if output, ok := jsonData["output"].(map[string]interface{}); ok {
+		if message, ok := output["message"].(map[string]interface{}); ok {
+			content, _ = message["content"].([]interface{})
+		}
+	} else {
+		content, _ = jsonData["content"].([]interface{})
+	}
+	for _, block := range content {
+		if blockMap, ok := block.(map[string]interface{}); ok {
+			if text, ok := blockMap["text"].(string); ok && text != "" {
+				return text
+			}
+		}
+	}
+
+	if results, ok := jsonData["results"].([]interface{}); ok && len(results) > 0 {
+		if result, ok := results[0].(map[string]interface{}); ok {
+			if text, ok := result["outputText"].(string); ok {
+				return text
+			}
+		}
+	}
+	if text, ok := jsonData["generation"].(string); ok {
+		return text
+	}
+	return ""
+}
+
+// bedrockUsage maps the Bedrock usage shapes that normalizeUsage doesn't
+// understand (Converse camelCase, Titan and Llama token counts) onto the
+// OpenAI-style usage keys. It returns nil for Anthropic-shaped bodies,
+// whose usage object normalizeUsage already handles.
+func bedrockUsage(jsonData map[string]interface{}) map[string]interface{} {
+	var prompt, completion float64
+	found := false
+
+	if usage, ok := jsonData["usage"].(map[string]interface{}); ok {
+		if v, ok := usage["inputTokens"].(float64); ok {
+			prompt, found = v, true
+		}
+		if v, ok := usage["outputTokens"].(float64); ok {
+			completion, found = v, true
+		}
+	}
+	if v, ok := jsonData["inputTextTokenCount"].(float64); ok {
+		prompt, found = v, true
+		if results, ok := jsonData["results"].([]interface{}); ok {
+			for _, result := range results {
+				if resultMap, ok := result.(map[string]interface{}); ok {
+					if n, ok := resultMap["tokenCount"].(float64); ok {
+						completion += n
+					}
+				}
+			}
+		}
+	}
+	if v, ok := jsonData["prompt_token_count"].(float64); ok {
+		prompt, found = v, true
+	}
+	if v, ok := jsonData["generation_token_count"].(float64); ok {
+		completion, found = v, true
+	}
+
+	if !found {
+		return nil
+	}
+	return map[string]interface{}{
+		"prompt_tokens":     prompt,
+		"completion_tokens": completion,
+		"total_tokens":      prompt + completion,
+	}
+}
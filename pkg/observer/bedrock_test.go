@@ -0,0 +1,111 @@
+package observer
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestDetectBedrockAndVertex(t *testing.T) {
+	registry := NewProviderRegistry("", testLogger())
+
+	tests := []struct {
+		host string
+		path string
+		want string
+	}{
+		{"bedrock-runtime.us-east-1.amazonaws.com", "/model/anthropic.claude-3-sonnet-20240229-v1:0/invoke", "Amazon Bedrock"},
+		{"bedrock-runtime.eu-west-3.amazonaws.com", "/model/amazon.titan-text-express-v1/invoke-with-response-stream", "Amazon Bedrock"},
+		{"bedrock-runtime.us-west-2.amazonaws.com", "/model/meta.llama3-70b-instruct-v1:0/converse", "Amazon Bedrock"},
+		{"bedrock-runtime.us-west-2.amazonaws.com", "/model/mistral.mistral-large-2402-v1:0/converse-stream", "Amazon Bedrock"},
+		{"bedrock-runtime.us-east-1.amazonaws.com", "/guardrails", ""},
+		{"bedrock.us-east-1.amazonaws.com", "/model/amazon.titan-text-express-v1/invoke", ""},
+		{"us-central1-aiplatform.googleapis.com", "/v1/projects/acme/locations/us-central1/publishers/google/models/gemini-1.5-pro:generateContent", "Vertex AI"},
+		{"europe-west4-aiplatform.googleapis.com", "/v1/projects/acme/locations/europe-west4/publishers/google/models/text-bison:predict", "Vertex AI"},
+		{"us-east5-aiplatform.googleapis.com", "/v1/projects/acme/locations/us-east5/publishers/anthropic/models/claude-3-5-sonnet:streamRawPredict", "Vertex AI"},
+		{"aiplatform.googleapis.com", "/v1/projects/acme/locations/global/publishers/google/models/gemini-2.0-flash:streamGenerateContent", "Vertex AI"},
+		{"us-central1-aiplatform.googleapis.com", "/v1/projects/acme/locations/us-central1/endpoints", ""},
+	}
+	for _, tt := range tests {
+		provider := registry.Match(tt.host, tt.path)
+		got := ""
+		if provider != nil {
+			got = provider.Name
+		}
+		if got != tt.want {
+			t.Errorf("Match(%q, %q) = %q, want %q", tt.host, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestBedrockSignal(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		body       string
+		response   string
+		model      string
+		preview    string
+		prompt     int
+		completion int
+	}{
+		{
+			name:       "titan invoke",
+			path:       "/model/amazon.titan-text-express-v1/invoke",
+			body:       `{"inputText": "Summarize the report", "textGenerationConfig": {"maxTokenCount": 200}}`,
+			response:   `{"inputTextTokenCount": 4, "results": [{"tokenCount": 9, "outputText": "The report says...", "completionReason": "FINISH"}]}`,
+			model:      "amazon.titan-text-express-v1",
+			preview:    "The report says...",
+			prompt:     4,
+			completion: 9,
+		},
+		{
+			name:       "converse",
+			path:       "/model/meta.llama3-70b-instruct-v1:0/converse",
+			body:       `{"messages": [{"role": "user", "content": [{"text": "Hello"}]}], "inferenceConfig": {"maxTokens": 50}}`,
+			response:   `{"output": {"message": {"role": "assistant", "content": [{"text": "Hi there"}]}}, "stopReason": "end_turn", "usage": {"inputTokens": 3, "outputTokens": 2, "totalTokens": 5}}`,
+			model:      "meta.llama3-70b-instruct-v1:0",
+			preview:    "Hi there",
+			prompt:     3,
+			completion: 2,
+		},
+		{
+			name:       "anthropic invoke",
+			path:       "/model/anthropic.claude-3-sonnet-20240229-v1:0/invoke",
+			body:       `{"anthropic_version": "bedrock-2023-05-31", "max_tokens": 100, "messages": [{"role": "user", "content": "Hello"}]}`,
+			response:   `{"type": "message", "content": [{"type": "text", "text": "Hello!"}], "usage": {"input_tokens": 8, "output_tokens": 4}}`,
+			model:      "anthropic.claude-3-sonnet-20240229-v1:0",
+			preview:    "Hello!",
+			prompt:     8,
+			completion: 4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "application/json", tt.response))
+			req := jsonRequest("http://bedrock-runtime.us-east-1.amazonaws.com"+tt.path, tt.body)
+			// SigV4 auth carries no bearer token; detection must not need one
+			req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20240101/us-east-1/bedrock/aws4_request, SignedHeaders=host;x-amz-date, Signature=abc")
+			req.Header.Set("X-Amz-Date", "20240101T000000Z")
+			signal := proxySignal(t, p, signalCh, req)
+
+			if got := signal.Metadata["provider"]; got != "Amazon Bedrock" {
+				t.Errorf("provider = %v, want Amazon Bedrock", got)
+			}
+			if got := signal.Metadata["model"]; got != tt.model {
+				t.Errorf("model = %v, want %s", got, tt.model)
+			}
+			if got := signal.Metadata["response_preview"]; got != tt.preview {
+				t.Errorf("response_preview = %q, want %q", got, tt.preview)
+			}
+			for key, want := range map[string]int{
+				"prompt_tokens":     tt.prompt,
+				"completion_tokens": tt.completion,
+				"total_tokens":      tt.prompt + tt.completion,
+			} {
+				if got := signal.Metadata[key]; got != want {
+					t.Errorf("%s = %v, want %d", key, got, want)
+				}
+			}
+		})
+	}
+}
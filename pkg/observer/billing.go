@@ -0,0 +1,206 @@
+package observer
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"axom-observer/pkg/models"
+)
+
+// Environment variables:
+//   AXOM_BILLING_PERIOD - Optional. Aggregation period for the /billing usage view: "hourly" or "daily". Default: daily
+
+// BillingAggregator accumulates per-customer/agent usage from the signal
+// stream into models.BillingMetrics for the current period. It implements
+// export.SignalExporter so it can be fed alongside the other exporters, and
+// http.Handler to serve the current totals as JSON.
+type BillingAggregator struct {
+	period string
+	length time.Duration
+	logger *log.Logger
+	now    func() time.Time
+
+	mu      sync.Mutex
+	metrics map[billingKey]*models.BillingMetrics
+}
+
+// billingKey identifies one customer/agent pair
+type billingKey struct {
+	customerID string
+	agentID    string
+}
+
+// NewBillingAggregator creates a billing aggregator. If period is empty it is
+// read from AXOM_BILLING_PERIOD; anything other than "hourly" means daily.
+func NewBillingAggregator(period string, logger *log.Logger) *BillingAggregator {
+	if period == "" {
+		period = os.Getenv("AXOM_BILLING_PERIOD")
+	}
+	length := 24 * time.Hour
+	if period == "hourly" {
+		length = time.Hour
+	} else {
+		period = "daily"
+	}
+	return &BillingAggregator{
+		period:  period,
+		length:  length,
+		logger:  logger,
+		now:     time.Now,
+		metrics: make(map[billingKey]*models.BillingMetrics),
+	}
+}
+
+// Export adds signals to the totals of their customer/agent. Signals from
+// before the current period of their customer/agent are ignored.
+func (b *BillingAggregator) Export(ctx context.Context, signals []models.Signal) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i := range signals {
+		b.add(&signals[i])
+	}
+	return nil
+}
+
+// add must be called with b.mu held
+func (b *BillingAggregator) add(signal *models.Signal) {
+	timestamp := signal.Timestamp
+	if timestamp.IsZero() {
+		timestamp = b.now()
+	}
+	start := timestamp.UTC().Truncate(b.length)
+
+	key := billingKey{customerID: signal.CustomerID, agentID: signal.AgentID}
+	m := b.metrics[key]
+	if m == nil || start.After(m.StartTime) {
+		// First signal, or the period rolled over
+		m = b.newMetrics(key, start)
+		b.metrics[key] = m
+	} else if start.Before(m.StartTime) {
+		return
+	}
+
+	m.TotalSignals++
+	m.TotalLatency += signal.LatencyMS
+	m.TotalCPUUsage += signal.CPUUsage
+	m.TotalMemoryUsage += signal.MemoryUsage
+	if signal.Operation != "" {
+		m.Operations[signal.Operation]++
+	}
+	if signal.Metadata != nil {
+		m.TotalTokens += usageInt(signal.Metadata, "total_tokens")
+		if model, ok := signal.Metadata["model"].(string); ok && model != "" {
+			m.Models[model]++
+		}
+		if cost, ok := signal.Metadata["estimated_cost_usd"].(float64); ok {
+			m.EstimatedCost += cost
+		}
+	}
+	if signal.IsTaskComplete() {
+		switch signal.Outcome {
+		case "success":
+			m.SuccessfulTasks++
+		case "failure":
+			m.FailedTasks++
+		}
+		if signal.TaskType != "" {
+			m.TaskTypes[signal.TaskType]++
+		}
+	}
+}
+
+// newMetrics returns empty totals for the period starting at start
+func (b *BillingAggregator) newMetrics(key billingKey, start time.Time) *models.BillingMetrics {
+	return &models.BillingMetrics{
+		CustomerID: key.customerID,
+		AgentID:    key.agentID,
+		Period:     b.period,
+		StartTime:  start,
+		EndTime:    start.Add(b.length),
+		Operations: make(map[string]int),
+		Models:     make(map[string]int),
+		TaskTypes:  make(map[string]int),
+		Currency:   "USD",
+		Metadata:   make(map[string]interface{}),
+	}
+}
+
+// Snapshot returns a copy of the totals for the current period, sorted by
+// customer and agent. Customer/agent pairs with no signals yet in the
+// current period are reported with zero totals.
+func (b *BillingAggregator) Snapshot() []models.BillingMetrics {
+	start := b.now().UTC().Truncate(b.length)
+
+	b.mu.Lock()
+	snapshot := make([]models.BillingMetrics, 0, len(b.metrics))
+	for key, m := range b.metrics {
+		if m.StartTime.Before(start) {
+			m = b.newMetrics(key, start)
+			b.metrics[key] = m
+		}
+		snapshot = append(snapshot, copyBillingMetrics(m))
+	}
+	b.mu.Unlock()
+
+	sort.Slice(snapshot, func(i, j int) bool {
+		if snapshot[i].CustomerID != snapshot[j].CustomerID {
+			return snapshot[i].CustomerID < snapshot[j].CustomerID
+		}
+		return snapshot[i].AgentID < snapshot[j].AgentID
+	})
+	return snapshot
+}
+
+// copyBillingMetrics copies m, including its maps
+func copyBillingMetrics(m *models.BillingMetrics) models.BillingMetrics {
+	c := *m
+	c.Operations = copyCounts(m.Operations)
+	c.Models = copyCounts(m.Models)
+	c.TaskTypes = copyCounts(m.TaskTypes)
+	c.Metadata = make(map[string]interface{}, len(m.Metadata))
+	for k, v := range m.Metadata {
+		c.Metadata[k] = v
+	}
+	return c
+}
+
+func copyCounts(counts map[string]int) map[string]int {
+	c := make(map[string]int, len(counts))
+	for k, v := range counts {
+		c[k] = v
+	}
+	return c
+}
+
+// ServeHTTP serves the current period's totals as a JSON array, optionally
+// filtered by the customer_id and agent_id query parameters
+func (b *BillingAggregator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	customerID := r.URL.Query().Get("customer_id")
+	agentID := r.URL.Query().Get("agent_id")
+
+	metrics := make([]models.BillingMetrics, 0)
+	for _, m := range b.Snapshot() {
+		if customerID != "" && m.CustomerID != customerID {
+			continue
+		}
+		if agentID != "" && m.AgentID != agentID {
+			continue
+		}
+		metrics = append(metrics, m)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(metrics); err != nil {
+		b.logger.Printf("Failed to write billing metrics: %v", err)
+	}
+}
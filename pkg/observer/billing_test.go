@@ -0,0 +1,110 @@
+package observer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"axom-observer/pkg/models"
+)
+
+func TestBillingAggregatorTotals(t *testing.T) {
+	now := time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC)
+	b := NewBillingAggregator("hourly", testLogger())
+	b.now = func() time.Time { return now }
+
+	chat := func(model string, tokens int, latency float64) models.Signal {
+		return models.Signal{
+			CustomerID: "acme", AgentID: "support", Timestamp: now, Operation: "chat_completion", LatencyMS: latency,
+			Metadata: map[string]interface{}{"model": model, "total_tokens": tokens, "estimated_cost_usd": 0.01},
+		}
+	}
+	b.Export(context.Background(), []models.Signal{
+		chat("gpt-4o", 100, 200),
+		chat("gpt-4o", 50, 300),
+		chat("claude-3-5-sonnet", 25, 100),
+		{CustomerID: "acme", AgentID: "support", Timestamp: now, Operation: "task_completion", TaskID: "task_1", TaskType: "code_review", Outcome: "success"},
+		{CustomerID: "acme", AgentID: "support", Timestamp: now, Operation: "task_completion", TaskID: "task_2", TaskType: "code_review", Outcome: "failure"},
+		{CustomerID: "acme", AgentID: "billing", Timestamp: now, Operation: "embedding", Metadata: map[string]interface{}{"total_tokens": 8}},
+		// From the previous hour: ignored
+		{CustomerID: "acme", AgentID: "support", Timestamp: now.Add(-time.Hour), Operation: "chat_completion"},
+	})
+
+	snapshot := b.Snapshot()
+	if len(snapshot) != 2 || snapshot[0].AgentID != "billing" || snapshot[1].AgentID != "support" {
+		t.Fatalf("snapshot = %+v, want billing and support sorted", snapshot)
+	}
+	m := snapshot[1]
+	if m.TotalSignals != 5 || m.TotalTokens != 175 || m.TotalLatency != 600 {
+		t.Errorf("totals = %d signals, %d tokens, %v ms, want 5, 175, 600", m.TotalSignals, m.TotalTokens, m.TotalLatency)
+	}
+	if m.SuccessfulTasks != 1 || m.FailedTasks != 1 || m.TaskTypes["code_review"] != 2 {
+		t.Errorf("tasks = %d ok, %d failed, %v, want 1, 1 and 2 code reviews", m.SuccessfulTasks, m.FailedTasks, m.TaskTypes)
+	}
+	if want := map[string]int{"gpt-4o": 2, "claude-3-5-sonnet": 1}; !reflect.DeepEqual(m.Models, want) {
+		t.Errorf("models = %v, want %v", m.Models, want)
+	}
+	if want := map[string]int{"chat_completion": 3, "task_completion": 2}; !reflect.DeepEqual(m.Operations, want) {
+		t.Errorf("operations = %v, want %v", m.Operations, want)
+	}
+	if m.EstimatedCost < 0.0299 || m.EstimatedCost > 0.0301 {
+		t.Errorf("estimated cost = %v, want 0.03", m.EstimatedCost)
+	}
+	if m.Period != "hourly" || !m.StartTime.Equal(now.Truncate(time.Hour)) || !m.EndTime.Equal(m.StartTime.Add(time.Hour)) {
+		t.Errorf("period = %s %v-%v, want the current hour", m.Period, m.StartTime, m.EndTime)
+	}
+
+	// Snapshots are copies
+	snapshot[1].Models["gpt-4o"] = 99
+	if b.Snapshot()[1].Models["gpt-4o"] != 2 {
+		t.Errorf("modifying a snapshot changed the aggregator")
+	}
+}
+
+func TestBillingAggregatorRollsOver(t *testing.T) {
+	now := time.Date(2024, 3, 1, 23, 0, 0, 0, time.UTC)
+	b := NewBillingAggregator("", testLogger())
+	b.now = func() time.Time { return now }
+	b.Export(context.Background(), []models.Signal{{CustomerID: "acme", AgentID: "support", Timestamp: now}})
+
+	now = now.Add(2 * time.Hour)
+	snapshot := b.Snapshot()
+	if len(snapshot) != 1 || snapshot[0].TotalSignals != 0 || snapshot[0].Period != "daily" {
+		t.Fatalf("snapshot after the day ended = %+v, want zero daily totals", snapshot)
+	}
+	b.Export(context.Background(), []models.Signal{{CustomerID: "acme", AgentID: "support", Timestamp: now}})
+	if got := b.Snapshot()[0]; got.TotalSignals != 1 || !got.StartTime.Equal(now.Truncate(24*time.Hour)) {
+		t.Errorf("new day = %d signals from %v, want 1 from the new day", got.TotalSignals, got.StartTime)
+	}
+}
+
+func TestBillingEndpoint(t *testing.T) {
+	b := NewBillingAggregator("daily", testLogger())
+	b.Export(context.Background(), []models.Signal{
+		{CustomerID: "acme", AgentID: "support", Operation: "chat_completion"},
+		{CustomerID: "globex", AgentID: "support", Operation: "chat_completion"},
+	})
+
+	rec := httptest.NewRecorder()
+	b.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/billing?customer_id=globex", nil))
+	var metrics []models.BillingMetrics
+	if err := json.Unmarshal(rec.Body.Bytes(), &metrics); err != nil {
+		t.Fatalf("decode /billing: %v", err)
+	}
+	if len(metrics) != 1 || metrics[0].CustomerID != "globex" || metrics[0].TotalSignals != 1 {
+		t.Errorf("/billing?customer_id=globex = %+v", metrics)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	rec = httptest.NewRecorder()
+	b.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/billing", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /billing = %d, want 405", rec.Code)
+	}
+}
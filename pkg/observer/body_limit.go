@@ -0,0 +1,197 @@
+package observer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Environment variables:
+//   AXOM_MAX_BODY_BYTES - Optional. Bytes of each request/response body buffered for parsing; the rest is streamed through unparsed. Default: 10485760 (10MiB)
+
+// bodyLimit is the maximum number of body bytes the proxies buffer
+type bodyLimit int64
+
+// bodyLimitFromEnv reads the body limit from AXOM_MAX_BODY_BYTES
+func bodyLimitFromEnv() bodyLimit {
+	if v := os.Getenv("AXOM_MAX_BODY_BYTES"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
+			return bodyLimit(n)
+		}
+	}
+	return 10 << 20
+}
+
+// streamHeadBytes is how much of a streamed upload is buffered for parsing
+const streamHeadBytes = 64 << 10
+
+// capturedBody is the buffered head of a body plus whatever was not read
+type capturedBody struct {
+	data      []byte      // at most the limit; what gets parsed
+	truncated bool        // the body was longer than the limit
+	rest      io.Reader   // unread remainder, only set when truncated
+	closer    io.Closer   // the original body
+	digest    *bodyDigest // size and hash of a streamed body, as it is read
+}
+
+// bodyDigest counts and hashes the bytes of a streamed body as they pass
+// through. It is written by whoever forwards the body and read once the
+// exchange is over, hence the lock.
+type bodyDigest struct {
+	mu   sync.Mutex
+	size int64
+	hash hash.Hash
+}
+
+func (d *bodyDigest) Write(b []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.size += int64(len(b))
+	d.hash.Write(b)
+	return len(b), nil
+}
+
+// isStreamedUpload reports whether a request body is audio, video or another
+// file upload, which is streamed to the upstream rather than buffered
+func isStreamedUpload(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(mediaType, "audio/") ||
+		strings.HasPrefix(mediaType, "video/") ||
+		mediaType == "application/octet-stream" ||
+		mediaType == "multipart/form-data"
+}
+
+// captureRequest captures a request body for parsing. Uploads (see
+// isStreamedUpload) only have a small head buffered; the rest streams to the
+// upstream while its size and SHA-256 are computed, see recordDigest.
+func (l bodyLimit) captureRequest(r *http.Request) (capturedBody, error) {
+	if !isStreamedUpload(r) {
+		return l.capture(r.Body)
+	}
+	headLimit := bodyLimit(streamHeadBytes)
+	if l < headLimit {
+		headLimit = l
+	}
+	captured, err := headLimit.capture(r.Body)
+	if captured.closer == nil {
+		return captured, err
+	}
+	captured.digest = &bodyDigest{hash: sha256.New()}
+	captured.digest.Write(captured.data)
+	if captured.truncated {
+		captured.rest = io.TeeReader(captured.rest, captured.digest)
+	}
+	return captured, err
+}
+
+// capture buffers up to the limit of body for parsing. Bodies over the limit
+// are marked truncated and the remainder is left unread so it can be
+// streamed to its destination without being held in memory. On a read
+// error the bytes read so far are returned along with the error.
+func (l bodyLimit) capture(body io.ReadCloser) (capturedBody, error) {
+	if body == nil || body == http.NoBody {
+		return capturedBody{}, nil
+	}
+	buf, err := io.ReadAll(io.LimitReader(body, int64(l)+1))
+	captured := capturedBody{data: buf, closer: body}
+	if int64(len(buf)) > int64(l) {
+		captured.data = buf[:l]
+		captured.truncated = true
+		captured.rest = io.MultiReader(bytes.NewReader(buf[l:]), body)
+	}
+	return captured, err
+}
+
+// reader returns the complete body: the buffered head followed by the
+// unread remainder
+func (c capturedBody) reader() io.Reader {
+	if !c.truncated {
+		return bytes.NewReader(c.data)
+	}
+	return io.MultiReader(bytes.NewReader(c.data), c.rest)
+}
+
+// readCloser returns the complete body, closing the original on Close
+func (c capturedBody) readCloser() io.ReadCloser {
+	if c.closer == nil {
+		return io.NopCloser(c.reader())
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{c.reader(), c.closer}
+}
+
+// length returns the full body length: the buffered size, or declared
+// (possibly -1 for unknown) when the body was truncated
+func (c capturedBody) length(declared int64) int64 {
+	if !c.truncated {
+		return int64(len(c.data))
+	}
+	return declared
+}
+
+// mark flags parsed request or response fields as coming from a truncated body
+func (c capturedBody) mark(fields map[string]interface{}) {
+	if c.truncated {
+		fields["body_truncated"] = true
+	}
+}
+
+// recordDigest records the size and SHA-256 of a streamed request body. It
+// is called once the exchange is over, when the body has been forwarded.
+func (c capturedBody) recordDigest(fields map[string]interface{}) {
+	if c.digest == nil {
+		return
+	}
+	c.digest.mu.Lock()
+	defer c.digest.mu.Unlock()
+	fields["request_body_bytes"] = c.digest.size
+	fields["request_body_sha256"] = hex.EncodeToString(c.digest.hash.Sum(nil))
+}
+
+// size returns the number of body bytes transferred: the streamed count for
+// uploads, the buffered size when the whole body was read, and otherwise the
+// declared Content-Length, falling back to the buffered size when unknown.
+// Bodies that were never captured report their declared length.
+func (c capturedBody) size(declared int64) int64 {
+	if c.digest != nil {
+		c.digest.mu.Lock()
+		defer c.digest.mu.Unlock()
+		return c.digest.size
+	}
+	if (c.truncated || c.closer == nil) && declared > 0 {
+		return declared
+	}
+	return int64(len(c.data))
+}
+
+// headerSize approximates the wire size of header fields as
+// "Name: value\r\n" lines
+func headerSize(header http.Header) int64 {
+	var n int64
+	for name, values := range header {
+		for _, value := range values {
+			n += int64(len(name) + len(value) + 4)
+		}
+	}
+	return n
+}
+
+// recordBytes records the body and header byte counts of a request or
+// response (prefix "request" or "response") for bandwidth billing
+func recordBytes(fields map[string]interface{}, prefix string, body capturedBody, declared int64, header http.Header) {
+	fields[prefix+"_bytes"] = body.size(declared)
+	fields[prefix+"_header_bytes"] = headerSize(header)
+}
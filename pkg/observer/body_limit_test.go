@@ -0,0 +1,132 @@
+package observer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// countingReader yields n zero bytes and counts how many were read
+type countingReader struct {
+	remaining int64
+	read      int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	if r.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	clear(p)
+	r.remaining -= int64(len(p))
+	r.read += int64(len(p))
+	return len(p), nil
+}
+
+func TestCaptureBuffersOnlyTheLimit(t *testing.T) {
+	const limit = 1024
+	body := &countingReader{remaining: 64 << 20}
+	captured, err := bodyLimit(limit).capture(io.NopCloser(body))
+	if err != nil {
+		t.Fatalf("capture: %v", err)
+	}
+
+	if len(captured.data) != limit || !captured.truncated {
+		t.Fatalf("captured %d bytes, truncated %v; want %d and truncated", len(captured.data), captured.truncated, limit)
+	}
+	// Reading stops one byte past the limit; the rest is left for the
+	// destination to stream
+	if body.read != limit+1 {
+		t.Errorf("capture read %d bytes of the body, want %d", body.read, limit+1)
+	}
+
+	n, err := io.Copy(io.Discard, captured.reader())
+	if err != nil || n != 64<<20 {
+		t.Errorf("complete body replays %d bytes (%v), want %d", n, err, 64<<20)
+	}
+}
+
+func TestOversizedBodiesForwardedVerbatim(t *testing.T) {
+	t.Setenv("AXOM_MAX_BODY_BYTES", "1024")
+
+	prompt := strings.Repeat("a", 256<<10)
+	reqBody := `{"model": "gpt-4", "messages": [{"role": "user", "content": "` + prompt + `"}]}`
+	respBody := `{"model": "gpt-4", "choices": [{"message": {"role": "assistant", "content": "` + strings.Repeat("b", 8<<10) + `"}}]}`
+
+	var forwarded []byte
+	upstream := ForwarderFunc(func(req *http.Request) (*http.Response, error) {
+		forwarded, _ = io.ReadAll(req.Body)
+		return cannedResponse(http.StatusOK, "application/json", respBody).Forward(req)
+	})
+	p, signalCh := newTestHTTPProxy(t, upstream)
+
+	rec := httptest.NewRecorder()
+	p.handleRequest(rec, chatRequest(reqBody))
+
+	if !bytes.Equal(forwarded, []byte(reqBody)) {
+		t.Errorf("upstream received %d request bytes, want the full %d", len(forwarded), len(reqBody))
+	}
+	if rec.Body.String() != respBody {
+		t.Errorf("client received %d response bytes, want the full %d", rec.Body.Len(), len(respBody))
+	}
+
+	signal := <-signalCh
+	if signal.Metadata["body_truncated"] != true {
+		t.Errorf("body_truncated = %v, want true", signal.Metadata["body_truncated"])
+	}
+	if preview, _ := signal.Metadata["prompt_preview"].(string); len(preview) > 1024 {
+		t.Errorf("prompt_preview holds %d bytes, more than the buffered limit", len(preview))
+	}
+}
+
+func TestBodyUnderLimitNotTruncated(t *testing.T) {
+	t.Setenv("AXOM_MAX_BODY_BYTES", "")
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "application/json", okChatResponse))
+	signal := proxySignal(t, p, signalCh, chatRequest(`{"model": "gpt-4", "messages": [{"role": "user", "content": "Hi"}]}`))
+
+	if _, ok := signal.Metadata["body_truncated"]; ok {
+		t.Errorf("small body flagged as truncated")
+	}
+	if got := signal.Metadata["response_preview"]; got != "Hi" {
+		t.Errorf("response_preview = %v, want the parsed response", got)
+	}
+}
+
+func TestAudioUploadStreamedWithSizeAndHash(t *testing.T) {
+	const size = 12 << 20
+	body := &countingReader{remaining: size}
+
+	var readBeforeForward int64
+	upstream := ForwarderFunc(func(req *http.Request) (*http.Response, error) {
+		readBeforeForward = body.read
+		if n, err := io.Copy(io.Discard, req.Body); err != nil || n != size {
+			t.Errorf("upstream received %d bytes (%v), want %d", n, err, size)
+		}
+		return cannedResponse(http.StatusOK, "application/json", `{"text": "hello"}`).Forward(req)
+	})
+	p, signalCh := newTestHTTPProxy(t, upstream)
+
+	req := httptest.NewRequest(http.MethodPost, "http://api.openai.com/v1/audio/transcriptions", io.NopCloser(body))
+	req.Header.Set("Content-Type", "audio/wav")
+	req.ContentLength = size
+	signal := proxySignal(t, p, signalCh, req)
+
+	// Only the head is buffered before the upstream starts reading
+	if readBeforeForward > streamHeadBytes+1 {
+		t.Errorf("proxy read %d bytes before forwarding, want at most %d", readBeforeForward, streamHeadBytes+1)
+	}
+	if got := signal.Metadata["request_body_bytes"]; got != int64(size) {
+		t.Errorf("request_body_bytes = %v, want %d", got, size)
+	}
+	want := sha256.Sum256(make([]byte, size))
+	if got := signal.Metadata["request_body_sha256"]; got != hex.EncodeToString(want[:]) {
+		t.Errorf("request_body_sha256 = %v, want the hash of the whole body", got)
+	}
+}
@@ -0,0 +1,140 @@
+package observer
+
+import (
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Environment variables:
+//   AXOM_BREAKER_THRESHOLD - Optional. Consecutive failed batches that open the backend circuit breaker; 0 disables it. Default: 5
+//   AXOM_BREAKER_COOLDOWN  - Optional. Seconds the breaker stays open before a probe batch is let through. Default: 30
+
+// breakerState is the state of a circuit breaker, exported as the
+// axom_backend_breaker_state gauge
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// circuitBreaker stops batch sends to a backend that keeps failing. After
+// threshold consecutive failures it opens and rejects sends for cooldown,
+// then half-opens to let a single probe through: success closes it, failure
+// opens it again.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// circuitBreakerFromEnv configures a breaker from AXOM_BREAKER_THRESHOLD and
+// AXOM_BREAKER_COOLDOWN. It returns nil when the breaker is disabled.
+func circuitBreakerFromEnv() *circuitBreaker {
+	threshold := 5
+	if v := os.Getenv("AXOM_BREAKER_THRESHOLD"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			threshold = n
+		}
+	}
+	if threshold == 0 {
+		return nil
+	}
+	cooldown := 30 * time.Second
+	if v := os.Getenv("AXOM_BREAKER_COOLDOWN"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cooldown = time.Duration(n) * time.Second
+		}
+	}
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+// allow reports whether a send may go ahead, and whether it is the probe of
+// a half-open breaker. A nil breaker allows everything.
+func (b *circuitBreaker) allow() (ok, probe bool) {
+	if b == nil {
+		return true, false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case breakerOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return false, false
+		}
+		b.setState(breakerHalfOpen)
+		fallthrough
+	case breakerHalfOpen:
+		if b.probing {
+			return false, false
+		}
+		b.probing = true
+		return true, true
+	}
+	return true, false
+}
+
+// success records a send the backend accepted (or rejected outright, which
+// still shows it is up) and closes the breaker
+func (b *circuitBreaker) success() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.probing = false
+	b.setState(breakerClosed)
+}
+
+// failure records a send that failed after its retries, opening the breaker
+// at the threshold or when a probe fails
+func (b *circuitBreaker) failure() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		b.probing = false
+		b.openedAt = b.now()
+		b.setState(breakerOpen)
+	}
+}
+
+// currentState returns the breaker state; a nil breaker is always closed
+func (b *circuitBreaker) currentState() breakerState {
+	if b == nil {
+		return breakerClosed
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// setState must be called with b.mu held
+func (b *circuitBreaker) setState(state breakerState) {
+	b.state = state
+	eachMetrics(func(m *observerMetrics) { m.backendBreakerState.Set(float64(state)) })
+}
@@ -0,0 +1,124 @@
+package observer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"axom-observer/pkg/models"
+)
+
+func TestCircuitBreakerTripsAndResets(t *testing.T) {
+	reg := newTestRegistry(t)
+	now := time.Now()
+	b := &circuitBreaker{threshold: 3, cooldown: time.Minute, now: func() time.Time { return now }}
+
+	for i := 0; i < 2; i++ {
+		b.failure()
+	}
+	if ok, _ := b.allow(); !ok || b.currentState() != breakerClosed {
+		t.Fatalf("breaker %s below the threshold, want closed", b.currentState())
+	}
+	b.failure()
+	if ok, _ := b.allow(); ok || b.currentState() != breakerOpen {
+		t.Fatalf("breaker %s at the threshold, want open and rejecting", b.currentState())
+	}
+	if got := metricValue(t, reg, "axom_backend_breaker_state", nil); got != float64(breakerOpen) {
+		t.Errorf("breaker gauge = %v, want %d (open)", got, breakerOpen)
+	}
+
+	// After the cooldown a single probe is let through
+	now = now.Add(time.Minute)
+	if ok, probe := b.allow(); !ok || !probe {
+		t.Fatalf("allow after cooldown = %v, %v, want a probe", ok, probe)
+	}
+	if ok, _ := b.allow(); ok {
+		t.Errorf("second send allowed while the probe is in flight")
+	}
+	if got := metricValue(t, reg, "axom_backend_breaker_state", nil); got != float64(breakerHalfOpen) {
+		t.Errorf("breaker gauge = %v, want %d (half-open)", got, breakerHalfOpen)
+	}
+
+	// A failed probe opens it again for another cooldown
+	b.failure()
+	if ok, _ := b.allow(); ok || b.currentState() != breakerOpen {
+		t.Fatalf("breaker %s after a failed probe, want open", b.currentState())
+	}
+
+	// A successful probe closes it
+	now = now.Add(time.Minute)
+	b.allow()
+	b.success()
+	if ok, probe := b.allow(); !ok || probe || b.currentState() != breakerClosed {
+		t.Errorf("breaker %s after a successful probe, want closed", b.currentState())
+	}
+	if got := metricValue(t, reg, "axom_backend_breaker_state", nil); got != float64(breakerClosed) {
+		t.Errorf("breaker gauge = %v, want %d (closed)", got, breakerClosed)
+	}
+}
+
+func TestCircuitBreakerFromEnv(t *testing.T) {
+	t.Setenv("AXOM_BREAKER_THRESHOLD", "0")
+	if b := circuitBreakerFromEnv(); b != nil {
+		t.Errorf("AXOM_BREAKER_THRESHOLD=0 gave a breaker, want disabled")
+	}
+	var disabled *circuitBreaker
+	if ok, _ := disabled.allow(); !ok {
+		t.Errorf("disabled breaker rejected a send")
+	}
+
+	t.Setenv("AXOM_BREAKER_THRESHOLD", "2")
+	t.Setenv("AXOM_BREAKER_COOLDOWN", "7")
+	if b := circuitBreakerFromEnv(); b.threshold != 2 || b.cooldown != 7*time.Second {
+		t.Errorf("breaker = %d, %v, want 2 and 7s", b.threshold, b.cooldown)
+	}
+}
+
+func TestSenderBreakerShortCircuitsSends(t *testing.T) {
+	t.Setenv("AXOM_STARTUP_GRACE", "0")
+	t.Setenv("AXOM_BREAKER_THRESHOLD", "2")
+	t.Setenv("AXOM_DLQ_PATH", filepath.Join(t.TempDir(), "dlq.ndjson"))
+	var requests atomic.Int32
+	var status atomic.Int32
+	status.Store(http.StatusServiceUnavailable)
+	backend := newTestBackend(t, func() int {
+		requests.Add(1)
+		return int(status.Load())
+	})
+	s := NewSignalSender("test-key", backend.URL, 10, time.Hour)
+
+	// Two failed batches trip the breaker; the retry backoff is cut short
+	for _, id := range []string{"sig-1", "sig-2"} {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		s.sendBatchWithRetry(ctx, []models.Signal{testSignal(id, nil)})
+		cancel()
+	}
+	if s.breaker.currentState() != breakerOpen {
+		t.Fatalf("breaker %s after 2 failed batches, want open", s.breaker.currentState())
+	}
+
+	// While open, batches go straight to the dead-letter file
+	sent := requests.Load()
+	err := s.sendBatchWithRetry(context.Background(), []models.Signal{testSignal("sig-3", nil)})
+	if !errors.Is(err, errBreakerOpen) || requests.Load() != sent {
+		t.Errorf("send with the breaker open = %v after %d requests, want errBreakerOpen and none", err, requests.Load()-sent)
+	}
+
+	// Once the backend is back, the probe closes the breaker and replays
+	// the dead-lettered batches
+	status.Store(http.StatusOK)
+	s.breaker.now = func() time.Time { return time.Now().Add(time.Hour) }
+	if err := s.sendBatchWithRetry(context.Background(), []models.Signal{testSignal("sig-4", nil)}); err != nil {
+		t.Fatalf("probe send: %v", err)
+	}
+	if s.breaker.currentState() != breakerClosed {
+		t.Errorf("breaker %s after a successful probe, want closed", s.breaker.currentState())
+	}
+	if got := waitForSignals(t, backend, 4, 5*time.Second); len(got) != 4 {
+		t.Errorf("backend received %d signals, want the probe and 3 replayed", len(got))
+	}
+}
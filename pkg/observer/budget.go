@@ -0,0 +1,270 @@
+package observer
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"axom-observer/pkg/models"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Environment variables:
+//   AXOM_BUDGETS_FILE - Optional. YAML file of per-agent usage budgets (see budgetsFile). Default: disabled
+
+const (
+	// budgetLatencyMinSamples is how many signals a window needs before its
+	// p95 latency is compared against the budget
+	budgetLatencyMinSamples = 20
+	// budgetLatencySamples bounds the latencies kept per agent; the p95 is
+	// taken over the most recent ones in the hour
+	budgetLatencySamples = 1000
+	// budgetMaxAgents bounds how many agents are tracked; once full, agents
+	// whose day has passed are dropped and further new agents not checked
+	budgetMaxAgents = 10000
+)
+
+// budgetsFile is the on-disk layout of the budgets. default applies to
+// agents without an entry of their own; unset or zero limits are not checked.
+//
+//	default:
+//	  max_tokens_per_hour: 200000
+//	agents:
+//	  support-bot:
+//	    max_tokens_per_hour: 50000
+//	    max_cost_per_day: 25
+//	    p95_latency_ms: 8000
+type budgetsFile struct {
+	Default *agentBudget           `yaml:"default"`
+	Agents  map[string]agentBudget `yaml:"agents"`
+}
+
+// agentBudget is the set of limits for one agent. Token and latency limits
+// apply per clock hour, the cost limit per UTC day.
+type agentBudget struct {
+	MaxTokensPerHour int     `yaml:"max_tokens_per_hour"`
+	MaxCostPerDay    float64 `yaml:"max_cost_per_day"`
+	P95LatencyMS     float64 `yaml:"p95_latency_ms"`
+}
+
+// BudgetMonitor tracks per-agent token, cost and latency usage in fixed time
+// windows and raises an alert on the signal that first takes an agent over
+// one of its budgets. Each budget alerts at most once per window.
+type BudgetMonitor struct {
+	defaultBudget *agentBudget
+	budgets       map[string]agentBudget
+
+	mu       sync.Mutex
+	usage    map[string]*agentUsage
+	sweptDay time.Time // day of the last sweep for expired agents
+	now      func() time.Time
+}
+
+// agentUsage is one agent's usage in the current hour and day. latencies
+// is a ring of the hour's most recent latencies, latencyOver how many of
+// them are above the agent's p95 budget.
+type agentUsage struct {
+	hourStart   time.Time
+	tokens      int
+	latencies   []float64
+	latencyNext int
+	latencyOver int
+	tokensHit   bool
+	latencyHit  bool
+
+	dayStart time.Time
+	cost     float64
+	costHit  bool
+}
+
+// NewBudgetMonitor loads budgets from path, or from AXOM_BUDGETS_FILE when
+// path is empty. It returns nil and no error when no file is configured.
+func NewBudgetMonitor(path string) (*BudgetMonitor, error) {
+	if path == "" {
+		path = os.Getenv("AXOM_BUDGETS_FILE")
+		if path == "" {
+			return nil, nil
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file budgetsFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse budgets: %w", err)
+	}
+	return &BudgetMonitor{
+		defaultBudget: file.Default,
+		budgets:       file.Agents,
+		usage:         make(map[string]*agentUsage),
+		now:           time.Now,
+	}, nil
+}
+
+// budgetFor returns the budget for agentID, falling back to the default
+func (b *BudgetMonitor) budgetFor(agentID string) (agentBudget, bool) {
+	if budget, ok := b.budgets[agentID]; ok {
+		return budget, true
+	}
+	if b.defaultBudget != nil {
+		return *b.defaultBudget, true
+	}
+	return agentBudget{}, false
+}
+
+// Apply adds signal to its agent's usage and appends an alert to it for
+// each budget it takes the agent over
+func (b *BudgetMonitor) Apply(signal *models.Signal) {
+	if b == nil {
+		return
+	}
+	budget, ok := b.budgetFor(signal.AgentID)
+	if !ok {
+		return
+	}
+
+	timestamp := signal.Timestamp
+	if timestamp.IsZero() {
+		timestamp = b.now()
+	}
+	hourStart := timestamp.UTC().Truncate(time.Hour)
+	dayStart := timestamp.UTC().Truncate(24 * time.Hour)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	u := b.usage[signal.AgentID]
+	if u == nil {
+		if len(b.usage) >= budgetMaxAgents && !b.sweep(dayStart) {
+			return
+		}
+		u = &agentUsage{}
+		b.usage[signal.AgentID] = u
+	}
+	if hourStart.After(u.hourStart) {
+		u.hourStart = hourStart
+		u.tokens = 0
+		u.latencies = u.latencies[:0]
+		u.latencyNext = 0
+		u.latencyOver = 0
+		u.tokensHit = false
+		u.latencyHit = false
+	}
+	if dayStart.After(u.dayStart) {
+		u.dayStart = dayStart
+		u.cost = 0
+		u.costHit = false
+	}
+
+	latencyAdded := false
+	if hourStart.Equal(u.hourStart) {
+		u.tokens += usageInt(signal.Metadata, "total_tokens")
+		if signal.LatencyMS > 0 && budget.P95LatencyMS > 0 {
+			u.addLatency(signal.LatencyMS, budget.P95LatencyMS)
+			latencyAdded = true
+		}
+	}
+	if dayStart.Equal(u.dayStart) {
+		if cost, ok := signal.Metadata["estimated_cost_usd"].(float64); ok {
+			u.cost += cost
+		}
+	}
+
+	if budget.MaxTokensPerHour > 0 && !u.tokensHit && u.tokens > budget.MaxTokensPerHour {
+		u.tokensHit = true
+		signal.Alerts = append(signal.Alerts, budgetAlert(signal.AgentID, "tokens_per_hour",
+			fmt.Sprintf("Agent %s used %d tokens this hour, over its budget of %d", signal.AgentID, u.tokens, budget.MaxTokensPerHour),
+			u.tokens, budget.MaxTokensPerHour, u.hourStart))
+	}
+	if budget.MaxCostPerDay > 0 && !u.costHit && u.cost > budget.MaxCostPerDay {
+		u.costHit = true
+		signal.Alerts = append(signal.Alerts, budgetAlert(signal.AgentID, "cost_per_day",
+			fmt.Sprintf("Agent %s spent an estimated $%.2f today, over its budget of $%.2f", signal.AgentID, u.cost, budget.MaxCostPerDay),
+			u.cost, budget.MaxCostPerDay, u.dayStart))
+	}
+	// The p95 can only cross the budget when a latency over it comes in, and
+	// is only sorted for once it has
+	if latencyAdded && !u.latencyHit && signal.LatencyMS > budget.P95LatencyMS && u.p95Over() {
+		if p95 := percentile(u.latencies, 0.95); p95 > budget.P95LatencyMS {
+			u.latencyHit = true
+			signal.Alerts = append(signal.Alerts, budgetAlert(signal.AgentID, "p95_latency_ms",
+				fmt.Sprintf("Agent %s p95 latency this hour is %.0fms, over its budget of %.0fms", signal.AgentID, p95, budget.P95LatencyMS),
+				p95, budget.P95LatencyMS, u.hourStart))
+		}
+	}
+}
+
+// sweep drops agents whose day window has passed, at most once per day,
+// and reports whether there is room for another agent
+func (b *BudgetMonitor) sweep(dayStart time.Time) bool {
+	if dayStart.After(b.sweptDay) {
+		b.sweptDay = dayStart
+		for agentID, u := range b.usage {
+			if u.dayStart.Before(dayStart) {
+				delete(b.usage, agentID)
+			}
+		}
+	}
+	return len(b.usage) < budgetMaxAgents
+}
+
+// addLatency records a latency, overwriting the oldest once the ring is
+// full, and keeps the count of those over limit
+func (u *agentUsage) addLatency(latency, limit float64) {
+	if len(u.latencies) < budgetLatencySamples {
+		u.latencies = append(u.latencies, latency)
+	} else {
+		if u.latencies[u.latencyNext] > limit {
+			u.latencyOver--
+		}
+		u.latencies[u.latencyNext] = latency
+		u.latencyNext = (u.latencyNext + 1) % budgetLatencySamples
+	}
+	if latency > limit {
+		u.latencyOver++
+	}
+}
+
+// p95Over reports whether the kept latencies have enough samples and their
+// nearest-rank p95 is over the limit: that is, whether fewer than
+// ceil(0.95·n) of them are within it
+func (u *agentUsage) p95Over() bool {
+	n := len(u.latencies)
+	if n < budgetLatencyMinSamples {
+		return false
+	}
+	return n-u.latencyOver < int(math.Ceil(0.95*float64(n)))
+}
+
+// budgetAlert builds the alert for an exceeded budget
+func budgetAlert(agentID, budget, message string, value, limit interface{}, windowStart time.Time) models.Alert {
+	return models.Alert{
+		Type:     "warning",
+		Message:  message,
+		Severity: "medium",
+		Metadata: map[string]interface{}{
+			"agent_id":     agentID,
+			"budget":       budget,
+			"value":        value,
+			"limit":        limit,
+			"window_start": windowStart,
+		},
+		Timestamp: time.Now(),
+	}
+}
+
+// percentile returns the p-th percentile (0-1) of values by nearest rank
+func percentile(values []float64, p float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
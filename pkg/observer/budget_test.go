@@ -0,0 +1,189 @@
+package observer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"axom-observer/pkg/models"
+)
+
+const sampleBudgets = `
+default:
+  max_tokens_per_hour: 1000
+agents:
+  support-bot:
+    max_tokens_per_hour: 100
+    max_cost_per_day: 1
+    p95_latency_ms: 500
+`
+
+// newTestBudgetMonitor loads budgets from a temporary file and fixes its
+// clock at the returned pointer's time
+func newTestBudgetMonitor(t *testing.T, budgets string) (*BudgetMonitor, *time.Time) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "budgets.yaml")
+	if err := os.WriteFile(path, []byte(budgets), 0o600); err != nil {
+		t.Fatalf("write budgets: %v", err)
+	}
+	b, err := NewBudgetMonitor(path)
+	if err != nil {
+		t.Fatalf("NewBudgetMonitor: %v", err)
+	}
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	b.now = func() time.Time { return now }
+	return b, &now
+}
+
+// budgetAlerts returns the budgets named by the alerts on signal
+func budgetAlerts(signal models.Signal) []string {
+	var names []string
+	for _, alert := range signal.Alerts {
+		names = append(names, alert.Metadata["budget"].(string))
+	}
+	return names
+}
+
+func TestBudgetTokensAlertOncePerWindow(t *testing.T) {
+	b, now := newTestBudgetMonitor(t, sampleBudgets)
+
+	alerts := 0
+	for i := 0; i < 10; i++ {
+		signal := models.Signal{AgentID: "support-bot", Timestamp: *now, Metadata: map[string]interface{}{"total_tokens": 30}}
+		b.Apply(&signal)
+		if i == 3 && len(signal.Alerts) != 1 {
+			t.Errorf("signal taking usage to 120 tokens has alerts %v, want tokens_per_hour", budgetAlerts(signal))
+		}
+		alerts += len(signal.Alerts)
+	}
+	if alerts != 1 {
+		t.Errorf("%d alerts in one hour, want exactly 1", alerts)
+	}
+
+	// The next hour starts a new window
+	*now = now.Add(time.Hour)
+	signal := models.Signal{AgentID: "support-bot", Timestamp: *now, Metadata: map[string]interface{}{"total_tokens": 150}}
+	b.Apply(&signal)
+	if got := budgetAlerts(signal); len(got) != 1 || got[0] != "tokens_per_hour" {
+		t.Errorf("alerts in the next hour = %v, want tokens_per_hour again", got)
+	}
+	if limit := signal.Alerts[0].Metadata["limit"]; limit != 100 {
+		t.Errorf("alert limit = %v, want 100", limit)
+	}
+}
+
+func TestBudgetCostAndLatency(t *testing.T) {
+	b, now := newTestBudgetMonitor(t, sampleBudgets)
+
+	var names []string
+	for i := 0; i < 30; i++ {
+		signal := models.Signal{
+			AgentID:   "support-bot",
+			Timestamp: now.Add(time.Duration(i) * time.Minute),
+			LatencyMS: 900,
+			Metadata:  map[string]interface{}{"estimated_cost_usd": 0.25},
+		}
+		b.Apply(&signal)
+		names = append(names, budgetAlerts(signal)...)
+	}
+	if len(names) != 2 || names[0] != "cost_per_day" || names[1] != "p95_latency_ms" {
+		t.Errorf("alerts = %v, want cost_per_day then p95_latency_ms once each", names)
+	}
+}
+
+func TestBudgetDefaultAndUnbudgetedAgents(t *testing.T) {
+	b, now := newTestBudgetMonitor(t, sampleBudgets)
+	signal := models.Signal{AgentID: "other-bot", Timestamp: *now, Metadata: map[string]interface{}{"total_tokens": 1500}}
+	b.Apply(&signal)
+	if got := budgetAlerts(signal); len(got) != 1 || got[0] != "tokens_per_hour" {
+		t.Errorf("agent on the default budget got alerts %v, want tokens_per_hour", got)
+	}
+
+	b, now = newTestBudgetMonitor(t, "agents:\n  support-bot:\n    max_tokens_per_hour: 100\n")
+	signal = models.Signal{AgentID: "other-bot", Timestamp: *now, Metadata: map[string]interface{}{"total_tokens": 1500}}
+	b.Apply(&signal)
+	if len(signal.Alerts) != 0 {
+		t.Errorf("agent without a budget got alerts %v", budgetAlerts(signal))
+	}
+
+	t.Setenv("AXOM_BUDGETS_FILE", "")
+	if monitor, err := NewBudgetMonitor(""); monitor != nil || err != nil {
+		t.Errorf("NewBudgetMonitor without a file = %v, %v, want nil", monitor, err)
+	}
+	var disabled *BudgetMonitor
+	disabled.Apply(&signal)
+}
+
+func TestBudgetLatencyNearestRank(t *testing.T) {
+	b, now := newTestBudgetMonitor(t, sampleBudgets)
+	apply := func(latency float64) []string {
+		signal := models.Signal{AgentID: "support-bot", Timestamp: *now, LatencyMS: latency, Metadata: map[string]interface{}{}}
+		b.Apply(&signal)
+		return budgetAlerts(signal)
+	}
+
+	// One slow request in 20 leaves the p95 (the 19th fastest) within budget
+	for i := 0; i < 19; i++ {
+		apply(100)
+	}
+	if got := apply(900); len(got) != 0 {
+		t.Errorf("1 slow request in 20 raised %v, want no alert", got)
+	}
+	// A second one takes it over
+	if got := apply(900); len(got) != 1 || got[0] != "p95_latency_ms" {
+		t.Errorf("2 slow requests in 21 raised %v, want p95_latency_ms", got)
+	}
+}
+
+func TestBudgetLatencyWindowBounded(t *testing.T) {
+	b, now := newTestBudgetMonitor(t, sampleBudgets)
+	alerts := 0
+	for i := 0; i < 3*budgetLatencySamples; i++ {
+		// Slow requests stop once the early ones have rotated out
+		latency := 100.0
+		if i >= 2*budgetLatencySamples && i%10 == 0 {
+			latency = 900
+		}
+		signal := models.Signal{AgentID: "support-bot", Timestamp: *now, LatencyMS: latency, Metadata: map[string]interface{}{}}
+		b.Apply(&signal)
+		alerts += len(signal.Alerts)
+	}
+	u := b.usage["support-bot"]
+	if len(u.latencies) != budgetLatencySamples {
+		t.Errorf("kept %d latencies, want at most %d", len(u.latencies), budgetLatencySamples)
+	}
+	if u.latencyOver != budgetLatencySamples/10 {
+		t.Errorf("latencyOver = %d, want %d of the kept latencies", u.latencyOver, budgetLatencySamples/10)
+	}
+	if alerts != 1 {
+		t.Errorf("%d alerts, want 1 once 10%% of the window is slow", alerts)
+	}
+}
+
+func TestBudgetAgentsBounded(t *testing.T) {
+	b, now := newTestBudgetMonitor(t, sampleBudgets)
+	for i := 0; i < budgetMaxAgents; i++ {
+		signal := models.Signal{AgentID: fmt.Sprintf("agent-%d", i), Timestamp: *now, Metadata: map[string]interface{}{}}
+		b.Apply(&signal)
+	}
+
+	// Further agents aren't tracked while every tracked one is current
+	signal := models.Signal{AgentID: "late-bot", Timestamp: *now, Metadata: map[string]interface{}{"total_tokens": 1500}}
+	b.Apply(&signal)
+	if len(b.usage) != budgetMaxAgents || len(signal.Alerts) != 0 {
+		t.Errorf("tracking %d agents with alerts %v, want the new agent skipped", len(b.usage), budgetAlerts(signal))
+	}
+
+	// The next day the stale agents make room
+	*now = now.Add(24 * time.Hour)
+	signal = models.Signal{AgentID: "late-bot", Timestamp: *now, Metadata: map[string]interface{}{"total_tokens": 1500}}
+	b.Apply(&signal)
+	if len(b.usage) != 1 {
+		t.Errorf("tracking %d agents the next day, want the expired ones dropped", len(b.usage))
+	}
+	if got := budgetAlerts(signal); len(got) != 1 || got[0] != "tokens_per_hour" {
+		t.Errorf("new agent after the sweep got alerts %v, want tokens_per_hour", got)
+	}
+}
@@ -0,0 +1,53 @@
+package observer
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSignalByteCounts(t *testing.T) {
+	reqBody := `{"model": "gpt-4", "messages": [{"role": "user", "content": "Hi"}]}`
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "application/json", okChatResponse))
+	req := chatRequest(reqBody)
+	req.Header.Set("X-Request-ID", "req-1")
+	signal := proxySignal(t, p, signalCh, req)
+
+	for key, want := range map[string]int64{
+		"request_bytes":         int64(len(reqBody)),
+		"response_bytes":        int64(len(okChatResponse)),
+		"request_header_bytes":  int64(len("Content-Type: application/json\r\nX-Request-Id: req-1\r\n")),
+		"response_header_bytes": int64(len("Content-Type: application/json\r\n")),
+	} {
+		if got := signal.Metadata[key]; got != want {
+			t.Errorf("%s = %v, want %d", key, got, want)
+		}
+	}
+}
+
+func TestByteCountsOfTruncatedBodies(t *testing.T) {
+	t.Setenv("AXOM_MAX_BODY_BYTES", "16")
+	respBody := strings.Repeat("x", 4096)
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "text/plain", respBody))
+
+	reqBody := `{"model": "gpt-4", "messages": [{"role": "user", "content": "` + strings.Repeat("a", 1000) + `"}]}`
+	req := httptest.NewRequest(http.MethodPost, "http://api.openai.com/v1/chat/completions", io.NopCloser(strings.NewReader(reqBody)))
+	req.ContentLength = int64(len(reqBody))
+	signal := proxySignal(t, p, signalCh, req)
+
+	if got := signal.Metadata["request_bytes"]; got != int64(len(reqBody)) {
+		t.Errorf("request_bytes = %v, want the declared %d", got, len(reqBody))
+	}
+	if got := signal.Metadata["response_bytes"]; got != int64(len(respBody)) {
+		t.Errorf("response_bytes = %v, want the declared %d", got, len(respBody))
+	}
+}
+
+func TestHeaderSize(t *testing.T) {
+	header := http.Header{"Content-Type": {"application/json"}, "Set-Cookie": {"a=1", "b=2"}}
+	if got, want := headerSize(header), int64(32+2*17); got != want {
+		t.Errorf("headerSize = %d, want %d", got, want)
+	}
+}
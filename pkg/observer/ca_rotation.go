@@ -0,0 +1,132 @@
+package observer
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Environment variables:
+//   AXOM_CA_RELOAD_INTERVAL - Optional. Seconds between checks of the CA files for rotation; 0 disables. Default: 30
+
+// caFileStamp identifies a version of the CA files on disk
+type caFileStamp struct {
+	certModTime time.Time
+	keyModTime  time.Time
+	certSize    int64
+	keySize     int64
+}
+
+// statCAFiles returns the current stamp of the CA cert and key files
+func statCAFiles(certPath, keyPath string) (caFileStamp, error) {
+	certInfo, err := os.Stat(certPath)
+	if err != nil {
+		return caFileStamp{}, err
+	}
+	keyInfo, err := os.Stat(keyPath)
+	if err != nil {
+		return caFileStamp{}, err
+	}
+	return caFileStamp{
+		certModTime: certInfo.ModTime(),
+		keyModTime:  keyInfo.ModTime(),
+		certSize:    certInfo.Size(),
+		keySize:     keyInfo.Size(),
+	}, nil
+}
+
+// loadCAKeyPair reads a PEM CA certificate and RSA key and checks they match
+func loadCAKeyPair(certPath, keyPath string) (*x509.Certificate, *rsa.PrivateKey, error) {
+	certPEM, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read CA cert: %w", err)
+	}
+	keyPEM, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read CA key: %w", err)
+	}
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse CA key pair: %w", err)
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse CA certificate: %w", err)
+	}
+	key, ok := pair.PrivateKey.(*rsa.PrivateKey)
+	if !ok {
+		return nil, nil, fmt.Errorf("CA key is %T, expected an RSA key", pair.PrivateKey)
+	}
+	return cert, key, nil
+}
+
+// caReloadIntervalFromEnv reads how often to check the CA files for changes
+func caReloadIntervalFromEnv() time.Duration {
+	if v := os.Getenv("AXOM_CA_RELOAD_INTERVAL"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			return time.Duration(n) * time.Second
+		}
+	}
+	return 30 * time.Second
+}
+
+// watchCA polls the CA files and reloads the CA when they change, until ctx
+// is cancelled. A change is only applied once both files parse as a
+// matching pair, so a rotation that writes the cert and key separately is
+// picked up on the next tick after both are in place.
+func (p *HTTPSProxy) watchCA(ctx context.Context, certPath, keyPath string, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	last, _ := statCAFiles(certPath, keyPath)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			stamp, err := statCAFiles(certPath, keyPath)
+			if err != nil || stamp == last {
+				continue
+			}
+			if err := p.reloadCA(certPath, keyPath); err != nil {
+				p.logger.Printf("⚠️ CA files changed but could not be loaded, keeping current CA: %v", err)
+				continue
+			}
+			last = stamp
+		}
+	}
+}
+
+// reloadCA loads the CA from disk and swaps it in, clearing cached leaf
+// certificates so new connections get leaves signed by the new CA.
+// Connections already established keep their old leaf.
+func (p *HTTPSProxy) reloadCA(certPath, keyPath string) error {
+	cert, key, err := loadCAKeyPair(certPath, keyPath)
+	if err != nil {
+		return err
+	}
+
+	p.certMutex.Lock()
+	p.caCert = cert
+	p.caKey = key
+	p.certMutex.Unlock()
+
+	p.certs.clear()
+	p.logger.Printf("🔄 CA rotated: %s (expires %s)", cert.Subject.CommonName, cert.NotAfter.Format(time.RFC3339))
+	return nil
+}
+
+// currentCA returns the CA used to sign new leaf certificates
+func (p *HTTPSProxy) currentCA() (*x509.Certificate, *rsa.PrivateKey) {
+	p.certMutex.RLock()
+	defer p.certMutex.RUnlock()
+	return p.caCert, p.caKey
+}
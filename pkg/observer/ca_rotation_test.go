@@ -0,0 +1,143 @@
+package observer
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"axom-observer/pkg/models"
+)
+
+// writeTestCA generates a CA named name and writes it as PEM to certPath and
+// keyPath
+func writeTestCA(t testing.TB, certPath, keyPath, name string) *x509.Certificate {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate CA key: %v", err)
+	}
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create CA certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse CA certificate: %v", err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := os.WriteFile(certPath, certPEM, 0o644); err != nil {
+		t.Fatalf("write CA cert: %v", err)
+	}
+	if err := os.WriteFile(keyPath, keyPEM, 0o600); err != nil {
+		t.Fatalf("write CA key: %v", err)
+	}
+	return cert
+}
+
+// chainsTo reports whether leaf verifies for hostname against ca alone
+func chainsTo(leaf *x509.Certificate, ca *x509.Certificate, hostname string) bool {
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	_, err := leaf.Verify(x509.VerifyOptions{Roots: roots, DNSName: hostname})
+	return err == nil
+}
+
+func TestCARotationSignsNewLeavesWithNewCA(t *testing.T) {
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "ca.crt")
+	keyPath := filepath.Join(dir, "ca.key")
+	const host = "api.openai.com"
+
+	oldCA := writeTestCA(t, certPath, keyPath, "old CA")
+	p := NewHTTPSProxy("0", make(chan models.Signal, 1), testLogger(), "test-customer", "test-agent")
+	if err := p.reloadCA(certPath, keyPath); err != nil {
+		t.Fatalf("load CA: %v", err)
+	}
+	oldLeaf, err := p.certs.getOrCreate(host, p.generateCert)
+	if err != nil {
+		t.Fatalf("generate leaf: %v", err)
+	}
+	if !chainsTo(oldLeaf.Leaf, oldCA, host) {
+		t.Fatalf("leaf does not chain to the loaded CA")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.watchCA(ctx, certPath, keyPath, 10*time.Millisecond)
+
+	// Make sure the replacement's modification time differs from the
+	// stamp watchCA took at startup
+	time.Sleep(20 * time.Millisecond)
+	newCA := writeTestCA(t, certPath, keyPath, "new CA")
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(certPath, future, future)
+	os.Chtimes(keyPath, future, future)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if caCert, _ := p.currentCA(); caCert.Equal(newCA) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("CA not reloaded after its files were replaced")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	newLeaf, err := p.certs.getOrCreate(host, p.generateCert)
+	if err != nil {
+		t.Fatalf("generate leaf after rotation: %v", err)
+	}
+	if newLeaf == oldLeaf {
+		t.Fatalf("leaf cache not cleared on rotation")
+	}
+	if !chainsTo(newLeaf.Leaf, newCA, host) {
+		t.Errorf("leaf generated after rotation does not chain to the new CA")
+	}
+	if chainsTo(newLeaf.Leaf, oldCA, host) {
+		t.Errorf("leaf generated after rotation still chains to the old CA")
+	}
+	// Leaves handed out before the rotation stay valid for their connections
+	if !chainsTo(oldLeaf.Leaf, oldCA, host) {
+		t.Errorf("leaf issued before rotation no longer chains to the old CA")
+	}
+}
+
+func TestReloadCAKeepsCurrentCAOnInvalidFiles(t *testing.T) {
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "ca.crt")
+	keyPath := filepath.Join(dir, "ca.key")
+
+	ca := writeTestCA(t, certPath, keyPath, "current CA")
+	p := NewHTTPSProxy("0", make(chan models.Signal, 1), testLogger(), "test-customer", "test-agent")
+	if err := p.reloadCA(certPath, keyPath); err != nil {
+		t.Fatalf("load CA: %v", err)
+	}
+
+	// A cert without its matching key is rejected
+	writeTestCA(t, certPath, filepath.Join(dir, "other.key"), "half-written CA")
+	if err := p.reloadCA(certPath, keyPath); err == nil {
+		t.Fatalf("reloadCA accepted a cert and key that don't match")
+	}
+	if caCert, _ := p.currentCA(); !caCert.Equal(ca) {
+		t.Errorf("CA replaced by a failed reload")
+	}
+}
@@ -0,0 +1,142 @@
+package observer
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// caSelfCheckHost is the name the self-check's leaf certificate is issued for
+const caSelfCheckHost = "axom-ca-selfcheck.local"
+
+// caSelfCheckTimeout bounds the self-check's loopback handshake
+const caSelfCheckTimeout = 5 * time.Second
+
+// CACheck is the result of the MITM CA self-check
+type CACheck struct {
+	// Err is why a client trusting CertPath could not complete a handshake
+	// with a leaf certificate the proxy issues; nil when it could
+	Err error
+	// CertPath is the CA certificate file clients need to trust
+	CertPath string
+	// Fingerprint is the SHA-256 fingerprint of the CA certificate in use
+	Fingerprint string
+	// SystemTrusted is whether this host's system roots already trust the CA
+	SystemTrusted bool
+}
+
+// caCheckState holds a proxy's latest CA self-check result
+type caCheckState struct {
+	mu     sync.Mutex
+	result *CACheck
+}
+
+func (s *caCheckState) set(result CACheck) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.result = &result
+}
+
+// get returns the latest result, or nil if no check has run
+func (s *caCheckState) get() *CACheck {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.result
+}
+
+// checkMITMCA issues a leaf certificate with issue and performs a loopback
+// TLS handshake against it as a client that trusts only the CA certificate
+// at certPath would. A failure means every intercepted connection would fail
+// the same way: the file clients trust doesn't match the CA in use, the CA
+// has expired, or it can't sign server certificates.
+func checkMITMCA(certPath string, caCert *x509.Certificate, issue func(host string) (*tls.Certificate, error)) CACheck {
+	result := CACheck{CertPath: certPath}
+	if caCert == nil {
+		result.Err = errors.New("no CA certificate loaded")
+		return result
+	}
+	result.Fingerprint = certFingerprint(caCert)
+	if _, err := caCert.Verify(x509.VerifyOptions{}); err == nil {
+		result.SystemTrusted = true
+	}
+
+	pemData, err := os.ReadFile(certPath)
+	if err != nil {
+		result.Err = fmt.Errorf("failed to read CA certificate: %w", err)
+		return result
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(pemData) {
+		result.Err = fmt.Errorf("%s contains no PEM certificates", certPath)
+		return result
+	}
+	leaf, err := issue(caSelfCheckHost)
+	if err != nil {
+		result.Err = fmt.Errorf("failed to issue a leaf certificate: %w", err)
+		return result
+	}
+	result.Err = loopbackHandshake(leaf, roots)
+	return result
+}
+
+// loopbackHandshake serves leaf on a loopback listener and handshakes with
+// it as a client verifying against roots
+func loopbackHandshake(leaf *tls.Certificate, roots *x509.CertPool) error {
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{*leaf}})
+	if err != nil {
+		return fmt.Errorf("failed to listen for the self-check: %w", err)
+	}
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(caSelfCheckTimeout))
+		conn.(*tls.Conn).Handshake()
+	}()
+
+	dialer := &net.Dialer{Timeout: caSelfCheckTimeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", listener.Addr().String(), &tls.Config{
+		ServerName: caSelfCheckHost,
+		RootCAs:    roots,
+	})
+	if err != nil {
+		return fmt.Errorf("TLS handshake failed: %w", err)
+	}
+	return conn.Close()
+}
+
+// certFingerprint returns the colon-separated SHA-256 fingerprint of cert
+func certFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	hex := make([]string, len(sum))
+	for i, b := range sum {
+		hex[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(hex, ":")
+}
+
+// logCACheck logs the self-check result with what clients need to trust and,
+// on failure, how to fix it
+func logCACheck(logger *log.Logger, check CACheck) {
+	if check.Err != nil {
+		logger.Printf("❌ MITM CA self-check failed: %v", check.Err)
+		logger.Printf("❌ Intercepted HTTPS connections will fail. Make sure %s and its key are a matching, unexpired CA pair (delete both to regenerate), then have clients trust %s", check.CertPath, check.CertPath)
+		return
+	}
+	logger.Printf("🔐 MITM CA self-check passed. Clients must trust %s (SHA-256 %s)", check.CertPath, check.Fingerprint)
+	if !check.SystemTrusted {
+		logger.Printf("ℹ️ The CA is not in this host's system trust store; install %s in each client's trust store or point it at the file (e.g. SSL_CERT_FILE, REQUESTS_CA_BUNDLE, NODE_EXTRA_CA_CERTS)", check.CertPath)
+	}
+}
@@ -0,0 +1,91 @@
+package observer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"axom-observer/pkg/models"
+)
+
+// caCheckProxy returns an HTTPSProxy using a CA written to dir
+func caCheckProxy(t *testing.T, dir string) (*HTTPSProxy, string) {
+	t.Helper()
+	certPath, keyPath := filepath.Join(dir, "ca.crt"), filepath.Join(dir, "ca.key")
+	writeTestCA(t, certPath, keyPath, "test proxy CA")
+	p := NewHTTPSProxy("0", make(chan models.Signal, 1), testLogger(), "test-customer", "test-agent")
+	if err := p.reloadCA(certPath, keyPath); err != nil {
+		t.Fatalf("load CA: %v", err)
+	}
+	return p, certPath
+}
+
+func TestCASelfCheckValid(t *testing.T) {
+	p, certPath := caCheckProxy(t, t.TempDir())
+	caCert, _ := p.currentCA()
+
+	check := checkMITMCA(certPath, caCert, p.generateCert)
+	if check.Err != nil {
+		t.Fatalf("self-check of a valid CA failed: %v", check.Err)
+	}
+	if check.CertPath != certPath || check.Fingerprint != certFingerprint(caCert) {
+		t.Errorf("check = %+v, want the CA's path and fingerprint", check)
+	}
+	if len(check.Fingerprint) != 32*3-1 {
+		t.Errorf("fingerprint %q, want 32 colon-separated bytes", check.Fingerprint)
+	}
+	if check.SystemTrusted {
+		t.Errorf("a freshly generated CA is reported system-trusted")
+	}
+}
+
+func TestCASelfCheckInvalid(t *testing.T) {
+	dir := t.TempDir()
+	p, certPath := caCheckProxy(t, dir)
+	caCert, _ := p.currentCA()
+
+	// The file clients trust no longer matches the CA the proxy signs with
+	otherCert := filepath.Join(dir, "other.crt")
+	writeTestCA(t, otherCert, filepath.Join(dir, "other.key"), "stale CA")
+	check := checkMITMCA(otherCert, caCert, p.generateCert)
+	if check.Err == nil || !strings.Contains(check.Err.Error(), "handshake failed") {
+		t.Errorf("self-check against a mismatched CA file = %v, want a failed handshake", check.Err)
+	}
+
+	if check := checkMITMCA(filepath.Join(dir, "missing.crt"), caCert, p.generateCert); check.Err == nil {
+		t.Errorf("self-check with a missing CA file passed")
+	}
+	if check := checkMITMCA(certPath, nil, p.generateCert); check.Err == nil {
+		t.Errorf("self-check without a CA loaded passed")
+	}
+}
+
+func TestHealthzReportsCACheck(t *testing.T) {
+	dir := t.TempDir()
+	p, _ := caCheckProxy(t, dir)
+	caCert, _ := p.currentCA()
+	p.caCheck.set(checkMITMCA(filepath.Join(dir, "missing.crt"), caCert, p.generateCert))
+
+	monitor := NewAITrafficMonitor(make(chan models.Signal, 1), testLogger(), "test-customer", "test-agent")
+	monitor.httpStarted.Store(true)
+	monitor.productionStarted.Store(true)
+	monitor.httpsProxy = p
+	h := NewHealthServer("127.0.0.1:0", testLogger(), monitor, nil)
+
+	rec := httptest.NewRecorder()
+	h.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	// A failed self-check is reported but doesn't fail liveness
+	if rec.Code != http.StatusOK {
+		t.Fatalf("/healthz = %d, want 200", rec.Code)
+	}
+	var status healthStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatalf("decode /healthz: %v", err)
+	}
+	if status.CA == nil || status.CA.OK || status.CA.Error == "" || status.CA.Fingerprint != certFingerprint(caCert) {
+		t.Errorf("/healthz mitm_ca = %+v, want the failure with the CA fingerprint", status.CA)
+	}
+}
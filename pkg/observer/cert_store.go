@@ -0,0 +1,150 @@
+package observer
+
+import (
+	"crypto/tls"
+	"net"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Environment variables:
+//   AXOM_MAX_CERT_GENERATIONS - Optional. Maximum number of leaf certificates generated concurrently. Default: number of CPUs
+//   AXOM_MAX_CACHED_CERTS     - Optional. Maximum number of leaf certificates kept in the cache. Default: 10000
+
+// leafRenewBefore is how long before expiry a cached leaf is regenerated
+const leafRenewBefore = 24 * time.Hour
+
+// leafCertStore caches leaf certificates by hostname. Concurrent requests for
+// the same uncached host share a single generation, and the number of
+// generations running at once is bounded so a burst of new SNIs doesn't spike
+// CPU with parallel RSA key generation.
+type leafCertStore struct {
+	mu       sync.Mutex
+	certs    map[string]*tls.Certificate
+	inflight map[string]*certCall
+	sem      chan struct{}
+	maxCerts int
+	epoch    uint64 // incremented by clear so in-flight generations aren't cached
+}
+
+// certCall is an in-progress generation that other callers can wait on
+type certCall struct {
+	done chan struct{}
+	cert *tls.Certificate
+	err  error
+}
+
+// newLeafCertStore creates a cert store. If maxConcurrent is <= 0 the limit
+// is read from AXOM_MAX_CERT_GENERATIONS.
+func newLeafCertStore(maxConcurrent int) *leafCertStore {
+	if maxConcurrent <= 0 {
+		if v := os.Getenv("AXOM_MAX_CERT_GENERATIONS"); v != "" {
+			if n, err := strconv.Atoi(v); err == nil && n > 0 {
+				maxConcurrent = n
+			}
+		}
+		if maxConcurrent <= 0 {
+			maxConcurrent = runtime.NumCPU()
+		}
+	}
+	maxCerts := 10000
+	if v := os.Getenv("AXOM_MAX_CACHED_CERTS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			maxCerts = n
+		}
+	}
+	return &leafCertStore{
+		certs:    make(map[string]*tls.Certificate),
+		inflight: make(map[string]*certCall),
+		sem:      make(chan struct{}, maxConcurrent),
+		maxCerts: maxCerts,
+	}
+}
+
+// getOrCreate returns the cached certificate for hostname, generating it with
+// generate if needed or if the cached one is close to expiry. Any port
+// suffix on hostname is ignored.
+func (s *leafCertStore) getOrCreate(hostname string, generate func(hostname string) (*tls.Certificate, error)) (*tls.Certificate, error) {
+	if host, _, err := net.SplitHostPort(hostname); err == nil {
+		hostname = host
+	}
+
+	s.mu.Lock()
+	if cert, ok := s.certs[hostname]; ok && !leafExpiring(cert, time.Now()) {
+		s.mu.Unlock()
+		return cert, nil
+	}
+	if call, ok := s.inflight[hostname]; ok {
+		s.mu.Unlock()
+		<-call.done
+		return call.cert, call.err
+	}
+	call := &certCall{done: make(chan struct{})}
+	s.inflight[hostname] = call
+	epoch := s.epoch
+	s.mu.Unlock()
+
+	s.sem <- struct{}{}
+	call.cert, call.err = generate(hostname)
+	<-s.sem
+
+	s.mu.Lock()
+	if call.err == nil && epoch == s.epoch {
+		if _, ok := s.certs[hostname]; !ok && len(s.certs) >= s.maxCerts {
+			s.evictLocked(time.Now())
+		}
+		s.certs[hostname] = call.cert
+	}
+	if s.inflight[hostname] == call {
+		delete(s.inflight, hostname)
+	}
+	s.mu.Unlock()
+	close(call.done)
+
+	return call.cert, call.err
+}
+
+// clear drops all cached certificates. Generations already in flight still
+// return to their callers but are not cached, since they may be signed by a
+// CA that has since been replaced.
+func (s *leafCertStore) clear() {
+	s.mu.Lock()
+	s.certs = make(map[string]*tls.Certificate)
+	s.inflight = make(map[string]*certCall)
+	s.epoch++
+	s.mu.Unlock()
+}
+
+// evictLocked makes room in a full cache by dropping expiring certificates,
+// or the soonest-expiring one if none are. s.mu must be held.
+func (s *leafCertStore) evictLocked(now time.Time) {
+	var soonest string
+	var soonestExpiry time.Time
+	for host, cert := range s.certs {
+		if leafExpiring(cert, now) {
+			delete(s.certs, host)
+			continue
+		}
+		if cert.Leaf != nil && (soonest == "" || cert.Leaf.NotAfter.Before(soonestExpiry)) {
+			soonest, soonestExpiry = host, cert.Leaf.NotAfter
+		}
+	}
+	if len(s.certs) >= s.maxCerts {
+		if soonest == "" {
+			for host := range s.certs {
+				soonest = host
+				break
+			}
+		}
+		delete(s.certs, soonest)
+	}
+}
+
+// leafExpiring reports whether cert expires within leafRenewBefore of now.
+// Certificates without a parsed leaf are treated as current.
+func leafExpiring(cert *tls.Certificate, now time.Time) bool {
+	return cert.Leaf != nil && now.Add(leafRenewBefore).After(cert.Leaf.NotAfter)
+}
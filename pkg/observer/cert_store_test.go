@@ -0,0 +1,177 @@
+package observer
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"axom-observer/pkg/models"
+)
+
+func TestLeafCertStoreGeneratesOncePerHost(t *testing.T) {
+	store := newLeafCertStore(4)
+	var generated atomic.Int32
+	release := make(chan struct{})
+	generate := func(hostname string) (*tls.Certificate, error) {
+		generated.Add(1)
+		<-release
+		return &tls.Certificate{}, nil
+	}
+
+	const callers = 50
+	certs := make([]*tls.Certificate, callers)
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			cert, err := store.getOrCreate("api.openai.com:443", generate)
+			if err != nil {
+				t.Errorf("getOrCreate: %v", err)
+			}
+			certs[i] = cert
+		}(i)
+	}
+	// Let every caller reach the in-flight generation before it finishes
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := generated.Load(); n != 1 {
+		t.Errorf("generated %d certificates for one host, want 1", n)
+	}
+	for i, cert := range certs {
+		if cert != certs[0] {
+			t.Fatalf("caller %d got a different certificate", i)
+		}
+	}
+}
+
+func TestLeafCertStoreBoundsConcurrentGenerations(t *testing.T) {
+	const limit = 2
+	store := newLeafCertStore(limit)
+	var running, peak atomic.Int32
+	generate := func(hostname string) (*tls.Certificate, error) {
+		n := running.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		running.Add(-1)
+		return &tls.Certificate{}, nil
+	}
+
+	var wg sync.WaitGroup
+	for _, host := range []string{"a.example.com", "b.example.com", "c.example.com", "d.example.com", "e.example.com", "f.example.com"} {
+		wg.Add(1)
+		go func(host string) {
+			defer wg.Done()
+			store.getOrCreate(host, generate)
+		}(host)
+	}
+	wg.Wait()
+
+	if p := peak.Load(); p > limit {
+		t.Errorf("%d generations ran at once, want at most %d", p, limit)
+	}
+}
+
+// leafExpiringAt returns a certificate whose leaf expires at notAfter
+func leafExpiringAt(notAfter time.Time) *tls.Certificate {
+	return &tls.Certificate{Leaf: &x509.Certificate{NotAfter: notAfter}}
+}
+
+func TestLeafCertStoreRenewsExpiringLeaves(t *testing.T) {
+	store := newLeafCertStore(1)
+	var generated atomic.Int32
+	expiry := time.Now().Add(time.Hour) // within leafRenewBefore
+	generate := func(hostname string) (*tls.Certificate, error) {
+		generated.Add(1)
+		return leafExpiringAt(expiry), nil
+	}
+
+	store.getOrCreate("api.openai.com", generate)
+	store.getOrCreate("api.openai.com", generate)
+	if n := generated.Load(); n != 2 {
+		t.Errorf("generated %d certificates, want a renewal of the expiring leaf", n)
+	}
+
+	expiry = time.Now().AddDate(1, 0, 0)
+	store.getOrCreate("api.openai.com", generate)
+	store.getOrCreate("api.openai.com", generate)
+	if n := generated.Load(); n != 3 {
+		t.Errorf("generated %d certificates, want the current leaf reused", n)
+	}
+}
+
+func TestLeafCertStoreEvictsSoonestExpiring(t *testing.T) {
+	t.Setenv("AXOM_MAX_CACHED_CERTS", "2")
+	store := newLeafCertStore(1)
+	expiries := map[string]time.Time{
+		"a.example.com": time.Now().AddDate(0, 6, 0),
+		"b.example.com": time.Now().AddDate(0, 1, 0),
+		"c.example.com": time.Now().AddDate(1, 0, 0),
+	}
+	generate := func(hostname string) (*tls.Certificate, error) {
+		return leafExpiringAt(expiries[hostname]), nil
+	}
+
+	for _, host := range []string{"a.example.com", "b.example.com", "c.example.com"} {
+		store.getOrCreate(host, generate)
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if len(store.certs) != 2 {
+		t.Fatalf("cache holds %d certificates, want 2", len(store.certs))
+	}
+	if _, ok := store.certs["b.example.com"]; ok {
+		t.Errorf("soonest-expiring certificate was not evicted")
+	}
+}
+
+// benchmarkHTTPSProxy returns an HTTPS proxy with a freshly generated CA
+func benchmarkHTTPSProxy(b *testing.B) *HTTPSProxy {
+	dir := b.TempDir()
+	certPath := filepath.Join(dir, "ca.crt")
+	keyPath := filepath.Join(dir, "ca.key")
+	writeTestCA(b, certPath, keyPath, "benchmark CA")
+	p := NewHTTPSProxy("0", make(chan models.Signal, 1), testLogger(), "test-customer", "test-agent")
+	if err := p.reloadCA(certPath, keyPath); err != nil {
+		b.Fatalf("load CA: %v", err)
+	}
+	return p
+}
+
+// BenchmarkLeafCertUncached is the cost of a CONNECT without the cache: a
+// new key and leaf for every connection
+func BenchmarkLeafCertUncached(b *testing.B) {
+	p := benchmarkHTTPSProxy(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := p.generateCert("api.openai.com"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkLeafCertCached is the cost of a CONNECT to a host seen before
+func BenchmarkLeafCertCached(b *testing.B) {
+	p := benchmarkHTTPSProxy(b)
+	if _, err := p.certs.getOrCreate("api.openai.com", p.generateCert); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := p.certs.getOrCreate("api.openai.com:443", p.generateCert); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package observer
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestDetectOpenAICompatibleProviders(t *testing.T) {
+	p, _ := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "", ""))
+
+	tests := []struct {
+		host string
+		path string
+		want string
+	}{
+		{"api.mistral.ai", "/v1/chat/completions", "Mistral"},
+		{"api.mistral.ai", "/v1/fim/completions", "Mistral"},
+		{"api.deepseek.com", "/chat/completions", "DeepSeek"},
+		{"api.deepseek.com", "/v1/chat/completions", "DeepSeek"},
+		{"api.deepseek.com", "/beta/completions", "DeepSeek"},
+		{"api.x.ai", "/v1/chat/completions", "xAI"},
+		{"api.x.ai", "/v1/images/generations", "xAI"},
+		{"console.mistral.ai", "/v1/chat/completions", ""},
+		{"x.ai", "/v1/chat/completions", ""},
+	}
+	for _, tt := range tests {
+		got := ""
+		if provider := p.detectAIProvider(tt.host, tt.path); provider != nil {
+			got = provider.Name
+		}
+		if got != tt.want {
+			t.Errorf("detectAIProvider(%q, %q) = %q, want %q", tt.host, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestOpenAICompatibleParsing(t *testing.T) {
+	tests := []struct {
+		provider string
+		url      string
+		model    string
+	}{
+		{"Mistral", "http://api.mistral.ai/v1/chat/completions", "mistral-large-latest"},
+		{"DeepSeek", "http://api.deepseek.com/chat/completions", "deepseek-chat"},
+		{"xAI", "http://api.x.ai/v1/chat/completions", "grok-2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			response := `{"model": "` + tt.model + `", "choices": [{"message": {"role": "assistant", "content": "Bonjour"}, "finish_reason": "stop"}], "usage": {"prompt_tokens": 12, "completion_tokens": 3, "total_tokens": 15}}`
+			p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "application/json", response))
+			signal := proxySignal(t, p, signalCh, jsonRequest(tt.url,
+				`{"model": "`+tt.model+`", "messages": [{"role": "user", "content": "Say hello in French"}]}`))
+
+			if got := signal.Metadata["provider"]; got != tt.provider {
+				t.Errorf("provider = %v, want %s", got, tt.provider)
+			}
+			if got := signal.Metadata["model"]; got != tt.model {
+				t.Errorf("model = %v, want %s", got, tt.model)
+			}
+			if got := signal.Metadata["response_preview"]; got != "Bonjour" {
+				t.Errorf("response_preview = %v, want Bonjour", got)
+			}
+			if got := signal.Metadata["total_tokens"]; got != 15 {
+				t.Errorf("total_tokens = %v, want 15", got)
+			}
+			if cost, ok := signal.Metadata["estimated_cost_usd"].(float64); !ok || cost <= 0 {
+				t.Errorf("estimated_cost_usd = %v, want a priced estimate", signal.Metadata["estimated_cost_usd"])
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package observer
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"os"
+	"time"
+)
+
+// Environment variables:
+//   AXOM_MITM_ALL_HOSTS - Optional. Set to 1 for HTTPSProxy to intercept every CONNECT tunnel, e.g. to capture MCP servers on hosts outside the provider list. Default: only AI provider hosts are intercepted, others are spliced through untouched
+
+// clientHelloTimeout bounds waiting for the client's ClientHello after a
+// CONNECT is accepted
+const clientHelloTimeout = 10 * time.Second
+
+// mitmAllHostsFromEnv reports whether AXOM_MITM_ALL_HOSTS disables splicing
+func mitmAllHostsFromEnv() bool {
+	return os.Getenv("AXOM_MITM_ALL_HOSTS") == "1"
+}
+
+// peekClientHello reads the client's first bytes until the SNI is found or
+// the data can't be a ClientHello, returning what was read so it can be
+// replayed to whichever side handles the connection
+func peekClientHello(conn net.Conn) (hello []byte, sni string) {
+	conn.SetReadDeadline(time.Now().Add(clientHelloTimeout))
+	defer conn.SetReadDeadline(time.Time{})
+
+	buf := make([]byte, 4096)
+	for len(hello) < maxClientHelloBytes {
+		n, err := conn.Read(buf)
+		hello = append(hello, buf[:n]...)
+		name, complete := parseClientHelloSNI(hello)
+		if complete {
+			return hello, name
+		}
+		if err != nil {
+			break
+		}
+	}
+	return hello, ""
+}
+
+// prefixConn is a connection whose first reads return already consumed bytes
+type prefixConn struct {
+	net.Conn
+	r io.Reader
+}
+
+// newPrefixConn returns conn with prefix put back in front of its data
+func newPrefixConn(conn net.Conn, prefix []byte) *prefixConn {
+	return &prefixConn{Conn: conn, r: io.MultiReader(bytes.NewReader(prefix), conn)}
+}
+
+func (c *prefixConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+// spliceTunnel connects client to addr and copies bytes both ways, starting
+// with the client's already read hello, until either side closes. Nothing
+// is decrypted or recorded. The upstream is dialed with dialer, so splices
+// honour the upstream proxy.
+func spliceTunnel(client net.Conn, addr string, hello []byte, dialer *upstreamDialer) error {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "443")
+	}
+	upstream, err := dialer.dial("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer upstream.Close()
+	if _, err := upstream.Write(hello); err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		io.Copy(upstream, client)
+		// Let the upstream see the client's EOF
+		if tcp, ok := upstream.(*net.TCPConn); ok {
+			tcp.CloseWrite()
+		}
+		close(done)
+	}()
+	io.Copy(client, upstream)
+	client.Close()
+	<-done
+	return nil
+}
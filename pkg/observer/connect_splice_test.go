@@ -0,0 +1,100 @@
+package observer
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"axom-observer/pkg/models"
+)
+
+// newTestHTTPSProxy serves an HTTPSProxy, configured from the current
+// environment, with a freshly generated CA. It returns the proxy's address.
+func newTestHTTPSProxy(t *testing.T) (string, *x509.Certificate) {
+	t.Helper()
+	dir := t.TempDir()
+	certPath, keyPath := filepath.Join(dir, "ca.crt"), filepath.Join(dir, "ca.key")
+	ca := writeTestCA(t, certPath, keyPath, "test proxy CA")
+	p := NewHTTPSProxy("0", make(chan models.Signal, 16), testLogger(), "test-customer", "test-agent")
+	if err := p.reloadCA(certPath, keyPath); err != nil {
+		t.Fatalf("load CA: %v", err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(p.handleRequest))
+	t.Cleanup(server.Close)
+	return server.Listener.Addr().String(), ca
+}
+
+// connectTLS opens a CONNECT tunnel to target through the proxy at
+// proxyAddr and handshakes with sni, without verifying, returning the leaf
+// certificate presented
+func connectTLS(t *testing.T, proxyAddr, target, sni string) *x509.Certificate {
+	t.Helper()
+	conn, err := net.Dial("tcp", proxyAddr)
+	if err != nil {
+		t.Fatalf("dial proxy: %v", err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "CONNECT "+target+" HTTP/1.1\r\nHost: "+target+"\r\n\r\n")
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("CONNECT %s = %v, %v, want 200", target, resp, err)
+	}
+	tlsConn := tls.Client(conn, &tls.Config{ServerName: sni, InsecureSkipVerify: true})
+	if err := tlsConn.Handshake(); err != nil {
+		t.Fatalf("TLS handshake through the tunnel: %v", err)
+	}
+	return tlsConn.ConnectionState().PeerCertificates[0]
+}
+
+func TestNonAIHostSpliced(t *testing.T) {
+	upstream := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "from upstream")
+	}))
+	defer upstream.Close()
+	proxyAddr, _ := newTestHTTPSProxy(t)
+
+	// A client pinning the upstream's own certificate only succeeds if the
+	// tunnel isn't intercepted. The SNI names the host, as the upstream's
+	// loopback address is itself a local AI service.
+	pinned := x509.NewCertPool()
+	pinned.AddCert(upstream.Certificate())
+	client := &http.Client{Transport: &http.Transport{
+		Proxy:           http.ProxyURL(&url.URL{Scheme: "http", Host: proxyAddr}),
+		TLSClientConfig: &tls.Config{RootCAs: pinned, ServerName: "example.com"},
+	}}
+	resp, err := client.Get(upstream.URL)
+	if err != nil {
+		t.Fatalf("GET through the proxy: %v", err)
+	}
+	defer resp.Body.Close()
+	if body, _ := io.ReadAll(resp.Body); string(body) != "from upstream" {
+		t.Errorf("body = %q, want the upstream's", body)
+	}
+}
+
+func TestAIHostIntercepted(t *testing.T) {
+	proxyAddr, ca := newTestHTTPSProxy(t)
+	leaf := connectTLS(t, proxyAddr, "api.openai.com:443", "api.openai.com")
+	if !chainsTo(leaf, ca, "api.openai.com") {
+		t.Errorf("certificate for api.openai.com issued by %q, want the proxy CA", leaf.Issuer.CommonName)
+	}
+}
+
+func TestMITMAllHosts(t *testing.T) {
+	t.Setenv("AXOM_MITM_ALL_HOSTS", "1")
+	upstream := httptest.NewTLSServer(http.NotFoundHandler())
+	defer upstream.Close()
+	proxyAddr, ca := newTestHTTPSProxy(t)
+
+	leaf := connectTLS(t, proxyAddr, upstream.Listener.Addr().String(), "tools.internal")
+	if leaf.Issuer.CommonName != ca.Subject.CommonName {
+		t.Errorf("certificate issued by %q with AXOM_MITM_ALL_HOSTS=1, want the proxy CA", leaf.Issuer.CommonName)
+	}
+}
@@ -0,0 +1,109 @@
+package observer
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// decodableEncodings are the content codings decodeResponseBody can undo.
+// Brotli and zstd aren't among them (there is no decoder in the standard
+// library), so restrictAcceptEncoding keeps providers from choosing them.
+var decodableEncodings = map[string]bool{
+	"gzip":     true,
+	"x-gzip":   true,
+	"deflate":  true,
+	"identity": true,
+}
+
+// restrictAcceptEncoding narrows a forwarded request's Accept-Encoding to the
+// codings that can be decoded for parsing, dropping br, zstd and the like. If
+// none are left the header is removed and the provider answers uncompressed.
+func restrictAcceptEncoding(header http.Header) {
+	values := header.Values("Accept-Encoding")
+	if len(values) == 0 {
+		return
+	}
+	var kept []string
+	for _, value := range values {
+		for _, coding := range strings.Split(value, ",") {
+			coding = strings.TrimSpace(coding)
+			name, _, _ := strings.Cut(coding, ";")
+			if decodableEncodings[strings.ToLower(strings.TrimSpace(name))] {
+				kept = append(kept, coding)
+			}
+		}
+	}
+	if len(kept) == 0 {
+		header.Del("Accept-Encoding")
+		return
+	}
+	header.Set("Accept-Encoding", strings.Join(kept, ", "))
+}
+
+// decodeResponseBody returns the response body with its Content-Encoding
+// undone, for parsing; what is forwarded to the client is left as it came.
+// If the body can't be decoded the raw bytes are returned with the error. A
+// truncated body decodes as far as it goes.
+func decodeResponseBody(body capturedBody, header http.Header, limit bodyLimit) ([]byte, error) {
+	encoding := strings.ToLower(strings.TrimSpace(header.Get("Content-Encoding")))
+	if encoding == "" || encoding == "identity" {
+		return body.data, nil
+	}
+	data := body.data
+	// Codings are listed in the order they were applied
+	codings := strings.Split(encoding, ",")
+	for i := len(codings) - 1; i >= 0; i-- {
+		decoded, err := decodeContent(data, strings.TrimSpace(codings[i]), limit)
+		if err != nil && !(body.truncated && len(decoded) > 0) {
+			return body.data, err
+		}
+		data = decoded
+	}
+	return data, nil
+}
+
+// recordContentEncoding records a compressed response's Content-Encoding
+// and, if it couldn't be decoded, why
+func recordContentEncoding(fields map[string]interface{}, header http.Header, decodeErr error) {
+	if encoding := header.Get("Content-Encoding"); encoding != "" && encoding != "identity" {
+		fields["content_encoding"] = encoding
+	}
+	if decodeErr != nil {
+		fields["response_decode_error"] = decodeErr.Error()
+	}
+}
+
+// decodeContent undoes a single content coding, reading at most limit
+// decoded bytes
+func decodeContent(data []byte, coding string, limit bodyLimit) ([]byte, error) {
+	var reader io.Reader
+	switch coding {
+	case "identity":
+		return data, nil
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		reader = gz
+	case "deflate":
+		// "deflate" is meant to be zlib-wrapped, but some servers send it raw
+		zr, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			reader = flate.NewReader(bytes.NewReader(data))
+		} else {
+			defer zr.Close()
+			reader = zr
+		}
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", coding)
+	}
+	return io.ReadAll(io.LimitReader(reader, int64(limit)))
+}
@@ -0,0 +1,125 @@
+package observer
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// encodedResponse returns a Forwarder answering with body under the given
+// Content-Encoding, and reports the Accept-Encoding each request carried
+func encodedResponse(encoding string, body []byte, accepted *string) Forwarder {
+	return ForwarderFunc(func(req *http.Request) (*http.Response, error) {
+		*accepted = req.Header.Get("Accept-Encoding")
+		resp, err := cannedResponse(http.StatusOK, "application/json", string(body)).Forward(req)
+		resp.Header.Set("Content-Encoding", encoding)
+		return resp, err
+	})
+}
+
+func compress(t *testing.T, encoding string, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	}
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestCompressedResponsesParsed(t *testing.T) {
+	for _, encoding := range []string{"gzip", "deflate", "raw-deflate"} {
+		t.Run(encoding, func(t *testing.T) {
+			body := compress(t, encoding, []byte(okChatResponse))
+			header := encoding
+			if encoding == "raw-deflate" {
+				header = "deflate"
+			}
+			var accepted string
+			p, signalCh := newTestHTTPProxy(t, encodedResponse(header, body, &accepted))
+
+			rec := httptest.NewRecorder()
+			req := chatRequest(`{"model": "gpt-4", "messages": [{"role": "user", "content": "Hi"}]}`)
+			p.handleRequest(rec, req)
+			signal := <-signalCh
+
+			for key, want := range map[string]interface{}{
+				"response_preview": "Hi",
+				"prompt_tokens":    5,
+				"total_tokens":     6,
+				"content_encoding": header,
+			} {
+				if got := signal.Metadata[key]; got != want {
+					t.Errorf("%s = %v, want %v", key, got, want)
+				}
+			}
+			if !bytes.Equal(rec.Body.Bytes(), body) || rec.Header().Get("Content-Encoding") != header {
+				t.Errorf("client got %q, want the compressed bytes as sent", rec.Body.Bytes())
+			}
+		})
+	}
+}
+
+func TestBrotliNotRequestedUpstream(t *testing.T) {
+	var accepted string
+	p, signalCh := newTestHTTPProxy(t, encodedResponse("gzip", compress(t, "gzip", []byte(okChatResponse)), &accepted))
+	req := chatRequest(`{"model": "gpt-4", "messages": [{"role": "user", "content": "Hi"}]}`)
+	req.Header.Set("Accept-Encoding", "br, gzip;q=0.8, zstd")
+	signal := proxySignal(t, p, signalCh, req)
+
+	if accepted != "gzip;q=0.8" {
+		t.Errorf("upstream Accept-Encoding = %q, want only gzip", accepted)
+	}
+	if got := signal.Metadata["total_tokens"]; got != 6 {
+		t.Errorf("total_tokens = %v, want 6", got)
+	}
+}
+
+func TestBrotliResponseForwardedUndecoded(t *testing.T) {
+	// A provider that sends brotli regardless: not decodable here, so the
+	// bytes go to the client untouched and the reason is recorded
+	brotli := []byte{0x1b, 0x2f, 0x00, 0x00, 0x24, 0x00, 0x00, 0x00}
+	var accepted string
+	p, signalCh := newTestHTTPProxy(t, encodedResponse("br", brotli, &accepted))
+	rec := httptest.NewRecorder()
+	p.handleRequest(rec, chatRequest(`{"model": "gpt-4", "messages": [{"role": "user", "content": "Hi"}]}`))
+	signal := <-signalCh
+
+	if !bytes.Equal(rec.Body.Bytes(), brotli) {
+		t.Errorf("client got %q, want the brotli bytes as sent", rec.Body.Bytes())
+	}
+	if got := signal.Metadata["content_encoding"]; got != "br" {
+		t.Errorf("content_encoding = %v, want br", got)
+	}
+	if got := signal.Metadata["response_decode_error"]; got != `unsupported content encoding "br"` {
+		t.Errorf("response_decode_error = %v, want br reported unsupported", got)
+	}
+}
+
+func TestRestrictAcceptEncoding(t *testing.T) {
+	tests := map[string]string{
+		"gzip, deflate, br": "gzip, deflate",
+		"br":                "",
+		"GZIP;q=1.0, zstd":  "GZIP;q=1.0",
+		"identity":          "identity",
+	}
+	for value, want := range tests {
+		header := http.Header{"Accept-Encoding": {value}}
+		restrictAcceptEncoding(header)
+		if got := header.Get("Accept-Encoding"); got != want {
+			t.Errorf("restrictAcceptEncoding(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
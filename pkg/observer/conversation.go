@@ -0,0 +1,92 @@
+package observer
+
+import (
+	"os"
+	"strings"
+)
+
+// Environment variables:
+//   AXOM_CONVERSATION_SUMMARY - Optional. Set to "1" to record the role and length of every message as "conversation". Default: disabled
+
+// conversationSummaryFromEnv reports whether per-message summaries are enabled
+func conversationSummaryFromEnv() bool {
+	return os.Getenv("AXOM_CONVERSATION_SUMMARY") == "1"
+}
+
+// messageText returns the text of a message's content, which is either a
+// string or an array of parts (OpenAI content parts, Anthropic blocks) whose
+// text parts are joined
+func messageText(content interface{}) string {
+	switch content := content.(type) {
+	case string:
+		return content
+	case []interface{}:
+		var texts []string
+		for _, part := range content {
+			if part, ok := part.(map[string]interface{}); ok {
+				if text, ok := part["text"].(string); ok && text != "" {
+					texts = append(texts, text)
+				}
+			}
+		}
+		return strings.Join(texts, "\n")
+	}
+	return ""
+}
+
+// latestPrompt returns the text of the last user message, which is the
+// prompt in a multi-turn conversation, falling back to the last message with
+// any text
+func latestPrompt(messages []interface{}) (string, bool) {
+	fallback := ""
+	for i := len(messages) - 1; i >= 0; i-- {
+		msg, ok := messages[i].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		text := messageText(msg["content"])
+		if text == "" {
+			continue
+		}
+		if role, _ := msg["role"].(string); role == "user" {
+			return text, true
+		}
+		if fallback == "" {
+			fallback = text
+		}
+	}
+	return fallback, fallback != ""
+}
+
+// summarizeConversation records conversation_turns (the number of
+// non-system messages) and system_prompt_present, from either a system
+// message or Anthropic's top-level "system". With detailed it also records
+// each message's role and text length as "conversation".
+func summarizeConversation(request map[string]interface{}, messages []interface{}, jsonData map[string]interface{}, detailed bool) {
+	turns := 0
+	systemPrompt := jsonData["system"] != nil
+	var conversation []map[string]interface{}
+	for _, m := range messages {
+		msg, ok := m.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		role, _ := msg["role"].(string)
+		if role == "system" || role == "developer" {
+			systemPrompt = true
+		} else {
+			turns++
+		}
+		if detailed {
+			conversation = append(conversation, map[string]interface{}{
+				"role":  role,
+				"chars": len(messageText(msg["content"])),
+			})
+		}
+	}
+	request["conversation_turns"] = turns
+	request["system_prompt_present"] = systemPrompt
+	if detailed {
+		request["conversation"] = conversation
+	}
+}
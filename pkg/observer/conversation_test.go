@@ -0,0 +1,84 @@
+package observer
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+const multiTurnChat = `{"model": "gpt-4", "messages": [
+	{"role": "system", "content": "You are a travel assistant."},
+	{"role": "user", "content": "Plan a weekend in Lisbon"},
+	{"role": "assistant", "content": "Day one: Alfama and the castle."},
+	{"role": "user", "content": [{"type": "text", "text": "Now book a hotel"}]},
+	{"role": "assistant", "content": null, "tool_calls": [{"id": "call_1", "type": "function"}]}
+]}`
+
+func TestPromptPreviewFromLatestUserMessage(t *testing.T) {
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "application/json", okChatResponse))
+	signal := proxySignal(t, p, signalCh, chatRequest(multiTurnChat))
+
+	if got := signal.Metadata["prompt_preview"]; got != "Now book a hotel" {
+		t.Errorf("prompt_preview = %v, want the last user message", got)
+	}
+	if got := signal.Metadata["conversation_turns"]; got != 4 {
+		t.Errorf("conversation_turns = %v, want 4", got)
+	}
+	if got := signal.Metadata["system_prompt_present"]; got != true {
+		t.Errorf("system_prompt_present = %v, want true", got)
+	}
+	if _, ok := signal.Metadata["conversation"]; ok {
+		t.Errorf("conversation recorded without AXOM_CONVERSATION_SUMMARY")
+	}
+}
+
+func TestConversationSummary(t *testing.T) {
+	t.Setenv("AXOM_CONVERSATION_SUMMARY", "1")
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "application/json", okChatResponse))
+	signal := proxySignal(t, p, signalCh, chatRequest(multiTurnChat))
+
+	want := []map[string]interface{}{
+		{"role": "system", "chars": 27},
+		{"role": "user", "chars": 24},
+		{"role": "assistant", "chars": 31},
+		{"role": "user", "chars": 16},
+		{"role": "assistant", "chars": 0},
+	}
+	if got := signal.Metadata["conversation"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("conversation = %v, want %v", got, want)
+	}
+}
+
+func TestAnthropicTopLevelSystemPrompt(t *testing.T) {
+	request := make(map[string]interface{})
+	messages := []interface{}{map[string]interface{}{"role": "user", "content": "Hi"}}
+	summarizeConversation(request, messages, map[string]interface{}{"system": "Be brief."}, false)
+	if request["system_prompt_present"] != true || request["conversation_turns"] != 1 {
+		t.Errorf("summary = %v, want one turn with a system prompt", request)
+	}
+}
+
+func TestLatestPrompt(t *testing.T) {
+	tests := map[string]struct {
+		messages []interface{}
+		want     string
+		ok       bool
+	}{
+		"no user message": {[]interface{}{
+			map[string]interface{}{"role": "system", "content": "Be brief."},
+			map[string]interface{}{"role": "assistant", "content": "Hello!"},
+		}, "Hello!", true},
+		"empty user message skipped": {[]interface{}{
+			map[string]interface{}{"role": "user", "content": "First"},
+			map[string]interface{}{"role": "user", "content": ""},
+		}, "First", true},
+		"no text": {[]interface{}{
+			map[string]interface{}{"role": "user", "content": []interface{}{map[string]interface{}{"type": "image_url"}}},
+		}, "", false},
+	}
+	for name, tt := range tests {
+		if got, ok := latestPrompt(tt.messages); got != tt.want || ok != tt.ok {
+			t.Errorf("%s: latestPrompt = %q, %v, want %q, %v", name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
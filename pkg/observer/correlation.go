@@ -0,0 +1,48 @@
+package observer
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// correlationHeader carries the correlation ID when the client sent none
+const correlationHeader = "X-Request-ID"
+
+// ensureCorrelationID returns the ID correlating a request with the caller's
+// own tracing: the trace-id of a W3C traceparent header, else X-Request-ID.
+// When neither is present a random ID is generated and set as X-Request-ID,
+// so it is forwarded to the provider along with the rest of the headers.
+func ensureCorrelationID(r *http.Request) string {
+	if traceID, ok := traceparentTraceID(r.Header.Get("traceparent")); ok {
+		return traceID
+	}
+	if id := strings.TrimSpace(r.Header.Get(correlationHeader)); id != "" {
+		return id
+	}
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return ""
+	}
+	id := hex.EncodeToString(b[:])
+	r.Header.Set(correlationHeader, id)
+	return id
+}
+
+// traceparentTraceID extracts the trace-id from a W3C traceparent header
+// ("version-traceid-parentid-flags"). The all-zero trace-id is invalid.
+func traceparentTraceID(traceparent string) (string, bool) {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return "", false
+	}
+	traceID := strings.ToLower(parts[1])
+	if _, err := hex.DecodeString(traceID); err != nil {
+		return "", false
+	}
+	if traceID == strings.Repeat("0", 32) {
+		return "", false
+	}
+	return traceID, true
+}
@@ -0,0 +1,87 @@
+package observer
+
+import (
+	"net/http"
+	"testing"
+)
+
+// correlatedExchange proxies a chat request with the given headers and
+// returns the signal and the headers the upstream received
+func correlatedExchange(t *testing.T, headers map[string]string) (map[string]interface{}, http.Header) {
+	t.Helper()
+	var forwarded http.Header
+	upstream := ForwarderFunc(func(req *http.Request) (*http.Response, error) {
+		forwarded = req.Header.Clone()
+		return cannedResponse(http.StatusOK, "application/json", okChatResponse).Forward(req)
+	})
+	p, signalCh := newTestHTTPProxy(t, upstream)
+	req := chatRequest(`{"model": "gpt-4", "messages": [{"role": "user", "content": "Hi"}]}`)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	signal := proxySignal(t, p, signalCh, req)
+	return signal.Metadata, forwarded
+}
+
+func TestCorrelationIDFromTraceparent(t *testing.T) {
+	traceparent := "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01"
+	metadata, forwarded := correlatedExchange(t, map[string]string{
+		"traceparent":  traceparent,
+		"X-Request-ID": "req-123",
+	})
+	if got := metadata["correlation_id"]; got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("correlation_id = %v, want the traceparent trace-id", got)
+	}
+	if forwarded.Get("traceparent") != traceparent || forwarded.Get("X-Request-ID") != "req-123" {
+		t.Errorf("upstream headers = %v, want traceparent and X-Request-ID forwarded unchanged", forwarded)
+	}
+}
+
+func TestCorrelationIDFromRequestID(t *testing.T) {
+	metadata, forwarded := correlatedExchange(t, map[string]string{"X-Request-ID": "req-123"})
+	if got := metadata["correlation_id"]; got != "req-123" {
+		t.Errorf("correlation_id = %v, want req-123", got)
+	}
+	if got := forwarded.Get("X-Request-ID"); got != "req-123" {
+		t.Errorf("upstream X-Request-ID = %q, want req-123", got)
+	}
+}
+
+func TestCorrelationIDGenerated(t *testing.T) {
+	metadata, forwarded := correlatedExchange(t, map[string]string{
+		// Invalid: the all-zero trace-id
+		"traceparent": "00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+	})
+	id, _ := metadata["correlation_id"].(string)
+	if len(id) != 32 {
+		t.Fatalf("correlation_id = %q, want a generated 32-character ID", id)
+	}
+	if got := forwarded.Get("X-Request-ID"); got != id {
+		t.Errorf("upstream X-Request-ID = %q, want the generated %s", got, id)
+	}
+
+	other, _ := correlatedExchange(t, nil)
+	if other["correlation_id"] == id {
+		t.Errorf("two requests were given the same generated ID")
+	}
+}
+
+func TestTraceparentTraceID(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+		ok     bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "4bf92f3577b34da6a3ce929d0e0e4736", true},
+		{" 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00 ", "4bf92f3577b34da6a3ce929d0e0e4736", true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01", "", false},
+		{"00-zzf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "", false},
+		{"4bf92f3577b34da6a3ce929d0e0e4736", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		if got, ok := traceparentTraceID(tt.header); got != tt.want || ok != tt.ok {
+			t.Errorf("traceparentTraceID(%q) = %q, %v, want %q, %v", tt.header, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package observer
+
+import (
+	"log"
+	"os"
+)
+
+// Environment variables:
+//   AXOM_DETECT_DEBUG - Optional. Set to "1" to log why a request was not classified as AI traffic. Default: disabled
+
+// detectDebugEnabled reports whether provider detection diagnostics are on
+func detectDebugEnabled() bool {
+	return os.Getenv("AXOM_DETECT_DEBUG") == "1"
+}
+
+// detectionMiss describes how close a request came to matching one provider
+type detectionMiss struct {
+	provider      string
+	domainMatched bool
+	pathMatched   bool
+}
+
+// explainDetectionMiss lists the providers whose domain matched host but none
+// of whose API patterns matched path, and vice versa
+func explainDetectionMiss(providers []AIProvider, host, path string) []detectionMiss {
+	var misses []detectionMiss
+	for i := range providers {
+		provider := &providers[i]
+		domainMatched := matchesProviderDomain(provider, host)
+		pathMatched := matchesProviderPath(provider, path)
+		if domainMatched != pathMatched {
+			misses = append(misses, detectionMiss{
+				provider:      provider.Name,
+				domainMatched: domainMatched,
+				pathMatched:   pathMatched,
+			})
+		}
+	}
+	return misses
+}
+
+// logDetectionMiss logs the near-misses for a request that wasn't detected
+// as belonging to any of the registry's providers
+func (r *ProviderRegistry) logDetectionMiss(logger *log.Logger, host, path string) {
+	misses := explainDetectionMiss(r.providers, host, path)
+	if len(misses) == 0 {
+		logger.Printf("🔍 No AI provider detected: host='%s', path='%s' (no provider domain or path pattern matched)", host, path)
+		return
+	}
+	for _, miss := range misses {
+		if miss.domainMatched {
+			logger.Printf("🔍 No AI provider detected: host='%s', path='%s' - %s domain matched but no API pattern matched the path",
+				host, path, miss.provider)
+		} else {
+			logger.Printf("🔍 No AI provider detected: host='%s', path='%s' - %s API pattern matched but the host is not a %s domain",
+				host, path, miss.provider, miss.provider)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package observer
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestDetectDebugExplainsNearMiss(t *testing.T) {
+	t.Setenv("AXOM_DETECT_DEBUG", "1")
+	var logs bytes.Buffer
+	p := NewHTTPProxy("0", nil, log.New(&logs, "", 0), "", "", false, "")
+
+	if provider := p.detectAIProvider("api.openai.com", "/v1/assistants"); provider != nil {
+		t.Fatalf("detected %s for an unknown OpenAI path", provider.Name)
+	}
+	want := "OpenAI domain matched but no API pattern matched the path"
+	if !strings.Contains(logs.String(), want) {
+		t.Errorf("log does not explain the near-miss; want %q in:\n%s", want, logs.String())
+	}
+}
+
+func TestDetectDebugExplainsForeignHost(t *testing.T) {
+	t.Setenv("AXOM_DETECT_DEBUG", "1")
+	var logs bytes.Buffer
+	p := NewHTTPProxy("0", nil, log.New(&logs, "", 0), "", "", false, "")
+
+	p.detectAIProvider("llm-gateway.internal", "/v1/messages")
+	want := "Anthropic API pattern matched but the host is not a Anthropic domain"
+	if !strings.Contains(logs.String(), want) {
+		t.Errorf("log does not explain the near-miss; want %q in:\n%s", want, logs.String())
+	}
+}
+
+func TestDetectDebugDisabled(t *testing.T) {
+	t.Setenv("AXOM_DETECT_DEBUG", "")
+	var logs bytes.Buffer
+	p := NewHTTPProxy("0", nil, log.New(&logs, "", 0), "", "", false, "")
+
+	p.detectAIProvider("api.openai.com", "/v1/assistants")
+	if strings.Contains(logs.String(), "No AI provider detected") {
+		t.Errorf("near-miss logged with AXOM_DETECT_DEBUG unset")
+	}
+}
+
+func TestExplainDetectionMiss(t *testing.T) {
+	misses := explainDetectionMiss(knownAIProviders, "unknown.example.com", "/nothing")
+	if len(misses) != 0 {
+		t.Errorf("misses = %+v, want none when neither domain nor path match", misses)
+	}
+
+	misses = explainDetectionMiss(knownAIProviders, "api.anthropic.com", "/v2/other")
+	if len(misses) != 1 || misses[0].provider != "Anthropic" || !misses[0].domainMatched || misses[0].pathMatched {
+		t.Errorf("misses = %+v, want an Anthropic domain-only match", misses)
+	}
+}
@@ -0,0 +1,159 @@
+package observer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"sync"
+)
+
+// Environment variables:
+//   AXOM_DLQ_PATH - Optional. File that batches dropped after retries are appended to as NDJSON and replayed from on startup. Default: disabled
+
+// deadLetterQueue persists signals the sender gave up on so they survive
+// backend outages and restarts. Signals are appended one JSON object per
+// line; a replay first renames the file aside so that new dead letters
+// written meanwhile are never lost or replayed twice.
+type deadLetterQueue struct {
+	path string
+	mu   sync.Mutex
+}
+
+// deadLetterQueueFromEnv returns the queue configured by AXOM_DLQ_PATH, or
+// nil when dead-lettering is disabled
+func deadLetterQueueFromEnv() *deadLetterQueue {
+	path := os.Getenv("AXOM_DLQ_PATH")
+	if path == "" {
+		return nil
+	}
+	return &deadLetterQueue{path: path}
+}
+
+// write appends the signals in an encoded batch (a JSON array) to the file
+func (q *deadLetterQueue) write(body []byte) (int, error) {
+	var signals []json.RawMessage
+	if err := json.Unmarshal(body, &signals); err != nil {
+		return 0, fmt.Errorf("failed to decode batch: %w", err)
+	}
+
+	var buf bytes.Buffer
+	for _, sig := range signals {
+		if err := json.Compact(&buf, sig); err != nil {
+			return 0, fmt.Errorf("failed to compact signal: %w", err)
+		}
+		buf.WriteByte('\n')
+	}
+	return len(signals), q.appendLines(buf.Bytes())
+}
+
+// appendLines appends complete lines to the file in a single write and
+// syncs it to disk
+func (q *deadLetterQueue) appendLines(lines []byte) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	f, err := os.OpenFile(q.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(lines); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// replay sends dead-lettered signals in batches of batchSize using send.
+// Signals from a batch that fails, and everything after it, are appended
+// back to the queue. A replay file left over by an interrupted replay is
+// picked up before the live file.
+func (q *deadLetterQueue) replay(batchSize int, send func(body []byte, count int) error) (sent int, err error) {
+	replayPath := q.path + ".replay"
+	if _, statErr := os.Stat(replayPath); errors.Is(statErr, fs.ErrNotExist) {
+		q.mu.Lock()
+		renameErr := os.Rename(q.path, replayPath)
+		q.mu.Unlock()
+		if errors.Is(renameErr, fs.ErrNotExist) {
+			return 0, nil
+		}
+		if renameErr != nil {
+			return 0, renameErr
+		}
+	}
+
+	f, err := os.Open(replayPath)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var (
+		batch   [][]byte
+		pending bytes.Buffer // lines to put back after a failed send
+		failed  bool
+	)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if !failed {
+			body := append([]byte{'['}, bytes.Join(batch, []byte{','})...)
+			body = append(body, ']')
+			sendErr := send(body, len(batch))
+			if sendErr == nil {
+				sent += len(batch)
+				batch = batch[:0]
+				return
+			}
+			failed = true
+			err = sendErr
+		}
+		for _, line := range batch {
+			pending.Write(line)
+			pending.WriteByte('\n')
+		}
+		batch = batch[:0]
+	}
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16<<20)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if !json.Valid(line) {
+			// A partial line from a crash mid-write can't be recovered
+			log.Printf("[observer] Skipping corrupt dead-letter entry in %s", replayPath)
+			continue
+		}
+		batch = append(batch, append([]byte(nil), line...))
+		if len(batch) >= batchSize {
+			flush()
+		}
+	}
+	if scanErr := scanner.Err(); scanErr != nil {
+		// Keep the replay file so the unread remainder is retried next time
+		return sent, scanErr
+	}
+	flush()
+
+	if pending.Len() > 0 {
+		if appendErr := q.appendLines(pending.Bytes()); appendErr != nil {
+			return sent, appendErr
+		}
+	}
+	if removeErr := os.Remove(replayPath); removeErr != nil {
+		return sent, removeErr
+	}
+	return sent, err
+}
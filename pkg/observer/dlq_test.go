@@ -0,0 +1,130 @@
+package observer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"axom-observer/pkg/models"
+)
+
+// deadLetterIDs returns the IDs of the signals in a dead-letter file
+func deadLetterIDs(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read dead-letter file: %v", err)
+	}
+	var ids []string
+	for _, line := range bytes.Split(bytes.TrimSpace(data), []byte("\n")) {
+		var sig models.Signal
+		if err := json.Unmarshal(line, &sig); err != nil {
+			t.Fatalf("dead-letter file holds an invalid line %q: %v", line, err)
+		}
+		ids = append(ids, sig.ID)
+	}
+	return ids
+}
+
+func TestDeadLetterFailingThenRecoveringBackend(t *testing.T) {
+	dlqPath := filepath.Join(t.TempDir(), "dlq.ndjson")
+	t.Setenv("AXOM_DLQ_PATH", dlqPath)
+	var status atomic.Int32
+	status.Store(http.StatusServiceUnavailable)
+	backend := newTestBackend(t, func() int { return int(status.Load()) })
+
+	// The backend is down: the batch is dead-lettered once retrying stops
+	s := NewSignalSender("test-key", backend.URL, 10, time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	err := s.sendBatchWithRetry(ctx, []models.Signal{
+		testSignal("sig-1", nil), testSignal("sig-2", nil), testSignal("sig-3", nil),
+	})
+	cancel()
+	if err == nil {
+		t.Fatalf("sendBatchWithRetry succeeded against a failing backend")
+	}
+	if ids := deadLetterIDs(t, dlqPath); len(ids) != 3 || ids[0] != "sig-1" || ids[2] != "sig-3" {
+		t.Fatalf("dead-letter file holds %v, want sig-1..sig-3", ids)
+	}
+
+	// After a restart with the backend back, the file is replayed first
+	status.Store(http.StatusOK)
+	restarted := NewSignalSender("test-key", backend.URL, 10, time.Hour)
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	go restarted.Start(ctx, make(chan models.Signal))
+
+	got := waitForSignals(t, backend, 3, 5*time.Second)
+	if got[0].ID != "sig-1" || got[2].ID != "sig-3" {
+		t.Errorf("backend received %v, want the dead-lettered signals in order", got)
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		_, liveErr := os.Stat(dlqPath)
+		_, replayErr := os.Stat(dlqPath + ".replay")
+		if os.IsNotExist(liveErr) && os.IsNotExist(replayErr) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("dead-letter files left behind after a successful replay")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDeadLetterReplayKeepsUnsentSignals(t *testing.T) {
+	q := &deadLetterQueue{path: filepath.Join(t.TempDir(), "dlq.ndjson")}
+	for _, batch := range []string{
+		`[{"id":"sig-1"},{"id":"sig-2"}]`,
+		`[{"id":"sig-3"},{"id":"sig-4"}]`,
+	} {
+		if _, err := q.write([]byte(batch)); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	// A line cut short by a crash mid-write is skipped
+	if err := q.appendLines([]byte("{\"id\":\"sig-5\"\n")); err != nil {
+		t.Fatalf("appendLines: %v", err)
+	}
+
+	unavailable := errors.New("backend unavailable")
+	calls := 0
+	sent, err := q.replay(2, func(body []byte, count int) error {
+		calls++
+		if calls == 2 {
+			return unavailable
+		}
+		return nil
+	})
+	if sent != 2 || !errors.Is(err, unavailable) {
+		t.Fatalf("replay = %d, %v, want 2 sent and the send error", sent, err)
+	}
+	if ids := deadLetterIDs(t, q.path); len(ids) != 2 || ids[0] != "sig-3" || ids[1] != "sig-4" {
+		t.Fatalf("dead-letter file holds %v after a failed replay, want sig-3 and sig-4", ids)
+	}
+
+	var replayed []string
+	sent, err = q.replay(10, func(body []byte, count int) error {
+		var signals []models.Signal
+		if err := json.Unmarshal(body, &signals); err != nil {
+			t.Fatalf("replayed body is not a JSON batch: %v", err)
+		}
+		for _, sig := range signals {
+			replayed = append(replayed, sig.ID)
+		}
+		return nil
+	})
+	if sent != 2 || err != nil || len(replayed) != 2 {
+		t.Errorf("second replay = %d %v %v, want sig-3 and sig-4", sent, err, replayed)
+	}
+	if _, err := os.Stat(q.path); !os.IsNotExist(err) {
+		t.Errorf("dead-letter file still exists after a full replay")
+	}
+}
@@ -0,0 +1,124 @@
+package observer
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/json"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"axom-observer/pkg/models"
+)
+
+// Environment variables:
+//   AXOM_DUPLICATE_WINDOW - Optional. Seconds within which an agent's repeat of an identical request is flagged as a duplicate; 0 disables. Default: 10
+
+// maxDuplicateEntries bounds how many recent request hashes are remembered
+const maxDuplicateEntries = 10000
+
+// duplicateDetector flags requests identical to one the same agent made
+// within the window: same method, path and normalized body. The store holds
+// at most maxDuplicateEntries hashes, expiring in the order they were last
+// seen.
+type duplicateDetector struct {
+	window time.Duration
+	now    func() time.Time
+
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]*list.Element
+	order   *list.List // of *duplicateEntry, least recently seen first
+}
+
+// duplicateEntry is the latest signal seen for one request hash
+type duplicateEntry struct {
+	key      [sha256.Size]byte
+	signalID string
+	seen     time.Time
+}
+
+// duplicateDetectorFromEnv creates a detector with the window from
+// AXOM_DUPLICATE_WINDOW, or nil when detection is disabled
+func duplicateDetectorFromEnv() *duplicateDetector {
+	window := 10 * time.Second
+	if v := os.Getenv("AXOM_DUPLICATE_WINDOW"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			window = time.Duration(n) * time.Second
+		}
+	}
+	if window == 0 {
+		return nil
+	}
+	return &duplicateDetector{
+		window:  window,
+		now:     time.Now,
+		entries: make(map[[sha256.Size]byte]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// check sets signal.Metadata["duplicate_of"] to the ID of the agent's
+// previous identical request when it was seen within the window, then
+// remembers the signal as the latest for its request. Bodies that are
+// empty or were truncated are not compared.
+func (d *duplicateDetector) check(signal *models.Signal, method, path string, body capturedBody, providerName string) {
+	if d == nil || len(body.data) == 0 || body.truncated {
+		return
+	}
+	key := duplicateKey(signal.AgentID, method, path, body.data)
+	now := d.now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.expire(now)
+	if elem, ok := d.entries[key]; ok {
+		entry := elem.Value.(*duplicateEntry)
+		signal.Metadata["duplicate_of"] = entry.signalID
+		eachMetrics(func(m *observerMetrics) { m.duplicateRequests.WithLabelValues(providerName).Inc() })
+		entry.signalID = signal.ID
+		entry.seen = now
+		d.order.MoveToBack(elem)
+		return
+	}
+	if d.order.Len() >= maxDuplicateEntries {
+		d.remove(d.order.Front())
+	}
+	d.entries[key] = d.order.PushBack(&duplicateEntry{key: key, signalID: signal.ID, seen: now})
+}
+
+// expire drops the entries last seen longer than the window ago
+func (d *duplicateDetector) expire(now time.Time) {
+	for elem := d.order.Front(); elem != nil; elem = d.order.Front() {
+		if now.Sub(elem.Value.(*duplicateEntry).seen) < d.window {
+			return
+		}
+		d.remove(elem)
+	}
+}
+
+func (d *duplicateDetector) remove(elem *list.Element) {
+	delete(d.entries, elem.Value.(*duplicateEntry).key)
+	d.order.Remove(elem)
+}
+
+// duplicateKey hashes a request for comparison. JSON bodies are
+// re-marshalled so that key order and whitespace don't matter.
+func duplicateKey(agentID, method, path string, body []byte) [sha256.Size]byte {
+	var parsed interface{}
+	if err := json.Unmarshal(body, &parsed); err == nil {
+		if normalized, err := json.Marshal(parsed); err == nil {
+			body = normalized
+		}
+	}
+	h := sha256.New()
+	for _, part := range []string{agentID, method, path} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	h.Write(body)
+	var key [sha256.Size]byte
+	h.Sum(key[:0])
+	return key
+}
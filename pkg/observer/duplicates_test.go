@@ -0,0 +1,113 @@
+package observer
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"axom-observer/pkg/models"
+)
+
+func TestRepeatedRequestFlagged(t *testing.T) {
+	reg := newTestRegistry(t)
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "application/json", okChatResponse))
+
+	first := proxySignal(t, p, signalCh, chatRequest(`{"model": "gpt-4", "messages": [{"role": "user", "content": "Hi"}]}`))
+	if _, ok := first.Metadata["duplicate_of"]; ok {
+		t.Fatalf("first request flagged as a duplicate")
+	}
+	// The same request with different key order and whitespace
+	second := proxySignal(t, p, signalCh, chatRequest(`{"messages":[{"content":"Hi","role":"user"}],"model":"gpt-4"}`))
+	if got := second.Metadata["duplicate_of"]; got != first.ID {
+		t.Errorf("duplicate_of = %v, want the first signal %s", got, first.ID)
+	}
+	// A third repeat points at the latest, not the original
+	third := proxySignal(t, p, signalCh, chatRequest(`{"model": "gpt-4", "messages": [{"role": "user", "content": "Hi"}]}`))
+	if got := third.Metadata["duplicate_of"]; got != second.ID {
+		t.Errorf("duplicate_of = %v, want the previous signal %s", got, second.ID)
+	}
+	if got := metricValue(t, reg, "axom_duplicate_requests_total", map[string]string{"provider": "OpenAI"}); got != 2 {
+		t.Errorf("axom_duplicate_requests_total = %v, want 2", got)
+	}
+}
+
+func TestDistinctRequestsNotFlagged(t *testing.T) {
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "application/json", okChatResponse))
+	for _, body := range []string{
+		`{"model": "gpt-4", "messages": [{"role": "user", "content": "Hi"}]}`,
+		`{"model": "gpt-4", "messages": [{"role": "user", "content": "Hello"}]}`,
+		`{"model": "gpt-4o", "messages": [{"role": "user", "content": "Hi"}]}`,
+	} {
+		signal := proxySignal(t, p, signalCh, chatRequest(body))
+		if got, ok := signal.Metadata["duplicate_of"]; ok {
+			t.Errorf("%s flagged as a duplicate of %v", body, got)
+		}
+	}
+}
+
+// duplicateOf checks a signal from agent with body at d and returns what it
+// was flagged as a duplicate of
+func duplicateOf(d *duplicateDetector, id, agent, body string) interface{} {
+	signal := &models.Signal{ID: id, AgentID: agent, Metadata: map[string]interface{}{}}
+	d.check(signal, http.MethodPost, "/v1/chat/completions", capturedBody{data: []byte(body)}, "OpenAI")
+	return signal.Metadata["duplicate_of"]
+}
+
+func TestDuplicateWindowExpires(t *testing.T) {
+	d := duplicateDetectorFromEnv()
+	now := time.Now()
+	d.now = func() time.Time { return now }
+
+	duplicateOf(d, "sig-1", "agent-1", `{"a":1}`)
+	now = now.Add(9 * time.Second)
+	if got := duplicateOf(d, "sig-2", "agent-1", `{"a":1}`); got != "sig-1" {
+		t.Errorf("repeat after 9s flagged as %v, want sig-1", got)
+	}
+	// The window runs from the latest repeat
+	now = now.Add(10 * time.Second)
+	if got := duplicateOf(d, "sig-3", "agent-1", `{"a":1}`); got != nil {
+		t.Errorf("repeat after 10s flagged as %v, want the entry expired", got)
+	}
+	if d.order.Len() != 1 {
+		t.Errorf("store holds %d entries, want the expired one dropped", d.order.Len())
+	}
+}
+
+func TestDuplicatesPerAgentAndBounded(t *testing.T) {
+	d := duplicateDetectorFromEnv()
+	duplicateOf(d, "sig-1", "agent-1", `{"a":1}`)
+	if got := duplicateOf(d, "sig-2", "agent-2", `{"a":1}`); got != nil {
+		t.Errorf("another agent's identical request flagged as %v", got)
+	}
+	if got := duplicateOf(d, "sig-3", "agent-1", `not json`); got != nil {
+		t.Errorf("a different body flagged as %v", got)
+	}
+
+	for i := 0; i < maxDuplicateEntries+10; i++ {
+		duplicateOf(d, "sig", "agent-3", fmt.Sprintf(`{"n":%d}`, i))
+	}
+	if d.order.Len() != maxDuplicateEntries || len(d.entries) != maxDuplicateEntries {
+		t.Errorf("store holds %d entries, want at most %d", d.order.Len(), maxDuplicateEntries)
+	}
+	// The oldest were evicted
+	if got := duplicateOf(d, "sig-4", "agent-1", `{"a":1}`); got != nil {
+		t.Errorf("evicted entry still flagged as %v", got)
+	}
+}
+
+func TestDuplicateDetectionSkipsTruncatedAndDisabled(t *testing.T) {
+	d := duplicateDetectorFromEnv()
+	for _, id := range []string{"sig-1", "sig-2"} {
+		signal := &models.Signal{ID: id, AgentID: "agent-1", Metadata: map[string]interface{}{}}
+		d.check(signal, http.MethodPost, "/v1/chat/completions", capturedBody{data: []byte(`{"a":1}`), truncated: true}, "OpenAI")
+		if got, ok := signal.Metadata["duplicate_of"]; ok {
+			t.Errorf("truncated body flagged as a duplicate of %v", got)
+		}
+	}
+
+	t.Setenv("AXOM_DUPLICATE_WINDOW", "0")
+	if d := duplicateDetectorFromEnv(); d != nil {
+		t.Errorf("AXOM_DUPLICATE_WINDOW=0 gave a detector, want disabled")
+	}
+}
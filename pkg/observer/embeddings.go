@@ -0,0 +1,101 @@
+package observer
+
+import (
+	"encoding/base64"
+	"strings"
+)
+
+// isEmbeddingPath reports whether path is an embeddings endpoint, matching
+// determineOperation
+func isEmbeddingPath(path string) bool {
+	return strings.Contains(path, "/embeddings") || strings.Contains(path, "/embed")
+}
+
+// parseEmbeddingRequest records embedding_input_count, the number of inputs
+// in an embeddings request: OpenAI-style "input" (a string, an array of
+// strings or an array of token arrays), Cohere "texts", Gemini batch
+// "requests" or single "content", and Bedrock Titan "inputText"
+func parseEmbeddingRequest(request map[string]interface{}, jsonData map[string]interface{}) {
+	count := 0
+	switch input := jsonData["input"].(type) {
+	case string:
+		count = 1
+	case []interface{}:
+		count = len(input)
+		// A single token array is one input, not one per token
+		if len(input) > 0 {
+			if _, ok := input[0].(float64); ok {
+				count = 1
+			}
+		}
+	}
+	if count == 0 {
+		if texts, ok := jsonData["texts"].([]interface{}); ok {
+			count = len(texts)
+		} else if requests, ok := jsonData["requests"].([]interface{}); ok {
+			count = len(requests)
+		} else if _, ok := jsonData["content"].(map[string]interface{}); ok {
+			count = 1
+		} else if _, ok := jsonData["inputText"].(string); ok {
+			count = 1
+		}
+	}
+	if count > 0 {
+		request["embedding_input_count"] = count
+	}
+}
+
+// parseEmbeddingResponse records embedding_count and embedding_dimensions
+// (the length of the first vector) from an embeddings response: OpenAI-style
+// "data[].embedding", Cohere and Gemini batch "embeddings", and Gemini or
+// Bedrock Titan single "embedding". Base64-encoded vectors are float32s.
+func parseEmbeddingResponse(response map[string]interface{}, jsonData map[string]interface{}) {
+	var vectors []interface{}
+	if data, ok := jsonData["data"].([]interface{}); ok {
+		for _, item := range data {
+			if entry, ok := item.(map[string]interface{}); ok {
+				if vector, ok := entry["embedding"]; ok {
+					vectors = append(vectors, vector)
+				}
+			}
+		}
+	} else if embeddings, ok := jsonData["embeddings"]; ok {
+		switch embeddings := embeddings.(type) {
+		case []interface{}:
+			vectors = embeddings
+		case map[string]interface{}:
+			// Cohere v2 groups vectors by embedding type
+			if floats, ok := embeddings["float"].([]interface{}); ok {
+				vectors = floats
+			}
+		}
+	} else if embedding, ok := jsonData["embedding"]; ok {
+		vectors = []interface{}{embedding}
+	}
+	if len(vectors) == 0 {
+		return
+	}
+
+	response["embedding_count"] = len(vectors)
+	if dimensions := embeddingDimensions(vectors[0]); dimensions > 0 {
+		response["embedding_dimensions"] = dimensions
+	}
+}
+
+// embeddingDimensions returns the length of a single vector, which may be a
+// float array, a Gemini {"values": [...]} object or a base64 string
+func embeddingDimensions(vector interface{}) int {
+	switch vector := vector.(type) {
+	case []interface{}:
+		return len(vector)
+	case map[string]interface{}:
+		if values, ok := vector["values"].([]interface{}); ok {
+			return len(values)
+		}
+	case string:
+		if decoded, err := base64.StdEncoding.DecodeString(vector); err == nil {
+			return len(decoded) / 4
+		}
+	}
+	return 0
+}
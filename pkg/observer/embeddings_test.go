@@ -0,0 +1,87 @@
+package observer
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestSingleEmbedding(t *testing.T) {
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "application/json",
+		`{"object":"list","data":[{"object":"embedding","index":0,"embedding":[0.1,-0.2,0.3,0.4]}],"model":"text-embedding-3-small","usage":{"prompt_tokens":3,"total_tokens":3}}`))
+
+	signal := proxySignal(t, p, signalCh, jsonRequest("http://api.openai.com/v1/embeddings",
+		`{"model":"text-embedding-3-small","input":"The quick brown fox"}`))
+
+	for key, want := range map[string]int{
+		"embedding_input_count": 1,
+		"embedding_count":       1,
+		"embedding_dimensions":  4,
+	} {
+		if got := signal.Metadata[key]; got != want {
+			t.Errorf("%s = %v, want %d", key, got, want)
+		}
+	}
+}
+
+func TestBatchedEmbeddings(t *testing.T) {
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "application/json",
+		`{"object":"list","data":[
+			{"object":"embedding","index":0,"embedding":[0.1,0.2,0.3]},
+			{"object":"embedding","index":1,"embedding":[0.4,0.5,0.6]},
+			{"object":"embedding","index":2,"embedding":[0.7,0.8,0.9]}
+		],"model":"text-embedding-3-small"}`))
+
+	signal := proxySignal(t, p, signalCh, jsonRequest("http://api.openai.com/v1/embeddings",
+		`{"model":"text-embedding-3-small","input":["first","second","third"]}`))
+
+	for key, want := range map[string]int{
+		"embedding_input_count": 3,
+		"embedding_count":       3,
+		"embedding_dimensions":  3,
+	} {
+		if got := signal.Metadata[key]; got != want {
+			t.Errorf("%s = %v, want %d", key, got, want)
+		}
+	}
+}
+
+func TestParseEmbeddingRequest(t *testing.T) {
+	tests := map[string]struct {
+		body map[string]interface{}
+		want interface{}
+	}{
+		"string":       {map[string]interface{}{"input": "hello"}, 1},
+		"strings":      {map[string]interface{}{"input": []interface{}{"a", "b"}}, 2},
+		"token array":  {map[string]interface{}{"input": []interface{}{1.0, 2.0, 3.0}}, 1},
+		"token arrays": {map[string]interface{}{"input": []interface{}{[]interface{}{1.0}, []interface{}{2.0}}}, 2},
+		"cohere":       {map[string]interface{}{"texts": []interface{}{"a", "b", "c"}}, 3},
+		"gemini batch": {map[string]interface{}{"requests": []interface{}{map[string]interface{}{}, map[string]interface{}{}}}, 2},
+		"titan":        {map[string]interface{}{"inputText": "hello"}, 1},
+		"no input":     {map[string]interface{}{"model": "m"}, nil},
+	}
+	for name, tt := range tests {
+		request := make(map[string]interface{})
+		parseEmbeddingRequest(request, tt.body)
+		if got := request["embedding_input_count"]; got != tt.want {
+			t.Errorf("%s: embedding_input_count = %v, want %v", name, got, tt.want)
+		}
+	}
+}
+
+func TestEmbeddingDimensions(t *testing.T) {
+	tests := map[string]struct {
+		vector interface{}
+		want   int
+	}{
+		"floats": {[]interface{}{0.1, 0.2}, 2},
+		"gemini": {map[string]interface{}{"values": []interface{}{0.1, 0.2, 0.3}}, 3},
+		// Four little-endian float32s
+		"base64":  {"AAAAAAAAAAAAAAAAAAAAAA==", 4},
+		"invalid": {"not base64!", 0},
+	}
+	for name, tt := range tests {
+		if got := embeddingDimensions(tt.vector); got != tt.want {
+			t.Errorf("%s: embeddingDimensions = %d, want %d", name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package observer
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Environment variables:
+//   AXOM_EXTRACT_RULES_FILE - Optional. YAML file of per-path metadata extraction rules (see extractRulesFile). Default: disabled
+
+// extractRulesFile is the on-disk layout of the extraction rules:
+//
+//	paths:
+//	  - pattern: /v1/chat/completions
+//	    extract:
+//	      - jsonpath: $.choices[0].finish_reason
+//	        metric: finish_reason
+//	      - jsonpath: $.user
+//	        source: request
+//	        metric: end_user
+//	      - header: x-request-id
+//	        metric: provider_request_id
+type extractRulesFile struct {
+	Paths []pathExtractRules `yaml:"paths"`
+}
+
+// pathExtractRules are the rules applied to requests whose URL path matches
+// Pattern (a prefix, or a whole-path pattern when it contains "*")
+type pathExtractRules struct {
+	Pattern string        `yaml:"pattern"`
+	Extract []extractRule `yaml:"extract"`
+}
+
+// extractRule copies one JSONPath value or header into metadata[Metric].
+// Source selects the request or response (default) body and headers.
+type extractRule struct {
+	JSONPath string `yaml:"jsonpath"`
+	Header   string `yaml:"header"`
+	Source   string `yaml:"source"`
+	Metric   string `yaml:"metric"`
+}
+
+// extractRules applies configured extraction rules to proxied traffic
+type extractRules struct {
+	paths []pathExtractRules
+}
+
+// extractRulesFromEnv loads the rules named by AXOM_EXTRACT_RULES_FILE. It
+// returns nil when no file is configured or the file is invalid.
+func extractRulesFromEnv(logger *log.Logger) *extractRules {
+	path := os.Getenv("AXOM_EXTRACT_RULES_FILE")
+	if path == "" {
+		return nil
+	}
+	rules, err := loadExtractRules(path)
+	if err != nil {
+		logger.Printf("⚠️ Failed to load extraction rules %s: %v", path, err)
+		return nil
+	}
+	return rules
+}
+
+// loadExtractRules reads and validates a YAML rules file
+func loadExtractRules(path string) (*extractRules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file extractRulesFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse extraction rules: %w", err)
+	}
+	for _, p := range file.Paths {
+		if p.Pattern == "" {
+			return nil, fmt.Errorf("extraction rule missing path pattern")
+		}
+		for _, rule := range p.Extract {
+			if rule.Metric == "" {
+				return nil, fmt.Errorf("extraction rule for %s missing metric name", p.Pattern)
+			}
+			if (rule.JSONPath == "") == (rule.Header == "") {
+				return nil, fmt.Errorf("extraction rule %s must set exactly one of jsonpath or header", rule.Metric)
+			}
+			if rule.Source != "" && rule.Source != "request" && rule.Source != "response" {
+				return nil, fmt.Errorf("extraction rule %s has unknown source %q", rule.Metric, rule.Source)
+			}
+			if rule.JSONPath != "" {
+				if _, err := parseJSONPath(rule.JSONPath); err != nil {
+					return nil, fmt.Errorf("extraction rule %s: %w", rule.Metric, err)
+				}
+			}
+		}
+	}
+	return &extractRules{paths: file.Paths}, nil
+}
+
+// apply evaluates the rules for source ("request" or "response") against
+// body and header, storing results in fields. Rules whose path doesn't
+// resolve are skipped.
+func (e *extractRules) apply(fields map[string]interface{}, source, urlPath string, body []byte, header http.Header) {
+	if e == nil {
+		return
+	}
+	var doc interface{}
+	parsed := false
+	for _, p := range e.paths {
+		if !matchPathPattern(p.Pattern, urlPath) {
+			continue
+		}
+		for _, rule := range p.Extract {
+			ruleSource := rule.Source
+			if ruleSource == "" {
+				ruleSource = "response"
+			}
+			if ruleSource != source {
+				continue
+			}
+
+			if rule.Header != "" {
+				if value := header.Get(rule.Header); value != "" {
+					fields[rule.Metric] = value
+				}
+				continue
+			}
+
+			if !parsed {
+				parsed = true
+				if err := json.Unmarshal(body, &doc); err != nil {
+					doc = nil
+				}
+			}
+			if doc == nil {
+				continue
+			}
+			if value, ok := evalJSONPath(doc, rule.JSONPath); ok {
+				fields[rule.Metric] = value
+			}
+		}
+	}
+}
+
+// jsonPathStep is one step of a parsed JSONPath: a key, an index, or a
+// wildcard over array elements
+type jsonPathStep struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// parseJSONPath parses the supported JSONPath subset: $, .key, ['key'],
+// [n] (negative counts from the end) and [*]
+func parseJSONPath(path string) ([]jsonPathStep, error) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(path), "$")
+	if !ok {
+		return nil, fmt.Errorf("jsonpath %q must start with $", path)
+	}
+	var steps []jsonPathStep
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("jsonpath %q has an empty key", path)
+			}
+			steps = append(steps, jsonPathStep{key: rest[:end]})
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("jsonpath %q has an unclosed bracket", path)
+			}
+			inner := strings.TrimSpace(rest[1:end])
+			rest = rest[end+1:]
+			switch {
+			case inner == "*":
+				steps = append(steps, jsonPathStep{wildcard: true})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				steps = append(steps, jsonPathStep{key: inner[1 : len(inner)-1]})
+			default:
+				n, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("jsonpath %q has an invalid index %q", path, inner)
+				}
+				steps = append(steps, jsonPathStep{index: n, isIndex: true})
+			}
+		default:
+			return nil, fmt.Errorf("jsonpath %q has unexpected %q", path, rest[0])
+		}
+	}
+	return steps, nil
+}
+
+// evalJSONPath evaluates path against a decoded JSON document. A wildcard
+// step yields a list of the values it reaches.
+func evalJSONPath(doc interface{}, path string) (interface{}, bool) {
+	steps, err := parseJSONPath(path)
+	if err != nil {
+		return nil, false
+	}
+	return evalJSONPathSteps(doc, steps)
+}
+
+func evalJSONPathSteps(node interface{}, steps []jsonPathStep) (interface{}, bool) {
+	for i, step := range steps {
+		switch {
+		case step.wildcard:
+			items, ok := node.([]interface{})
+			if !ok {
+				return nil, false
+			}
+			var results []interface{}
+			for _, item := range items {
+				if value, ok := evalJSONPathSteps(item, steps[i+1:]); ok {
+					results = append(results, value)
+				}
+			}
+			return results, len(results) > 0
+		case step.isIndex:
+			items, ok := node.([]interface{})
+			if !ok {
+				return nil, false
+			}
+			index := step.index
+			if index < 0 {
+				index += len(items)
+			}
+			if index < 0 || index >= len(items) {
+				return nil, false
+			}
+			node = items[index]
+		default:
+			object, ok := node.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			node, ok = object[step.key]
+			if !ok {
+				return nil, false
+			}
+		}
+	}
+	return node, true
+}
@@ -0,0 +1,115 @@
+package observer
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const sampleExtractRules = `
+paths:
+  - pattern: /v1/chat/completions
+    extract:
+      - jsonpath: $.choices[0].finish_reason
+        metric: finish
+      - jsonpath: $.choices[*].message.role
+        metric: roles
+      - jsonpath: $.user
+        source: request
+        metric: end_user
+      - header: x-request-id
+        metric: provider_request_id
+  - pattern: /v1/embeddings
+    extract:
+      - jsonpath: $.usage.prompt_tokens
+        metric: embedding_tokens
+`
+
+// writeExtractRules writes rules to a temporary file and returns its path
+func writeExtractRules(t *testing.T, rules string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "extract.yaml")
+	if err := os.WriteFile(path, []byte(rules), 0o600); err != nil {
+		t.Fatalf("write rules: %v", err)
+	}
+	return path
+}
+
+func TestExtractRulesCopyIntoMetadata(t *testing.T) {
+	t.Setenv("AXOM_EXTRACT_RULES_FILE", writeExtractRules(t, sampleExtractRules))
+
+	upstream := ForwarderFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := cannedResponse(http.StatusOK, "application/json",
+			`{"model": "gpt-4", "choices": [{"message": {"role": "assistant", "content": "Hi"}, "finish_reason": "stop"}], "usage": {"prompt_tokens": 5, "completion_tokens": 1, "total_tokens": 6}}`).Forward(req)
+		if resp != nil {
+			resp.Header.Set("X-Request-Id", "req_abc123")
+		}
+		return resp, err
+	})
+	p, signalCh := newTestHTTPProxy(t, upstream)
+	signal := proxySignal(t, p, signalCh, chatRequest(`{"model": "gpt-4", "user": "user-7", "messages": [{"role": "user", "content": "Hi"}]}`))
+
+	for key, want := range map[string]interface{}{
+		"finish":              "stop",
+		"roles":               []interface{}{"assistant"},
+		"end_user":            "user-7",
+		"provider_request_id": "req_abc123",
+	} {
+		if got := signal.Metadata[key]; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s = %#v, want %#v", key, got, want)
+		}
+	}
+	if _, ok := signal.Metadata["embedding_tokens"]; ok {
+		t.Errorf("rule for another path applied to a chat completion")
+	}
+}
+
+func TestLoadExtractRulesRejectsInvalid(t *testing.T) {
+	tests := map[string]string{
+		"missing pattern": "paths:\n  - extract:\n      - header: x-id\n        metric: id\n",
+		"missing metric":  "paths:\n  - pattern: /v1\n    extract:\n      - header: x-id\n",
+		"both kinds":      "paths:\n  - pattern: /v1\n    extract:\n      - header: x-id\n        jsonpath: $.id\n        metric: id\n",
+		"unknown source":  "paths:\n  - pattern: /v1\n    extract:\n      - header: x-id\n        source: body\n        metric: id\n",
+		"bad jsonpath":    "paths:\n  - pattern: /v1\n    extract:\n      - jsonpath: choices[0]\n        metric: id\n",
+		"not yaml":        "paths: [",
+	}
+	for name, rules := range tests {
+		if _, err := loadExtractRules(writeExtractRules(t, rules)); err == nil {
+			t.Errorf("%s: loadExtractRules accepted invalid rules", name)
+		}
+	}
+
+	t.Setenv("AXOM_EXTRACT_RULES_FILE", writeExtractRules(t, tests["missing metric"]))
+	if rules := extractRulesFromEnv(testLogger()); rules != nil {
+		t.Errorf("extractRulesFromEnv returned rules from an invalid file")
+	}
+}
+
+func TestEvalJSONPath(t *testing.T) {
+	var doc interface{}
+	json.Unmarshal([]byte(`{"a": {"b-c": [1, 2, 3]}, "items": [{"id": "x"}, {"name": "y"}, {"id": "z"}]}`), &doc)
+
+	tests := []struct {
+		path string
+		want interface{}
+		ok   bool
+	}{
+		{"$", doc, true},
+		{"$.a['b-c'][1]", float64(2), true},
+		{"$.a[\"b-c\"][-1]", float64(3), true},
+		{"$.items[*].id", []interface{}{"x", "z"}, true},
+		{"$.a['b-c'][3]", nil, false},
+		{"$.missing", nil, false},
+		{"$.a.b-c.x", nil, false},
+		{"a.b", nil, false},
+	}
+	for _, tt := range tests {
+		got, ok := evalJSONPath(doc, tt.path)
+		if ok != tt.ok || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("evalJSONPath(%q) = %#v, %v, want %#v, %v", tt.path, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package observer
+
+import (
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Environment variables:
+//   AXOM_BACKEND_URLS - Optional. Comma-separated backend URLs to fail over between, in order of preference; overrides the single backend URL. Default: the backend URL
+
+// backendEndpoints is the set of backend URLs a sender fails over between.
+// Batches go to the preferred endpoint, which is the last one known to be
+// good; a failure there moves the preference on to the next URL in order.
+type backendEndpoints struct {
+	mu        sync.Mutex
+	urls      []string
+	health    []endpointHealth
+	preferred int
+}
+
+// endpointHealth is the recent send history of one backend URL
+type endpointHealth struct {
+	failures    int // consecutive
+	lastSuccess time.Time
+	lastFailure time.Time
+}
+
+// backendEndpointsFromEnv returns the endpoints from AXOM_BACKEND_URLS, or
+// from url, which may itself be a comma-separated list
+func backendEndpointsFromEnv(url string) *backendEndpoints {
+	if v := os.Getenv("AXOM_BACKEND_URLS"); v != "" {
+		url = v
+	}
+	var urls []string
+	for _, u := range strings.Split(url, ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			urls = append(urls, u)
+		}
+	}
+	return &backendEndpoints{urls: urls, health: make([]endpointHealth, len(urls))}
+}
+
+// current returns the preferred URL
+func (e *backendEndpoints) current() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.urls) == 0 {
+		return ""
+	}
+	return e.urls[e.preferred]
+}
+
+// count returns the number of URLs
+func (e *backendEndpoints) count() int {
+	return len(e.urls)
+}
+
+// success records that url accepted a request and makes it the preferred URL
+func (e *backendEndpoints) success(url string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	i := e.index(url)
+	if i < 0 {
+		return
+	}
+	e.health[i].failures = 0
+	e.health[i].lastSuccess = time.Now()
+	e.preferred = i
+}
+
+// failure records that url failed and, if it was preferred, moves the
+// preference on to the next URL
+func (e *backendEndpoints) failure(url string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	i := e.index(url)
+	if i < 0 {
+		return
+	}
+	e.health[i].failures++
+	e.health[i].lastFailure = time.Now()
+	if i == e.preferred && len(e.urls) > 1 {
+		e.preferred = (i + 1) % len(e.urls)
+		log.Printf("[observer] Backend %s failed, failing over to %s", redactURL(url), redactURL(e.urls[e.preferred]))
+	}
+}
+
+// index returns the position of url, or -1
+func (e *backendEndpoints) index(url string) int {
+	for i, u := range e.urls {
+		if u == url {
+			return i
+		}
+	}
+	return -1
+}
+
+// status describes each URL's health, for EffectiveConfig
+func (e *backendEndpoints) status() []map[string]interface{} {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	status := make([]map[string]interface{}, len(e.urls))
+	for i, u := range e.urls {
+		entry := map[string]interface{}{
+			"url":       redactURL(u),
+			"preferred": i == e.preferred,
+			"failures":  e.health[i].failures,
+		}
+		if !e.health[i].lastSuccess.IsZero() {
+			entry["last_success"] = e.health[i].lastSuccess.UTC().Format(time.RFC3339)
+		}
+		if !e.health[i].lastFailure.IsZero() {
+			entry["last_failure"] = e.health[i].lastFailure.UTC().Format(time.RFC3339)
+		}
+		status[i] = entry
+	}
+	return status
+}
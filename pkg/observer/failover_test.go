@@ -0,0 +1,82 @@
+package observer
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"axom-observer/pkg/models"
+)
+
+func TestSenderFailsOverToSecondBackend(t *testing.T) {
+	t.Setenv("AXOM_STARTUP_GRACE", "0")
+	t.Setenv("AXOM_DLQ_PATH", "")
+	var primaryRequests atomic.Int32
+	primary := newTestBackend(t, func() int {
+		primaryRequests.Add(1)
+		return http.StatusInternalServerError
+	})
+	secondary := newTestBackend(t, nil)
+	t.Setenv("AXOM_BACKEND_URLS", primary.URL+", "+secondary.URL)
+	s := NewSignalSender("test-key", "https://unused.example.com", 10, time.Hour)
+
+	// Failing over doesn't wait out a retry backoff
+	start := time.Now()
+	if err := s.sendBatchWithRetry(context.Background(), []models.Signal{testSignal("sig-1", nil)}); err != nil {
+		t.Fatalf("sendBatchWithRetry: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("failover took %v, want no backoff before trying the second backend", elapsed)
+	}
+	if got := secondary.received(); len(got) != 1 || got[0].ID != "sig-1" {
+		t.Fatalf("secondary received %v, want sig-1", got)
+	}
+
+	// The last-known-good backend stays preferred
+	if err := s.sendBatchWithRetry(context.Background(), []models.Signal{testSignal("sig-2", nil)}); err != nil {
+		t.Fatalf("sendBatchWithRetry: %v", err)
+	}
+	if n := primaryRequests.Load(); n != 1 {
+		t.Errorf("primary saw %d requests, want 1 before failing over", n)
+	}
+	if got := secondary.received(); len(got) != 2 {
+		t.Errorf("secondary received %d signals, want 2", len(got))
+	}
+
+	status := s.endpoints.status()
+	if status[0]["failures"] != 1 || status[0]["preferred"] != false || status[1]["preferred"] != true {
+		t.Errorf("endpoint status = %v, want the primary failed once and the secondary preferred", status)
+	}
+}
+
+func TestBackendEndpointsRotate(t *testing.T) {
+	e := backendEndpointsFromEnv(" https://a.example.com ,https://b.example.com,, https://c.example.com")
+	if e.count() != 3 || e.current() != "https://a.example.com" {
+		t.Fatalf("endpoints = %v, want three URLs starting with a", e.urls)
+	}
+	for _, want := range []string{"https://b.example.com", "https://c.example.com", "https://a.example.com"} {
+		e.failure(e.current())
+		if got := e.current(); got != want {
+			t.Errorf("after a failure current = %s, want %s", got, want)
+		}
+	}
+
+	// A failure of a URL that isn't preferred doesn't move the preference
+	e.failure("https://c.example.com")
+	if got := e.current(); got != "https://a.example.com" {
+		t.Errorf("current = %s after another URL failed, want a", got)
+	}
+	e.success("https://c.example.com")
+	if got := e.current(); got != "https://c.example.com" {
+		t.Errorf("current = %s after c succeeded, want c", got)
+	}
+}
+
+func TestBackendURLsEnvOverridesURL(t *testing.T) {
+	t.Setenv("AXOM_BACKEND_URLS", "https://a.example.com,https://b.example.com")
+	if e := backendEndpointsFromEnv("https://single.example.com"); e.count() != 2 || e.current() != "https://a.example.com" {
+		t.Errorf("endpoints = %v, want AXOM_BACKEND_URLS", e.urls)
+	}
+}
@@ -0,0 +1,26 @@
+package observer
+
+// extractOpenAIFinishReason records choices[0].finish_reason ("stop",
+// "length", "content_filter", "tool_calls", ...) as response["finish_reason"]
+func extractOpenAIFinishReason(response map[string]interface{}, jsonData map[string]interface{}) {
+	choices, ok := jsonData["choices"].([]interface{})
+	if !ok || len(choices) == 0 {
+		return
+	}
+	choice, ok := choices[0].(map[string]interface{})
+	if !ok {
+		return
+	}
+	if reason, ok := choice["finish_reason"].(string); ok && reason != "" {
+		response["finish_reason"] = reason
+	}
+}
+
+// extractAnthropicStopReason records stop_reason ("end_turn", "max_tokens",
+// "stop_sequence", "tool_use", ...) as response["finish_reason"], so both
+// providers' reasons share one key
+func extractAnthropicStopReason(response map[string]interface{}, jsonData map[string]interface{}) {
+	if reason, ok := jsonData["stop_reason"].(string); ok && reason != "" {
+		response["finish_reason"] = reason
+	}
+}
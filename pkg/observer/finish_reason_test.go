@@ -0,0 +1,71 @@
+package observer
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestOpenAIFinishReason(t *testing.T) {
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "application/json",
+		`{"id":"chatcmpl-1","choices":[{"index":0,"message":{"role":"assistant","content":"The first ten primes are 2, 3"},"finish_reason":"length"}],"usage":{"prompt_tokens":9,"completion_tokens":8,"total_tokens":17}}`))
+	signal := proxySignal(t, p, signalCh, chatRequest(`{"model": "gpt-4", "max_tokens": 8, "messages": [{"role": "user", "content": "List the first ten primes"}]}`))
+
+	if got := signal.Metadata["finish_reason"]; got != "length" {
+		t.Errorf("finish_reason = %v, want length", got)
+	}
+}
+
+func TestAnthropicStopReason(t *testing.T) {
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "application/json",
+		`{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"text","text":"Checking the weather."},{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{"city":"Paris"}}],"stop_reason":"tool_use","usage":{"input_tokens":20,"output_tokens":12}}`))
+	signal := proxySignal(t, p, signalCh, jsonRequest("http://api.anthropic.com/v1/messages",
+		`{"model":"claude-3-5-sonnet-20241022","max_tokens":256,"messages":[{"role":"user","content":"Weather in Paris?"}]}`))
+
+	if got := signal.Metadata["finish_reason"]; got != "tool_use" {
+		t.Errorf("finish_reason = %v, want tool_use", got)
+	}
+}
+
+func TestStreamedFinishReason(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		body string
+		want string
+	}{
+		{"OpenAI", "http://api.openai.com/v1/chat/completions",
+			`data: {"choices":[{"delta":{"content":"Hi"},"finish_reason":null}]}` + "\n\n" +
+				`data: {"choices":[{"delta":{},"finish_reason":"content_filter"}]}` + "\n\n" +
+				"data: [DONE]\n\n",
+			"content_filter"},
+		{"Anthropic", "http://api.anthropic.com/v1/messages",
+			"event: message_start\n" + `data: {"type":"message_start","message":{"usage":{"input_tokens":5}}}` + "\n\n" +
+				"event: content_block_delta\n" + `data: {"type":"content_block_delta","delta":{"type":"text_delta","text":"Hi"}}` + "\n\n" +
+				"event: message_delta\n" + `data: {"type":"message_delta","delta":{"stop_reason":"max_tokens"},"usage":{"output_tokens":1}}` + "\n\n",
+			"max_tokens"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "text/event-stream", tt.body))
+			signal := proxySignal(t, p, signalCh, jsonRequest(tt.url,
+				`{"model": "m", "stream": true, "max_tokens": 1, "messages": [{"role": "user", "content": "Hi"}]}`))
+			if got := signal.Metadata["finish_reason"]; got != tt.want {
+				t.Errorf("finish_reason = %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOutcomeConditionOnFinishReason(t *testing.T) {
+	d, _ := newTestTaskDetector()
+	signal := taskTestSignal("sig-1", "Summarize the report", "The report says", 0, 0, 0)
+	signal.Metadata["finish_reason"] = "length"
+
+	if !d.matchesConditions(signal, "", map[string]string{"finish_reason": "^length$"}) {
+		t.Errorf("finish_reason condition didn't match a truncated completion")
+	}
+	signal.Metadata["finish_reason"] = "stop"
+	if d.matchesConditions(signal, "", map[string]string{"finish_reason": "^length$"}) {
+		t.Errorf("finish_reason condition matched a completed response")
+	}
+}
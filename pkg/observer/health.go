@@ -0,0 +1,188 @@
+package observer
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Environment variables:
+//   AXOM_HEALTH_PORT - Optional. Port for the /healthz and /readyz endpoints. Default: 8081
+
+// backendCheckTTL is how long a backend reachability result is reused, so
+// frequent readiness probes don't hammer the backend
+const backendCheckTTL = 10 * time.Second
+
+// HealthServer serves Kubernetes liveness (/healthz) and readiness (/readyz)
+// probes
+type HealthServer struct {
+	addr     string
+	logger   *log.Logger
+	monitor  *AITrafficMonitor
+	sender   *SignalSender
+	server   *http.Server
+	listener net.Listener
+
+	mu            sync.Mutex
+	lastCheck     time.Time
+	lastCheckErr  error
+	checkInFlight bool
+}
+
+// NewHealthServer creates a health server for the monitor's proxies. If addr
+// is empty, the port is taken from AXOM_HEALTH_PORT (default 8081). sender
+// may be nil, in which case readiness doesn't check the backend.
+func NewHealthServer(addr string, logger *log.Logger, monitor *AITrafficMonitor, sender *SignalSender) *HealthServer {
+	if addr == "" {
+		port := os.Getenv("AXOM_HEALTH_PORT")
+		if port == "" {
+			port = "8081"
+		}
+		addr = ":" + port
+	}
+	return &HealthServer{
+		addr:    addr,
+		logger:  logger,
+		monitor: monitor,
+		sender:  sender,
+	}
+}
+
+// Handler returns the mux serving /healthz and /readyz
+func (h *HealthServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", h.handleHealthz)
+	mux.HandleFunc("/readyz", h.handleReadyz)
+	return mux
+}
+
+// Start binds the health listener and serves in the background until Stop
+// is called or ctx is cancelled
+func (h *HealthServer) Start(ctx context.Context) error {
+	listener, err := net.Listen("tcp", h.addr)
+	if err != nil {
+		return err
+	}
+	h.listener = listener
+	h.server = &http.Server{Handler: h.Handler()}
+
+	go func() {
+		if err := h.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			h.logger.Printf("Health server error: %v", err)
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		h.Stop(shutdownCtx)
+	}()
+
+	h.logger.Printf("💓 Health probes available at %s/healthz and /readyz", listener.Addr())
+	return nil
+}
+
+// Stop gracefully shuts down the health server
+func (h *HealthServer) Stop(ctx context.Context) error {
+	if h.server != nil {
+		return h.server.Shutdown(ctx)
+	}
+	return nil
+}
+
+// Addr returns the address the server is listening on
+func (h *HealthServer) Addr() string {
+	if h.listener != nil {
+		return h.listener.Addr().String()
+	}
+	return h.addr
+}
+
+// healthStatus is the /healthz response body
+type healthStatus struct {
+	Status string    `json:"status"`
+	CA     *caStatus `json:"mitm_ca,omitempty"`
+}
+
+// caStatus reports the MITM CA self-check in /healthz
+type caStatus struct {
+	OK            bool   `json:"ok"`
+	Error         string `json:"error,omitempty"`
+	CertPath      string `json:"cert_path"`
+	Fingerprint   string `json:"fingerprint,omitempty"`
+	SystemTrusted bool   `json:"system_trusted"`
+}
+
+// handleHealthz reports live once the proxies are up, with the MITM CA
+// self-check result when TLS interception is enabled. A failed self-check
+// is reported but doesn't fail liveness: restarting won't fix the CA, and
+// traffic to hosts that aren't intercepted still flows.
+func (h *HealthServer) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	if !h.monitor.ProxiesStarted() {
+		http.Error(w, "proxies not started", http.StatusServiceUnavailable)
+		return
+	}
+	status := healthStatus{Status: "ok"}
+	if check := h.monitor.CACheck(); check != nil {
+		status.CA = &caStatus{
+			OK:            check.Err == nil,
+			CertPath:      check.CertPath,
+			Fingerprint:   check.Fingerprint,
+			SystemTrusted: check.SystemTrusted,
+		}
+		if check.Err != nil {
+			status.CA.Error = check.Err.Error()
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// handleReadyz reports ready once both proxies have started and the backend
+// is reachable
+func (h *HealthServer) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if !h.monitor.ProxiesStarted() {
+		http.Error(w, "proxies not started", http.StatusServiceUnavailable)
+		return
+	}
+	if err := h.backendStatus(r.Context()); err != nil {
+		http.Error(w, "backend unreachable: "+err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ready"))
+}
+
+// backendStatus returns the cached backend reachability, refreshing it when
+// older than backendCheckTTL
+func (h *HealthServer) backendStatus(ctx context.Context) error {
+	if h.sender == nil {
+		return nil
+	}
+	h.mu.Lock()
+	if time.Since(h.lastCheck) < backendCheckTTL || h.checkInFlight {
+		err := h.lastCheckErr
+		h.mu.Unlock()
+		return err
+	}
+	h.checkInFlight = true
+	h.mu.Unlock()
+
+	checkCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	// Probe only: readiness polling must not move sends between backends
+	err := h.sender.probeBackend(checkCtx, h.sender.endpoints.current())
+
+	h.mu.Lock()
+	h.lastCheck = time.Now()
+	h.lastCheckErr = err
+	h.checkInFlight = false
+	h.mu.Unlock()
+	return err
+}
@@ -0,0 +1,74 @@
+package observer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"axom-observer/pkg/models"
+)
+
+// probe returns the status code h serves for path
+func probe(h *HealthServer, path string) int {
+	rec := httptest.NewRecorder()
+	h.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec.Code
+}
+
+func TestHealthProbesBeforeAndAfterProxiesStart(t *testing.T) {
+	backend := newTestBackend(t, nil)
+	sender := NewSignalSender("test-key", backend.URL, 10, 0)
+	monitor := NewAITrafficMonitor(make(chan models.Signal, 1), testLogger(), "test-customer", "test-agent")
+	h := NewHealthServer("127.0.0.1:0", testLogger(), monitor, sender)
+
+	if code := probe(h, "/healthz"); code != http.StatusServiceUnavailable {
+		t.Errorf("/healthz before start = %d, want 503", code)
+	}
+	if code := probe(h, "/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz before start = %d, want 503", code)
+	}
+
+	// Only the HTTP proxy up is not ready yet
+	monitor.httpStarted.Store(true)
+	if code := probe(h, "/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz with one proxy started = %d, want 503", code)
+	}
+
+	monitor.productionStarted.Store(true)
+	if code := probe(h, "/healthz"); code != http.StatusOK {
+		t.Errorf("/healthz after start = %d, want 200", code)
+	}
+	if code := probe(h, "/readyz"); code != http.StatusOK {
+		t.Errorf("/readyz after start = %d, want 200", code)
+	}
+}
+
+func TestReadyzFailsWhenBackendUnreachable(t *testing.T) {
+	backend := httptest.NewServer(http.NotFoundHandler())
+	url := backend.URL
+	backend.Close()
+
+	sender := NewSignalSender("test-key", url, 10, 0)
+	monitor := NewAITrafficMonitor(make(chan models.Signal, 1), testLogger(), "test-customer", "test-agent")
+	monitor.httpStarted.Store(true)
+	monitor.productionStarted.Store(true)
+	h := NewHealthServer("127.0.0.1:0", testLogger(), monitor, sender)
+
+	if code := probe(h, "/healthz"); code != http.StatusOK {
+		t.Errorf("/healthz = %d, want 200 regardless of the backend", code)
+	}
+	if code := probe(h, "/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz with the backend down = %d, want 503", code)
+	}
+}
+
+func TestReadyzWithoutSender(t *testing.T) {
+	monitor := NewAITrafficMonitor(make(chan models.Signal, 1), testLogger(), "test-customer", "test-agent")
+	monitor.httpStarted.Store(true)
+	monitor.productionStarted.Store(true)
+	h := NewHealthServer("127.0.0.1:0", testLogger(), monitor, nil)
+
+	if code := probe(h, "/readyz"); code != http.StatusOK {
+		t.Errorf("/readyz without a sender = %d, want 200", code)
+	}
+}
@@ -2,7 +2,6 @@ package observer
 
 import (
 	"bufio"
-	"bytes"
 	"context"
 	"crypto/rand"
 	"crypto/rsa"
@@ -17,34 +16,87 @@ import (
 	"math/big"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"axom-observer/pkg/models"
+	"axom-observer/pkg/pricing"
 )
 
 // HTTPSProxy handles HTTPS traffic with MITM capabilities
 type HTTPSProxy struct {
-	port         string
-	signalCh     chan<- models.Signal
-	logger       *log.Logger
-	customerID   string
-	agentID      string
-	taskDetector *TaskDetector
-	server       *http.Server
-	caCert       *x509.Certificate
-	caKey        *rsa.PrivateKey
+	port                string
+	signalCh            chan<- models.Signal
+	logger              *log.Logger
+	customerID          string
+	agentID             string
+	taskDetector        *TaskDetector
+	server              *http.Server
+	caCert              *x509.Certificate
+	caKey               *rsa.PrivateKey
+	certMutex           sync.RWMutex // guards caCert and caKey
+	certs               *leafCertStore
+	moderator           *LocalModerator
+	costEstimator       *pricing.CostEstimator
+	identity            identityHeaders
+	metadataLimits      metadataLimits
+	metadataFilter      metadataFilter
+	duplicates          *duplicateDetector
+	resolver            *hostResolver
+	detectDebug         bool
+	enqueuer            signalEnqueuer
+	forwarder           Forwarder
+	dialer              *upstreamDialer
+	inflight            *inflightLimiter
+	sampler             signalSampler
+	azureModels         azureDeploymentModels
+	bodyLimit           bodyLimit
+	extractRules        *extractRules
+	providers           *ProviderRegistry
+	conversationSummary bool
+	estimateTokens      bool
+	preview             previewLimit
+	upstreamOverrides   upstreamOverrides
+	mitmAllHosts        bool
+	intercept           interceptAllowlist
+	caCheck             caCheckState
 }
 
 // NewHTTPSProxy creates a new HTTPS proxy
 func NewHTTPSProxy(port string, signalCh chan<- models.Signal, logger *log.Logger, customerID, agentID string) *HTTPSProxy {
 	return &HTTPSProxy{
-		port:         port,
-		signalCh:     signalCh,
-		logger:       logger,
-		customerID:   customerID,
-		agentID:      agentID,
-		taskDetector: NewTaskDetector(signalCh, logger, customerID, agentID),
+		port:                port,
+		signalCh:            signalCh,
+		logger:              logger,
+		customerID:          customerID,
+		agentID:             agentID,
+		taskDetector:        NewTaskDetector(signalCh, logger, customerID, agentID),
+		certs:               newLeafCertStore(0),
+		moderator:           newLocalModeratorFromEnv(),
+		costEstimator:       pricing.NewCostEstimator("", logger),
+		identity:            identityHeadersFromEnv(),
+		metadataLimits:      metadataLimitsFromEnv(),
+		metadataFilter:      metadataFilterFromEnv(),
+		duplicates:          duplicateDetectorFromEnv(),
+		resolver:            hostResolverFromEnv(),
+		detectDebug:         detectDebugEnabled(),
+		enqueuer:            signalEnqueuerFromEnv(),
+		forwarder:           newUpstreamForwarder(logger),
+		dialer:              newUpstreamDialer(logger),
+		inflight:            inflightLimiterFromEnv("https"),
+		sampler:             signalSamplerFromEnv(),
+		azureModels:         azureDeploymentModelsFromEnv(),
+		bodyLimit:           bodyLimitFromEnv(),
+		extractRules:        extractRulesFromEnv(logger),
+		providers:           NewProviderRegistry("", logger),
+		conversationSummary: conversationSummaryFromEnv(),
+		estimateTokens:      tokenEstimationFromEnv(),
+		preview:             previewLimitFromEnv(),
+		upstreamOverrides:   upstreamOverridesFromEnv(logger),
+		mitmAllHosts:        mitmAllHostsFromEnv(),
+		intercept:           interceptAllowlistFromEnv(),
 	}
 }
 
@@ -52,11 +104,20 @@ func NewHTTPSProxy(port string, signalCh chan<- models.Signal, logger *log.Logge
 func (p *HTTPSProxy) Start(ctx context.Context) error {
 	p.logger.Printf("Starting HTTPS proxy on port %s", p.port)
 
+	// Expire tasks that stop receiving signals
+	go p.taskDetector.Run(ctx)
+
 	// Load or generate CA certificate and key
 	if err := p.loadOrGenerateCA(); err != nil {
 		return fmt.Errorf("failed to load or generate CA: %w", err)
 	}
 
+	// Check that clients trusting the CA file can handshake with our leaves
+	p.runCACheck()
+
+	// Pick up CA rotations without a restart
+	go p.watchCA(ctx, "certs/ca.crt", "certs/ca.key", caReloadIntervalFromEnv())
+
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", p.handleRequest)
 
@@ -82,6 +143,26 @@ func (p *HTTPSProxy) Stop(ctx context.Context) error {
 	return nil
 }
 
+// runCACheck runs the MITM CA self-check and logs and stores the result
+func (p *HTTPSProxy) runCACheck() {
+	caCert, _ := p.currentCA()
+	check := checkMITMCA("certs/ca.crt", caCert, p.generateCert)
+	logCACheck(p.logger, check)
+	p.caCheck.set(check)
+}
+
+// CACheck returns the result of the startup CA self-check, or nil before it
+// has run
+func (p *HTTPSProxy) CACheck() *CACheck {
+	return p.caCheck.get()
+}
+
+// SetForwarder replaces how requests are sent upstream. It must be called
+// before Start.
+func (p *HTTPSProxy) SetForwarder(forwarder Forwarder) {
+	p.forwarder = forwarder
+}
+
 // loadOrGenerateCA loads a CA from disk or generates and saves a new one
 func (p *HTTPSProxy) loadOrGenerateCA() error {
 	certPath := "certs/ca.crt"
@@ -94,28 +175,15 @@ func (p *HTTPSProxy) loadOrGenerateCA() error {
 	}
 
 	p.logger.Println("Loading CA certificate from", certPath)
-	certPEM, err := os.ReadFile(certPath)
+	cert, key, err := loadCAKeyPair(certPath, keyPath)
 	if err != nil {
-		return fmt.Errorf("failed to read CA cert: %w", err)
-	}
-
-	keyPEM, err := os.ReadFile(keyPath)
-	if err != nil {
-		return fmt.Errorf("failed to read CA key: %w", err)
-	}
-
-	cert, err := tls.X509KeyPair(certPEM, keyPEM)
-	if err != nil {
-		return fmt.Errorf("failed to parse CA key pair: %w", err)
-	}
-
-	x509Cert, err := x509.ParseCertificate(cert.Certificate[0])
-	if err != nil {
-		return fmt.Errorf("failed to parse CA certificate: %w", err)
+		return err
 	}
 
-	p.caCert = x509Cert
-	p.caKey = cert.PrivateKey.(*rsa.PrivateKey)
+	p.certMutex.Lock()
+	p.caCert = cert
+	p.caKey = key
+	p.certMutex.Unlock()
 
 	p.logger.Println("✅ CA loaded successfully.")
 	return nil
@@ -156,8 +224,10 @@ func (p *HTTPSProxy) generateAndSaveCA() error {
 		return err
 	}
 
+	p.certMutex.Lock()
 	p.caCert = cert
 	p.caKey = privateKey
+	p.certMutex.Unlock()
 
 	// Create certs directory if it doesn't exist
 	if err := os.MkdirAll("certs", 0755); err != nil {
@@ -216,13 +286,34 @@ func (p *HTTPSProxy) handleCONNECT(w http.ResponseWriter, r *http.Request) {
 	// Send 200 OK to client
 	clientConn.Write([]byte("HTTP/1.1 200 OK\r\n\r\n"))
 
+	// Only allowed AI hosts are intercepted; other tunnels, e.g. from
+	// clients that pin certificates, pass through untouched
+	hello, sni := peekClientHello(clientConn)
+	host := sni
+	if host == "" {
+		host = endpointHost(hostEndpoint(r.Host, 443))
+	}
+	if !p.intercept.allows(host) || (!p.mitmAllHosts && p.providers.MatchHost(host) == nil) {
+		if err := spliceTunnel(clientConn, r.Host, hello, p.dialer); err != nil {
+			p.logger.Printf("Failed to tunnel to %s: %v", r.Host, err)
+		}
+		return
+	}
+
+	// Get (or generate) the leaf certificate for the target host
+	cert, err := p.certs.getOrCreate(r.Host, p.generateCert)
+	if err != nil {
+		p.logger.Printf("Failed to generate certificate for %s: %v", r.Host, err)
+		return
+	}
+
 	// Create TLS config for the client connection
 	tlsConfig := &tls.Config{
-		Certificates: []tls.Certificate{p.generateCert(r.Host)},
+		Certificates: []tls.Certificate{*cert},
 	}
 
-	// Upgrade client connection to TLS
-	tlsConn := tls.Server(clientConn, tlsConfig)
+	// Upgrade client connection to TLS, replaying the peeked ClientHello
+	tlsConn := tls.Server(newPrefixConn(clientConn, hello), tlsConfig)
 	defer tlsConn.Close()
 
 	// Handle the TLS connection
@@ -238,15 +329,23 @@ func (p *HTTPSProxy) handleTLSConnection(tlsConn *tls.Conn, host string) {
 	}
 
 	// Read HTTP request from TLS connection
-	req, err := http.ReadRequest(bufio.NewReader(tlsConn))
+	reader := bufio.NewReader(tlsConn)
+	req, err := http.ReadRequest(reader)
 	if err != nil {
 		p.logger.Printf("Failed to read request from TLS: %v", err)
 		return
 	}
 
-	// Set the host
+	// Set the host, and the client address that ReadRequest leaves empty
 	req.URL.Host = host
 	req.URL.Scheme = "https"
+	req.RemoteAddr = tlsConn.RemoteAddr().String()
+
+	// WebSocket upgrades keep the connection, relaying frames both ways
+	if isWebSocketUpgrade(req) {
+		p.proxyWebSocket(req, tlsConn, reader)
+		return
+	}
 
 	// Handle the request
 	p.processHTTPSRequest(req, tlsConn)
@@ -258,26 +357,30 @@ func (p *HTTPSProxy) handleHTTPSRequest(w http.ResponseWriter, r *http.Request)
 
 	// Check if this is an AI API call
 	aiProvider := p.detectAIProvider(r.URL.Host, r.URL.Path)
+	if aiProvider == nil && p.intercept.allows(r.URL.Host) && detectMCPRequest(r, p.bodyLimit) {
+		aiProvider = &mcpProvider
+	}
 	if aiProvider == nil {
 		// Not an AI API call, forward as-is
 		p.forwardHTTPSRequest(w, r)
 		return
 	}
 
-	// Capture request body
-	bodyBytes, err := io.ReadAll(r.Body)
+	// Capture request body, up to the body limit
+	reqBody, err := p.bodyLimit.captureRequest(r)
 	if err != nil {
 		p.logger.Printf("Failed to read request body: %v", err)
 		http.Error(w, "Bad request", http.StatusBadRequest)
 		return
 	}
-	r.Body.Close()
 
 	// Parse AI request
-	aiRequest := p.parseAIRequest(r, bodyBytes, aiProvider)
+	aiRequest := p.parseAIRequest(r, reqBody.data, aiProvider)
+	reqBody.mark(aiRequest)
+	p.extractRules.apply(aiRequest, "request", r.URL.Path, reqBody.data, r.Header)
 
 	// Forward request to actual AI service
-	resp, err := p.forwardAIRequest(r, bodyBytes)
+	resp, err := p.forwardAIRequest(r, reqBody, aiProvider)
 	if err != nil {
 		p.logger.Printf("Failed to forward AI request: %v", err)
 		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
@@ -285,14 +388,25 @@ func (p *HTTPSProxy) handleHTTPSRequest(w http.ResponseWriter, r *http.Request)
 	}
 	defer resp.Body.Close()
 
-	// Capture response body
-	respBodyBytes, err := io.ReadAll(resp.Body)
+	// Capture response body, up to the body limit, noting when it starts
+	ttfb := timeFirstByte(resp)
+	respBody, err := p.bodyLimit.capture(resp.Body)
 	if err != nil {
 		p.logger.Printf("Failed to read response body: %v", err)
 	}
 
-	// Parse AI response
-	aiResponse := p.parseAIResponse(respBodyBytes, aiProvider)
+	// Parse AI response, decompressed; the client still gets the bytes as sent
+	respData, decodeErr := decodeResponseBody(respBody, resp.Header, p.bodyLimit)
+	aiResponse := p.parseAIResponse(respData, resp.Header.Get("Content-Type"), aiProvider)
+	respBody.mark(aiResponse)
+	recordContentEncoding(aiResponse, resp.Header, decodeErr)
+	p.extractRules.apply(aiResponse, "response", r.URL.Path, respData, resp.Header)
+	recordTTSResponseAudio(aiResponse, aiProvider, resp.Header, respData)
+	recordRateLimitHeaders(aiResponse, resp.Header)
+	reqBody.recordDigest(aiRequest)
+	recordBytes(aiRequest, "request", reqBody, r.ContentLength, r.Header)
+	recordBytes(aiResponse, "response", respBody, resp.ContentLength, resp.Header)
+	recordTTFB(aiResponse, resp.Header, startTime, ttfb)
 
 	// Calculate latency
 	latency := time.Since(startTime)
@@ -300,6 +414,9 @@ func (p *HTTPSProxy) handleHTTPSRequest(w http.ResponseWriter, r *http.Request)
 	// Create signal
 	signal := p.createSignal(r, aiRequest, aiResponse, resp.StatusCode, latency, aiProvider)
 
+	// Flag repeats of a recent identical request from the same agent
+	p.duplicates.check(&signal, r.Method, r.URL.Path, reqBody, aiProvider.Name)
+
 	// Detect task if this is a new task
 	if task := p.taskDetector.DetectTask(signal); task != nil {
 		signal.TaskID = task.ID
@@ -307,18 +424,28 @@ func (p *HTTPSProxy) handleHTTPSRequest(w http.ResponseWriter, r *http.Request)
 		signal.Metadata["task_confidence"] = task.Metadata["confidence"]
 	}
 
-	// Send signal
-	select {
-	case p.signalCh <- signal:
+	// Drop governed keys and bound metadata size now that nothing else adds
+	// to it
+	p.metadataFilter.apply(signal.Metadata)
+	p.metadataLimits.apply(signal.Metadata)
+
+	// Send signal unless sampled out
+	if !p.sampler.keep(&signal, aiProvider.Name) {
+		p.logger.Printf("Signal sampled out: %s %s", aiProvider.Name, signal.Operation)
+	} else if p.enqueuer.send(p.signalCh, signal, aiProvider.Name) {
 		p.logger.Printf("📡 HTTPS AI signal captured: %s %s -> %s (latency: %.2fms)",
 			aiProvider.Name, signal.Operation, r.URL.Host, signal.LatencyMS)
-	default:
+	} else {
 		p.logger.Printf("Signal channel full, dropping signal")
 	}
 
-	// Return response to client
+	// Return response to client with the upstream headers
+	copyResponseHeaders(w.Header(), resp.Header)
+	if n := respBody.length(resp.ContentLength); n >= 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(n, 10))
+	}
 	w.WriteHeader(resp.StatusCode)
-	w.Write(respBodyBytes)
+	io.Copy(w, respBody.reader())
 }
 
 // processHTTPSRequest processes HTTPS requests
@@ -327,39 +454,54 @@ func (p *HTTPSProxy) processHTTPSRequest(req *http.Request, tlsConn *tls.Conn) {
 
 	// Check if this is an AI API call
 	aiProvider := p.detectAIProvider(req.URL.Host, req.URL.Path)
+	if aiProvider == nil && p.intercept.allows(req.URL.Host) && detectMCPRequest(req, p.bodyLimit) {
+		aiProvider = &mcpProvider
+	}
 	if aiProvider == nil {
 		// Not an AI API call, forward as-is
 		p.forwardTLSRequest(req, tlsConn)
 		return
 	}
 
-	// Capture request body
-	bodyBytes, err := io.ReadAll(req.Body)
+	// Capture request body, up to the body limit
+	reqBody, err := p.bodyLimit.captureRequest(req)
 	if err != nil {
 		p.logger.Printf("Failed to read request body: %v", err)
 		return
 	}
-	req.Body.Close()
 
 	// Parse AI request
-	aiRequest := p.parseAIRequest(req, bodyBytes, aiProvider)
+	aiRequest := p.parseAIRequest(req, reqBody.data, aiProvider)
+	reqBody.mark(aiRequest)
+	p.extractRules.apply(aiRequest, "request", req.URL.Path, reqBody.data, req.Header)
 
 	// Forward request to actual AI service
-	resp, err := p.forwardAIRequest(req, bodyBytes)
+	resp, err := p.forwardAIRequest(req, reqBody, aiProvider)
 	if err != nil {
 		p.logger.Printf("Failed to forward AI request: %v", err)
 		return
 	}
 	defer resp.Body.Close()
 
-	// Capture response body
-	respBodyBytes, err := io.ReadAll(resp.Body)
+	// Capture response body, up to the body limit, noting when it starts
+	ttfb := timeFirstByte(resp)
+	respBody, err := p.bodyLimit.capture(resp.Body)
 	if err != nil {
 		p.logger.Printf("Failed to read response body: %v", err)
 	}
 
-	// Parse AI response
-	aiResponse := p.parseAIResponse(respBodyBytes, aiProvider)
+	// Parse AI response, decompressed; the client still gets the bytes as sent
+	respData, decodeErr := decodeResponseBody(respBody, resp.Header, p.bodyLimit)
+	aiResponse := p.parseAIResponse(respData, resp.Header.Get("Content-Type"), aiProvider)
+	respBody.mark(aiResponse)
+	recordContentEncoding(aiResponse, resp.Header, decodeErr)
+	p.extractRules.apply(aiResponse, "response", req.URL.Path, respData, resp.Header)
+	recordTTSResponseAudio(aiResponse, aiProvider, resp.Header, respData)
+	recordRateLimitHeaders(aiResponse, resp.Header)
+	reqBody.recordDigest(aiRequest)
+	recordBytes(aiRequest, "request", reqBody, req.ContentLength, req.Header)
+	recordBytes(aiResponse, "response", respBody, resp.ContentLength, resp.Header)
+	recordTTFB(aiResponse, resp.Header, startTime, ttfb)
 
 	// Calculate latency
 	latency := time.Since(startTime)
@@ -367,6 +509,9 @@ func (p *HTTPSProxy) processHTTPSRequest(req *http.Request, tlsConn *tls.Conn) {
 	// Create signal
 	signal := p.createSignal(req, aiRequest, aiResponse, resp.StatusCode, latency, aiProvider)
 
+	// Flag repeats of a recent identical request from the same agent
+	p.duplicates.check(&signal, req.Method, req.URL.Path, reqBody, aiProvider.Name)
+
 	// Detect task if this is a new task
 	if task := p.taskDetector.DetectTask(signal); task != nil {
 		signal.TaskID = task.ID
@@ -374,31 +519,40 @@ func (p *HTTPSProxy) processHTTPSRequest(req *http.Request, tlsConn *tls.Conn) {
 		signal.Metadata["task_confidence"] = task.Metadata["confidence"]
 	}
 
-	// Send signal
-	select {
-	case p.signalCh <- signal:
+	// Drop governed keys and bound metadata size now that nothing else adds
+	// to it
+	p.metadataFilter.apply(signal.Metadata)
+	p.metadataLimits.apply(signal.Metadata)
+
+	// Send signal unless sampled out
+	if !p.sampler.keep(&signal, aiProvider.Name) {
+		p.logger.Printf("Signal sampled out: %s %s", aiProvider.Name, signal.Operation)
+	} else if p.enqueuer.send(p.signalCh, signal, aiProvider.Name) {
 		p.logger.Printf("📡 TLS AI signal captured: %s %s -> %s (latency: %.2fms)",
 			aiProvider.Name, signal.Operation, req.URL.Host, signal.LatencyMS)
-	default:
+	} else {
 		p.logger.Printf("Signal channel full, dropping signal")
 	}
 
-	// Write response to TLS connection
+	// Write response to TLS connection, replaying the buffered body
+	resp.Body = respBody.readCloser()
+	resp.ContentLength = respBody.length(resp.ContentLength)
+	resp.TransferEncoding = nil
+	resp.Header.Del("Transfer-Encoding")
 	resp.Write(tlsConn)
 }
 
 // generateCert generates a certificate for the given hostname
-func (p *HTTPSProxy) generateCert(hostname string) tls.Certificate {
+func (p *HTTPSProxy) generateCert(hostname string) (*tls.Certificate, error) {
 	// Generate private key
 	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
 	if err != nil {
-		p.logger.Printf("Failed to generate private key: %v", err)
-		return tls.Certificate{}
+		return nil, fmt.Errorf("failed to generate private key: %w", err)
 	}
 
 	// Create certificate template
 	template := x509.Certificate{
-		SerialNumber: big.NewInt(2),
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
 		Subject: pkix.Name{
 			Organization: []string{"Axom AI Observer"},
 			Country:      []string{"US"},
@@ -411,41 +565,35 @@ func (p *HTTPSProxy) generateCert(hostname string) tls.Certificate {
 	}
 
 	// Create certificate
-	derBytes, err := x509.CreateCertificate(rand.Reader, &template, p.caCert, &privateKey.PublicKey, p.caKey)
+	caCert, caKey := p.currentCA()
+	derBytes, err := x509.CreateCertificate(rand.Reader, &template, caCert, &privateKey.PublicKey, caKey)
 	if err != nil {
-		p.logger.Printf("Failed to create certificate: %v", err)
-		return tls.Certificate{}
+		return nil, fmt.Errorf("failed to create certificate: %w", err)
 	}
 
 	// Parse certificate
 	cert, err := x509.ParseCertificate(derBytes)
 	if err != nil {
-		p.logger.Printf("Failed to parse certificate: %v", err)
-		return tls.Certificate{}
+		return nil, fmt.Errorf("failed to parse certificate: %w", err)
 	}
 
-	return tls.Certificate{
+	return &tls.Certificate{
 		Certificate: [][]byte{derBytes},
 		PrivateKey:  privateKey,
 		Leaf:        cert,
-	}
+	}, nil
 }
 
 // detectAIProvider detects which AI provider this request is for
 func (p *HTTPSProxy) detectAIProvider(host, path string) *AIProvider {
-	for _, provider := range knownAIProviders {
-		for _, domain := range provider.Domains {
-			// Handle wildcard domains for services like Azure
-			matchPattern := strings.ReplaceAll(domain, "*", "")
-			if (strings.HasPrefix(domain, "*") && strings.HasSuffix(host, matchPattern)) ||
-				(!strings.HasPrefix(domain, "*") && host == domain) {
-				for _, apiPattern := range provider.APIPatterns {
-					if strings.HasPrefix(path, apiPattern) {
-						return &provider
-					}
-				}
-			}
-		}
+	if !p.intercept.allows(host) {
+		return nil
+	}
+	if provider := p.providers.Match(host, path); provider != nil {
+		return provider
+	}
+	if p.detectDebug {
+		p.providers.logDetectionMiss(p.logger, host, path)
 	}
 	return nil
 }
@@ -458,10 +606,11 @@ func (p *HTTPSProxy) parseAIRequest(r *http.Request, bodyBytes []byte, provider
 	request["provider"] = provider.Name
 	request["endpoint"] = r.URL.Path
 	request["method"] = r.Method
+	request["correlation_id"] = ensureCorrelationID(r)
 
 	// Parse JSON body if available
+	var jsonData map[string]interface{}
 	if len(bodyBytes) > 0 {
-		var jsonData map[string]interface{}
 		if err := json.Unmarshal(bodyBytes, &jsonData); err == nil {
 			// Extract model
 			if model, ok := jsonData["model"].(string); ok {
@@ -471,41 +620,99 @@ func (p *HTTPSProxy) parseAIRequest(r *http.Request, bodyBytes []byte, provider
 			// Extract messages for chat completions
 			if messages, ok := jsonData["messages"].([]interface{}); ok {
 				request["messages"] = messages
-				if len(messages) > 0 {
-					if msg, ok := messages[0].(map[string]interface{}); ok {
-						if content, ok := msg["content"].(string); ok {
-							request["prompt_preview"] = p.truncateString(content, 100)
-						}
-					}
+				// The latest user message is the prompt, not the first one
+				if content, ok := latestPrompt(messages); ok {
+					p.preview.set(request, "prompt_preview", content)
 				}
+				summarizeConversation(request, messages, jsonData, p.conversationSummary)
 			}
 
-			// Extract other common fields
-			for _, field := range []string{"max_tokens", "temperature", "top_p", "frequency_penalty", "presence_penalty"} {
-				if value, ok := jsonData[field]; ok {
-					request[field] = value
-				}
-			}
+			// Extract sampling params with normalized types
+			normalizeSamplingParams(request, jsonData)
 
 			// Provider-specific parsing
-			switch provider.Name {
-			case "OpenAI":
+			switch provider.Parser {
+			case "openai":
 				p.parseOpenAIRequest(request, jsonData)
-			case "Anthropic":
+			case "anthropic":
 				p.parseAnthropicRequest(request, jsonData)
-			case "Google AI":
+			case "google":
 				p.parseGoogleAIRequest(request, jsonData)
+			case "bedrock":
+				p.parseBedrockRequest(request, jsonData)
+			case "mcp":
+				parseMCPRequest(request, jsonData)
+			}
+
+			// Embedding input counts, for billing by vector
+			if isEmbeddingPath(r.URL.Path) {
+				parseEmbeddingRequest(request, jsonData)
+			}
+
+			// Image generation options, for billing by image
+			if isImageGenerationPath(r.URL.Path) {
+				parseImageGenerationRequest(request, jsonData)
+			}
+
+			// Prompt size, in case the provider reports no usage
+			if p.estimateTokens {
+				recordPromptEstimate(request, jsonData)
 			}
 		}
 	}
 
+	// Multipart and raw audio uploads carry their metadata outside a JSON body
+	parseUploadRequest(request, r, bodyBytes)
+
+	// Bedrock, Vertex AI and Azure OpenAI name the model in the URL path
+	if provider.Name == "Azure OpenAI" {
+		p.azureModels.apply(request, r)
+	} else if _, ok := request["model"]; !ok {
+		if model := modelFromPath(provider.Name, r.URL.Path); model != "" {
+			request["model"] = model
+		}
+	}
+
+	// Capture requested audio format for TTS billing
+	if isTTSProvider(provider.Name) {
+		extractTTSRequestFormat(request, r, jsonData, provider)
+	}
+
 	return request
 }
 
 // parseAIResponse parses the AI response based on provider
-func (p *HTTPSProxy) parseAIResponse(bodyBytes []byte, provider *AIProvider) map[string]interface{} {
+func (p *HTTPSProxy) parseAIResponse(bodyBytes []byte, contentType string, provider *AIProvider) map[string]interface{} {
 	response := make(map[string]interface{})
 
+	// MCP answers are JSON-RPC, as a JSON body or SSE events
+	if provider.Parser == "mcp" {
+		parseMCPResponse(response, bodyBytes, contentType)
+		return response
+	}
+
+	// Streamed responses are a series of SSE chunks rather than one JSON body
+	if isEventStream(contentType) {
+		stream := parseEventStream(bodyBytes)
+		if stream.Content != "" {
+			p.preview.set(response, "response_preview", stream.Content)
+		}
+		if stream.Usage != nil {
+			response["usage"] = stream.Usage
+		}
+		if stream.ID != "" {
+			response["id"] = stream.ID
+		}
+		if stream.FinishReason != "" {
+			response["finish_reason"] = stream.FinishReason
+		}
+		if p.estimateTokens {
+			recordCompletionEstimate(response, "", stream.Content)
+		}
+		response["stream_chunks"] = stream.Chunks
+		return response
+	}
+
 	if len(bodyBytes) > 0 {
 		var jsonData map[string]interface{}
 		if err := json.Unmarshal(bodyBytes, &jsonData); err == nil {
@@ -514,23 +721,42 @@ func (p *HTTPSProxy) parseAIResponse(bodyBytes []byte, provider *AIProvider) map
 				response["usage"] = usage
 			}
 
+			// Extract provider error details
+			extractProviderError(response, jsonData)
+
+			// Embedding vector count and dimensions
+			parseEmbeddingResponse(response, jsonData)
+
+			// Generated image count
+			parseImageGenerationResponse(response, jsonData)
+
+			// Completion size, in case the provider reports no usage
+			if p.estimateTokens {
+				model, _ := jsonData["model"].(string)
+				recordCompletionEstimate(response, model, completionText(jsonData))
+			}
+
 			// Extract choices/response
 			if choices, ok := jsonData["choices"].([]interface{}); ok && len(choices) > 0 {
 				if choice, ok := choices[0].(map[string]interface{}); ok {
 					if message, ok := choice["message"].(map[string]interface{}); ok {
 						if content, ok := message["content"].(string); ok {
-							response["response_preview"] = p.truncateString(content, 100)
+							p.preview.set(response, "response_preview", content)
 						}
 					}
 				}
 			}
 
 			// Provider-specific parsing
-			switch provider.Name {
-			case "OpenAI":
+			switch provider.Parser {
+			case "openai":
 				p.parseOpenAIResponse(response, jsonData)
-			case "Anthropic":
+			case "anthropic":
 				p.parseAnthropicResponse(response, jsonData)
+			case "google":
+				p.parseGoogleAIResponse(response, jsonData)
+			case "bedrock":
+				p.parseBedrockResponse(response, jsonData)
 			}
 		}
 	}
@@ -552,12 +778,15 @@ func (p *HTTPSProxy) parseOpenAIRequest(request map[string]interface{}, jsonData
 // parseAnthropicRequest parses Anthropic-specific request fields
 func (p *HTTPSProxy) parseAnthropicRequest(request map[string]interface{}, jsonData map[string]interface{}) {
 	// Anthropic-specific fields
-	if max_tokens, ok := jsonData["max_tokens"].(float64); ok {
-		request["max_tokens"] = int(max_tokens)
-	}
 	if system, ok := jsonData["system"].(string); ok {
 		request["system"] = system
 	}
+	// Customer-provided attribution dimension
+	if metadata, ok := jsonData["metadata"].(map[string]interface{}); ok {
+		if userID, ok := metadata["user_id"].(string); ok && userID != "" {
+			request["provider_user"] = userID
+		}
+	}
 }
 
 // parseGoogleAIRequest parses Google AI-specific request fields
@@ -566,6 +795,16 @@ func (p *HTTPSProxy) parseGoogleAIRequest(request map[string]interface{}, jsonDa
 	if generationConfig, ok := jsonData["generationConfig"].(map[string]interface{}); ok {
 		request["generation_config"] = generationConfig
 	}
+	// Customer-provided attribution labels
+	if labels, ok := jsonData["labels"].(map[string]interface{}); ok && len(labels) > 0 {
+		providerLabels := make(map[string]string, len(labels))
+		for k, v := range labels {
+			if value, ok := v.(string); ok {
+				providerLabels[k] = value
+			}
+		}
+		request["provider_labels"] = providerLabels
+	}
 }
 
 // parseOpenAIResponse parses OpenAI-specific response fields
@@ -574,6 +813,10 @@ func (p *HTTPSProxy) parseOpenAIResponse(response map[string]interface{}, jsonDa
 	if id, ok := jsonData["id"].(string); ok {
 		response["id"] = id
 	}
+
+	// Record why generation stopped, and tool calls
+	extractOpenAIFinishReason(response, jsonData)
+	extractOpenAIToolCalls(response, jsonData)
 }
 
 // parseAnthropicResponse parses Anthropic-specific response fields
@@ -582,10 +825,67 @@ func (p *HTTPSProxy) parseAnthropicResponse(response map[string]interface{}, jso
 	if content, ok := jsonData["content"].([]interface{}); ok && len(content) > 0 {
 		if contentItem, ok := content[0].(map[string]interface{}); ok {
 			if text, ok := contentItem["text"].(string); ok {
-				response["response_preview"] = p.truncateString(text, 100)
+				p.preview.set(response, "response_preview", text)
+			}
+		}
+	}
+
+	// Record why generation stopped, and tool_use blocks
+	extractAnthropicStopReason(response, jsonData)
+	extractAnthropicToolUse(response, jsonData)
+}
+
+// parseGoogleAIResponse parses Gemini candidates and usageMetadata
+func (p *HTTPSProxy) parseGoogleAIResponse(response map[string]interface{}, jsonData map[string]interface{}) {
+	if candidates, ok := jsonData["candidates"].([]interface{}); ok && len(candidates) > 0 {
+		if candidate, ok := candidates[0].(map[string]interface{}); ok {
+			if content, ok := candidate["content"].(map[string]interface{}); ok {
+				if parts, ok := content["parts"].([]interface{}); ok {
+					var text strings.Builder
+					for _, part := range parts {
+						if partMap, ok := part.(map[string]interface{}); ok {
+							if partText, ok := partMap["text"].(string); ok {
+								text.WriteString(partText)
+							}
+						}
+					}
+					if text.Len() > 0 {
+						p.preview.set(response, "response_preview", text.String())
+					}
+				}
 			}
 		}
 	}
+
+	// Token counts are normalized in createSignal
+	if usageMetadata, ok := jsonData["usageMetadata"].(map[string]interface{}); ok {
+		response["usageMetadata"] = usageMetadata
+	}
+}
+
+// parseBedrockRequest parses the model-specific Bedrock request bodies
+func (p *HTTPSProxy) parseBedrockRequest(request map[string]interface{}, jsonData map[string]interface{}) {
+	if _, ok := request["prompt_preview"]; !ok {
+		if text := bedrockPromptText(jsonData); text != "" {
+			p.preview.set(request, "prompt_preview", text)
+		}
+	}
+	if maxTokens, ok := bedrockMaxTokens(jsonData); ok {
+		request["max_tokens"] = maxTokens
+	}
+}
+
+// parseBedrockResponse parses Bedrock InvokeModel and Converse responses
+func (p *HTTPSProxy) parseBedrockResponse(response map[string]interface{}, jsonData map[string]interface{}) {
+	if text := bedrockResponseText(jsonData); text != "" {
+		p.preview.set(response, "response_preview", text)
+	}
+	if usage := bedrockUsage(jsonData); usage != nil {
+		response["usage"] = usage
+	}
+
+	// Record tool_use blocks from Anthropic models
+	extractAnthropicToolUse(response, jsonData)
 }
 
 // createSignal creates a signal from the AI request/response
@@ -600,6 +900,10 @@ func (p *HTTPSProxy) createSignal(
 
 	// Determine operation type
 	operation := p.determineOperation(r.URL.Path, request, provider)
+	// Responses that invoke tools are tool calls regardless of endpoint
+	if hasToolCalls(response) {
+		operation = "tool_call"
+	}
 
 	// Extract metadata
 	metadata := make(map[string]interface{})
@@ -614,36 +918,56 @@ func (p *HTTPSProxy) createSignal(
 	metadata["provider"] = provider.Name
 	metadata["endpoint"] = r.URL.Path
 
-	// Extract usage information
-	if usage, ok := response["usage"].(map[string]interface{}); ok {
-		if promptTokens, ok := usage["prompt_tokens"].(float64); ok {
-			metadata["prompt_tokens"] = int(promptTokens)
-		}
-		if completionTokens, ok := usage["completion_tokens"].(float64); ok {
-			metadata["completion_tokens"] = int(completionTokens)
-		}
-		if totalTokens, ok := usage["total_tokens"].(float64); ok {
-			metadata["total_tokens"] = int(totalTokens)
-		}
-	}
-
-	return models.Signal{
-		ID:          p.generateSignalID(),
-		CustomerID:  p.customerID,
-		AgentID:     p.agentID,
-		Timestamp:   time.Now(),
-		Protocol:    "https",
-		LatencyMS:   float64(latency.Milliseconds()),
-		Metadata:    metadata,
-		Source:      models.Endpoint{IP: "127.0.0.1", Port: 0},
-		Destination: models.Endpoint{IP: r.URL.Host, Port: 443},
-		Operation:   operation,
-		Status:      statusCode,
-	}
+	// Normalize token usage across provider shapes, falling back to the
+	// estimate from the captured text when there is none
+	estimate, estimated := takeTokenEstimate(metadata)
+	usage, ok := normalizeUsage(provider.Name, response)
+	if !ok && estimated {
+		usage, ok = estimate, true
+		metadata["tokens_estimated"] = true
+	}
+	if ok {
+		metadata["prompt_tokens"] = usage.Prompt
+		metadata["completion_tokens"] = usage.Completion
+		metadata["total_tokens"] = usage.Total
+	}
+
+	// Estimate cost from token usage
+	p.costEstimator.Annotate(provider.Name, metadata)
+
+	// Per-request identity headers override the configured defaults
+	customerID, agentID := p.identity.resolve(r, p.customerID, p.agentID)
+
+	signal := models.Signal{
+		ID:            models.NewSignalID(),
+		SchemaVersion: models.SignalSchemaVersion,
+		CustomerID:    customerID,
+		AgentID:       agentID,
+		Timestamp:     time.Now(),
+		Protocol:      "https",
+		LatencyMS:     float64(latency.Milliseconds()),
+		Metadata:      metadata,
+		Source:        endpointFromAddr(r.RemoteAddr),
+		Destination:   p.resolver.enrich(destinationEndpoint(r)),
+		Operation:     operation,
+		Status:        statusCode,
+	}
+	applyStatusOutcome(&signal, provider.Name, r.URL.Path)
+	signal.Alerts = append(signal.Alerts, failureClassAlerts(provider.Name, r.URL.Path, metadata)...)
+	signal.Alerts = append(signal.Alerts, p.moderator.Apply(metadata)...)
+
+	recordSignalMetrics(signal, provider.Name)
+
+	return signal
 }
 
 // determineOperation determines the operation type
 func (p *HTTPSProxy) determineOperation(path string, request map[string]interface{}, provider *AIProvider) string {
+	// MCP calls are named by their JSON-RPC method, e.g. tools/call
+	if method, ok := request["mcp_method"].(string); ok {
+		return method
+	}
+
 	// Check path patterns
 	if strings.Contains(path, "/chat/completions") || strings.Contains(path, "/messages") {
 		return "chat_completion"
@@ -672,25 +996,25 @@ func (p *HTTPSProxy) determineOperation(path string, request map[string]interfac
 }
 
 // forwardAIRequest forwards the request to the actual AI service
-func (p *HTTPSProxy) forwardAIRequest(r *http.Request, bodyBytes []byte) (*http.Response, error) {
+func (p *HTTPSProxy) forwardAIRequest(r *http.Request, body capturedBody, provider *AIProvider) (*http.Response, error) {
+	// Forward to the original URL unless its provider or host is redirected
+	targetURL, err := p.upstreamOverrides.target(r, provider.Name)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create new request to actual AI service
-	req, err := http.NewRequest(r.Method, r.URL.String(), bytes.NewReader(bodyBytes))
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, targetURL.String(), body.reader())
 	if err != nil {
 		return nil, err
 	}
+	req.ContentLength = body.length(r.ContentLength)
 
-	// Copy headers
+	// Copy headers, only accepting encodings the response can be parsed in
 	req.Header = r.Header
+	restrictAcceptEncoding(req.Header)
 
-	// Create HTTP client with TLS
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: false},
-		},
-	}
-
-	return client.Do(req)
+	return p.inflight.forward(p.forwarder, req)
 }
 
 // forwardHTTPSRequest forwards non-AI HTTPS requests
@@ -701,15 +1025,10 @@ func (p *HTTPSProxy) forwardHTTPSRequest(w http.ResponseWriter, r *http.Request)
 
 // forwardTLSRequest forwards TLS requests
 func (p *HTTPSProxy) forwardTLSRequest(req *http.Request, tlsConn *tls.Conn) {
-	// Forward to actual service
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: false},
-		},
-	}
-
-	resp, err := client.Do(req)
+	// Forward to actual service; requests read off the wire carry a
+	// RequestURI, which client requests must not set
+	req.RequestURI = ""
+	resp, err := p.inflight.forward(p.forwarder, req)
 	if err != nil {
 		p.logger.Printf("Failed to forward TLS request: %v", err)
 		return
@@ -719,16 +1038,3 @@ func (p *HTTPSProxy) forwardTLSRequest(req *http.Request, tlsConn *tls.Conn) {
 	// Copy response to TLS connection
 	resp.Write(tlsConn)
 }
-
-// generateSignalID generates a unique signal ID
-func (p *HTTPSProxy) generateSignalID() string {
-	return fmt.Sprintf("signal_%d", time.Now().UnixNano())
-}
-
-// truncateString truncates a string to max length
-func (p *HTTPSProxy) truncateString(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
-	}
-	return s[:maxLen] + "..."
-}
@@ -0,0 +1,45 @@
+package observer
+
+import (
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Environment variables:
+//   AXOM_CUSTOMER_HEADER - Optional. Request header overriding the customer ID per request. Default: X-Axom-Customer
+//   AXOM_AGENT_HEADER    - Optional. Request header overriding the agent ID per request. Default: X-Axom-Agent
+
+// identityHeaders names the request headers that carry a per-request
+// customer/agent identity for multi-tenant gateways
+type identityHeaders struct {
+	customer string
+	agent    string
+}
+
+// identityHeadersFromEnv returns the configured identity header names
+func identityHeadersFromEnv() identityHeaders {
+	h := identityHeaders{
+		customer: os.Getenv("AXOM_CUSTOMER_HEADER"),
+		agent:    os.Getenv("AXOM_AGENT_HEADER"),
+	}
+	if h.customer == "" {
+		h.customer = "X-Axom-Customer"
+	}
+	if h.agent == "" {
+		h.agent = "X-Axom-Agent"
+	}
+	return h
+}
+
+// resolve returns the customer and agent IDs for a request, falling back to
+// the configured defaults when the headers are absent
+func (h identityHeaders) resolve(r *http.Request, customerID, agentID string) (string, string) {
+	if v := strings.TrimSpace(r.Header.Get(h.customer)); v != "" {
+		customerID = v
+	}
+	if v := strings.TrimSpace(r.Header.Get(h.agent)); v != "" {
+		agentID = v
+	}
+	return customerID, agentID
+}
@@ -0,0 +1,49 @@
+package observer
+
+import (
+	"net/http"
+	"testing"
+)
+
+const okChatResponse = `{"id":"chatcmpl-1","model":"gpt-4","choices":[{"message":{"role":"assistant","content":"Hi"},"finish_reason":"stop"}],"usage":{"prompt_tokens":5,"completion_tokens":1,"total_tokens":6}}`
+
+func TestIdentityHeadersOverrideDefaults(t *testing.T) {
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "application/json", okChatResponse))
+
+	req := chatRequest(`{"model":"gpt-4","messages":[{"role":"user","content":"Hello"}]}`)
+	req.Header.Set("X-Axom-Customer", "tenant-7")
+	req.Header.Set("X-Axom-Agent", "support-bot")
+	signal := proxySignal(t, p, signalCh, req)
+
+	if signal.CustomerID != "tenant-7" || signal.AgentID != "support-bot" {
+		t.Errorf("identity = %q/%q, want tenant-7/support-bot", signal.CustomerID, signal.AgentID)
+	}
+}
+
+func TestIdentityHeadersFallBackToDefaults(t *testing.T) {
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "application/json", okChatResponse))
+
+	req := chatRequest(`{"model":"gpt-4","messages":[{"role":"user","content":"Hello"}]}`)
+	req.Header.Set("X-Axom-Agent", "  ")
+	signal := proxySignal(t, p, signalCh, req)
+
+	if signal.CustomerID != "test-customer" || signal.AgentID != "test-agent" {
+		t.Errorf("identity = %q/%q, want the configured test-customer/test-agent", signal.CustomerID, signal.AgentID)
+	}
+}
+
+func TestIdentityHeaderNamesFromEnv(t *testing.T) {
+	t.Setenv("AXOM_CUSTOMER_HEADER", "X-Tenant")
+	t.Setenv("AXOM_AGENT_HEADER", "X-Bot")
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "application/json", okChatResponse))
+
+	req := chatRequest(`{"model":"gpt-4","messages":[{"role":"user","content":"Hello"}]}`)
+	req.Header.Set("X-Tenant", "tenant-9")
+	req.Header.Set("X-Bot", "sales-bot")
+	req.Header.Set("X-Axom-Customer", "ignored")
+	signal := proxySignal(t, p, signalCh, req)
+
+	if signal.CustomerID != "tenant-9" || signal.AgentID != "sales-bot" {
+		t.Errorf("identity = %q/%q, want tenant-9/sales-bot", signal.CustomerID, signal.AgentID)
+	}
+}
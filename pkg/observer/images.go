@@ -0,0 +1,56 @@
+package observer
+
+import "strings"
+
+// isImageGenerationPath reports whether path is an image generation
+// endpoint, matching determineOperation
+func isImageGenerationPath(path string) bool {
+	return strings.Contains(path, "/images/generations")
+}
+
+// parseImageGenerationRequest records the billable options of an
+// OpenAI-style image generation request (OpenAI, Azure OpenAI, xAI) as
+// image_size, image_quality and image_style. The image count requested is
+// already recorded as "n".
+func parseImageGenerationRequest(request map[string]interface{}, jsonData map[string]interface{}) {
+	if size, ok := jsonData["size"].(string); ok {
+		request["image_size"] = size
+	}
+	if quality, ok := jsonData["quality"].(string); ok {
+		request["image_quality"] = quality
+	}
+	if style, ok := jsonData["style"].(string); ok {
+		request["image_style"] = style
+	}
+}
+
+// parseImageGenerationResponse records image_count, the number of images
+// returned: OpenAI-style "data[]" entries carrying a "url" or "b64_json",
+// and Vertex AI Imagen "predictions[]" carrying "bytesBase64Encoded"
+func parseImageGenerationResponse(response map[string]interface{}, jsonData map[string]interface{}) {
+	count := 0
+	if data, ok := jsonData["data"].([]interface{}); ok {
+		for _, item := range data {
+			entry, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if _, ok := entry["url"].(string); ok {
+				count++
+			} else if _, ok := entry["b64_json"].(string); ok {
+				count++
+			}
+		}
+	} else if predictions, ok := jsonData["predictions"].([]interface{}); ok {
+		for _, item := range predictions {
+			if entry, ok := item.(map[string]interface{}); ok {
+				if _, ok := entry["bytesBase64Encoded"].(string); ok {
+					count++
+				}
+			}
+		}
+	}
+	if count > 0 {
+		response["image_count"] = count
+	}
+}
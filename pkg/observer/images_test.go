@@ -0,0 +1,65 @@
+package observer
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestImageGeneration(t *testing.T) {
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "application/json",
+		`{"created":1700000000,"data":[
+			{"url":"https://images.example.com/1.png","revised_prompt":"A lighthouse at dusk"},
+			{"url":"https://images.example.com/2.png"}
+		]}`))
+	signal := proxySignal(t, p, signalCh, jsonRequest("http://api.openai.com/v1/images/generations",
+		`{"model":"dall-e-3","prompt":"A lighthouse at dusk","n":2,"size":"1024x1792","quality":"hd","style":"vivid"}`))
+
+	if signal.Operation != "image_generation" {
+		t.Errorf("Operation = %q, want image_generation", signal.Operation)
+	}
+	for key, want := range map[string]interface{}{
+		"image_count":   2,
+		"image_size":    "1024x1792",
+		"image_quality": "hd",
+		"image_style":   "vivid",
+	} {
+		if got := signal.Metadata[key]; got != want {
+			t.Errorf("%s = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestParseImageGenerationResponse(t *testing.T) {
+	tests := map[string]struct {
+		body map[string]interface{}
+		want interface{}
+	}{
+		"b64_json": {map[string]interface{}{"data": []interface{}{
+			map[string]interface{}{"b64_json": "iVBORw0KGgo="},
+		}}, 1},
+		"imagen": {map[string]interface{}{"predictions": []interface{}{
+			map[string]interface{}{"bytesBase64Encoded": "iVBORw0KGgo=", "mimeType": "image/png"},
+			map[string]interface{}{"bytesBase64Encoded": "iVBORw0KGgo=", "mimeType": "image/png"},
+			map[string]interface{}{"raiFilteredReason": "filtered"},
+		}}, 2},
+		// Embedding data entries aren't images
+		"embeddings": {map[string]interface{}{"data": []interface{}{
+			map[string]interface{}{"embedding": []interface{}{0.1}},
+		}}, nil},
+	}
+	for name, tt := range tests {
+		response := make(map[string]interface{})
+		parseImageGenerationResponse(response, tt.body)
+		if got := response["image_count"]; got != tt.want {
+			t.Errorf("%s: image_count = %v, want %v", name, got, tt.want)
+		}
+	}
+}
+
+func TestImageOptionsOnlyForImageGeneration(t *testing.T) {
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "application/json", okChatResponse))
+	signal := proxySignal(t, p, signalCh, chatRequest(`{"model": "gpt-4", "size": "large", "messages": [{"role": "user", "content": "Hi"}]}`))
+	if got, ok := signal.Metadata["image_size"]; ok {
+		t.Errorf("image_size = %v on a chat completion, want it absent", got)
+	}
+}
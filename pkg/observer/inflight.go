@@ -0,0 +1,113 @@
+package observer
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Environment variables:
+//   AXOM_MAX_INFLIGHT  - Optional. Maximum concurrent upstream requests per proxy; further requests queue for a free slot. 0 means unlimited. Default: 0
+//   AXOM_INFLIGHT_WAIT - Optional. Seconds a queued request waits for a slot before failing. Default: 30
+
+// errInflightTimeout is returned when no upstream request slot frees up in
+// time
+var errInflightTimeout = errors.New("timed out waiting for an upstream request slot")
+
+// inflightLimiter bounds a proxy's concurrent upstream requests and reports
+// how many are in flight. A request holds its slot until its response body
+// is closed, as streamed responses keep the upstream connection busy.
+type inflightLimiter struct {
+	proxy string
+	slots chan struct{} // nil when unlimited
+	wait  time.Duration
+}
+
+// inflightLimiterFromEnv creates the limiter for the named proxy from
+// AXOM_MAX_INFLIGHT and AXOM_INFLIGHT_WAIT
+func inflightLimiterFromEnv(proxy string) *inflightLimiter {
+	l := &inflightLimiter{proxy: proxy, wait: 30 * time.Second}
+	if n, err := strconv.Atoi(os.Getenv("AXOM_MAX_INFLIGHT")); err == nil && n > 0 {
+		l.slots = make(chan struct{}, n)
+	}
+	if n, err := strconv.Atoi(os.Getenv("AXOM_INFLIGHT_WAIT")); err == nil && n >= 0 {
+		l.wait = time.Duration(n) * time.Second
+	}
+	return l
+}
+
+// acquire waits for a free slot, for at most the configured wait or until
+// req is cancelled, and returns the function that releases it. The release
+// function may be called more than once.
+func (l *inflightLimiter) acquire(req *http.Request) (func(), error) {
+	// A free slot is taken at once, even with no wait configured
+	select {
+	case l.slots <- struct{}{}:
+	default:
+		if err := l.queue(req); err != nil {
+			return nil, err
+		}
+	}
+	eachMetrics(func(m *observerMetrics) { m.upstreamInflight.WithLabelValues(l.proxy).Inc() })
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			eachMetrics(func(m *observerMetrics) { m.upstreamInflight.WithLabelValues(l.proxy).Dec() })
+			if l.slots != nil {
+				<-l.slots
+			}
+		})
+	}, nil
+}
+
+// queue waits for a slot to free up, for at most the configured wait. An
+// unlimited limiter never queues.
+func (l *inflightLimiter) queue(req *http.Request) error {
+	if l.slots == nil {
+		return nil
+	}
+	timer := time.NewTimer(l.wait)
+	defer timer.Stop()
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-timer.C:
+		eachMetrics(func(m *observerMetrics) { m.inflightRejected.WithLabelValues(l.proxy).Inc() })
+		return errInflightTimeout
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
+
+// forward sends req with f once a slot is free, holding the slot until the
+// response body is closed
+func (l *inflightLimiter) forward(f Forwarder, req *http.Request) (*http.Response, error) {
+	release, err := l.acquire(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := f.Forward(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// releasingBody releases an upstream request slot when closed
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
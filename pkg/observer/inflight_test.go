@@ -0,0 +1,129 @@
+package observer
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// closeFunc calls onClose when the wrapped body is closed
+type closeFunc struct {
+	io.Reader
+	onClose func()
+}
+
+func (c closeFunc) Close() error {
+	c.onClose()
+	return nil
+}
+
+func TestInflightNeverExceedsLimit(t *testing.T) {
+	t.Setenv("AXOM_MAX_INFLIGHT", "3")
+	reg := newTestRegistry(t)
+
+	// Track requests from forward until their body is closed, which is as
+	// long as they hold a slot
+	var inflight, peak atomic.Int32
+	upstream := ForwarderFunc(func(req *http.Request) (*http.Response, error) {
+		n := inflight.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       closeFunc{strings.NewReader(okChatResponse), func() { inflight.Add(-1) }},
+			Request:    req,
+		}, nil
+	})
+	p, signalCh := newTestHTTPProxy(t, upstream)
+	go func() {
+		for range signalCh {
+		}
+	}()
+
+	var wg sync.WaitGroup
+	var failed atomic.Int32
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := httptest.NewRecorder()
+			p.handleRequest(rec, chatRequest(`{"model": "gpt-4", "messages": [{"role": "user", "content": "Hi"}]}`))
+			if rec.Code != http.StatusOK {
+				failed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := peak.Load(); got > 3 {
+		t.Errorf("peak of %d concurrent upstream requests, want at most 3", got)
+	}
+	if got := peak.Load(); got < 2 {
+		t.Errorf("peak of %d concurrent upstream requests, want parallel forwards", got)
+	}
+	if n := failed.Load(); n != 0 {
+		t.Errorf("%d requests failed, want them queued instead", n)
+	}
+	if got := metricValue(t, reg, "axom_upstream_inflight_requests", map[string]string{"proxy": "http"}); got != 0 {
+		t.Errorf("axom_upstream_inflight_requests = %v after all requests, want 0", got)
+	}
+}
+
+func TestInflightQueueTimeout(t *testing.T) {
+	t.Setenv("AXOM_MAX_INFLIGHT", "1")
+	t.Setenv("AXOM_INFLIGHT_WAIT", "0")
+	reg := newTestRegistry(t)
+	l := inflightLimiterFromEnv("http")
+	req := httptest.NewRequest(http.MethodPost, "http://api.openai.com/v1/chat/completions", nil)
+
+	release, err := l.acquire(req)
+	if err != nil {
+		t.Fatalf("acquire a free slot: %v", err)
+	}
+	if got := metricValue(t, reg, "axom_upstream_inflight_requests", map[string]string{"proxy": "http"}); got != 1 {
+		t.Errorf("axom_upstream_inflight_requests = %v, want 1", got)
+	}
+	if _, err := l.acquire(req); !errors.Is(err, errInflightTimeout) {
+		t.Errorf("acquire with no free slot = %v, want a timeout", err)
+	}
+	if got := metricValue(t, reg, "axom_upstream_inflight_timeouts_total", map[string]string{"proxy": "http"}); got != 1 {
+		t.Errorf("axom_upstream_inflight_timeouts_total = %v, want 1", got)
+	}
+
+	// Releasing twice frees the one slot only once
+	release()
+	release()
+	second, err := l.acquire(req)
+	if err != nil {
+		t.Fatalf("acquire after release: %v", err)
+	}
+	if _, err := l.acquire(req); err == nil {
+		t.Errorf("double release freed a second slot")
+	}
+	second()
+}
+
+func TestInflightUnlimitedByDefault(t *testing.T) {
+	l := inflightLimiterFromEnv("http")
+	if l.slots != nil {
+		t.Fatalf("limiter has %d slots without AXOM_MAX_INFLIGHT, want unlimited", cap(l.slots))
+	}
+	req := httptest.NewRequest(http.MethodGet, "http://api.openai.com/v1/models", nil)
+	for i := 0; i < 100; i++ {
+		if _, err := l.acquire(req); err != nil {
+			t.Fatalf("acquire %d: %v", i, err)
+		}
+	}
+}
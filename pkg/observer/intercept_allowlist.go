@@ -0,0 +1,40 @@
+package observer
+
+import (
+	"os"
+	"strings"
+)
+
+// Environment variables:
+//   AXOM_INTERCEPT_HOSTS - Optional. Comma-separated hostnames or patterns (e.g. "api.openai.com,*.openai.azure.com") the proxies may intercept; other hosts are tunneled without TLS termination or body capture, whatever provider detection says. Default: no restriction
+
+// interceptAllowlist lists the host patterns the proxies may decrypt and
+// capture. It narrows provider detection rather than replacing it: a host
+// must be on the list and still be detected as a provider (or
+// AXOM_MITM_ALL_HOSTS set) to be intercepted.
+type interceptAllowlist []string
+
+// interceptAllowlistFromEnv parses AXOM_INTERCEPT_HOSTS
+func interceptAllowlistFromEnv() interceptAllowlist {
+	var list interceptAllowlist
+	for _, pattern := range strings.Split(os.Getenv("AXOM_INTERCEPT_HOSTS"), ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			list = append(list, pattern)
+		}
+	}
+	return list
+}
+
+// allows reports whether host, with or without port, may be intercepted.
+// An empty list allows every host.
+func (l interceptAllowlist) allows(host string) bool {
+	if len(l) == 0 {
+		return true
+	}
+	for _, pattern := range l {
+		if matchDomain(host, pattern) {
+			return true
+		}
+	}
+	return false
+}
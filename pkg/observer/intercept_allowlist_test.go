@@ -0,0 +1,77 @@
+package observer
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestInterceptAllowlistAllows(t *testing.T) {
+	t.Setenv("AXOM_INTERCEPT_HOSTS", " api.openai.com , *.openai.azure.com,")
+	l := interceptAllowlistFromEnv()
+	if len(l) != 2 {
+		t.Fatalf("allowlist = %q, want 2 patterns", l)
+	}
+	for host, want := range map[string]bool{
+		"api.openai.com":                  true,
+		"API.OpenAI.com:443":              true,
+		"myorg.openai.azure.com":          true,
+		"api.anthropic.com":               false,
+		"deep.myorg.openai.azure.com":     false,
+		"api.openai.com.attacker.example": false,
+	} {
+		if got := l.allows(host); got != want {
+			t.Errorf("allows(%q) = %v, want %v", host, got, want)
+		}
+	}
+
+	// No list allows everything
+	if !interceptAllowlist(nil).allows("api.anthropic.com") {
+		t.Errorf("empty allowlist refused a host")
+	}
+}
+
+func TestAllowedHostIntercepted(t *testing.T) {
+	t.Setenv("AXOM_INTERCEPT_HOSTS", "api.openai.com")
+	proxyAddr, ca := newTestHTTPSProxy(t)
+
+	leaf := connectTLS(t, proxyAddr, "api.openai.com:443", "api.openai.com")
+	if !chainsTo(leaf, ca, "api.openai.com") {
+		t.Errorf("certificate for api.openai.com issued by %q, want the proxy CA", leaf.Issuer.CommonName)
+	}
+}
+
+func TestNonAllowedHostTunneled(t *testing.T) {
+	upstream := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer upstream.Close()
+	t.Setenv("AXOM_INTERCEPT_HOSTS", "api.openai.com")
+	proxyAddr, _ := newTestHTTPSProxy(t)
+
+	// localhost is a detected provider (local AI services), but not on the
+	// list, so the client reaches the upstream's own certificate
+	leaf := connectTLS(t, proxyAddr, upstream.Listener.Addr().String(), "localhost")
+	if !bytes.Equal(leaf.Raw, upstream.Certificate().Raw) {
+		t.Errorf("non-allowed host presented %s, want the upstream's certificate", leaf.Subject)
+	}
+}
+
+func TestNonAllowedHostNotCaptured(t *testing.T) {
+	t.Setenv("AXOM_INTERCEPT_HOSTS", "api.anthropic.com")
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "application/json", okChatResponse))
+
+	rec := httptest.NewRecorder()
+	p.handleRequest(rec, chatRequest(`{"model": "gpt-4", "messages": [{"role": "user", "content": "Hi"}]}`))
+	if body, _ := io.ReadAll(rec.Body); rec.Code != http.StatusOK || string(body) != okChatResponse {
+		t.Errorf("response = %d %q, want the upstream's forwarded", rec.Code, body)
+	}
+	select {
+	case signal := <-signalCh:
+		if signal.Protocol != "internal" {
+			t.Errorf("non-allowed host emitted a %s signal", signal.Operation)
+		}
+	case <-time.After(100 * time.Millisecond):
+	}
+}
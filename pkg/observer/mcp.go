@@ -0,0 +1,152 @@
+package observer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// mcpProvider classifies Model Context Protocol traffic: JSON-RPC 2.0 calls
+// from agents to tool servers, which are recognized by their body rather
+// than by host. Known MCP hosts can also be listed in AXOM_PROVIDERS_FILE
+// with parser "mcp".
+var mcpProvider = AIProvider{Name: "MCP", Parser: "mcp"}
+
+// jsonRPCMessage is the envelope shared by JSON-RPC requests and responses
+type jsonRPCMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Result  json.RawMessage `json:"result"`
+	Error   *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// isMCPRequest reports whether body is a JSON-RPC 2.0 request
+func isMCPRequest(body []byte) bool {
+	var msg jsonRPCMessage
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return false
+	}
+	return msg.JSONRPC == "2.0" && msg.Method != ""
+}
+
+// detectMCPRequest reports whether a request that matched no provider is an
+// MCP call. The body is read to check and put back for forwarding.
+func detectMCPRequest(r *http.Request, limit bodyLimit) bool {
+	if r.Method != http.MethodPost {
+		return false
+	}
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+		return false
+	}
+	body, err := limit.capture(r.Body)
+	r.Body = body.readCloser()
+	return err == nil && isMCPRequest(body.data)
+}
+
+// parseMCPRequest records the JSON-RPC method as mcp_method, which becomes
+// the signal's operation, and what it acts on: the tool of tools/call, the
+// prompt of prompts/get, the resource of resources/read and the client of
+// initialize
+func parseMCPRequest(request map[string]interface{}, jsonData map[string]interface{}) {
+	method, _ := jsonData["method"].(string)
+	if method == "" {
+		return
+	}
+	request["mcp_method"] = method
+	if id, ok := jsonData["id"]; ok {
+		request["jsonrpc_id"] = id
+	}
+
+	params, _ := jsonData["params"].(map[string]interface{})
+	switch method {
+	case "tools/call":
+		if name, ok := params["name"].(string); ok {
+			request["mcp_tool"] = name
+		}
+		if arguments, ok := params["arguments"].(map[string]interface{}); ok {
+			names := make([]string, 0, len(arguments))
+			for name := range arguments {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			request["mcp_tool_arguments"] = names
+		}
+	case "prompts/get":
+		if name, ok := params["name"].(string); ok {
+			request["mcp_prompt"] = name
+		}
+	case "resources/read", "resources/subscribe":
+		if uri, ok := params["uri"].(string); ok {
+			request["mcp_resource_uri"] = uri
+		}
+	case "initialize":
+		if clientInfo, ok := params["clientInfo"].(map[string]interface{}); ok {
+			if name, ok := clientInfo["name"].(string); ok {
+				request["mcp_client"] = name
+			}
+		}
+		if version, ok := params["protocolVersion"].(string); ok {
+			request["mcp_protocol_version"] = version
+		}
+	}
+}
+
+// parseMCPResponse records the outcome of a JSON-RPC response, sent either
+// as a JSON body or as SSE "data:" events: a JSON-RPC error becomes
+// error_code/error_message, and a tool result flagged isError sets
+// mcp_tool_error
+func parseMCPResponse(response map[string]interface{}, bodyBytes []byte, contentType string) {
+	msg, ok := mcpResponseMessage(bodyBytes, contentType)
+	if !ok {
+		return
+	}
+	if msg.Error != nil {
+		response["error_code"] = strconv.Itoa(msg.Error.Code)
+		response["error_message"] = msg.Error.Message
+		return
+	}
+	var result struct {
+		IsError bool          `json:"isError"`
+		Tools   []interface{} `json:"tools"`
+	}
+	if json.Unmarshal(msg.Result, &result) != nil {
+		return
+	}
+	if result.IsError {
+		response["mcp_tool_error"] = true
+	}
+	if result.Tools != nil {
+		response["mcp_tool_count"] = len(result.Tools)
+	}
+}
+
+// mcpResponseMessage returns the JSON-RPC response in a body, taking the
+// first response event of an SSE stream
+func mcpResponseMessage(bodyBytes []byte, contentType string) (jsonRPCMessage, bool) {
+	var msg jsonRPCMessage
+	if !isEventStream(contentType) {
+		err := json.Unmarshal(bodyBytes, &msg)
+		return msg, err == nil && msg.JSONRPC == "2.0"
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(bodyBytes))
+	scanner.Buffer(make([]byte, 0, 64<<10), len(bodyBytes)+1)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		msg = jsonRPCMessage{}
+		if json.Unmarshal([]byte(strings.TrimSpace(data)), &msg) == nil && msg.JSONRPC == "2.0" && msg.Method == "" {
+			return msg, true
+		}
+	}
+	return msg, false
+}
@@ -0,0 +1,135 @@
+package observer
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const mcpToolCall = `{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"search_issues","arguments":{"repo":"axom","query":"flaky"}}}`
+
+func TestMCPToolCall(t *testing.T) {
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "application/json",
+		`{"jsonrpc":"2.0","id":7,"result":{"content":[{"type":"text","text":"3 issues found"}],"isError":false}}`))
+	signal := proxySignal(t, p, signalCh, jsonRequest("http://tools.internal:8080/mcp", mcpToolCall))
+
+	if signal.Operation != "tools/call" {
+		t.Errorf("Operation = %q, want tools/call", signal.Operation)
+	}
+	for key, want := range map[string]interface{}{
+		"provider":   "MCP",
+		"mcp_method": "tools/call",
+		"mcp_tool":   "search_issues",
+		"jsonrpc_id": 7.0,
+	} {
+		if got := signal.Metadata[key]; got != want {
+			t.Errorf("%s = %v, want %v", key, got, want)
+		}
+	}
+	if got := signal.Metadata["mcp_tool_arguments"]; !reflect.DeepEqual(got, []string{"query", "repo"}) {
+		t.Errorf("mcp_tool_arguments = %v, want the sorted argument names", got)
+	}
+	if _, ok := signal.Metadata["mcp_tool_error"]; ok {
+		t.Errorf("mcp_tool_error set for a successful call")
+	}
+}
+
+func TestMCPToolErrorOverSSE(t *testing.T) {
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "text/event-stream",
+		"event: message\n"+
+			`data: {"jsonrpc":"2.0","method":"notifications/progress","params":{"progress":1}}`+"\n\n"+
+			"event: message\n"+
+			`data: {"jsonrpc":"2.0","id":7,"result":{"content":[{"type":"text","text":"rate limited"}],"isError":true}}`+"\n\n"))
+	signal := proxySignal(t, p, signalCh, jsonRequest("http://tools.internal:8080/mcp", mcpToolCall))
+
+	if got := signal.Metadata["mcp_tool_error"]; got != true {
+		t.Errorf("mcp_tool_error = %v, want true from the response event", got)
+	}
+}
+
+func TestMCPJSONRPCError(t *testing.T) {
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "application/json",
+		`{"jsonrpc":"2.0","id":3,"error":{"code":-32601,"message":"Method not found"}}`))
+	signal := proxySignal(t, p, signalCh, jsonRequest("http://tools.internal:8080/mcp",
+		`{"jsonrpc":"2.0","id":3,"method":"resources/read","params":{"uri":"file:///etc/app.conf"}}`))
+
+	for key, want := range map[string]interface{}{
+		"mcp_resource_uri": "file:///etc/app.conf",
+		"error_code":       "-32601",
+		"error_message":    "Method not found",
+	} {
+		if got := signal.Metadata[key]; got != want {
+			t.Errorf("%s = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestMCPToolsList(t *testing.T) {
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "application/json",
+		`{"jsonrpc":"2.0","id":2,"result":{"tools":[{"name":"search_issues"},{"name":"create_issue"}]}}`))
+	signal := proxySignal(t, p, signalCh, jsonRequest("http://tools.internal:8080/mcp",
+		`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`))
+
+	if signal.Operation != "tools/list" || signal.Metadata["mcp_tool_count"] != 2 {
+		t.Errorf("signal = %s with %v tools, want tools/list with 2", signal.Operation, signal.Metadata["mcp_tool_count"])
+	}
+}
+
+func TestParseMCPRequest(t *testing.T) {
+	tests := map[string]struct {
+		body string
+		want map[string]interface{}
+	}{
+		"prompts/get": {`{"jsonrpc":"2.0","id":1,"method":"prompts/get","params":{"name":"summarize"}}`,
+			map[string]interface{}{"mcp_method": "prompts/get", "jsonrpc_id": 1.0, "mcp_prompt": "summarize"}},
+		"initialize": {`{"jsonrpc":"2.0","id":"init","method":"initialize","params":{"protocolVersion":"2025-03-26","clientInfo":{"name":"agent-runner"}}}`,
+			map[string]interface{}{"mcp_method": "initialize", "jsonrpc_id": "init", "mcp_client": "agent-runner", "mcp_protocol_version": "2025-03-26"}},
+		"notification": {`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+			map[string]interface{}{"mcp_method": "notifications/initialized"}},
+	}
+	for name, tt := range tests {
+		request := make(map[string]interface{})
+		var jsonData map[string]interface{}
+		if err := json.Unmarshal([]byte(tt.body), &jsonData); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		parseMCPRequest(request, jsonData)
+		if !reflect.DeepEqual(request, tt.want) {
+			t.Errorf("%s: parseMCPRequest = %v, want %v", name, request, tt.want)
+		}
+	}
+}
+
+func TestDetectMCPRequest(t *testing.T) {
+	tests := []struct {
+		method      string
+		contentType string
+		body        string
+		want        bool
+	}{
+		{http.MethodPost, "application/json", mcpToolCall, true},
+		{http.MethodPost, "application/json; charset=utf-8", mcpToolCall, true},
+		{http.MethodPost, "text/plain", mcpToolCall, false},
+		{http.MethodGet, "application/json", mcpToolCall, false},
+		{http.MethodPost, "application/json", `{"jsonrpc":"1.0","method":"tools/call"}`, false},
+		{http.MethodPost, "application/json", `{"jsonrpc":"2.0","id":1,"result":{}}`, false},
+		{http.MethodPost, "application/json", `{"model":"gpt-4"}`, false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "http://tools.internal:8080/mcp", strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", tt.contentType)
+		if got := detectMCPRequest(req, bodyLimitFromEnv()); got != tt.want {
+			t.Errorf("%s %s %s: detectMCPRequest = %v, want %v", tt.method, tt.contentType, tt.body, got, tt.want)
+		}
+		// The body is put back for forwarding
+		if tt.method == http.MethodPost {
+			if body, _ := io.ReadAll(req.Body); string(body) != tt.body {
+				t.Errorf("body after detection = %q, want %q", body, tt.body)
+			}
+		}
+	}
+}
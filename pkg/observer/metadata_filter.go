@@ -0,0 +1,54 @@
+package observer
+
+import (
+	"os"
+	"strings"
+)
+
+// Environment variables:
+//   AXOM_METADATA_ALLOW - Optional. Comma-separated metadata keys to keep; all others are dropped from signals. Default: all keys kept
+//   AXOM_METADATA_DENY  - Optional. Comma-separated metadata keys to drop from signals, e.g. "messages,system,generation_config". Default: none
+
+// metadataFilter drops top-level metadata keys by allowlist and denylist,
+// for data-governance rules on what leaves the environment. Unlike Redact,
+// which masks known secrets, it removes keys entirely.
+type metadataFilter struct {
+	allow map[string]bool // nil keeps every key not denied
+	deny  map[string]bool
+}
+
+// metadataFilterFromEnv reads the allowlist and denylist from the environment
+func metadataFilterFromEnv() metadataFilter {
+	return metadataFilter{
+		allow: metadataKeySet(os.Getenv("AXOM_METADATA_ALLOW")),
+		deny:  metadataKeySet(os.Getenv("AXOM_METADATA_DENY")),
+	}
+}
+
+// metadataKeySet parses a comma-separated key list, returning nil when empty
+func metadataKeySet(list string) map[string]bool {
+	var keys map[string]bool
+	for _, key := range strings.Split(list, ",") {
+		if key = strings.TrimSpace(key); key == "" {
+			continue
+		}
+		if keys == nil {
+			keys = make(map[string]bool)
+		}
+		keys[key] = true
+	}
+	return keys
+}
+
+// apply deletes the keys not on the allowlist, when one is set, and those on
+// the denylist
+func (f metadataFilter) apply(metadata map[string]interface{}) {
+	if f.allow == nil && f.deny == nil {
+		return
+	}
+	for key := range metadata {
+		if (f.allow != nil && !f.allow[key]) || f.deny[key] {
+			delete(metadata, key)
+		}
+	}
+}
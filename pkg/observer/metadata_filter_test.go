@@ -0,0 +1,66 @@
+package observer
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+const filteredChat = `{"model": "gpt-4", "messages": [{"role": "user", "content": "Hello"}]}`
+
+func TestMetadataDenylist(t *testing.T) {
+	t.Setenv("AXOM_METADATA_DENY", "prompt_preview, response_preview,model")
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "application/json", okChatResponse))
+	signal := proxySignal(t, p, signalCh, chatRequest(filteredChat))
+
+	for _, key := range []string{"prompt_preview", "response_preview", "model"} {
+		if got, ok := signal.Metadata[key]; ok {
+			t.Errorf("%s = %v, want it dropped by the denylist", key, got)
+		}
+	}
+	if got := signal.Metadata["total_tokens"]; got != 6 {
+		t.Errorf("total_tokens = %v, want token counts kept", got)
+	}
+	if got := signal.Metadata["provider"]; got != "OpenAI" {
+		t.Errorf("provider = %v, want it kept", got)
+	}
+}
+
+func TestMetadataAllowlist(t *testing.T) {
+	t.Setenv("AXOM_METADATA_ALLOW", "provider,prompt_tokens,completion_tokens,total_tokens")
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "application/json", okChatResponse))
+	signal := proxySignal(t, p, signalCh, chatRequest(filteredChat))
+
+	want := map[string]interface{}{
+		"provider":          "OpenAI",
+		"prompt_tokens":     5,
+		"completion_tokens": 1,
+		"total_tokens":      6,
+	}
+	if !reflect.DeepEqual(signal.Metadata, want) {
+		t.Errorf("Metadata = %v, want only the allowed keys %v", signal.Metadata, want)
+	}
+}
+
+func TestMetadataFilterDefaultKeepsEverything(t *testing.T) {
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "application/json", okChatResponse))
+	signal := proxySignal(t, p, signalCh, chatRequest(filteredChat))
+	for _, key := range []string{"provider", "model", "prompt_preview", "response_preview", "total_tokens"} {
+		if _, ok := signal.Metadata[key]; !ok {
+			t.Errorf("%s missing without a filter configured", key)
+		}
+	}
+}
+
+func TestMetadataFilterAllowAndDeny(t *testing.T) {
+	f := metadataFilter{allow: metadataKeySet("a, b"), deny: metadataKeySet("b,c")}
+	metadata := map[string]interface{}{"a": 1, "b": 2, "c": 3, "d": 4}
+	f.apply(metadata)
+	if want := map[string]interface{}{"a": 1}; !reflect.DeepEqual(metadata, want) {
+		t.Errorf("filtered = %v, want %v: a denied key is dropped even if allowed", metadata, want)
+	}
+
+	if keys := metadataKeySet(" , ,"); keys != nil {
+		t.Errorf("metadataKeySet of an empty list = %v, want nil", keys)
+	}
+}
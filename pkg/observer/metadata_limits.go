@@ -0,0 +1,96 @@
+package observer
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// Environment variables:
+//   AXOM_MAX_METADATA_KEYS  - Optional. Maximum number of metadata keys kept per signal. Default: 128
+//   AXOM_MAX_METADATA_BYTES - Optional. Maximum serialized metadata size per signal in bytes. Default: 65536
+
+const (
+	defaultMaxMetadataKeys  = 128
+	defaultMaxMetadataBytes = 64 * 1024
+)
+
+// priorityMetadataKeys are kept ahead of any other keys when metadata has to
+// be truncated, since billing and task detection depend on them
+var priorityMetadataKeys = []string{
+	"provider", "model", "endpoint", "method", "status_code",
+	"prompt_tokens", "completion_tokens", "total_tokens", "estimated_cost_usd",
+	"error_code", "error_type", "failure_class",
+}
+
+// metadataLimits bounds the size of a signal's metadata map
+type metadataLimits struct {
+	maxKeys  int
+	maxBytes int
+}
+
+// metadataLimitsFromEnv reads the metadata limits from the environment
+func metadataLimitsFromEnv() metadataLimits {
+	limits := metadataLimits{maxKeys: defaultMaxMetadataKeys, maxBytes: defaultMaxMetadataBytes}
+	if v := os.Getenv("AXOM_MAX_METADATA_KEYS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			limits.maxKeys = n
+		}
+	}
+	if v := os.Getenv("AXOM_MAX_METADATA_BYTES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			limits.maxBytes = n
+		}
+	}
+	return limits
+}
+
+// apply drops metadata keys beyond the configured key count and serialized
+// size, setting metadata["metadata_truncated"] when anything was dropped.
+// Priority keys are kept first, then the rest in sorted order so truncation
+// is deterministic.
+func (l metadataLimits) apply(metadata map[string]interface{}) {
+	if len(metadata) == 0 {
+		return
+	}
+	if len(metadata) <= l.maxKeys {
+		if data, err := json.Marshal(metadata); err == nil && len(data) <= l.maxBytes {
+			return
+		}
+	}
+
+	keys := make([]string, 0, len(metadata))
+	priority := make(map[string]bool, len(priorityMetadataKeys))
+	for _, key := range priorityMetadataKeys {
+		if _, ok := metadata[key]; ok {
+			keys = append(keys, key)
+			priority[key] = true
+		}
+	}
+	rest := make([]string, 0, len(metadata))
+	for key := range metadata {
+		if !priority[key] {
+			rest = append(rest, key)
+		}
+	}
+	sort.Strings(rest)
+	keys = append(keys, rest...)
+
+	// Reserve room for the truncation flag itself
+	maxKeys := l.maxKeys - 1
+	size := len(`{"metadata_truncated":true}`)
+	kept := 0
+	for _, key := range keys {
+		// Each entry costs "key":value plus a separating comma
+		entry, err := json.Marshal(metadata[key])
+		entrySize := len(key) + 4 + len(entry)
+		if err != nil || kept >= maxKeys || size+entrySize > l.maxBytes {
+			delete(metadata, key)
+			continue
+		}
+		size += entrySize
+		kept++
+	}
+	metadata["metadata_truncated"] = true
+}
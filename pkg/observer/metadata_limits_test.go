@@ -0,0 +1,76 @@
+package observer
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMetadataLimitsTruncatesKeyCount(t *testing.T) {
+	metadata := map[string]interface{}{"provider": "OpenAI", "model": "gpt-4", "total_tokens": 42}
+	for i := 0; i < 5000; i++ {
+		metadata[fmt.Sprintf("field_%04d", i)] = i
+	}
+
+	metadataLimits{maxKeys: 100, maxBytes: defaultMaxMetadataBytes}.apply(metadata)
+
+	if len(metadata) > 100 {
+		t.Errorf("%d keys kept, want at most 100", len(metadata))
+	}
+	if metadata["metadata_truncated"] != true {
+		t.Errorf("metadata_truncated not set")
+	}
+	for _, key := range []string{"provider", "model", "total_tokens"} {
+		if _, ok := metadata[key]; !ok {
+			t.Errorf("priority key %q was dropped", key)
+		}
+	}
+	if _, ok := metadata["field_0000"]; !ok {
+		t.Errorf("keys are not kept in sorted order")
+	}
+	if _, ok := metadata["field_4999"]; ok {
+		t.Errorf("excess key field_4999 was kept")
+	}
+}
+
+func TestMetadataLimitsTruncatesSerializedSize(t *testing.T) {
+	metadata := map[string]interface{}{
+		"provider":       "OpenAI",
+		"prompt_preview": strings.Repeat("a", 2000),
+		"messages":       strings.Repeat("b", 5000),
+	}
+
+	metadataLimits{maxKeys: defaultMaxMetadataKeys, maxBytes: 1024}.apply(metadata)
+
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) > 1024 {
+		t.Errorf("serialized metadata is %d bytes, want at most 1024", len(data))
+	}
+	if metadata["metadata_truncated"] != true || metadata["provider"] != "OpenAI" {
+		t.Errorf("metadata = %v, want provider kept and metadata_truncated set", metadata)
+	}
+}
+
+func TestMetadataLimitsLeavesSmallMetadataAlone(t *testing.T) {
+	metadata := map[string]interface{}{"provider": "OpenAI", "model": "gpt-4"}
+	metadataLimits{maxKeys: defaultMaxMetadataKeys, maxBytes: defaultMaxMetadataBytes}.apply(metadata)
+	if len(metadata) != 2 {
+		t.Errorf("metadata = %v, want it unchanged", metadata)
+	}
+}
+
+func TestMetadataLimitsInSignal(t *testing.T) {
+	t.Setenv("AXOM_MAX_METADATA_KEYS", "10")
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "application/json", okChatResponse))
+
+	signal := proxySignal(t, p, signalCh, chatRequest(`{"model":"gpt-4","messages":[{"role":"user","content":"Hello"}]}`))
+
+	if len(signal.Metadata) > 10 || signal.Metadata["metadata_truncated"] != true {
+		t.Errorf("signal kept %d metadata keys (truncated %v), want at most 10 and flagged", len(signal.Metadata), signal.Metadata["metadata_truncated"])
+	}
+}
@@ -0,0 +1,286 @@
+package observer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"axom-observer/pkg/models"
+)
+
+// observerMetrics is one set of the observer's collectors. RegisterMetrics
+// creates a set per registry, so registries never share counts, and every
+// update goes to each registered set.
+type observerMetrics struct {
+	signalsSent          prometheus.Counter
+	signalsDropped       prometheus.Counter
+	signalsDeadLettered  prometheus.Counter
+	signalsMarshalFailed prometheus.Counter
+	signalsHookDropped   prometheus.Counter
+	proxySignalsDropped  *prometheus.CounterVec
+	signalsSampledOut    *prometheus.CounterVec
+	tokensSampledOut     *prometheus.CounterVec
+	duplicateRequests    *prometheus.CounterVec
+	requestLatency       *prometheus.HistogramVec
+	tokensTotal          *prometheus.CounterVec
+	requestsTotal        *prometheus.CounterVec
+	upstreamInflight     *prometheus.GaugeVec
+	inflightRejected     *prometheus.CounterVec
+	backendBreakerState  prometheus.Gauge
+}
+
+// newObserverMetrics creates a fresh, unregistered set of collectors
+func newObserverMetrics() *observerMetrics {
+	return &observerMetrics{
+		signalsSent: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "axom_signals_sent_total",
+			Help: "Total number of signals sent to backend",
+		}),
+		signalsDropped: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "axom_signals_dropped_total",
+			Help: "Total number of signals dropped after retries",
+		}),
+		signalsDeadLettered: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "axom_signals_dead_lettered_total",
+			Help: "Total number of signals written to the dead-letter file after retries",
+		}),
+		signalsMarshalFailed: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "axom_signals_marshal_failed_total",
+			Help: "Total number of signals dropped because they could not be serialized",
+		}),
+		signalsHookDropped: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "axom_signals_hook_dropped_total",
+			Help: "Total number of signals dropped by a registered signal hook",
+		}),
+		proxySignalsDropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "axom_proxy_signals_dropped_total",
+			Help: "Total number of captured signals dropped because the signal channel was full",
+		}, []string{"provider"}),
+		signalsSampledOut: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "axom_signals_sampled_out_total",
+			Help: "Total number of captured signals discarded by sampling",
+		}, []string{"provider", "operation"}),
+		tokensSampledOut: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "axom_tokens_sampled_out_total",
+			Help: "Total tokens carried by signals discarded by sampling",
+		}, []string{"provider", "operation"}),
+		duplicateRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "axom_duplicate_requests_total",
+			Help: "Total number of requests identical to one the same agent made shortly before",
+		}, []string{"provider"}),
+		requestLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "axom_request_latency_ms",
+			Help:    "Latency of proxied AI requests in milliseconds",
+			Buckets: []float64{50, 100, 250, 500, 1000, 2500, 5000, 10000, 30000, 60000},
+		}, []string{"provider", "operation"}),
+		tokensTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "axom_tokens_total",
+			Help: "Total tokens reported by AI providers",
+		}, []string{"provider", "model", "token_type"}),
+		requestsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "axom_requests_total",
+			Help: "Total number of proxied AI requests by response status class",
+		}, []string{"provider", "status_class"}),
+		upstreamInflight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "axom_upstream_inflight_requests",
+			Help: "Number of upstream requests currently in flight",
+		}, []string{"proxy"}),
+		inflightRejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "axom_upstream_inflight_timeouts_total",
+			Help: "Total number of requests that failed waiting for an upstream request slot",
+		}, []string{"proxy"}),
+		backendBreakerState: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "axom_backend_breaker_state",
+			Help: "State of the backend circuit breaker: 0 closed, 1 open, 2 half-open",
+		}),
+	}
+}
+
+// collectors lists every collector in the set
+func (m *observerMetrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		m.signalsSent,
+		m.signalsDropped,
+		m.signalsDeadLettered,
+		m.signalsMarshalFailed,
+		m.signalsHookDropped,
+		m.proxySignalsDropped,
+		m.signalsSampledOut,
+		m.tokensSampledOut,
+		m.duplicateRequests,
+		m.requestLatency,
+		m.tokensTotal,
+		m.requestsTotal,
+		m.upstreamInflight,
+		m.inflightRejected,
+		m.backendBreakerState,
+	}
+}
+
+var (
+	metricsMu  sync.RWMutex
+	metricSets = make(map[prometheus.Registerer]*observerMetrics)
+)
+
+// eachMetrics calls update with every registered set of collectors
+func eachMetrics(update func(m *observerMetrics)) {
+	metricsMu.RLock()
+	defer metricsMu.RUnlock()
+	for _, m := range metricSets {
+		update(m)
+	}
+}
+
+// RegisterMetrics registers a new set of the observer's collectors with reg,
+// or with the default registerer if reg is nil, e.g. to expose them on an
+// embedding program's own registry. Nothing is registered until it is
+// called; calling it again for the same registry does nothing.
+func RegisterMetrics(reg prometheus.Registerer) error {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	if _, ok := metricSets[reg]; ok {
+		return nil
+	}
+	m := newObserverMetrics()
+	for i, c := range m.collectors() {
+		if err := reg.Register(c); err != nil {
+			for _, registered := range m.collectors()[:i] {
+				reg.Unregister(registered)
+			}
+			return err
+		}
+	}
+	metricSets[reg] = m
+	return nil
+}
+
+// recordSignalMetrics updates the per-request latency, token and status
+// metrics for a signal created by one of the proxies
+func recordSignalMetrics(signal models.Signal, provider string) {
+	model, _ := signal.Metadata["model"].(string)
+	if model == "" {
+		model = "unknown"
+	}
+	eachMetrics(func(m *observerMetrics) {
+		m.requestLatency.WithLabelValues(provider, signal.Operation).Observe(signal.LatencyMS)
+		m.requestsTotal.WithLabelValues(provider, statusClass(signal.Status)).Inc()
+		for _, tokenType := range []string{"prompt", "completion"} {
+			if tokens, ok := signal.Metadata[tokenType+"_tokens"].(int); ok && tokens > 0 {
+				m.tokensTotal.WithLabelValues(provider, model, tokenType).Add(float64(tokens))
+			}
+		}
+	})
+}
+
+// statusClass buckets an HTTP status code as "2xx", "4xx", etc.
+func statusClass(status int) string {
+	if status < 100 || status > 599 {
+		return "unknown"
+	}
+	return fmt.Sprintf("%dxx", status/100)
+}
+
+// MetricsServer serves Prometheus metrics over HTTP
+type MetricsServer struct {
+	addr     string
+	logger   *log.Logger
+	mux      *http.ServeMux
+	server   *http.Server
+	listener net.Listener
+}
+
+// NewMetricsServer creates a metrics server. If addr is empty, the port is
+// taken from AXOM_METRICS_PORT (default 2112).
+func NewMetricsServer(addr string, logger *log.Logger) *MetricsServer {
+	if addr == "" {
+		port := os.Getenv("AXOM_METRICS_PORT")
+		if port == "" {
+			port = "2112"
+		}
+		addr = ":" + port
+	}
+	// /metrics serves the default registry
+	if err := RegisterMetrics(nil); err != nil {
+		logger.Printf("Failed to register metrics: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	return &MetricsServer{
+		addr:   addr,
+		logger: logger,
+		mux:    mux,
+	}
+}
+
+// StartMetricsServer creates a metrics server on addr, as NewMetricsServer
+// does, and starts it. It shuts down when ctx is cancelled; pass ":0" for an
+// ephemeral port, e.g. in tests.
+func StartMetricsServer(ctx context.Context, addr string, logger *log.Logger) (*MetricsServer, error) {
+	m := NewMetricsServer(addr, logger)
+	if err := m.Start(ctx); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Handle serves handler at pattern alongside /metrics. It must be called
+// before Start.
+func (m *MetricsServer) Handle(pattern string, handler http.Handler) {
+	m.mux.Handle(pattern, handler)
+}
+
+// Start binds the metrics listener and serves in the background until Stop
+// is called or ctx is cancelled
+func (m *MetricsServer) Start(ctx context.Context) error {
+	listener, err := net.Listen("tcp", m.addr)
+	if err != nil {
+		return err
+	}
+	m.listener = listener
+
+	m.server = &http.Server{Handler: m.mux}
+
+	go func() {
+		if err := m.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			m.logger.Printf("Prometheus metrics server error: %v", err)
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		m.Stop(shutdownCtx)
+	}()
+
+	m.logger.Printf("📈 Prometheus metrics available at %s/metrics", listener.Addr())
+	return nil
+}
+
+// Stop gracefully shuts down the metrics server
+func (m *MetricsServer) Stop(ctx context.Context) error {
+	if m.server != nil {
+		return m.server.Shutdown(ctx)
+	}
+	return nil
+}
+
+// Addr returns the address the server is listening on, which differs from
+// the configured one when an ephemeral port (":0") was requested
+func (m *MetricsServer) Addr() string {
+	if m.listener != nil {
+		return m.listener.Addr().String()
+	}
+	return m.addr
+}
@@ -0,0 +1,254 @@
+package observer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// testLogger returns a logger that discards its output
+func testLogger() *log.Logger {
+	return log.New(io.Discard, "", 0)
+}
+
+func TestMetricsServerStartStop(t *testing.T) {
+	m := NewMetricsServer("127.0.0.1:0", testLogger())
+	if err := m.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	resp, err := http.Get("http://" + m.Addr() + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /metrics status = %d, want 200", resp.StatusCode)
+	}
+	if !strings.Contains(string(body), "axom_signals_sent_total") {
+		t.Errorf("/metrics does not expose axom_signals_sent_total")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := m.Stop(ctx); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if _, err := http.Get("http://" + m.Addr() + "/metrics"); err == nil {
+		t.Errorf("metrics server still serving after Stop")
+	}
+}
+
+func TestMetricsServerStopsWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := NewMetricsServer("127.0.0.1:0", testLogger())
+	if err := m.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	cancel()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := http.Get("http://" + m.Addr() + "/metrics"); err != nil {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("metrics server still serving after its context was cancelled")
+}
+
+func TestStartMetricsServerEphemeral(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// Two servers at once get their own ports and share the collectors
+	first, err := StartMetricsServer(ctx, "127.0.0.1:0", testLogger())
+	if err != nil {
+		t.Fatalf("StartMetricsServer: %v", err)
+	}
+	second, err := StartMetricsServer(ctx, "127.0.0.1:0", testLogger())
+	if err != nil {
+		t.Fatalf("second StartMetricsServer: %v", err)
+	}
+	if first.Addr() == second.Addr() || strings.HasSuffix(first.Addr(), ":0") {
+		t.Fatalf("servers listen on %s and %s, want distinct ephemeral ports", first.Addr(), second.Addr())
+	}
+	for _, m := range []*MetricsServer{first, second} {
+		resp, err := http.Get("http://" + m.Addr() + "/metrics")
+		if err != nil {
+			t.Fatalf("GET /metrics: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("GET %s/metrics status = %d, want 200", m.Addr(), resp.StatusCode)
+		}
+	}
+
+	cancel()
+	deadline := time.Now().Add(5 * time.Second)
+	for _, m := range []*MetricsServer{first, second} {
+		for {
+			if _, err := http.Get("http://" + m.Addr() + "/metrics"); err != nil {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("%s still serving after its context was cancelled", m.Addr())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
+
+func TestStartMetricsServerAddressInUse(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m, err := StartMetricsServer(ctx, "127.0.0.1:0", testLogger())
+	if err != nil {
+		t.Fatalf("StartMetricsServer: %v", err)
+	}
+	if _, err := StartMetricsServer(ctx, m.Addr(), testLogger()); err == nil {
+		t.Errorf("StartMetricsServer on a bound address succeeded, want an error")
+	}
+}
+
+func TestNewMetricsServerPortFromEnv(t *testing.T) {
+	t.Setenv("AXOM_METRICS_PORT", "9123")
+	if got := NewMetricsServer("", testLogger()).Addr(); got != ":9123" {
+		t.Errorf("Addr() = %q, want %q", got, ":9123")
+	}
+
+	t.Setenv("AXOM_METRICS_PORT", "")
+	if got := NewMetricsServer("", testLogger()).Addr(); got != ":2112" {
+		t.Errorf("Addr() = %q, want default %q", got, ":2112")
+	}
+}
+
+// newTestRegistry returns a fresh registry with the observer's collectors
+// registered, so a test sees only the counts recorded after it was created
+func newTestRegistry(t *testing.T) *prometheus.Registry {
+	t.Helper()
+	reg := prometheus.NewRegistry()
+	if err := RegisterMetrics(reg); err != nil {
+		t.Fatalf("RegisterMetrics: %v", err)
+	}
+	return reg
+}
+
+// metricValue returns the value of the counter or gauge series name with
+// the given labels in reg, or 0 if it has not been recorded
+func metricValue(t *testing.T, reg *prometheus.Registry, name string, labels map[string]string) float64 {
+	t.Helper()
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	series:
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if want, ok := labels[label.GetName()]; ok && want != label.GetValue() {
+					continue series
+				}
+			}
+			switch {
+			case metric.Counter != nil:
+				return metric.GetCounter().GetValue()
+			case metric.Gauge != nil:
+				return metric.GetGauge().GetValue()
+			}
+		}
+	}
+	return 0
+}
+
+func TestMetricsExposeSignalSeries(t *testing.T) {
+	m := NewMetricsServer("127.0.0.1:0", testLogger())
+	if err := m.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer m.Stop(context.Background())
+
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "application/json", okChatResponse))
+	// The default registry outlives the test, so a model of its own keeps
+	// the token series at this run's counts
+	model := fmt.Sprintf("gpt-4-metrics-%d", time.Now().UnixNano())
+	proxySignal(t, p, signalCh, chatRequest(`{"model":"`+model+`","messages":[{"role":"user","content":"Hello"}]}`))
+
+	resp, err := http.Get("http://" + m.Addr() + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	for _, series := range []string{
+		`axom_request_latency_ms_bucket{operation="chat_completion",provider="OpenAI",le="50"}`,
+		`axom_request_latency_ms_count{operation="chat_completion",provider="OpenAI"}`,
+		`axom_requests_total{provider="OpenAI",status_class="2xx"}`,
+		`axom_tokens_total{model="` + model + `",provider="OpenAI",token_type="prompt"} 5`,
+		`axom_tokens_total{model="` + model + `",provider="OpenAI",token_type="completion"} 1`,
+	} {
+		if !strings.Contains(string(body), series) {
+			t.Errorf("/metrics does not expose %s", series)
+		}
+	}
+}
+
+func TestRegisterMetricsTwice(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	for i := 0; i < 2; i++ {
+		if err := RegisterMetrics(reg); err != nil {
+			t.Fatalf("RegisterMetrics call %d: %v", i+1, err)
+		}
+	}
+	// The default registry too, as NewMetricsServer registers it
+	if err := RegisterMetrics(nil); err != nil {
+		t.Fatalf("RegisterMetrics(nil): %v", err)
+	}
+	if err := RegisterMetrics(nil); err != nil {
+		t.Fatalf("second RegisterMetrics(nil): %v", err)
+	}
+
+	// Every registry gets its own collectors, each counting once
+	other := newTestRegistry(t)
+	recordSignalMetrics(testSignal("sig-1", nil), "OpenAI")
+	for _, r := range []*prometheus.Registry{reg, other} {
+		if got := metricValue(t, r, "axom_requests_total", map[string]string{"provider": "OpenAI"}); got < 1 {
+			t.Errorf("axom_requests_total = %v, want the signal counted", got)
+		}
+	}
+	if got := metricValue(t, other, "axom_requests_total", map[string]string{"provider": "OpenAI"}); got != 1 {
+		t.Errorf("fresh registry axom_requests_total = %v, want 1", got)
+	}
+}
+
+func TestRegisterMetricsConflict(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "axom_backend_breaker_state",
+		Help: "A collector of the same name from elsewhere",
+	}))
+	if err := RegisterMetrics(reg); err == nil {
+		t.Fatalf("RegisterMetrics over a conflicting collector succeeded, want an error")
+	}
+	// Nothing registered before the conflict is left behind
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != "axom_backend_breaker_state" {
+			t.Errorf("%s left registered after a failed RegisterMetrics", family.GetName())
+		}
+	}
+}
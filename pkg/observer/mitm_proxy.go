@@ -13,7 +13,6 @@ import (
 	"math/big"
 	"net/http"
 	"os"
-	"sync"
 	"time"
 )
 
@@ -25,8 +24,7 @@ type MITMProxy struct {
 	CACertPath string
 	logger     *log.Logger
 	server     *http.Server
-	mu         sync.Mutex
-	certCache  map[string]*tls.Certificate
+	certs      *leafCertStore
 }
 
 func NewMITMProxy(addr, caCertPath, caKeyPath string, logger *log.Logger) *MITMProxy {
@@ -35,7 +33,7 @@ func NewMITMProxy(addr, caCertPath, caKeyPath string, logger *log.Logger) *MITMP
 		CAKeyPath:  caKeyPath,
 		CACertPath: caCertPath,
 		logger:     logger,
-		certCache:  make(map[string]*tls.Certificate),
+		certs:      newLeafCertStore(0),
 	}
 }
 
@@ -79,17 +77,9 @@ func (p *MITMProxy) Start(ctx context.Context, handler http.Handler) error {
 
 // getOrCreateCert returns a leaf cert for the given server name
 func (p *MITMProxy) getOrCreateCert(serverName string, caCert *x509.Certificate, caKey *rsa.PrivateKey) (*tls.Certificate, error) {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	if cert, ok := p.certCache[serverName]; ok {
-		return cert, nil
-	}
-	cert, err := generateLeafCert(serverName, caCert, caKey)
-	if err != nil {
-		return nil, err
-	}
-	p.certCache[serverName] = cert
-	return cert, nil
+	return p.certs.getOrCreate(serverName, func(hostname string) (*tls.Certificate, error) {
+		return generateLeafCert(hostname, caCert, caKey)
+	})
 }
 
 // ensureCA generates a CA cert/key if not present
@@ -179,9 +169,14 @@ func generateLeafCert(serverName string, caCert *x509.Certificate, caKey *rsa.Pr
 	if err != nil {
 		return nil, err
 	}
+	leaf, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		return nil, err
+	}
 	cert := &tls.Certificate{
 		Certificate: [][]byte{certDER, caCert.Raw},
 		PrivateKey:  priv,
+		Leaf:        leaf,
 	}
 	return cert, nil
 }
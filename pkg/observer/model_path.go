@@ -0,0 +1,84 @@
+package observer
+
+import (
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Environment variables:
+//   AXOM_AZURE_DEPLOYMENTS - Optional. Maps Azure OpenAI deployment names to models, e.g. "prod-chat=gpt-4o,embed=text-embedding-3-small". Default: deployment name used as the model
+
+// modelFromPath returns the model that Bedrock, Vertex AI and Azure OpenAI
+// carry in the URL path rather than the request body:
+//
+//	/model/{modelId}/invoke                                                  (Bedrock)
+//	/v1/projects/{p}/locations/{l}/publishers/{pub}/models/{model}:{method}  (Vertex AI)
+//	/openai/deployments/{deployment}/chat/completions                        (Azure OpenAI)
+//
+// For Azure this is the deployment name, which is only the model when the
+// deployment is named after it; see azureDeploymentModels.
+func modelFromPath(providerName, urlPath string) string {
+	switch providerName {
+	case "Amazon Bedrock":
+		rest, ok := strings.CutPrefix(urlPath, "/model/")
+		if !ok {
+			return ""
+		}
+		model, _, _ := strings.Cut(rest, "/")
+		return model
+	case "Vertex AI":
+		_, rest, ok := strings.Cut(urlPath, "/models/")
+		if !ok {
+			return ""
+		}
+		model, _, _ := strings.Cut(rest, ":")
+		return model
+	case "Azure OpenAI":
+		_, rest, ok := strings.Cut(urlPath, "/openai/deployments/")
+		if !ok {
+			return ""
+		}
+		deployment, _, _ := strings.Cut(rest, "/")
+		return deployment
+	}
+	return ""
+}
+
+// azureDeploymentModels maps Azure OpenAI deployment names to the model
+// they serve
+type azureDeploymentModels map[string]string
+
+// azureDeploymentModelsFromEnv reads the deployment table from
+// AXOM_AZURE_DEPLOYMENTS
+func azureDeploymentModelsFromEnv() azureDeploymentModels {
+	models := make(azureDeploymentModels)
+	for _, pair := range strings.Split(os.Getenv("AXOM_AZURE_DEPLOYMENTS"), ",") {
+		deployment, model, found := strings.Cut(pair, "=")
+		deployment, model = strings.TrimSpace(deployment), strings.TrimSpace(model)
+		if found && deployment != "" && model != "" {
+			models[deployment] = model
+		}
+	}
+	return models
+}
+
+// apply records the deployment and api-version of an Azure OpenAI request.
+// The model comes from the deployment table, then the request body, then
+// the deployment name itself, since Azure ignores any model in the body.
+func (m azureDeploymentModels) apply(request map[string]interface{}, r *http.Request) {
+	deployment := modelFromPath("Azure OpenAI", r.URL.Path)
+	if deployment == "" {
+		return
+	}
+	request["azure_deployment"] = deployment
+	if apiVersion := r.URL.Query().Get("api-version"); apiVersion != "" {
+		request["api_version"] = apiVersion
+	}
+
+	if model, ok := m[deployment]; ok {
+		request["model"] = model
+	} else if model, _ := request["model"].(string); model == "" {
+		request["model"] = deployment
+	}
+}
@@ -0,0 +1,61 @@
+package observer
+
+import (
+	"net/http"
+	"testing"
+)
+
+const azureChatURL = "http://myresource.openai.azure.com/openai/deployments/prod-gpt4o/chat/completions?api-version=2024-06-01"
+
+func TestAzureDeploymentModel(t *testing.T) {
+	tests := []struct {
+		name        string
+		deployments string
+		body        string
+		want        string
+	}{
+		{"deployment name", "", `{"messages": [{"role": "user", "content": "Hi"}]}`, "prod-gpt4o"},
+		{"model in body", "", `{"model": "gpt-4o", "messages": [{"role": "user", "content": "Hi"}]}`, "gpt-4o"},
+		{"deployment table", "prod-gpt4o=gpt-4o-2024-08-06, other=gpt-35-turbo", `{"model": "gpt-4", "messages": [{"role": "user", "content": "Hi"}]}`, "gpt-4o-2024-08-06"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("AXOM_AZURE_DEPLOYMENTS", tt.deployments)
+			p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "application/json", okChatResponse))
+			signal := proxySignal(t, p, signalCh, jsonRequest(azureChatURL, tt.body))
+
+			if got := signal.Metadata["provider"]; got != "Azure OpenAI" {
+				t.Fatalf("provider = %v, want Azure OpenAI", got)
+			}
+			if got := signal.Metadata["model"]; got != tt.want {
+				t.Errorf("model = %v, want %s", got, tt.want)
+			}
+			if got := signal.Metadata["azure_deployment"]; got != "prod-gpt4o" {
+				t.Errorf("azure_deployment = %v, want prod-gpt4o", got)
+			}
+			if got := signal.Metadata["api_version"]; got != "2024-06-01" {
+				t.Errorf("api_version = %v, want 2024-06-01", got)
+			}
+		})
+	}
+}
+
+func TestModelFromPath(t *testing.T) {
+	tests := []struct {
+		provider string
+		path     string
+		want     string
+	}{
+		{"Azure OpenAI", "/openai/deployments/prod-gpt4o/chat/completions", "prod-gpt4o"},
+		{"Azure OpenAI", "/openai/deployments/embed-small/embeddings", "embed-small"},
+		{"Azure OpenAI", "/openai/models", ""},
+		{"Amazon Bedrock", "/model/amazon.titan-text-express-v1/invoke", "amazon.titan-text-express-v1"},
+		{"Vertex AI", "/v1/projects/p/locations/l/publishers/google/models/gemini-1.5-pro:generateContent", "gemini-1.5-pro"},
+		{"OpenAI", "/openai/deployments/prod-gpt4o/chat/completions", ""},
+	}
+	for _, tt := range tests {
+		if got := modelFromPath(tt.provider, tt.path); got != tt.want {
+			t.Errorf("modelFromPath(%q, %q) = %q, want %q", tt.provider, tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package observer
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"axom-observer/pkg/models"
+)
+
+// Environment variables:
+//   AXOM_LOCAL_MODERATION      - Optional. Set to "1" to score prompt/response previews locally. Default: disabled
+//   AXOM_MODERATION_KEYWORDS   - Optional. Extra comma-separated keywords to flag (weight 0.5 each)
+//   AXOM_MODERATION_THRESHOLD  - Optional. Score (0-1) at or above which an alert is raised. Default: 0.5
+
+// defaultModerationTerms maps flagged terms to their weight. Weights combine
+// as independent probabilities, so several weak hits add up to a strong one.
+var defaultModerationTerms = map[string]float64{
+	// Violence
+	"kill":       0.4,
+	"murder":     0.6,
+	"bomb":       0.5,
+	"shoot":      0.3,
+	"weapon":     0.3,
+	"massacre":   0.7,
+	"terrorist":  0.6,
+	"explosives": 0.5,
+	// Self-harm
+	"suicide":     0.6,
+	"self-harm":   0.6,
+	"kill myself": 0.9,
+	// Harassment
+	"idiot":     0.2,
+	"stupid":    0.15,
+	"hate you":  0.4,
+	"worthless": 0.3,
+	// Illicit
+	"meth":             0.4,
+	"cocaine":          0.4,
+	"hack into":        0.5,
+	"steal":            0.3,
+	"credit card dump": 0.8,
+}
+
+// LocalModerator computes a lightweight keyword-based risk score for prompt
+// and response previews. It is intended for providers without built-in
+// moderation and is deliberately cheap rather than accurate.
+type LocalModerator struct {
+	terms     []moderationTerm
+	threshold float64
+}
+
+// moderationTerm is a flagged term matched on word boundaries
+type moderationTerm struct {
+	pattern *regexp.Regexp
+	weight  float64
+}
+
+// NewLocalModerator creates a moderator from the given weighted terms
+func NewLocalModerator(terms map[string]float64, threshold float64) *LocalModerator {
+	m := &LocalModerator{threshold: threshold}
+	for term, weight := range terms {
+		m.terms = append(m.terms, moderationTerm{
+			pattern: regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(term) + `\b`),
+			weight:  weight,
+		})
+	}
+	return m
+}
+
+// newLocalModeratorFromEnv returns a moderator configured from the
+// environment, or nil when local moderation is disabled
+func newLocalModeratorFromEnv() *LocalModerator {
+	if os.Getenv("AXOM_LOCAL_MODERATION") != "1" {
+		return nil
+	}
+	terms := make(map[string]float64, len(defaultModerationTerms))
+	for term, weight := range defaultModerationTerms {
+		terms[term] = weight
+	}
+	for _, term := range strings.Split(os.Getenv("AXOM_MODERATION_KEYWORDS"), ",") {
+		if term = strings.ToLower(strings.TrimSpace(term)); term != "" {
+			terms[term] = 0.5
+		}
+	}
+	threshold := 0.5
+	if v := os.Getenv("AXOM_MODERATION_THRESHOLD"); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f > 0 && f <= 1 {
+			threshold = f
+		}
+	}
+	return NewLocalModerator(terms, threshold)
+}
+
+// Score returns a 0-1 risk score for text
+func (m *LocalModerator) Score(text string) float64 {
+	if text == "" {
+		return 0
+	}
+	clean := 1.0
+	for _, term := range m.terms {
+		if term.pattern.MatchString(text) {
+			clean *= 1 - term.weight
+		}
+	}
+	return math.Round((1-clean)*1000) / 1000
+}
+
+// Apply scores the prompt and response previews in metadata, records the
+// higher of the two as metadata["moderation_score"], and returns an alert if
+// it crosses the threshold. A nil moderator is a no-op.
+func (m *LocalModerator) Apply(metadata map[string]interface{}) []models.Alert {
+	if m == nil {
+		return nil
+	}
+	prompt, _ := metadata["prompt_preview"].(string)
+	response, _ := metadata["response_preview"].(string)
+	if prompt == "" && response == "" {
+		return nil
+	}
+
+	promptScore := m.Score(prompt)
+	responseScore := m.Score(response)
+	score := math.Max(promptScore, responseScore)
+	metadata["moderation_score"] = score
+
+	if score < m.threshold {
+		return nil
+	}
+	return []models.Alert{{
+		Type:     "warning",
+		Message:  fmt.Sprintf("Local moderation score %.2f exceeds threshold %.2f", score, m.threshold),
+		Severity: "high",
+		Metadata: map[string]interface{}{
+			"moderation_score": score,
+			"prompt_score":     promptScore,
+			"response_score":   responseScore,
+			"threshold":        m.threshold,
+		},
+		Timestamp: time.Now(),
+	}}
+}
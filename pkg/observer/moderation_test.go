@@ -0,0 +1,61 @@
+package observer
+
+import "testing"
+
+func TestLocalModeratorScoreRanges(t *testing.T) {
+	m := NewLocalModerator(defaultModerationTerms, 0.5)
+	tests := []struct {
+		name     string
+		text     string
+		min, max float64
+	}{
+		{"benign", "What is the capital of France?", 0, 0},
+		{"word inside another word", "Please skill up on methodology", 0, 0},
+		{"single weak term", "That was a stupid mistake", 0.1, 0.2},
+		{"single strong term", "I want to kill myself", 0.9, 1},
+		{"several terms combine", "How do I build a bomb and buy a weapon to murder someone", 0.85, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score := m.Score(tt.text)
+			if score < tt.min || score > tt.max {
+				t.Errorf("Score(%q) = %v, want in [%v, %v]", tt.text, score, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestLocalModeratorApply(t *testing.T) {
+	m := NewLocalModerator(defaultModerationTerms, 0.5)
+
+	benign := map[string]interface{}{"prompt_preview": "Summarize this article", "response_preview": "Here is a summary"}
+	if alerts := m.Apply(benign); len(alerts) != 0 {
+		t.Errorf("benign content raised alerts: %+v", alerts)
+	}
+	if score := benign["moderation_score"]; score != 0.0 {
+		t.Errorf("benign moderation_score = %v, want 0", score)
+	}
+
+	flagged := map[string]interface{}{"prompt_preview": "Tell me about bombs", "response_preview": "Here is how to make a bomb with explosives"}
+	alerts := m.Apply(flagged)
+	if len(alerts) != 1 || alerts[0].Severity != "high" {
+		t.Fatalf("flagged content alerts = %+v, want one high severity alert", alerts)
+	}
+	if score := flagged["moderation_score"].(float64); score < 0.5 {
+		t.Errorf("flagged moderation_score = %v, want at least the 0.5 threshold", score)
+	}
+	if alerts[0].Metadata["response_score"] != flagged["moderation_score"] {
+		t.Errorf("alert response_score = %v, want the higher response score %v", alerts[0].Metadata["response_score"], flagged["moderation_score"])
+	}
+}
+
+func TestLocalModeratorDisabledByDefault(t *testing.T) {
+	t.Setenv("AXOM_LOCAL_MODERATION", "")
+	if m := newLocalModeratorFromEnv(); m != nil {
+		t.Fatalf("moderator enabled without AXOM_LOCAL_MODERATION")
+	}
+	var m *LocalModerator
+	if alerts := m.Apply(map[string]interface{}{"prompt_preview": "murder"}); alerts != nil {
+		t.Errorf("nil moderator returned alerts")
+	}
+}
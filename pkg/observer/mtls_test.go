@@ -0,0 +1,119 @@
+package observer
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"axom-observer/pkg/models"
+)
+
+// mtlsBackend starts a TLS backend that requires a client certificate
+// issued by clientCA. It reports the CN and Authorization header of each
+// accepted request on the returned channel.
+func mtlsBackend(t *testing.T, clientCA *x509.Certificate) (*httptest.Server, chan [2]string) {
+	t.Helper()
+	accepted := make(chan [2]string, 4)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accepted <- [2]string{r.TLS.PeerCertificates[0].Subject.CommonName, r.Header.Get("Authorization")}
+	}))
+	pool := x509.NewCertPool()
+	pool.AddCert(clientCA)
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server, accepted
+}
+
+// writeServerCA writes the backend's certificate as a CA bundle
+func writeServerCA(t *testing.T, server *httptest.Server) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ca-bundle.pem")
+	bundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(path, bundle, 0o644); err != nil {
+		t.Fatalf("write CA bundle: %v", err)
+	}
+	return path
+}
+
+func TestSenderMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath := filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	clientCert := writeTestCA(t, certPath, keyPath, "observer-client")
+	server, accepted := mtlsBackend(t, clientCert)
+
+	t.Setenv("AXOM_CLIENT_CERT", certPath)
+	t.Setenv("AXOM_CLIENT_KEY", keyPath)
+	t.Setenv("AXOM_CA_BUNDLE", writeServerCA(t, server))
+	s := NewSignalSender("test-key", server.URL, 10, time.Hour)
+	if err := s.ConfigError(); err != nil {
+		t.Fatalf("ConfigError: %v", err)
+	}
+	if err, _, _ := s.sendBatchOnce(context.Background(), encodeTestBatch(t, s), 1); err != nil {
+		t.Fatalf("send over mutual TLS: %v", err)
+	}
+	got := <-accepted
+	if got[0] != "observer-client" || got[1] != "Bearer test-key" {
+		t.Errorf("backend saw client %q with %q, want observer-client and the bearer token", got[0], got[1])
+	}
+}
+
+func TestSenderWithoutClientCertRejected(t *testing.T) {
+	dir := t.TempDir()
+	clientCert := writeTestCA(t, filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key"), "observer-client")
+	server, accepted := mtlsBackend(t, clientCert)
+
+	t.Setenv("AXOM_CA_BUNDLE", writeServerCA(t, server))
+	s := NewSignalSender("test-key", server.URL, 10, time.Hour)
+	if err, _, _ := s.sendBatchOnce(context.Background(), encodeTestBatch(t, s), 1); err == nil {
+		t.Errorf("send without a client certificate succeeded")
+	}
+	select {
+	case <-accepted:
+		t.Errorf("backend accepted a request without a client certificate")
+	default:
+	}
+}
+
+func TestBackendTLSConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath := filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	writeTestCA(t, certPath, keyPath, "observer-client")
+	notPEM := filepath.Join(dir, "empty.pem")
+	os.WriteFile(notPEM, []byte("not a certificate"), 0o644)
+
+	tests := map[string]map[string]string{
+		"cert without key":  {"AXOM_CLIENT_CERT": certPath},
+		"key without cert":  {"AXOM_CLIENT_KEY": keyPath},
+		"missing cert file": {"AXOM_CLIENT_CERT": filepath.Join(dir, "missing.crt"), "AXOM_CLIENT_KEY": keyPath},
+		"invalid key":       {"AXOM_CLIENT_CERT": certPath, "AXOM_CLIENT_KEY": notPEM},
+		"empty CA bundle":   {"AXOM_CA_BUNDLE": notPEM},
+	}
+	for name, env := range tests {
+		t.Run(name, func(t *testing.T) {
+			for _, key := range []string{"AXOM_CLIENT_CERT", "AXOM_CLIENT_KEY", "AXOM_CA_BUNDLE"} {
+				t.Setenv(key, env[key])
+			}
+			if err := NewSignalSender("test-key", "https://backend.example.com", 10, time.Hour).ConfigError(); err == nil {
+				t.Errorf("ConfigError = nil, want the TLS settings rejected")
+			}
+		})
+	}
+}
+
+// encodeTestBatch encodes a one-signal batch with s
+func encodeTestBatch(t *testing.T, s *SignalSender) []byte {
+	t.Helper()
+	body, count := s.encodeBatch([]models.Signal{testSignal("sig-1", nil)})
+	if count != 1 {
+		t.Fatalf("encodeBatch encoded %d signals, want 1", count)
+	}
+	return body
+}
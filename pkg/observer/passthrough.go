@@ -0,0 +1,204 @@
+package observer
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"axom-observer/pkg/models"
+
+	"github.com/AdguardTeam/gomitmproxy"
+)
+
+// Environment variables:
+//   AXOM_MITM_DISABLED - Optional. Set to 1 to run ProductionProxy in passthrough mode: no request or response bodies are parsed, only connection-level metadata (SNI, provider, byte counts, duration). Default: disabled
+
+// tunnelDialTimeout bounds the upstream dial for a tunnelled CONNECT,
+// including the CONNECT through an upstream proxy
+const tunnelDialTimeout = 10 * time.Second
+
+// maxClientHelloBytes is how much of the client's first flight is buffered
+// while looking for the SNI
+const maxClientHelloBytes = 16 << 10
+
+// mitmDisabledFromEnv reports whether AXOM_MITM_DISABLED enables passthrough mode
+func mitmDisabledFromEnv() bool {
+	return os.Getenv("AXOM_MITM_DISABLED") == "1"
+}
+
+// tunnelStats is what a CONNECT tunnel records about its connection
+type tunnelStats struct {
+	sni           string
+	bytesSent     int64 // client to upstream
+	bytesReceived int64 // upstream to client
+	duration      time.Duration
+}
+
+// tunnelConn wraps the upstream side of a CONNECT tunnel. Bytes written are
+// the client's, bytes read are the server's; neither is retained beyond the
+// start of the TLS ClientHello, which is read for its SNI.
+type tunnelConn struct {
+	net.Conn
+	start    time.Time
+	sent     atomic.Int64
+	received atomic.Int64
+
+	helloMu  sync.Mutex
+	hello    []byte
+	helloEnd bool
+	sni      string
+
+	closeOnce sync.Once
+	onClose   func(tunnelStats)
+}
+
+func (c *tunnelConn) Write(b []byte) (int, error) {
+	c.observeHello(b)
+	n, err := c.Conn.Write(b)
+	c.sent.Add(int64(n))
+	return n, err
+}
+
+func (c *tunnelConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.received.Add(int64(n))
+	return n, err
+}
+
+// Close closes the upstream connection and reports the tunnel's stats once
+func (c *tunnelConn) Close() error {
+	err := c.Conn.Close()
+	c.closeOnce.Do(func() {
+		c.helloMu.Lock()
+		sni := c.sni
+		c.helloMu.Unlock()
+		if c.onClose != nil {
+			c.onClose(tunnelStats{
+				sni:           sni,
+				bytesSent:     c.sent.Load(),
+				bytesReceived: c.received.Load(),
+				duration:      time.Since(c.start),
+			})
+		}
+	})
+	return err
+}
+
+// observeHello buffers the client's first bytes until the SNI is found or
+// the data can't be a ClientHello
+func (c *tunnelConn) observeHello(b []byte) {
+	c.helloMu.Lock()
+	defer c.helloMu.Unlock()
+	if c.helloEnd {
+		return
+	}
+	c.hello = append(c.hello, b...)
+	sni, complete := parseClientHelloSNI(c.hello)
+	if complete || len(c.hello) >= maxClientHelloBytes {
+		c.sni = sni
+		c.helloEnd = true
+		c.hello = nil
+	}
+}
+
+// handleConnect dials the upstream for a CONNECT tunnel, through the
+// upstream proxy if one is configured, and returns it wrapped so the
+// provider can be detected from the TLS SNI. Tunnels to AI providers become
+// a signal when they close; others are forwarded as opaque tunnels and not
+// recorded. Returning nil leaves the dial, and its error handling, to
+// gomitmproxy.
+func (p *ProductionProxy) handleConnect(session *gomitmproxy.Session, proto, addr string) net.Conn {
+	req := session.Request()
+	if req.Method != http.MethodConnect {
+		// WebSocket upgrades are tunnelled through here too
+		return nil
+	}
+	conn, err := p.dialer.dial(proto, addr)
+	if err != nil {
+		p.logger.Printf("Failed to dial tunnel to %s: %v", addr, err)
+		return nil
+	}
+	clientAddr := req.RemoteAddr
+	customerID, agentID := p.identity.resolve(req, p.customerID, p.agentID)
+	return &tunnelConn{
+		Conn:  conn,
+		start: time.Now(),
+		onClose: func(stats tunnelStats) {
+			if provider := p.detectProviderBySNI(stats.sni, addr); provider != nil {
+				p.emitTunnelSignal(addr, clientAddr, customerID, agentID, provider, stats)
+			}
+		},
+	}
+}
+
+// detectProviderBySNI matches the tunnel's SNI, or the CONNECT host when the
+// client sent none, against the known provider domains. Hosts outside the
+// intercept allowlist are never matched.
+func (p *ProductionProxy) detectProviderBySNI(sni, addr string) *AIProvider {
+	host := sni
+	if host == "" {
+		host = endpointHost(hostEndpoint(addr, 443))
+	}
+	if !p.intercept.allows(host) {
+		return nil
+	}
+	if provider := p.providers.MatchHost(host); provider != nil {
+		return provider
+	}
+	if p.detectDebug {
+		p.providers.logDetectionMiss(p.logger, host, "")
+	}
+	return nil
+}
+
+// emitTunnelSignal sends the connection-level signal for a closed tunnel to
+// provider. Nothing in it is derived from the encrypted payload other
+// than the SNI.
+func (p *ProductionProxy) emitTunnelSignal(addr, clientAddr, customerID, agentID string, provider *AIProvider, stats tunnelStats) {
+	providerName := provider.Name
+	destination := p.resolver.enrich(hostEndpoint(addr, 443))
+	host := stats.sni
+	if host == "" {
+		host = endpointHost(destination)
+	}
+
+	// Tunnel byte counts include TLS framing and the HTTP headers
+	metadata := map[string]interface{}{
+		"provider":       providerName,
+		"host":           host,
+		"request_bytes":  stats.bytesSent,
+		"response_bytes": stats.bytesReceived,
+		"mitm":           false,
+	}
+	if stats.sni != "" {
+		metadata["sni"] = stats.sni
+	}
+	p.metadataFilter.apply(metadata)
+
+	signal := models.Signal{
+		ID:            models.NewSignalID(),
+		SchemaVersion: models.SignalSchemaVersion,
+		CustomerID:    customerID,
+		AgentID:       agentID,
+		Timestamp:     time.Now(),
+		Protocol:      "https",
+		LatencyMS:     float64(stats.duration.Milliseconds()),
+		Metadata:      metadata,
+		Source:        endpointFromAddr(clientAddr),
+		Destination:   destination,
+		Operation:     "tls_tunnel",
+	}
+	recordSignalMetrics(signal, providerName)
+
+	if !p.sampler.keep(&signal, providerName) {
+		p.logger.Printf("Signal sampled out: %s %s", providerName, signal.Operation)
+	} else if p.enqueuer.send(p.signalCh, signal, providerName) {
+		p.logger.Printf("📡 Tunnel closed: %s -> %s (sent: %d, received: %d bytes)",
+			providerName, host, stats.bytesSent, stats.bytesReceived)
+	} else {
+		p.logger.Printf("Signal channel full, dropping signal")
+	}
+}
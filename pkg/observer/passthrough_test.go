@@ -0,0 +1,107 @@
+package observer
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"axom-observer/pkg/models"
+
+	"github.com/AdguardTeam/gomitmproxy"
+)
+
+// newTestProductionProxy creates a ProductionProxy, configured from the
+// current environment, listening on an ephemeral local port. Signals it
+// emits are buffered on the returned channel.
+func newTestProductionProxy(t *testing.T) (*url.URL, chan models.Signal) {
+	t.Helper()
+	signalCh := make(chan models.Signal, 16)
+	p := NewProductionProxy("0", signalCh, testLogger(), "test-customer", "test-agent")
+	proxy := gomitmproxy.NewProxy(gomitmproxy.Config{
+		ListenAddr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)},
+		OnRequest:  p.handleRequest,
+		OnResponse: p.handleResponse,
+		OnConnect:  p.handleConnect,
+	})
+	if err := proxy.Start(); err != nil {
+		t.Fatalf("start proxy: %v", err)
+	}
+	t.Cleanup(proxy.Close)
+	return &url.URL{Scheme: "http", Host: proxy.Addr().String()}, signalCh
+}
+
+// tunnelClient returns a client that reaches HTTPS servers through proxyURL,
+// presenting sni in its ClientHello. Connections are not reused, so each
+// tunnel closes once its response is read.
+func tunnelClient(proxyURL *url.URL, sni string) *http.Client {
+	return &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{
+			Proxy:             http.ProxyURL(proxyURL),
+			TLSClientConfig:   &tls.Config{ServerName: sni, InsecureSkipVerify: true},
+			DisableKeepAlives: true,
+		},
+	}
+}
+
+// tunnelSignal waits for the signal of a closed tunnel
+func tunnelSignal(t *testing.T, signalCh chan models.Signal) models.Signal {
+	t.Helper()
+	for {
+		select {
+		case signal := <-signalCh:
+			if signal.Operation == "tls_tunnel" {
+				return signal
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no tunnel signal emitted")
+			return models.Signal{}
+		}
+	}
+}
+
+func TestPassthroughCapturesNoBodies(t *testing.T) {
+	t.Setenv("AXOM_MITM_DISABLED", "1")
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, okChatResponse)
+	}))
+	defer server.Close()
+
+	proxyURL, signalCh := newTestProductionProxy(t)
+	resp, err := tunnelClient(proxyURL, "api.openai.com").Post(server.URL+"/v1/chat/completions", "application/json",
+		strings.NewReader(`{"model": "gpt-4", "messages": [{"role": "user", "content": "secret prompt"}]}`))
+	if err != nil {
+		t.Fatalf("request through tunnel: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != okChatResponse {
+		t.Fatalf("tunnel returned %d %q, want the server's response unchanged", resp.StatusCode, body)
+	}
+
+	signal := tunnelSignal(t, signalCh)
+	if signal.Metadata["provider"] != "OpenAI" || signal.Metadata["sni"] != "api.openai.com" {
+		t.Errorf("provider = %v, sni = %v, want OpenAI from the SNI", signal.Metadata["provider"], signal.Metadata["sni"])
+	}
+	if signal.Metadata["mitm"] != false {
+		t.Errorf("mitm = %v, want false", signal.Metadata["mitm"])
+	}
+	for _, key := range []string{"prompt_preview", "response_preview", "model", "messages", "total_tokens"} {
+		if value, ok := signal.Metadata[key]; ok {
+			t.Errorf("passthrough signal captured %s = %v", key, value)
+		}
+	}
+	sent, _ := signal.Metadata["request_bytes"].(int64)
+	received, _ := signal.Metadata["response_bytes"].(int64)
+	if sent <= 0 || received <= 0 {
+		t.Errorf("byte counts = %d sent, %d received, want both recorded", sent, received)
+	}
+}
@@ -0,0 +1,48 @@
+package observer
+
+import (
+	"os"
+	"strconv"
+)
+
+// Environment variables:
+//   AXOM_PREVIEW_CHARS - Optional. Characters kept in prompt_preview and response_preview; 0 disables previews. Default: 100
+
+// previewLimit is how many characters of a prompt or response are kept as a
+// preview; 0 means previews aren't captured
+type previewLimit int
+
+// previewLimitFromEnv reads the preview length from AXOM_PREVIEW_CHARS
+func previewLimitFromEnv() previewLimit {
+	if v := os.Getenv("AXOM_PREVIEW_CHARS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			return previewLimit(n)
+		}
+	}
+	return 100
+}
+
+// set stores text under key, truncated to the limit with "..." appended,
+// unless previews are disabled
+func (l previewLimit) set(fields map[string]interface{}, key, text string) {
+	if l <= 0 {
+		return
+	}
+	fields[key] = truncatePreview(text, int(l))
+}
+
+// truncatePreview truncates s to maxLen characters, without splitting a
+// multi-byte character
+func truncatePreview(s string, maxLen int) string {
+	if len(s) <= maxLen {
+		return s
+	}
+	count := 0
+	for i := range s {
+		if count == maxLen {
+			return s[:i] + "..."
+		}
+		count++
+	}
+	return s
+}
@@ -0,0 +1,73 @@
+package observer
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestPreviewsDisabled(t *testing.T) {
+	t.Setenv("AXOM_PREVIEW_CHARS", "0")
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "application/json", okChatResponse))
+	signal := proxySignal(t, p, signalCh, chatRequest(`{"model": "gpt-4", "messages": [{"role": "user", "content": "Hello there"}]}`))
+
+	for _, key := range []string{"prompt_preview", "response_preview"} {
+		if got, ok := signal.Metadata[key]; ok {
+			t.Errorf("%s = %v with previews disabled, want it absent", key, got)
+		}
+	}
+	if got := signal.Metadata["total_tokens"]; got != 6 {
+		t.Errorf("total_tokens = %v, want the rest of the response still parsed", got)
+	}
+}
+
+func TestPreviewCustomLength(t *testing.T) {
+	t.Setenv("AXOM_PREVIEW_CHARS", "5")
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "application/json", okChatResponse))
+	signal := proxySignal(t, p, signalCh, chatRequest(`{"model": "gpt-4", "messages": [{"role": "user", "content": "Hello there"}]}`))
+
+	if got := signal.Metadata["prompt_preview"]; got != "Hello..." {
+		t.Errorf("prompt_preview = %v, want Hello...", got)
+	}
+	// Shorter than the limit, so kept whole
+	if got := signal.Metadata["response_preview"]; got != "Hi" {
+		t.Errorf("response_preview = %v, want Hi", got)
+	}
+}
+
+func TestPreviewLimitFromEnv(t *testing.T) {
+	tests := map[string]previewLimit{
+		"":     100,
+		"250":  250,
+		"0":    0,
+		"-1":   100,
+		"many": 100,
+	}
+	for value, want := range tests {
+		t.Setenv("AXOM_PREVIEW_CHARS", value)
+		if got := previewLimitFromEnv(); got != want {
+			t.Errorf("AXOM_PREVIEW_CHARS=%q gave %d, want %d", value, got, want)
+		}
+	}
+}
+
+func TestTruncatePreview(t *testing.T) {
+	tests := []struct {
+		s      string
+		maxLen int
+		want   string
+	}{
+		{"hello", 10, "hello"},
+		{"hello world", 5, "hello..."},
+		// Characters, not bytes, and never half of one
+		{"héllo wörld", 5, "héllo..."},
+		{"日本語", 3, "日本語"},
+		{"日本語テキスト", 2, "日本..."},
+		{strings.Repeat("x", 101), 100, strings.Repeat("x", 100) + "..."},
+	}
+	for _, tt := range tests {
+		if got := truncatePreview(tt.s, tt.maxLen); got != tt.want {
+			t.Errorf("truncatePreview(%q, %d) = %q, want %q", tt.s, tt.maxLen, got, tt.want)
+		}
+	}
+}
@@ -1,12 +1,9 @@
 package observer
 
 import (
-	"bytes"
 	"context"
 	"crypto/tls"
 	"encoding/json"
-	"fmt"
-	"io"
 	"log"
 	"net"
 	"net/http"
@@ -15,32 +12,84 @@ import (
 	"time"
 
 	"axom-observer/pkg/models"
+	"axom-observer/pkg/pricing"
 
 	"github.com/AdguardTeam/gomitmproxy"
+	"github.com/AdguardTeam/gomitmproxy/proxyutil"
 )
 
 // ProductionProxy provides production-grade MITM proxy capabilities
 type ProductionProxy struct {
-	proxy        *gomitmproxy.Proxy
-	signalCh     chan<- models.Signal
-	logger       *log.Logger
-	customerID   string
-	agentID      string
-	taskDetector *TaskDetector
-	certCache    map[string]*tls.Certificate
-	certMutex    sync.RWMutex
+	proxy               *gomitmproxy.Proxy
+	signalCh            chan<- models.Signal
+	logger              *log.Logger
+	customerID          string
+	agentID             string
+	taskDetector        *TaskDetector
+	certCache           map[string]*tls.Certificate
+	certMutex           sync.RWMutex
+	moderator           *LocalModerator
+	costEstimator       *pricing.CostEstimator
+	identity            identityHeaders
+	metadataLimits      metadataLimits
+	metadataFilter      metadataFilter
+	duplicates          *duplicateDetector
+	resolver            *hostResolver
+	intercept           interceptAllowlist
+	detectDebug         bool
+	enqueuer            signalEnqueuer
+	sampler             signalSampler
+	azureModels         azureDeploymentModels
+	bodyLimit           bodyLimit
+	extractRules        *extractRules
+	mitmDisabled        bool
+	providers           *ProviderRegistry
+	conversationSummary bool
+	estimateTokens      bool
+	forwarder           Forwarder
+	dialer              *upstreamDialer
+	inflight            *inflightLimiter
+	preview             previewLimit
+	upstreamOverrides   upstreamOverrides
+	overrideForwarder   Forwarder
 }
 
 // NewProductionProxy creates a new production-grade MITM proxy
 func NewProductionProxy(port string, signalCh chan<- models.Signal, logger *log.Logger, customerID, agentID string) *ProductionProxy {
-	return &ProductionProxy{
-		signalCh:     signalCh,
-		logger:       logger,
-		customerID:   customerID,
-		agentID:      agentID,
-		taskDetector: NewTaskDetector(signalCh, logger, customerID, agentID),
-		certCache:    make(map[string]*tls.Certificate),
-	}
+	p := &ProductionProxy{
+		signalCh:            signalCh,
+		logger:              logger,
+		customerID:          customerID,
+		agentID:             agentID,
+		taskDetector:        NewTaskDetector(signalCh, logger, customerID, agentID),
+		certCache:           make(map[string]*tls.Certificate),
+		moderator:           newLocalModeratorFromEnv(),
+		costEstimator:       pricing.NewCostEstimator("", logger),
+		identity:            identityHeadersFromEnv(),
+		metadataLimits:      metadataLimitsFromEnv(),
+		metadataFilter:      metadataFilterFromEnv(),
+		duplicates:          duplicateDetectorFromEnv(),
+		resolver:            hostResolverFromEnv(),
+		intercept:           interceptAllowlistFromEnv(),
+		detectDebug:         detectDebugEnabled(),
+		enqueuer:            signalEnqueuerFromEnv(),
+		sampler:             signalSamplerFromEnv(),
+		azureModels:         azureDeploymentModelsFromEnv(),
+		bodyLimit:           bodyLimitFromEnv(),
+		extractRules:        extractRulesFromEnv(logger),
+		mitmDisabled:        mitmDisabledFromEnv(),
+		providers:           NewProviderRegistry("", logger),
+		conversationSummary: conversationSummaryFromEnv(),
+		estimateTokens:      tokenEstimationFromEnv(),
+		dialer:              newUpstreamDialer(logger),
+		inflight:            inflightLimiterFromEnv("production"),
+		preview:             previewLimitFromEnv(),
+		upstreamOverrides:   upstreamOverridesFromEnv(logger),
+	}
+	if len(p.upstreamOverrides) > 0 {
+		p.overrideForwarder = newUpstreamForwarder(logger)
+	}
+	return p
 }
 
 // Start starts the production proxy
@@ -55,6 +104,10 @@ func (p *ProductionProxy) Start(ctx context.Context) error {
 		},
 		OnRequest:  p.handleRequest,
 		OnResponse: p.handleResponse,
+		OnConnect:  p.handleConnect,
+	}
+	if p.mitmDisabled {
+		p.logger.Println("🔒 MITM disabled: recording connection metadata only")
 	}
 
 	// Create proxy instance
@@ -71,6 +124,12 @@ func (p *ProductionProxy) Start(ctx context.Context) error {
 	return nil
 }
 
+// SetForwarder makes the proxy send requests upstream with forwarder instead
+// of gomitmproxy's own client. It must be called before Start.
+func (p *ProductionProxy) SetForwarder(forwarder Forwarder) {
+	p.forwarder = forwarder
+}
+
 // Stop stops the production proxy
 func (p *ProductionProxy) Stop(ctx context.Context) error {
 	if p.proxy != nil {
@@ -82,6 +141,17 @@ func (p *ProductionProxy) Stop(ctx context.Context) error {
 // handleRequest processes incoming requests
 func (p *ProductionProxy) handleRequest(session *gomitmproxy.Session) (*http.Request, *http.Response) {
 	req := session.Request()
+
+	// Wait for an upstream request slot, held until handleResponse.
+	// Tunnels and WebSocket upgrades are long-lived and don't take one.
+	if req.Method != http.MethodConnect && !isWebSocketUpgrade(req) {
+		release, err := p.inflight.acquire(req)
+		if err != nil {
+			p.logger.Printf("Failed to forward request: %v", err)
+			return nil, proxyutil.NewErrorResponse(req, err)
+		}
+		session.SetProp("inflight_release", release)
+	}
 	startTime := time.Now()
 
 	// Try to detect AI provider, but proceed regardless
@@ -93,22 +163,62 @@ func (p *ProductionProxy) handleRequest(session *gomitmproxy.Session) (*http.Req
 	p.logger.Printf("📡 Request detected: %s %s -> %s",
 		aiProvider.Name, req.Method, req.URL.String())
 
-	// Capture request body
-	bodyBytes, err := io.ReadAll(req.Body)
-	if err != nil {
-		p.logger.Printf("Failed to read request body: %v", err)
-		return nil, nil
+	// Capture request body, up to the body limit; the full body is passed on.
+	// In passthrough mode, and for hosts not allowed to be intercepted,
+	// bodies are never read.
+	var reqBody capturedBody
+	if !p.mitmDisabled && p.intercept.allows(req.URL.Host) {
+		var err error
+		reqBody, err = p.bodyLimit.captureRequest(req)
+		if err != nil {
+			p.logger.Printf("Failed to read request body: %v", err)
+			return nil, nil
+		}
+		req.Body = reqBody.readCloser()
+		// Only accept encodings the response can be parsed in
+		restrictAcceptEncoding(req.Header)
+	}
+
+	// MCP calls are recognized by their JSON-RPC body
+	if aiProvider.Name == "Unknown" && isMCPRequest(reqBody.data) {
+		aiProvider = &mcpProvider
 	}
-	req.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
 
 	// Parse request
-	aiRequest := p.parseAIRequest(req, bodyBytes, aiProvider)
+	aiRequest := p.parseAIRequest(req, reqBody.data, aiProvider)
+	reqBody.mark(aiRequest)
+	p.extractRules.apply(aiRequest, "request", req.URL.Path, reqBody.data, req.Header)
 
 	// Store request data in session for response handling
 	session.SetProp("ai_provider", aiProvider)
 	session.SetProp("ai_request", aiRequest)
+	session.SetProp("request_body", reqBody)
 	session.SetProp("start_time", startTime)
 
+	// An injected forwarder or a base URL override replaces gomitmproxy's own
+	// round trip; the response still goes through handleResponse with the
+	// original request
+	target := *req.URL
+	overridden := p.upstreamOverrides.rewrite(&target, aiProvider.Name)
+	if p.forwarder != nil || overridden {
+		out := req.Clone(req.Context())
+		out.RequestURI = ""
+		if overridden {
+			out.URL = &target
+			out.Host = ""
+		}
+		forwarder := p.forwarder
+		if forwarder == nil {
+			forwarder = p.overrideForwarder
+		}
+		resp, err := forwarder.Forward(out)
+		if err != nil {
+			p.logger.Printf("Failed to forward request: %v", err)
+			return nil, proxyutil.NewErrorResponse(req, err)
+		}
+		return nil, resp
+	}
+
 	// Pass through the request
 	return nil, nil
 }
@@ -117,6 +227,14 @@ func (p *ProductionProxy) handleRequest(session *gomitmproxy.Session) (*http.Req
 func (p *ProductionProxy) handleResponse(session *gomitmproxy.Session) *http.Response {
 	resp := session.Response()
 	req := session.Request()
+	if release, ok := session.GetProp("inflight_release"); ok {
+		defer release.(func())()
+	}
+
+	// Tunnels are recorded when they close (see handleConnect)
+	if req.Method == http.MethodConnect {
+		return nil
+	}
 
 	aiProviderVal, _ := session.GetProp("ai_provider")
 	aiProvider, _ := aiProviderVal.(*AIProvider)
@@ -137,16 +255,36 @@ func (p *ProductionProxy) handleResponse(session *gomitmproxy.Session) *http.Res
 	p.logger.Printf("📡 Response detected: %s %s -> %s (status: %d)",
 		aiProvider.Name, req.Method, req.URL.String(), resp.StatusCode)
 
-	// Capture response body
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		p.logger.Printf("Failed to read response body: %v", err)
-		return nil
-	}
-	resp.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
-
-	// Parse response
-	aiResponse := p.parseAIResponse(bodyBytes, aiProvider)
+	// Capture response body, up to the body limit; the full body is passed on.
+	// In passthrough mode, and for hosts not allowed to be intercepted,
+	// bodies are never read.
+	var respBody capturedBody
+	var ttfb *firstByteTimer
+	if !p.mitmDisabled && p.intercept.allows(req.URL.Host) {
+		var err error
+		ttfb = timeFirstByte(resp)
+		respBody, err = p.bodyLimit.capture(resp.Body)
+		if err != nil {
+			p.logger.Printf("Failed to read response body: %v", err)
+			return nil
+		}
+		resp.Body = respBody.readCloser()
+	}
+
+	// Parse response, decompressed; the client still gets the bytes as sent
+	respData, decodeErr := decodeResponseBody(respBody, resp.Header, p.bodyLimit)
+	aiResponse := p.parseAIResponse(respData, resp.Header.Get("Content-Type"), aiProvider)
+	respBody.mark(aiResponse)
+	recordContentEncoding(aiResponse, resp.Header, decodeErr)
+	p.extractRules.apply(aiResponse, "response", req.URL.Path, respData, resp.Header)
+	recordTTSResponseAudio(aiResponse, aiProvider, resp.Header, respData)
+	recordRateLimitHeaders(aiResponse, resp.Header)
+	reqBodyVal, _ := session.GetProp("request_body")
+	reqBody, _ := reqBodyVal.(capturedBody)
+	reqBody.recordDigest(aiRequest)
+	recordBytes(aiRequest, "request", reqBody, req.ContentLength, req.Header)
+	recordBytes(aiResponse, "response", respBody, resp.ContentLength, resp.Header)
+	recordTTFB(aiResponse, resp.Header, startTime, ttfb)
 
 	// Calculate latency
 	latency := time.Since(startTime)
@@ -154,12 +292,21 @@ func (p *ProductionProxy) handleResponse(session *gomitmproxy.Session) *http.Res
 	// Create signal
 	signal := p.createSignal(req, aiRequest, aiResponse, resp.StatusCode, latency, aiProvider)
 
-	// Send signal
-	select {
-	case p.signalCh <- signal:
+	// Flag repeats of a recent identical request from the same agent
+	p.duplicates.check(&signal, req.Method, req.URL.Path, reqBody, aiProvider.Name)
+
+	// Drop governed keys and bound metadata size now that nothing else adds
+	// to it
+	p.metadataFilter.apply(signal.Metadata)
+	p.metadataLimits.apply(signal.Metadata)
+
+	// Send signal unless sampled out
+	if !p.sampler.keep(&signal, aiProvider.Name) {
+		p.logger.Printf("Signal sampled out: %s %s", aiProvider.Name, signal.Operation)
+	} else if p.enqueuer.send(p.signalCh, signal, aiProvider.Name) {
 		p.logger.Printf("📡 Production signal captured: %s %s -> %s (latency: %.2fms)",
 			aiProvider.Name, signal.Operation, req.URL.Host, signal.LatencyMS)
-	default:
+	} else {
 		p.logger.Printf("Signal channel full, dropping signal")
 	}
 
@@ -169,17 +316,14 @@ func (p *ProductionProxy) handleResponse(session *gomitmproxy.Session) *http.Res
 
 // detectAIProvider detects which AI provider this request is for
 func (p *ProductionProxy) detectAIProvider(host, path string) *AIProvider {
-	for _, provider := range knownAIProviders {
-		for _, domain := range provider.Domains {
-			matchPattern := strings.ReplaceAll(domain, "*", "")
-			if strings.Contains(host, matchPattern) {
-				for _, apiPattern := range provider.APIPatterns {
-					if strings.Contains(path, apiPattern) {
-						return &provider
-					}
-				}
-			}
-		}
+	if !p.intercept.allows(host) {
+		return nil
+	}
+	if provider := p.providers.Match(host, path); provider != nil {
+		return provider
+	}
+	if p.detectDebug {
+		p.providers.logDetectionMiss(p.logger, host, path)
 	}
 	return nil
 }
@@ -192,10 +336,11 @@ func (p *ProductionProxy) parseAIRequest(r *http.Request, bodyBytes []byte, prov
 	request["provider"] = provider.Name
 	request["endpoint"] = r.URL.Path
 	request["method"] = r.Method
+	request["correlation_id"] = ensureCorrelationID(r)
 
 	// Parse JSON body if available
+	var jsonData map[string]interface{}
 	if len(bodyBytes) > 0 {
-		var jsonData map[string]interface{}
 		if err := json.Unmarshal(bodyBytes, &jsonData); err == nil {
 			// Extract model
 			if model, ok := jsonData["model"].(string); ok {
@@ -205,41 +350,99 @@ func (p *ProductionProxy) parseAIRequest(r *http.Request, bodyBytes []byte, prov
 			// Extract messages for chat completions
 			if messages, ok := jsonData["messages"].([]interface{}); ok {
 				request["messages"] = messages
-				if len(messages) > 0 {
-					if msg, ok := messages[0].(map[string]interface{}); ok {
-						if content, ok := msg["content"].(string); ok {
-							request["prompt_preview"] = p.truncateString(content, 100)
-						}
-					}
+				// The latest user message is the prompt, not the first one
+				if content, ok := latestPrompt(messages); ok {
+					p.preview.set(request, "prompt_preview", content)
 				}
+				summarizeConversation(request, messages, jsonData, p.conversationSummary)
 			}
 
-			// Extract other common fields
-			for _, field := range []string{"max_tokens", "temperature", "top_p", "frequency_penalty", "presence_penalty"} {
-				if value, ok := jsonData[field]; ok {
-					request[field] = value
-				}
-			}
+			// Extract sampling params with normalized types
+			normalizeSamplingParams(request, jsonData)
 
 			// Provider-specific parsing
-			switch provider.Name {
-			case "OpenAI":
+			switch provider.Parser {
+			case "openai":
 				p.parseOpenAIRequest(request, jsonData)
-			case "Anthropic":
+			case "anthropic":
 				p.parseAnthropicRequest(request, jsonData)
-			case "Google AI":
+			case "google":
 				p.parseGoogleAIRequest(request, jsonData)
+			case "bedrock":
+				p.parseBedrockRequest(request, jsonData)
+			case "mcp":
+				parseMCPRequest(request, jsonData)
+			}
+
+			// Embedding input counts, for billing by vector
+			if isEmbeddingPath(r.URL.Path) {
+				parseEmbeddingRequest(request, jsonData)
+			}
+
+			// Image generation options, for billing by image
+			if isImageGenerationPath(r.URL.Path) {
+				parseImageGenerationRequest(request, jsonData)
+			}
+
+			// Prompt size, in case the provider reports no usage
+			if p.estimateTokens {
+				recordPromptEstimate(request, jsonData)
 			}
 		}
 	}
 
+	// Multipart and raw audio uploads carry their metadata outside a JSON body
+	parseUploadRequest(request, r, bodyBytes)
+
+	// Bedrock, Vertex AI and Azure OpenAI name the model in the URL path
+	if provider.Name == "Azure OpenAI" {
+		p.azureModels.apply(request, r)
+	} else if _, ok := request["model"]; !ok {
+		if model := modelFromPath(provider.Name, r.URL.Path); model != "" {
+			request["model"] = model
+		}
+	}
+
+	// Capture requested audio format for TTS billing
+	if isTTSProvider(provider.Name) {
+		extractTTSRequestFormat(request, r, jsonData, provider)
+	}
+
 	return request
 }
 
 // parseAIResponse parses the AI response based on provider
-func (p *ProductionProxy) parseAIResponse(bodyBytes []byte, provider *AIProvider) map[string]interface{} {
+func (p *ProductionProxy) parseAIResponse(bodyBytes []byte, contentType string, provider *AIProvider) map[string]interface{} {
 	response := make(map[string]interface{})
 
+	// MCP answers are JSON-RPC, as a JSON body or SSE events
+	if provider.Parser == "mcp" {
+		parseMCPResponse(response, bodyBytes, contentType)
+		return response
+	}
+
+	// Streamed responses are a series of SSE chunks rather than one JSON body
+	if isEventStream(contentType) {
+		stream := parseEventStream(bodyBytes)
+		if stream.Content != "" {
+			p.preview.set(response, "response_preview", stream.Content)
+		}
+		if stream.Usage != nil {
+			response["usage"] = stream.Usage
+		}
+		if stream.ID != "" {
+			response["id"] = stream.ID
+		}
+		if stream.FinishReason != "" {
+			response["finish_reason"] = stream.FinishReason
+		}
+		if p.estimateTokens {
+			recordCompletionEstimate(response, "", stream.Content)
+		}
+		response["stream_chunks"] = stream.Chunks
+		return response
+	}
+
 	if len(bodyBytes) > 0 {
 		var jsonData map[string]interface{}
 		if err := json.Unmarshal(bodyBytes, &jsonData); err == nil {
@@ -248,23 +451,42 @@ func (p *ProductionProxy) parseAIResponse(bodyBytes []byte, provider *AIProvider
 				response["usage"] = usage
 			}
 
+			// Extract provider error details
+			extractProviderError(response, jsonData)
+
+			// Embedding vector count and dimensions
+			parseEmbeddingResponse(response, jsonData)
+
+			// Generated image count
+			parseImageGenerationResponse(response, jsonData)
+
+			// Completion size, in case the provider reports no usage
+			if p.estimateTokens {
+				model, _ := jsonData["model"].(string)
+				recordCompletionEstimate(response, model, completionText(jsonData))
+			}
+
 			// Extract choices/response
 			if choices, ok := jsonData["choices"].([]interface{}); ok && len(choices) > 0 {
 				if choice, ok := choices[0].(map[string]interface{}); ok {
 					if message, ok := choice["message"].(map[string]interface{}); ok {
 						if content, ok := message["content"].(string); ok {
-							response["response_preview"] = p.truncateString(content, 100)
+							p.preview.set(response, "response_preview", content)
 						}
 					}
 				}
 			}
 
 			// Provider-specific parsing
-			switch provider.Name {
-			case "OpenAI":
+			switch provider.Parser {
+			case "openai":
 				p.parseOpenAIResponse(response, jsonData)
-			case "Anthropic":
+			case "anthropic":
 				p.parseAnthropicResponse(response, jsonData)
+			case "google":
+				p.parseGoogleAIResponse(response, jsonData)
+			case "bedrock":
+				p.parseBedrockResponse(response, jsonData)
 			}
 		}
 	}
@@ -286,12 +508,15 @@ func (p *ProductionProxy) parseOpenAIRequest(request map[string]interface{}, jso
 // parseAnthropicRequest parses Anthropic-specific request fields
 func (p *ProductionProxy) parseAnthropicRequest(request map[string]interface{}, jsonData map[string]interface{}) {
 	// Anthropic-specific fields
-	if max_tokens, ok := jsonData["max_tokens"].(float64); ok {
-		request["max_tokens"] = int(max_tokens)
-	}
 	if system, ok := jsonData["system"].(string); ok {
 		request["system"] = system
 	}
+	// Customer-provided attribution dimension
+	if metadata, ok := jsonData["metadata"].(map[string]interface{}); ok {
+		if userID, ok := metadata["user_id"].(string); ok && userID != "" {
+			request["provider_user"] = userID
+		}
+	}
 }
 
 // parseGoogleAIRequest parses Google AI-specific request fields
@@ -300,6 +525,16 @@ func (p *ProductionProxy) parseGoogleAIRequest(request map[string]interface{}, j
 	if generationConfig, ok := jsonData["generationConfig"].(map[string]interface{}); ok {
 		request["generation_config"] = generationConfig
 	}
+	// Customer-provided attribution labels
+	if labels, ok := jsonData["labels"].(map[string]interface{}); ok && len(labels) > 0 {
+		providerLabels := make(map[string]string, len(labels))
+		for k, v := range labels {
+			if value, ok := v.(string); ok {
+				providerLabels[k] = value
+			}
+		}
+		request["provider_labels"] = providerLabels
+	}
 }
 
 // parseOpenAIResponse parses OpenAI-specific response fields
@@ -308,6 +543,10 @@ func (p *ProductionProxy) parseOpenAIResponse(response map[string]interface{}, j
 	if id, ok := jsonData["id"].(string); ok {
 		response["id"] = id
 	}
+
+	// Record why generation stopped, and tool calls
+	extractOpenAIFinishReason(response, jsonData)
+	extractOpenAIToolCalls(response, jsonData)
 }
 
 // parseAnthropicResponse parses Anthropic-specific response fields
@@ -316,10 +555,67 @@ func (p *ProductionProxy) parseAnthropicResponse(response map[string]interface{}
 	if content, ok := jsonData["content"].([]interface{}); ok && len(content) > 0 {
 		if contentItem, ok := content[0].(map[string]interface{}); ok {
 			if text, ok := contentItem["text"].(string); ok {
-				response["response_preview"] = p.truncateString(text, 100)
+				p.preview.set(response, "response_preview", text)
 			}
 		}
 	}
+
+	// Record why generation stopped, and tool_use blocks
+	extractAnthropicStopReason(response, jsonData)
+	extractAnthropicToolUse(response, jsonData)
+}
+
+// parseGoogleAIResponse parses Gemini candidates and usageMetadata
+func (p *ProductionProxy) parseGoogleAIResponse(response map[string]interface{}, jsonData map[string]interface{}) {
+	if candidates, ok := jsonData["candidates"].([]interface{}); ok && len(candidates) > 0 {
+		if candidate, ok := candidates[0].(map[string]interface{}); ok {
+			if content, ok := candidate["content"].(map[string]interface{}); ok {
+				if parts, ok := content["parts"].([]interface{}); ok {
+					var text strings.Builder
+					for _, part := range parts {
+						if partMap, ok := part.(map[string]interface{}); ok {
+							if partText, ok := partMap["text"].(string); ok {
+								text.WriteString(partText)
+							}
+						}
+					}
+					if text.Len() > 0 {
+						p.preview.set(response, "response_preview", text.String())
+					}
+				}
+			}
+		}
+	}
+
+	// Token counts are normalized in createSignal
+	if usageMetadata, ok := jsonData["usageMetadata"].(map[string]interface{}); ok {
+		response["usageMetadata"] = usageMetadata
+	}
+}
+
+// parseBedrockRequest parses the model-specific Bedrock request bodies
+func (p *ProductionProxy) parseBedrockRequest(request map[string]interface{}, jsonData map[string]interface{}) {
+	if _, ok := request["prompt_preview"]; !ok {
+		if text := bedrockPromptText(jsonData); text != "" {
+			p.preview.set(request, "prompt_preview", text)
+		}
+	}
+	if maxTokens, ok := bedrockMaxTokens(jsonData); ok {
+		request["max_tokens"] = maxTokens
+	}
+}
+
+// parseBedrockResponse parses Bedrock InvokeModel and Converse responses
+func (p *ProductionProxy) parseBedrockResponse(response map[string]interface{}, jsonData map[string]interface{}) {
+	if text := bedrockResponseText(jsonData); text != "" {
+		p.preview.set(response, "response_preview", text)
+	}
+	if usage := bedrockUsage(jsonData); usage != nil {
+		response["usage"] = usage
+	}
+
+	// Record tool_use blocks from Anthropic models
+	extractAnthropicToolUse(response, jsonData)
 }
 
 // createSignal creates a signal from the AI request/response
@@ -334,6 +630,10 @@ func (p *ProductionProxy) createSignal(
 
 	// Determine operation type
 	operation := p.determineOperation(r.URL.Path, request, provider)
+	// Responses that invoke tools are tool calls regardless of endpoint
+	if hasToolCalls(response) {
+		operation = "tool_call"
+	}
 
 	// Extract metadata
 	metadata := make(map[string]interface{})
@@ -348,36 +648,56 @@ func (p *ProductionProxy) createSignal(
 	metadata["provider"] = provider.Name
 	metadata["endpoint"] = r.URL.Path
 
-	// Extract usage information
-	if usage, ok := response["usage"].(map[string]interface{}); ok {
-		if promptTokens, ok := usage["prompt_tokens"].(float64); ok {
-			metadata["prompt_tokens"] = int(promptTokens)
-		}
-		if completionTokens, ok := usage["completion_tokens"].(float64); ok {
-			metadata["completion_tokens"] = int(completionTokens)
-		}
-		if totalTokens, ok := usage["total_tokens"].(float64); ok {
-			metadata["total_tokens"] = int(totalTokens)
-		}
-	}
-
-	return models.Signal{
-		ID:          p.generateSignalID(),
-		CustomerID:  p.customerID,
-		AgentID:     p.agentID,
-		Timestamp:   time.Now(),
-		Protocol:    "https",
-		LatencyMS:   float64(latency.Milliseconds()),
-		Metadata:    metadata,
-		Source:      models.Endpoint{IP: "127.0.0.1", Port: 0},
-		Destination: models.Endpoint{IP: r.URL.Host, Port: 443},
-		Operation:   operation,
-		Status:      statusCode,
-	}
+	// Normalize token usage across provider shapes, falling back to the
+	// estimate from the captured text when there is none
+	estimate, estimated := takeTokenEstimate(metadata)
+	usage, ok := normalizeUsage(provider.Name, response)
+	if !ok && estimated {
+		usage, ok = estimate, true
+		metadata["tokens_estimated"] = true
+	}
+	if ok {
+		metadata["prompt_tokens"] = usage.Prompt
+		metadata["completion_tokens"] = usage.Completion
+		metadata["total_tokens"] = usage.Total
+	}
+
+	// Estimate cost from token usage
+	p.costEstimator.Annotate(provider.Name, metadata)
+
+	// Per-request identity headers override the configured defaults
+	customerID, agentID := p.identity.resolve(r, p.customerID, p.agentID)
+
+	signal := models.Signal{
+		ID:            models.NewSignalID(),
+		SchemaVersion: models.SignalSchemaVersion,
+		CustomerID:    customerID,
+		AgentID:       agentID,
+		Timestamp:     time.Now(),
+		Protocol:      "https",
+		LatencyMS:     float64(latency.Milliseconds()),
+		Metadata:      metadata,
+		Source:        endpointFromAddr(r.RemoteAddr),
+		Destination:   p.resolver.enrich(destinationEndpoint(r)),
+		Operation:     operation,
+		Status:        statusCode,
+	}
+	applyStatusOutcome(&signal, provider.Name, r.URL.Path)
+	signal.Alerts = append(signal.Alerts, failureClassAlerts(provider.Name, r.URL.Path, metadata)...)
+	signal.Alerts = append(signal.Alerts, p.moderator.Apply(metadata)...)
+
+	recordSignalMetrics(signal, provider.Name)
+
+	return signal
 }
 
 // determineOperation determines the operation type
 func (p *ProductionProxy) determineOperation(path string, request map[string]interface{}, provider *AIProvider) string {
+	// MCP calls are named by their JSON-RPC method, e.g. tools/call
+	if method, ok := request["mcp_method"].(string); ok {
+		return method
+	}
+
 	// Check path patterns
 	if strings.Contains(path, "/chat/completions") || strings.Contains(path, "/messages") {
 		return "chat_completion"
@@ -404,16 +724,3 @@ func (p *ProductionProxy) determineOperation(path string, request map[string]int
 	// Default based on provider
 	return "ai_request"
 }
-
-// generateSignalID generates a unique signal ID
-func (p *ProductionProxy) generateSignalID() string {
-	return fmt.Sprintf("signal_%d", time.Now().UnixNano())
-}
-
-// truncateString truncates a string to max length
-func (p *ProductionProxy) truncateString(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
-	}
-	return s[:maxLen] + "..."
-}
@@ -0,0 +1,143 @@
+package observer
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"axom-observer/pkg/models"
+)
+
+// Failure classes recorded in metadata["failure_class"]
+const (
+	failureClassContextLength = "context_length_exceeded"
+)
+
+// contextLengthMessages are substrings providers use in error messages when a
+// prompt overflows the model's context window
+var contextLengthMessages = []string{
+	"maximum context length",
+	"context window",
+	"prompt is too long",
+	"exceeds the maximum number of tokens",
+	"input is too long",
+}
+
+// extractProviderError copies the provider error object (OpenAI, Anthropic and
+// Google all use a top-level "error" object) into the response metadata and
+// classifies well-known failure modes
+func extractProviderError(response map[string]interface{}, jsonData map[string]interface{}) {
+	errObj, ok := jsonData["error"].(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	var code string
+	switch v := errObj["code"].(type) {
+	case string:
+		code = v
+	case float64:
+		code = strconv.Itoa(int(v))
+	}
+	errType, _ := errObj["type"].(string)
+	if errType == "" {
+		// Google reports the canonical status instead of a type
+		errType, _ = errObj["status"].(string)
+	}
+	message, _ := errObj["message"].(string)
+
+	if code != "" {
+		response["error_code"] = code
+	}
+	if errType != "" {
+		response["error_type"] = errType
+	}
+	if message != "" {
+		response["error_message"] = message
+	}
+
+	if isContextLengthError(code, message) {
+		response["failure_class"] = failureClassContextLength
+	}
+}
+
+// isContextLengthError reports whether an error code/message pair describes a
+// context-length overflow
+func isContextLengthError(code, message string) bool {
+	if code == failureClassContextLength {
+		return true
+	}
+	lower := strings.ToLower(message)
+	for _, m := range contextLengthMessages {
+		if strings.Contains(lower, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// failureClassAlerts returns alerts for the failure class recorded in metadata
+func failureClassAlerts(providerName, endpoint string, metadata map[string]interface{}) []models.Alert {
+	failureClass, _ := metadata["failure_class"].(string)
+	if failureClass != failureClassContextLength {
+		return nil
+	}
+	return []models.Alert{{
+		Type:     "warning",
+		Message:  fmt.Sprintf("%s request to %s exceeded the model context length", providerName, endpoint),
+		Severity: "medium",
+		Metadata: map[string]interface{}{
+			"failure_class": failureClass,
+			"model":         metadata["model"],
+			"error_message": metadata["error_message"],
+		},
+		Timestamp: time.Now(),
+	}}
+}
+
+// statusAlertLevel maps a failing HTTP status to an alert type and severity:
+// rate limiting is a warning, auth failures are critical since every
+// subsequent call will fail too, and server errors are high
+func statusAlertLevel(statusCode int) (alertType, severity string) {
+	switch {
+	case statusCode == http.StatusTooManyRequests:
+		return "warning", "medium"
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return "error", "critical"
+	case statusCode >= 500:
+		return "error", "high"
+	default:
+		return "error", "medium"
+	}
+}
+
+// applyStatusOutcome marks a signal for a failed provider call (4xx/5xx) with
+// a failure outcome and an alert naming the provider and endpoint
+func applyStatusOutcome(signal *models.Signal, providerName, endpoint string) {
+	if signal.Status < 400 {
+		return
+	}
+	signal.Outcome = "failure"
+
+	alertType, severity := statusAlertLevel(signal.Status)
+	alertMetadata := map[string]interface{}{
+		"status_code": signal.Status,
+		"provider":    providerName,
+		"endpoint":    endpoint,
+	}
+	if code, ok := signal.Metadata["error_code"]; ok {
+		alertMetadata["error_code"] = code
+	}
+	if message, ok := signal.Metadata["error_message"]; ok {
+		alertMetadata["error_message"] = message
+	}
+	signal.Alerts = append(signal.Alerts, models.Alert{
+		Type:      alertType,
+		Message:   fmt.Sprintf("%s request to %s failed with status %d", providerName, endpoint, signal.Status),
+		Severity:  severity,
+		Metadata:  alertMetadata,
+		Timestamp: time.Now(),
+	})
+}
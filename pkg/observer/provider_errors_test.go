@@ -0,0 +1,117 @@
+package observer
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestContextLengthErrorIsClassified(t *testing.T) {
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusBadRequest, "application/json", `{
+		"error": {
+			"message": "This model's maximum context length is 8192 tokens. However, your messages resulted in 9000 tokens.",
+			"type": "invalid_request_error",
+			"param": "messages",
+			"code": "context_length_exceeded"
+		}
+	}`))
+
+	signal := proxySignal(t, p, signalCh, chatRequest(`{"model":"gpt-4","messages":[{"role":"user","content":"a very long prompt"}]}`))
+
+	for key, want := range map[string]string{
+		"failure_class": failureClassContextLength,
+		"error_code":    "context_length_exceeded",
+		"error_type":    "invalid_request_error",
+	} {
+		if got := signal.Metadata[key]; got != want {
+			t.Errorf("Metadata[%q] = %v, want %q", key, got, want)
+		}
+	}
+
+	var found bool
+	for _, alert := range signal.Alerts {
+		if alert.Metadata["failure_class"] == failureClassContextLength {
+			found = true
+			if alert.Type != "warning" || alert.Severity != "medium" {
+				t.Errorf("context length alert = %s/%s, want warning/medium", alert.Type, alert.Severity)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("no context length alert in %+v", signal.Alerts)
+	}
+}
+
+func TestIsContextLengthError(t *testing.T) {
+	tests := []struct {
+		code, message string
+		want          bool
+	}{
+		{"context_length_exceeded", "", true},
+		{"", "prompt is too long: 210000 tokens > 200000 maximum", true},
+		{"400", "The input token count exceeds the maximum number of tokens allowed", true},
+		{"rate_limit_exceeded", "Rate limit reached for requests", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		if got := isContextLengthError(tt.code, tt.message); got != tt.want {
+			t.Errorf("isContextLengthError(%q, %q) = %v, want %v", tt.code, tt.message, got, tt.want)
+		}
+	}
+}
+
+func TestStatusAlertLevel(t *testing.T) {
+	tests := []struct {
+		status              int
+		alertType, severity string
+	}{
+		{http.StatusTooManyRequests, "warning", "medium"},
+		{http.StatusUnauthorized, "error", "critical"},
+		{http.StatusForbidden, "error", "critical"},
+		{http.StatusInternalServerError, "error", "high"},
+		{http.StatusServiceUnavailable, "error", "high"},
+		{http.StatusBadRequest, "error", "medium"},
+		{http.StatusNotFound, "error", "medium"},
+	}
+	for _, tt := range tests {
+		alertType, severity := statusAlertLevel(tt.status)
+		if alertType != tt.alertType || severity != tt.severity {
+			t.Errorf("statusAlertLevel(%d) = %s/%s, want %s/%s", tt.status, alertType, severity, tt.alertType, tt.severity)
+		}
+	}
+}
+
+func TestFailedCallHasFailureOutcome(t *testing.T) {
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusUnauthorized, "application/json",
+		`{"error":{"message":"Incorrect API key provided","type":"invalid_request_error","code":"invalid_api_key"}}`))
+
+	signal := proxySignal(t, p, signalCh, chatRequest(`{"model":"gpt-4","messages":[{"role":"user","content":"Hello"}]}`))
+
+	if signal.Outcome != "failure" {
+		t.Errorf("Outcome = %q, want failure", signal.Outcome)
+	}
+	if len(signal.Alerts) != 1 {
+		t.Fatalf("Alerts = %+v, want one status alert", signal.Alerts)
+	}
+	alert := signal.Alerts[0]
+	if alert.Type != "error" || alert.Severity != "critical" {
+		t.Errorf("alert = %s/%s, want error/critical", alert.Type, alert.Severity)
+	}
+	for key, want := range map[string]interface{}{
+		"status_code": http.StatusUnauthorized,
+		"provider":    "OpenAI",
+		"endpoint":    "/v1/chat/completions",
+		"error_code":  "invalid_api_key",
+	} {
+		if got := alert.Metadata[key]; got != want {
+			t.Errorf("alert Metadata[%q] = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestSuccessfulCallHasNoFailureOutcome(t *testing.T) {
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "application/json", okChatResponse))
+	signal := proxySignal(t, p, signalCh, chatRequest(`{"model":"gpt-4","messages":[{"role":"user","content":"Hello"}]}`))
+	if signal.Outcome == "failure" || len(signal.Alerts) != 0 {
+		t.Errorf("successful call has outcome %q and alerts %+v", signal.Outcome, signal.Alerts)
+	}
+}
@@ -0,0 +1,82 @@
+package observer
+
+import (
+	"net"
+	"path"
+	"strings"
+)
+
+// matchDomain reports whether host matches a provider domain pattern. A "*"
+// label matches exactly one label of the host, so "*.openai.azure.com"
+// matches "myresource.openai.azure.com" and "polly.*.amazonaws.com" matches
+// "polly.us-east-1.amazonaws.com". A "*" inside a label matches within that
+// label only, so "*-aiplatform.googleapis.com" matches
+// "us-central1-aiplatform.googleapis.com". Any port on host is ignored and
+// matching is case-insensitive.
+func matchDomain(host, pattern string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	pattern = strings.ToLower(pattern)
+	if !strings.Contains(pattern, "*") {
+		return host == pattern
+	}
+
+	hostLabels := strings.Split(host, ".")
+	patternLabels := strings.Split(pattern, ".")
+	if len(hostLabels) != len(patternLabels) {
+		return false
+	}
+	for i, label := range patternLabels {
+		if label == "*" {
+			if hostLabels[i] == "" {
+				return false
+			}
+			continue
+		}
+		if strings.Contains(label, "*") {
+			if matched, _ := path.Match(label, hostLabels[i]); !matched {
+				return false
+			}
+			continue
+		}
+		if label != hostLabels[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesProviderDomain reports whether host belongs to the provider
+func matchesProviderDomain(provider *AIProvider, host string) bool {
+	for _, domain := range provider.Domains {
+		if matchDomain(host, domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesProviderPath reports whether urlPath matches one of the provider's
+// API patterns. Plain patterns match as a prefix; patterns containing "*"
+// must match the whole path, with "*" matching within one path segment
+// (e.g. "/model/*/invoke").
+func matchesProviderPath(provider *AIProvider, urlPath string) bool {
+	for _, pattern := range provider.APIPatterns {
+		if matchPathPattern(pattern, urlPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPathPattern matches urlPath against a plain prefix pattern, or a
+// whole-path pattern when it contains "*"
+func matchPathPattern(pattern, urlPath string) bool {
+	if strings.Contains(pattern, "*") {
+		matched, _ := path.Match(pattern, urlPath)
+		return matched
+	}
+	return strings.HasPrefix(urlPath, pattern)
+}
@@ -0,0 +1,67 @@
+package observer
+
+import "testing"
+
+func TestMatchDomain(t *testing.T) {
+	tests := []struct {
+		host    string
+		pattern string
+		want    bool
+	}{
+		{"api.openai.com", "api.openai.com", true},
+		{"API.OpenAI.com:443", "api.openai.com", true},
+		{"api.openai.com.", "api.openai.com", true},
+		{"evil-api.openai.com", "api.openai.com", false},
+		{"myresource.openai.azure.com", "*.openai.azure.com", true},
+		{"myresource.openai.azure.com:443", "*.openai.azure.com", true},
+		{"openai.azure.com", "*.openai.azure.com", false},
+		{"a.b.openai.azure.com", "*.openai.azure.com", false},
+		{".openai.azure.com", "*.openai.azure.com", false},
+		{"polly.us-east-1.amazonaws.com", "polly.*.amazonaws.com", true},
+		{"polly.amazonaws.com", "polly.*.amazonaws.com", false},
+		{"polly.us-east-1.evil.amazonaws.com", "polly.*.amazonaws.com", false},
+		{"bedrock-runtime.eu-west-1.amazonaws.com", "bedrock-runtime.*.amazonaws.com", true},
+		{"westeurope.tts.speech.microsoft.com", "*.tts.speech.microsoft.com", true},
+		{"eastus.cognitiveservices.azure.com", "*.cognitiveservices.azure.com", true},
+		{"us-central1-aiplatform.googleapis.com", "*-aiplatform.googleapis.com", true},
+		{"aiplatform.googleapis.com", "*-aiplatform.googleapis.com", false},
+	}
+	for _, tt := range tests {
+		if got := matchDomain(tt.host, tt.pattern); got != tt.want {
+			t.Errorf("matchDomain(%q, %q) = %v, want %v", tt.host, tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestDetectWildcardProviders(t *testing.T) {
+	p, _ := newTestHTTPProxy(t, cannedResponse(200, "", ""))
+
+	tests := []struct {
+		name string
+		host string
+		path string
+		want string
+	}{
+		{"azure openai", "myresource.openai.azure.com", "/openai/deployments/gpt4/chat/completions", "Azure OpenAI"},
+		{"amazon polly", "polly.us-east-1.amazonaws.com", "/v1/speech", "Amazon Polly"},
+		{"amazon polly other region", "polly.eu-central-1.amazonaws.com", "/v1/speech", "Amazon Polly"},
+		{"azure tts", "eastus.cognitiveservices.azure.com", "/cognitiveservices/v1", "Azure TTS"},
+		{"azure tts token", "eastus.cognitiveservices.azure.com", "/sts/v1.0/issueToken", "Azure TTS"},
+		{"bedrock", "bedrock-runtime.us-west-2.amazonaws.com", "/model/anthropic.claude-v2/invoke", "Amazon Bedrock"},
+		{"polly without region", "polly.amazonaws.com", "/v1/speech", ""},
+		{"azure apex", "openai.azure.com", "/openai/deployments/gpt4/chat/completions", ""},
+		{"unrelated aws service", "s3.us-east-1.amazonaws.com", "/v1/speech", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := p.detectAIProvider(tt.host, tt.path)
+			got := ""
+			if provider != nil {
+				got = provider.Name
+			}
+			if got != tt.want {
+				t.Errorf("detectAIProvider(%q, %q) = %q, want %q", tt.host, tt.path, got, tt.want)
+			}
+		})
+	}
+}
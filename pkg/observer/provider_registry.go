@@ -0,0 +1,135 @@
+package observer
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Environment variables:
+//   AXOM_PROVIDERS_FILE - Optional. YAML or JSON file of additional providers (see providersFile), e.g. internal or self-hosted model endpoints. Default: built-in providers only
+
+// providersFile is the on-disk layout of additional providers. JSON works
+// too, being valid YAML.
+//
+//	providers:
+//	  - name: Internal LLM
+//	    domains: [llm.internal.example.com]
+//	    api_patterns: [/v1/chat/completions]
+//	    parser: openai
+type providersFile struct {
+	Providers []AIProvider `yaml:"providers"`
+}
+
+// providerParsers are the accepted parser hints
+var providerParsers = map[string]bool{
+	"":          true,
+	"generic":   true,
+	"openai":    true,
+	"anthropic": true,
+	"google":    true,
+	"bedrock":   true,
+	"mcp":       true,
+}
+
+// ProviderRegistry is the set of providers traffic is classified against:
+// the built-in providers merged with any loaded from a file
+type ProviderRegistry struct {
+	providers []AIProvider
+}
+
+// NewProviderRegistry creates a registry of the built-in providers merged
+// with those in the file at path (or AXOM_PROVIDERS_FILE). A provider in the
+// file replaces the built-in one with the same name; new providers are
+// matched before the built-ins. A missing or invalid file is logged and only
+// the built-ins are used.
+func NewProviderRegistry(path string, logger *log.Logger) *ProviderRegistry {
+	r := &ProviderRegistry{providers: append([]AIProvider(nil), knownAIProviders...)}
+	if path == "" {
+		path = os.Getenv("AXOM_PROVIDERS_FILE")
+	}
+	if path == "" {
+		return r
+	}
+	providers, err := LoadProvidersFile(path)
+	if err != nil {
+		logger.Printf("⚠️ Failed to load providers %s: %v", path, err)
+		return r
+	}
+	r.merge(providers)
+	return r
+}
+
+// LoadProvidersFile reads and validates a providers file
+func LoadProvidersFile(path string) ([]AIProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file providersFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse providers: %w", err)
+	}
+	for _, provider := range file.Providers {
+		if provider.Name == "" {
+			return nil, fmt.Errorf("provider missing name")
+		}
+		if len(provider.Domains) == 0 || len(provider.APIPatterns) == 0 {
+			return nil, fmt.Errorf("provider %s needs at least one domain and API pattern", provider.Name)
+		}
+		if !providerParsers[provider.Parser] {
+			return nil, fmt.Errorf("provider %s has unknown parser %q", provider.Name, provider.Parser)
+		}
+	}
+	return file.Providers, nil
+}
+
+// merge replaces same-named providers and puts new ones first
+func (r *ProviderRegistry) merge(providers []AIProvider) {
+	var added []AIProvider
+	for _, provider := range providers {
+		replaced := false
+		for i := range r.providers {
+			if r.providers[i].Name == provider.Name {
+				r.providers[i] = provider
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			added = append(added, provider)
+		}
+	}
+	r.providers = append(added, r.providers...)
+}
+
+// Providers returns the registered providers in match order
+func (r *ProviderRegistry) Providers() []AIProvider {
+	return r.providers
+}
+
+// Match returns the provider whose domain matches host and one of whose API
+// patterns matches path, or nil
+func (r *ProviderRegistry) Match(host, path string) *AIProvider {
+	for i := range r.providers {
+		provider := &r.providers[i]
+		if matchesProviderDomain(provider, host) && matchesProviderPath(provider, path) {
+			return provider
+		}
+	}
+	return nil
+}
+
+// MatchHost returns the provider whose domain matches host, without looking
+// at the path (which a tunnel never sees), or nil
+func (r *ProviderRegistry) MatchHost(host string) *AIProvider {
+	for i := range r.providers {
+		provider := &r.providers[i]
+		if matchesProviderDomain(provider, host) {
+			return provider
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package observer
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const sampleProviders = `
+providers:
+  - name: Internal LLM
+    domains: [llm.internal.example.com]
+    api_patterns: [/v1/chat/completions]
+    parser: openai
+  - name: Mistral
+    domains: [mistral.gateway.example.com]
+    api_patterns: [/v1/chat/completions]
+    parser: openai
+`
+
+// writeProviders writes a providers file and returns its path
+func writeProviders(t *testing.T, name, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("write providers: %v", err)
+	}
+	return path
+}
+
+// matchName returns the name of the provider r matches, or ""
+func matchName(r *ProviderRegistry, host, path string) string {
+	if provider := r.Match(host, path); provider != nil {
+		return provider.Name
+	}
+	return ""
+}
+
+func TestProviderRegistryMergesFile(t *testing.T) {
+	r := NewProviderRegistry(writeProviders(t, "providers.yaml", sampleProviders), testLogger())
+
+	tests := []struct {
+		host, path, want string
+	}{
+		{"llm.internal.example.com", "/v1/chat/completions", "Internal LLM"},
+		{"llm.internal.example.com", "/v1/embeddings", ""},
+		{"mistral.gateway.example.com", "/v1/chat/completions", "Mistral"},
+		// The file's Mistral replaces the built-in one
+		{"api.mistral.ai", "/v1/chat/completions", ""},
+		{"api.openai.com", "/v1/chat/completions", "OpenAI"},
+	}
+	for _, tt := range tests {
+		if got := matchName(r, tt.host, tt.path); got != tt.want {
+			t.Errorf("Match(%q, %q) = %q, want %q", tt.host, tt.path, got, tt.want)
+		}
+	}
+	if providers := r.Providers(); providers[0].Name != "Internal LLM" {
+		t.Errorf("first provider = %s, want new providers matched first", providers[0].Name)
+	}
+	if len(r.Providers()) != len(knownAIProviders)+1 {
+		t.Errorf("registry has %d providers, want the built-ins plus one", len(r.Providers()))
+	}
+}
+
+func TestProviderRegistryJSONAndInvalidFiles(t *testing.T) {
+	providers := `{"providers": [{"name": "Local vLLM", "domains": ["vllm.local"], "api_patterns": ["/v1/completions"], "parser": "generic"}]}`
+	r := NewProviderRegistry(writeProviders(t, "providers.json", providers), testLogger())
+	if got := matchName(r, "vllm.local:8000", "/v1/completions"); got != "Local vLLM" {
+		t.Errorf("JSON provider match = %q, want Local vLLM", got)
+	}
+
+	for name, contents := range map[string]string{
+		"missing name":   "providers:\n  - domains: [a.example.com]\n    api_patterns: [/v1]\n",
+		"no patterns":    "providers:\n  - name: A\n    domains: [a.example.com]\n",
+		"unknown parser": "providers:\n  - name: A\n    domains: [a.example.com]\n    api_patterns: [/v1]\n    parser: cohere\n",
+	} {
+		path := writeProviders(t, "providers.yaml", contents)
+		if _, err := LoadProvidersFile(path); err == nil {
+			t.Errorf("%s: LoadProvidersFile accepted an invalid file", name)
+		}
+		if r := NewProviderRegistry(path, testLogger()); len(r.Providers()) != len(knownAIProviders) {
+			t.Errorf("%s: registry has %d providers, want only the built-ins", name, len(r.Providers()))
+		}
+	}
+}
+
+func TestProxyUsesCustomProvider(t *testing.T) {
+	t.Setenv("AXOM_PROVIDERS_FILE", writeProviders(t, "providers.yaml", sampleProviders))
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "application/json", okChatResponse))
+	signal := proxySignal(t, p, signalCh, jsonRequest("http://llm.internal.example.com/v1/chat/completions",
+		`{"model": "llama-3-70b", "messages": [{"role": "user", "content": "Hi"}]}`))
+
+	if got := signal.Metadata["provider"]; got != "Internal LLM" {
+		t.Errorf("provider = %v, want Internal LLM", got)
+	}
+	if got := signal.Metadata["response_preview"]; got != "Hi" {
+		t.Errorf("response_preview = %v, want the response parsed as OpenAI", got)
+	}
+	if got := signal.Metadata["total_tokens"]; got != 6 {
+		t.Errorf("total_tokens = %v, want 6", got)
+	}
+}
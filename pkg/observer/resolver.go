@@ -0,0 +1,140 @@
+package observer
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"axom-observer/pkg/models"
+)
+
+// Environment variables:
+//   AXOM_RESOLVE_TTL - Optional. Seconds a destination's resolved IP or reverse-DNS name is cached; 0 disables resolution. Default: 300
+
+// maxResolvedHosts bounds the resolver cache
+const maxResolvedHosts = 4096
+
+// resolveTimeout bounds a single background lookup
+const resolveTimeout = 5 * time.Second
+
+// hostResolver fills in the resolved IP of destination hostnames and the
+// reverse-DNS name of destination IPs from a TTL cache. Lookups run in the
+// background, so resolution never adds latency to the request path.
+type hostResolver struct {
+	ttl        time.Duration
+	lookupHost func(ctx context.Context, host string) ([]string, error)
+	lookupAddr func(ctx context.Context, addr string) ([]string, error)
+	now        func() time.Time
+
+	mu      sync.Mutex
+	cache   map[string]resolvedName
+	pending map[string]bool
+}
+
+// resolvedName is a cached lookup result; name is empty when it failed
+type resolvedName struct {
+	name    string
+	expires time.Time
+}
+
+// hostResolverFromEnv creates a resolver with the TTL from AXOM_RESOLVE_TTL,
+// or nil when resolution is disabled
+func hostResolverFromEnv() *hostResolver {
+	ttl := 5 * time.Minute
+	if v := os.Getenv("AXOM_RESOLVE_TTL"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			ttl = time.Duration(n) * time.Second
+		}
+	}
+	if ttl == 0 {
+		return nil
+	}
+	return &hostResolver{
+		ttl:        ttl,
+		lookupHost: net.DefaultResolver.LookupHost,
+		lookupAddr: net.DefaultResolver.LookupAddr,
+		now:        time.Now,
+		cache:      make(map[string]resolvedName),
+		pending:    make(map[string]bool),
+	}
+}
+
+// enrich fills in the missing half of e: the IP of a hostname, or the name
+// of an IP. Only cached results are used; a miss starts a background lookup
+// and leaves e as it is, so the first signals for a new destination go
+// without.
+func (r *hostResolver) enrich(e models.Endpoint) models.Endpoint {
+	if r == nil {
+		return e
+	}
+	switch {
+	case e.Hostname != "" && e.IP == "":
+		e.IP = r.cached(e.Hostname, false)
+	case e.IP != "" && e.Hostname == "":
+		e.Hostname = r.cached(e.IP, true)
+	}
+	return e
+}
+
+// cached returns the cached lookup result for key, starting a lookup if
+// there is none
+func (r *hostResolver) cached(key string, reverse bool) string {
+	now := r.now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if entry, ok := r.cache[key]; ok && now.Before(entry.expires) {
+		return entry.name
+	}
+	if !r.pending[key] {
+		r.pending[key] = true
+		go r.resolve(key, reverse)
+	}
+	return ""
+}
+
+// resolve looks key up and caches the result. Failures are cached too, as
+// an empty name, so an unresolvable destination isn't retried on every
+// request.
+func (r *hostResolver) resolve(key string, reverse bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+
+	var name string
+	if reverse {
+		if names, err := r.lookupAddr(ctx, key); err == nil && len(names) > 0 {
+			name = normalizeHostname(names[0])
+		}
+	} else if addrs, err := r.lookupHost(ctx, key); err == nil && len(addrs) > 0 {
+		name = addrs[0]
+	}
+
+	now := r.now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.pending, key)
+	if len(r.cache) >= maxResolvedHosts {
+		r.evict(now)
+	}
+	r.cache[key] = resolvedName{name: name, expires: now.Add(r.ttl)}
+}
+
+// evict drops expired entries, or an arbitrary one if none have expired
+func (r *hostResolver) evict(now time.Time) {
+	evicted := false
+	for key, entry := range r.cache {
+		if !now.Before(entry.expires) {
+			delete(r.cache, key)
+			evicted = true
+		}
+	}
+	if evicted {
+		return
+	}
+	for key := range r.cache {
+		delete(r.cache, key)
+		return
+	}
+}
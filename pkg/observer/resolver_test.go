@@ -0,0 +1,172 @@
+package observer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"axom-observer/pkg/models"
+)
+
+// stubResolver answers lookups from fixed tables and counts them
+type stubResolver struct {
+	mu      sync.Mutex
+	hosts   map[string][]string
+	addrs   map[string][]string
+	lookups int
+}
+
+func (s *stubResolver) lookupHost(ctx context.Context, host string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lookups++
+	if addrs, ok := s.hosts[host]; ok {
+		return addrs, nil
+	}
+	return nil, errors.New("no such host")
+}
+
+func (s *stubResolver) lookupAddr(ctx context.Context, addr string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lookups++
+	if names, ok := s.addrs[addr]; ok {
+		return names, nil
+	}
+	return nil, errors.New("no PTR record")
+}
+
+func (s *stubResolver) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lookups
+}
+
+// newStubHostResolver returns a resolver backed by stub, with a clock the
+// test can move through now
+func newStubHostResolver(t *testing.T, stub *stubResolver, now *time.Time) *hostResolver {
+	t.Helper()
+	r := hostResolverFromEnv()
+	r.lookupHost = stub.lookupHost
+	r.lookupAddr = stub.lookupAddr
+	r.now = func() time.Time { return *now }
+	return r
+}
+
+// enrichEventually enriches e until the background lookup has filled in
+// the result, or the deadline passes
+func enrichEventually(t *testing.T, r *hostResolver, e models.Endpoint, done func(models.Endpoint) bool) models.Endpoint {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got := r.enrich(e)
+		if done(got) || time.Now().After(deadline) {
+			return got
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestResolverFillsIPFromCache(t *testing.T) {
+	stub := &stubResolver{hosts: map[string][]string{"api.openai.com": {"104.18.7.192", "104.18.6.192"}}}
+	now := time.Now()
+	r := newStubHostResolver(t, stub, &now)
+	dest := models.Endpoint{Hostname: "api.openai.com", Port: 443}
+
+	// A miss doesn't wait for the lookup
+	if got := r.enrich(dest); got.IP != "" {
+		t.Errorf("first enrich IP = %q, want it left empty while resolving", got.IP)
+	}
+	got := enrichEventually(t, r, dest, func(e models.Endpoint) bool { return e.IP != "" })
+	if got.IP != "104.18.7.192" || got.Hostname != "api.openai.com" || got.Port != 443 {
+		t.Errorf("enrich = %+v, want the first resolved IP added", got)
+	}
+	if stub.count() != 1 {
+		t.Errorf("%d lookups, want 1 served from cache after", stub.count())
+	}
+
+	// Expired entries are looked up again
+	now = now.Add(6 * time.Minute)
+	r.enrich(dest)
+	enrichEventually(t, r, dest, func(models.Endpoint) bool { return stub.count() == 2 })
+	if stub.count() != 2 {
+		t.Errorf("%d lookups after the TTL, want 2", stub.count())
+	}
+}
+
+func TestResolverReverseDNS(t *testing.T) {
+	stub := &stubResolver{addrs: map[string][]string{"10.0.0.5": {"Inference.Internal.Example.COM."}}}
+	now := time.Now()
+	r := newStubHostResolver(t, stub, &now)
+
+	got := enrichEventually(t, r, models.Endpoint{IP: "10.0.0.5", Port: 8000},
+		func(e models.Endpoint) bool { return e.Hostname != "" })
+	if got.Hostname != "inference.internal.example.com" || got.IP != "10.0.0.5" {
+		t.Errorf("enrich = %+v, want the normalized reverse-DNS name", got)
+	}
+}
+
+func TestResolverCachesFailures(t *testing.T) {
+	stub := &stubResolver{}
+	now := time.Now()
+	r := newStubHostResolver(t, stub, &now)
+	dest := models.Endpoint{Hostname: "unresolvable.example.com", Port: 443}
+
+	r.enrich(dest)
+	enrichEventually(t, r, dest, func(models.Endpoint) bool {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		_, ok := r.cache[dest.Hostname]
+		return ok
+	})
+	for i := 0; i < 5; i++ {
+		if got := r.enrich(dest); got.IP != "" {
+			t.Fatalf("enrich IP = %q, want empty for a failed lookup", got.IP)
+		}
+	}
+	if stub.count() != 1 {
+		t.Errorf("%d lookups, want the failure cached after 1", stub.count())
+	}
+
+	// Complete endpoints are left alone
+	full := models.Endpoint{Hostname: "api.openai.com", IP: "104.18.7.192", Port: 443}
+	if got := r.enrich(full); got != full {
+		t.Errorf("enrich changed a complete endpoint to %+v", got)
+	}
+	if stub.count() != 1 {
+		t.Errorf("complete endpoint was looked up")
+	}
+}
+
+func TestResolverDisabled(t *testing.T) {
+	t.Setenv("AXOM_RESOLVE_TTL", "0")
+	r := hostResolverFromEnv()
+	if r != nil {
+		t.Fatalf("AXOM_RESOLVE_TTL=0 gave a resolver, want disabled")
+	}
+	dest := models.Endpoint{Hostname: "api.openai.com", Port: 443}
+	if got := r.enrich(dest); got != dest {
+		t.Errorf("disabled resolver changed the endpoint to %+v", got)
+	}
+}
+
+func TestSignalDestinationResolved(t *testing.T) {
+	stub := &stubResolver{hosts: map[string][]string{"api.openai.com": {"104.18.7.192"}}}
+	now := time.Now()
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "application/json", okChatResponse))
+	p.resolver = newStubHostResolver(t, stub, &now)
+
+	first := proxySignal(t, p, signalCh, chatRequest(`{"model": "gpt-4", "messages": [{"role": "user", "content": "Hi"}]}`))
+	if first.Destination.Hostname != "api.openai.com" {
+		t.Errorf("Destination.Hostname = %q, want api.openai.com", first.Destination.Hostname)
+	}
+	enrichEventually(t, p.resolver, first.Destination, func(e models.Endpoint) bool { return e.IP != "" })
+
+	second := proxySignal(t, p, signalCh, chatRequest(`{"model": "gpt-4", "messages": [{"role": "user", "content": "Hello"}]}`))
+	if second.Destination.IP != "104.18.7.192" {
+		t.Errorf("Destination.IP = %q, want the cached resolution", second.Destination.IP)
+	}
+}
@@ -0,0 +1,80 @@
+package observer
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// hopByHopHeaders apply to a single connection and must not be forwarded
+var hopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// copyResponseHeaders copies upstream response headers to the client
+// response, dropping hop-by-hop headers. Content-Length is dropped too since
+// the proxy writes the buffered body and sets its own length.
+func copyResponseHeaders(dst, src http.Header) {
+	for key, values := range src {
+		for _, value := range values {
+			dst.Add(key, value)
+		}
+	}
+	for _, key := range hopByHopHeaders {
+		dst.Del(key)
+	}
+	dst.Del("Content-Length")
+}
+
+// recordRateLimitHeaders copies x-ratelimit-* and retry-after response
+// headers into the response metadata, e.g. x-ratelimit-remaining-requests
+// becomes response["x_ratelimit_remaining_requests"]. The same values are
+// also collected, under provider-neutral names, in response["rate_limits"]:
+// OpenAI's x-ratelimit-remaining-tokens and Anthropic's
+// anthropic-ratelimit-tokens-remaining both become "remaining_tokens".
+// Limits and remaining counts are numbers; reset times are kept as sent.
+func recordRateLimitHeaders(response map[string]interface{}, header http.Header) {
+	limits := make(map[string]interface{})
+	for key, values := range header {
+		lower := strings.ToLower(key)
+		if len(values) == 0 {
+			continue
+		}
+		if strings.HasPrefix(lower, "x-ratelimit-") || strings.HasPrefix(lower, "anthropic-ratelimit-") || lower == "retry-after" {
+			response[strings.ReplaceAll(lower, "-", "_")] = values[0]
+			name := rateLimitName(lower)
+			if n, err := strconv.ParseInt(values[0], 10, 64); err == nil && !strings.HasPrefix(name, "reset") {
+				limits[name] = n
+			} else {
+				limits[name] = values[0]
+			}
+		}
+	}
+	if len(limits) > 0 {
+		response["rate_limits"] = limits
+	}
+}
+
+// rateLimitName returns the provider-neutral rate_limits key for a
+// lowercased rate-limit header. OpenAI-style headers name the measure first
+// (x-ratelimit-limit-requests), Anthropic's last
+// (anthropic-ratelimit-input-tokens-limit); both become measure_resource,
+// e.g. "limit_requests" and "limit_input_tokens".
+func rateLimitName(header string) string {
+	if rest, ok := strings.CutPrefix(header, "anthropic-ratelimit-"); ok {
+		if i := strings.LastIndex(rest, "-"); i > 0 {
+			rest = rest[i+1:] + "-" + rest[:i]
+		}
+		header = rest
+	} else {
+		header = strings.TrimPrefix(header, "x-ratelimit-")
+	}
+	return strings.ReplaceAll(header, "-", "_")
+}
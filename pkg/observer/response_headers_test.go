@@ -0,0 +1,171 @@
+package observer
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestHTTPProxyForwardsResponseHeaders(t *testing.T) {
+	upstream := ForwarderFunc(func(req *http.Request) (*http.Response, error) {
+		header := make(http.Header)
+		header.Set("Content-Type", "application/json")
+		header.Set("X-Ratelimit-Remaining-Requests", "59")
+		header.Set("Retry-After", "2")
+		header.Set("Openai-Processing-Ms", "123")
+		header.Add("Set-Cookie", "a=1")
+		header.Add("Set-Cookie", "b=2")
+		header.Set("Connection", "keep-alive")
+		header.Set("Keep-Alive", "timeout=5")
+		header.Set("Content-Length", "1")
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     header,
+			Body:       io.NopCloser(strings.NewReader(okChatResponse)),
+			// Chunked upstream: the proxy must send the whole buffered body
+			ContentLength:    -1,
+			TransferEncoding: []string{"chunked"},
+			Request:          req,
+		}, nil
+	})
+	p, signalCh := newTestHTTPProxy(t, upstream)
+
+	rec := httptest.NewRecorder()
+	p.handleRequest(rec, chatRequest(`{"model": "gpt-4", "messages": [{"role": "user", "content": "Hi"}]}`))
+
+	got := rec.Result().Header
+	for key, want := range map[string]string{
+		"Content-Type":                   "application/json",
+		"X-Ratelimit-Remaining-Requests": "59",
+		"Retry-After":                    "2",
+		"Openai-Processing-Ms":           "123",
+		"Content-Length":                 strconv.Itoa(len(okChatResponse)),
+	} {
+		if v := got.Get(key); v != want {
+			t.Errorf("response header %s = %q, want %q", key, v, want)
+		}
+	}
+	if cookies := got.Values("Set-Cookie"); len(cookies) != 2 {
+		t.Errorf("Set-Cookie = %v, want both values", cookies)
+	}
+	for _, key := range []string{"Connection", "Keep-Alive", "Transfer-Encoding"} {
+		if v := got.Get(key); v != "" {
+			t.Errorf("hop-by-hop header %s forwarded as %q", key, v)
+		}
+	}
+	if body := rec.Body.String(); body != okChatResponse {
+		t.Errorf("client received %d body bytes, want the full %d", len(body), len(okChatResponse))
+	}
+
+	signal := <-signalCh
+	if got := signal.Metadata["x_ratelimit_remaining_requests"]; got != "59" {
+		t.Errorf("x_ratelimit_remaining_requests = %v, want 59", got)
+	}
+	if got := signal.Metadata["retry_after"]; got != "2" {
+		t.Errorf("retry_after = %v, want 2", got)
+	}
+}
+
+func TestCopyResponseHeadersDropsHopByHop(t *testing.T) {
+	src := http.Header{}
+	src.Set("Content-Type", "text/event-stream")
+	src.Set("Upgrade", "h2c")
+	src.Set("Trailer", "X-Checksum")
+	src.Set("Content-Length", "10")
+
+	dst := http.Header{}
+	copyResponseHeaders(dst, src)
+	if dst.Get("Content-Type") != "text/event-stream" {
+		t.Errorf("Content-Type not copied")
+	}
+	for _, key := range []string{"Upgrade", "Trailer", "Content-Length"} {
+		if dst.Get(key) != "" {
+			t.Errorf("%s copied to the client response", key)
+		}
+	}
+}
+
+func TestRateLimitsOpenAIHeaders(t *testing.T) {
+	header := http.Header{}
+	header.Set("X-Ratelimit-Limit-Requests", "500")
+	header.Set("X-Ratelimit-Remaining-Requests", "499")
+	header.Set("X-Ratelimit-Limit-Tokens", "30000")
+	header.Set("X-Ratelimit-Remaining-Tokens", "29950")
+	header.Set("X-Ratelimit-Reset-Requests", "120ms")
+	header.Set("X-Ratelimit-Reset-Tokens", "100")
+	header.Set("Openai-Processing-Ms", "123")
+
+	response := make(map[string]interface{})
+	recordRateLimitHeaders(response, header)
+	limits, _ := response["rate_limits"].(map[string]interface{})
+	for key, want := range map[string]interface{}{
+		"limit_requests":     int64(500),
+		"remaining_requests": int64(499),
+		"limit_tokens":       int64(30000),
+		"remaining_tokens":   int64(29950),
+		"reset_requests":     "120ms",
+		// Resets are kept as sent, even when numeric
+		"reset_tokens": "100",
+	} {
+		if got := limits[key]; got != want {
+			t.Errorf("rate_limits[%s] = %#v, want %#v", key, got, want)
+		}
+	}
+	if len(limits) != 6 {
+		t.Errorf("rate_limits = %v, want only the rate-limit headers", limits)
+	}
+	if got := response["x_ratelimit_remaining_tokens"]; got != "29950" {
+		t.Errorf("x_ratelimit_remaining_tokens = %v, want the raw header kept", got)
+	}
+}
+
+func TestRateLimitsAnthropicHeaders(t *testing.T) {
+	upstream := ForwarderFunc(func(req *http.Request) (*http.Response, error) {
+		header := make(http.Header)
+		header.Set("Content-Type", "application/json")
+		header.Set("Anthropic-Ratelimit-Requests-Limit", "50")
+		header.Set("Anthropic-Ratelimit-Requests-Remaining", "49")
+		header.Set("Anthropic-Ratelimit-Tokens-Remaining", "39000")
+		header.Set("Anthropic-Ratelimit-Input-Tokens-Limit", "40000")
+		header.Set("Anthropic-Ratelimit-Requests-Reset", "2026-10-16T12:00:30Z")
+		header.Set("Retry-After", "30")
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     header,
+			Body:       io.NopCloser(strings.NewReader(`{"id":"msg_1","type":"message","content":[{"type":"text","text":"Hi"}],"usage":{"input_tokens":5,"output_tokens":1}}`)),
+			Request:    req,
+		}, nil
+	})
+	p, signalCh := newTestHTTPProxy(t, upstream)
+	signal := proxySignal(t, p, signalCh, jsonRequest("http://api.anthropic.com/v1/messages",
+		`{"model":"claude-3-5-sonnet-20241022","max_tokens":16,"messages":[{"role":"user","content":"Hi"}]}`))
+
+	limits, ok := signal.Metadata["rate_limits"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("rate_limits = %#v, want a map", signal.Metadata["rate_limits"])
+	}
+	// Named as OpenAI's are, measure first
+	for key, want := range map[string]interface{}{
+		"limit_requests":     int64(50),
+		"remaining_requests": int64(49),
+		"remaining_tokens":   int64(39000),
+		"limit_input_tokens": int64(40000),
+		"reset_requests":     "2026-10-16T12:00:30Z",
+		"retry_after":        int64(30),
+	} {
+		if got := limits[key]; got != want {
+			t.Errorf("rate_limits[%s] = %#v, want %#v", key, got, want)
+		}
+	}
+}
+
+func TestNoRateLimitHeaders(t *testing.T) {
+	response := make(map[string]interface{})
+	recordRateLimitHeaders(response, http.Header{"Content-Type": {"application/json"}})
+	if got, ok := response["rate_limits"]; ok {
+		t.Errorf("rate_limits = %v without rate-limit headers, want it absent", got)
+	}
+}
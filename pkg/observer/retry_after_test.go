@@ -0,0 +1,83 @@
+package observer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"axom-observer/pkg/models"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 2, 15, 4, 5, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"7", 7 * time.Second},
+		{now.Add(30 * time.Second).Format(http.TimeFormat), 30 * time.Second},
+		// Capped, in either form
+		{"3600", maxRetryAfter},
+		{now.Add(time.Hour).Format(http.TimeFormat), maxRetryAfter},
+		// A date in the past or an invalid value asks for no delay
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0},
+		{"-5", 0},
+		{"soon", 0},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestSendBatchOnceReturnsRetryAfter(t *testing.T) {
+	tests := map[string]string{
+		"seconds":   "12",
+		"HTTP-date": time.Now().Add(12 * time.Second).UTC().Format(http.TimeFormat),
+	}
+	for name, header := range tests {
+		t.Run(name, func(t *testing.T) {
+			backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Retry-After", header)
+				w.WriteHeader(http.StatusTooManyRequests)
+			}))
+			defer backend.Close()
+			s := NewSignalSender("test-key", backend.URL, 10, time.Hour)
+
+			err, retryable, status := s.sendBatchOnce(context.Background(), encodeTestBatch(t, s), 1)
+			if !retryable || status != http.StatusTooManyRequests {
+				t.Fatalf("sendBatchOnce = %v, %v, %d, want a retryable 429", err, retryable, status)
+			}
+			// The HTTP-date form loses sub-second precision
+			if got := retryAfter(err); got < 11*time.Second || got > 12*time.Second {
+				t.Errorf("retryAfter = %v, want about 12s", got)
+			}
+		})
+	}
+}
+
+func TestRetryAfterFloorsBackoff(t *testing.T) {
+	t.Setenv("AXOM_STARTUP_GRACE", "0")
+	t.Setenv("AXOM_DLQ_PATH", "")
+	attempts := make(chan time.Time, 4)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts <- time.Now()
+		w.Header().Set("Retry-After", "5")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer backend.Close()
+	s := NewSignalSender("test-key", backend.URL, 10, time.Hour)
+
+	// The first exponential delay is 2s; Retry-After raises it to 5s, so
+	// no second attempt is made before the context ends
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	s.sendBatchWithRetry(ctx, []models.Signal{testSignal("sig-1", nil)})
+	if n := len(attempts); n != 1 {
+		t.Errorf("backend saw %d attempts within 3s, want 1 after a 5s Retry-After", n)
+	}
+}
@@ -0,0 +1,120 @@
+package observer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// sensitiveConfigWords mark a setting whose value is masked wherever it appears
+var sensitiveConfigWords = []string{"SECRET", "KEY", "TOKEN", "PASSWORD", "CREDENTIAL", "AUTH"}
+
+// RuntimeConfig is the effective configuration served read-only as JSON, for
+// support to see which flags and environment variables took effect. Values
+// are set at startup; secrets are always masked.
+type RuntimeConfig struct {
+	mu     sync.RWMutex
+	values map[string]interface{}
+}
+
+// NewRuntimeConfig creates an empty runtime configuration
+func NewRuntimeConfig() *RuntimeConfig {
+	return &RuntimeConfig{values: make(map[string]interface{})}
+}
+
+// Set records a non-secret setting. Strings that look like URLs have any
+// credentials redacted.
+func (c *RuntimeConfig) Set(key string, value interface{}) {
+	if s, ok := value.(string); ok {
+		value = redactURL(s)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[key] = value
+}
+
+// SetSecret records a secret setting in masked form
+func (c *RuntimeConfig) SetSecret(key, value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[key] = MaskSecret(value)
+}
+
+// ServeHTTP writes the settings, plus every AXOM_* environment variable, as
+// JSON
+func (c *RuntimeConfig) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"config": c.values,
+		"env":    axomEnvironment(),
+	})
+}
+
+// axomEnvironment returns the AXOM_* environment variables, masking those
+// whose names suggest a secret
+func axomEnvironment() map[string]string {
+	env := make(map[string]string)
+	for _, kv := range os.Environ() {
+		name, value, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(name, "AXOM_") {
+			continue
+		}
+		if isSensitiveConfigName(name) {
+			env[name] = MaskSecret(value)
+		} else {
+			env[name] = redactURL(value)
+		}
+	}
+	return env
+}
+
+// isSensitiveConfigName reports whether a setting name suggests a secret
+func isSensitiveConfigName(name string) bool {
+	upper := strings.ToUpper(name)
+	for _, word := range sensitiveConfigWords {
+		if strings.Contains(upper, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactURL masks the password and any secret-looking query parameters of a
+// URL; other values are returned unchanged
+func redactURL(value string) string {
+	if !strings.Contains(value, "://") {
+		return value
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return value
+	}
+	if query := u.Query(); len(query) > 0 {
+		for name := range query {
+			if isSensitiveConfigName(name) {
+				query.Set(name, "xxxxx")
+			}
+		}
+		u.RawQuery = query.Encode()
+	}
+	return u.Redacted()
+}
+
+// MaskSecret masks a secret for logging, keeping only the first and last
+// four characters of long values
+func MaskSecret(secret string) string {
+	if len(secret) <= 8 {
+		return "***"
+	}
+	return secret[:4] + "***" + secret[len(secret)-4:]
+}
@@ -0,0 +1,102 @@
+package observer
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"os"
+	"strconv"
+	"strings"
+
+	"axom-observer/pkg/models"
+)
+
+// Environment variables:
+//   AXOM_SAMPLE_RATE  - Optional. Fraction of signals to keep, 0.0-1.0. Default: 1.0
+//   AXOM_SAMPLE_RATES - Optional. Per-operation overrides, e.g. "embedding=0.1,chat_completion=1.0"
+
+// signalSampler decides which captured signals reach the signal channel.
+// Decisions hash the task ID (or the signal ID when there is no task), so
+// every signal of one task is kept or dropped together.
+type signalSampler struct {
+	rate  float64
+	rates map[string]float64
+}
+
+// signalSamplerFromEnv reads the sampling rates from the environment
+func signalSamplerFromEnv() signalSampler {
+	s := signalSampler{rate: 1.0, rates: make(map[string]float64)}
+	if v := os.Getenv("AXOM_SAMPLE_RATE"); v != "" {
+		if rate, ok := parseSampleRate(v); ok {
+			s.rate = rate
+		}
+	}
+	for _, pair := range strings.Split(os.Getenv("AXOM_SAMPLE_RATES"), ",") {
+		operation, value, found := strings.Cut(pair, "=")
+		if !found {
+			continue
+		}
+		if rate, ok := parseSampleRate(value); ok {
+			s.rates[strings.TrimSpace(operation)] = rate
+		}
+	}
+	return s
+}
+
+// parseSampleRate parses a rate and clamps it to [0, 1]
+func parseSampleRate(v string) (float64, bool) {
+	rate, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil {
+		return 0, false
+	}
+	if rate < 0 {
+		rate = 0
+	}
+	if rate > 1 {
+		rate = 1
+	}
+	return rate, true
+}
+
+// rateFor returns the sampling rate for an operation
+func (s signalSampler) rateFor(operation string) float64 {
+	if rate, ok := s.rates[operation]; ok {
+		return rate
+	}
+	return s.rate
+}
+
+// keep reports whether signal should be sent. Kept signals from a sampled
+// operation record the rate in metadata["sample_rate"] so totals can be
+// extrapolated; discarded ones are counted, along with their tokens, against
+// provider.
+func (s signalSampler) keep(signal *models.Signal, provider string) bool {
+	rate := s.rateFor(signal.Operation)
+	if rate >= 1 {
+		return true
+	}
+
+	key := signal.TaskID
+	if key == "" {
+		key = signal.ID
+	}
+	if sampleFraction(key) < rate {
+		if signal.Metadata != nil {
+			signal.Metadata["sample_rate"] = rate
+		}
+		return true
+	}
+
+	eachMetrics(func(m *observerMetrics) { m.signalsSampledOut.WithLabelValues(provider, signal.Operation).Inc() })
+	if tokens, ok := signal.Metadata["total_tokens"].(int); ok && tokens > 0 {
+		eachMetrics(func(m *observerMetrics) {
+			m.tokensSampledOut.WithLabelValues(provider, signal.Operation).Add(float64(tokens))
+		})
+	}
+	return false
+}
+
+// sampleFraction maps a key onto [0, 1) deterministically
+func sampleFraction(key string) float64 {
+	sum := sha256.Sum256([]byte(key))
+	return float64(binary.BigEndian.Uint64(sum[:8])>>11) / float64(1<<53)
+}
@@ -0,0 +1,119 @@
+package observer
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"axom-observer/pkg/models"
+)
+
+// sampledFraction returns the fraction of n distinct signals of operation
+// that s keeps
+func sampledFraction(s signalSampler, operation string, n int) float64 {
+	kept := 0
+	for i := 0; i < n; i++ {
+		signal := models.Signal{ID: fmt.Sprintf("sig-%d", i), Operation: operation, Metadata: map[string]interface{}{}}
+		if s.keep(&signal, "OpenAI") {
+			kept++
+		}
+	}
+	return float64(kept) / float64(n)
+}
+
+func TestSamplerKeepsConfiguredFraction(t *testing.T) {
+	t.Setenv("AXOM_SAMPLE_RATE", "0.25")
+	t.Setenv("AXOM_SAMPLE_RATES", "embedding=0.1, chat_completion=1.0")
+	s := signalSamplerFromEnv()
+
+	const n = 20000
+	tests := []struct {
+		operation string
+		want      float64
+	}{
+		{"embedding", 0.1},
+		{"chat_completion", 1.0},
+		{"completion", 0.25},
+	}
+	for _, tt := range tests {
+		if got := sampledFraction(s, tt.operation, n); math.Abs(got-tt.want) > 0.02 {
+			t.Errorf("%s kept fraction = %.3f, want about %.2f", tt.operation, got, tt.want)
+		}
+	}
+}
+
+func TestSamplerRateBounds(t *testing.T) {
+	t.Setenv("AXOM_SAMPLE_RATE", "0")
+	t.Setenv("AXOM_SAMPLE_RATES", "embedding=7, chat_completion=-1, bad=x")
+	s := signalSamplerFromEnv()
+
+	if got := sampledFraction(s, "completion", 1000); got != 0 {
+		t.Errorf("rate 0 kept %.3f of signals", got)
+	}
+	if got := s.rateFor("embedding"); got != 1 {
+		t.Errorf("rate above 1 = %v, want clamped to 1", got)
+	}
+	if got := s.rateFor("chat_completion"); got != 0 {
+		t.Errorf("negative rate = %v, want clamped to 0", got)
+	}
+	if _, ok := s.rates["bad"]; ok {
+		t.Errorf("unparseable rate was kept")
+	}
+
+	t.Setenv("AXOM_SAMPLE_RATE", "")
+	t.Setenv("AXOM_SAMPLE_RATES", "")
+	if got := sampledFraction(signalSamplerFromEnv(), "embedding", 1000); got != 1 {
+		t.Errorf("default sampler kept %.3f of signals, want all", got)
+	}
+}
+
+func TestSamplerKeepsOrDropsWholeTasks(t *testing.T) {
+	s := signalSampler{rate: 0.5, rates: map[string]float64{}}
+	for task := 0; task < 100; task++ {
+		taskID := fmt.Sprintf("task-%d", task)
+		var first bool
+		for i := 0; i < 5; i++ {
+			signal := models.Signal{ID: fmt.Sprintf("%s-sig-%d", taskID, i), TaskID: taskID, Operation: "chat_completion", Metadata: map[string]interface{}{}}
+			kept := s.keep(&signal, "OpenAI")
+			if i == 0 {
+				first = kept
+			} else if kept != first {
+				t.Fatalf("signals of %s were split by sampling", taskID)
+			}
+		}
+	}
+}
+
+func TestSamplerRecordsRateAndCountsDropped(t *testing.T) {
+	reg := newTestRegistry(t)
+	s := signalSampler{rate: 0.5, rates: map[string]float64{}}
+
+	var kept, dropped, droppedTokens int
+	for i := 0; i < 200; i++ {
+		signal := models.Signal{
+			ID:        fmt.Sprintf("sig-%d", i),
+			Operation: "embedding",
+			Metadata:  map[string]interface{}{"total_tokens": 10},
+		}
+		if s.keep(&signal, "OpenAI") {
+			kept++
+			if got := signal.Metadata["sample_rate"]; got != 0.5 {
+				t.Fatalf("kept signal sample_rate = %v, want 0.5", got)
+			}
+		} else {
+			dropped++
+			droppedTokens += 10
+		}
+	}
+	if kept == 0 || dropped == 0 {
+		t.Fatalf("rate 0.5 kept %d and dropped %d of 200", kept, dropped)
+	}
+
+	labels := map[string]string{"provider": "OpenAI", "operation": "embedding"}
+	if got := metricValue(t, reg, "axom_signals_sampled_out_total", labels); got != float64(dropped) {
+		t.Errorf("axom_signals_sampled_out_total = %v, want %d", got, dropped)
+	}
+	if got := metricValue(t, reg, "axom_tokens_sampled_out_total", labels); got != float64(droppedTokens) {
+		t.Errorf("axom_tokens_sampled_out_total = %v, want %d", got, droppedTokens)
+	}
+}
@@ -0,0 +1,69 @@
+package observer
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// samplingParam describes the plausible range of a numeric sampling param
+type samplingParam struct {
+	name    string
+	integer bool
+	min     float64
+	max     float64
+}
+
+// samplingParams are the sampling params captured from request bodies
+var samplingParams = []samplingParam{
+	{name: "max_tokens", integer: true, min: 1, max: math.MaxInt32},
+	{name: "temperature", min: 0, max: 2},
+	{name: "top_p", min: 0, max: 1},
+	{name: "frequency_penalty", min: -2, max: 2},
+	{name: "presence_penalty", min: -2, max: 2},
+}
+
+// normalizeSamplingParams copies sampling params from the request body into
+// metadata with consistent types (int for max_tokens, float64 otherwise),
+// whether the client sent them as JSON numbers or strings. Unparseable or
+// implausible values are dropped and listed in metadata["invalid_params"].
+func normalizeSamplingParams(request map[string]interface{}, jsonData map[string]interface{}) {
+	var invalid []string
+	for _, param := range samplingParams {
+		raw, ok := jsonData[param.name]
+		if !ok || raw == nil {
+			continue
+		}
+		value, ok := toFloat64(raw)
+		if !ok || math.IsNaN(value) || value < param.min || value > param.max {
+			invalid = append(invalid, param.name)
+			continue
+		}
+		if param.integer {
+			if value != math.Trunc(value) {
+				invalid = append(invalid, param.name)
+				continue
+			}
+			request[param.name] = int(value)
+		} else {
+			request[param.name] = value
+		}
+	}
+	if len(invalid) > 0 {
+		request["invalid_params"] = invalid
+	}
+}
+
+// toFloat64 converts a decoded JSON value (number or numeric string) to float64
+func toFloat64(v interface{}) (float64, bool) {
+	switch val := v.(type) {
+	case float64:
+		return val, true
+	case int:
+		return float64(val), true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,66 @@
+package observer
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeSamplingParamsStringsAndNumbersAgree(t *testing.T) {
+	decode := func(body string) map[string]interface{} {
+		var jsonData map[string]interface{}
+		if err := json.Unmarshal([]byte(body), &jsonData); err != nil {
+			t.Fatal(err)
+		}
+		request := make(map[string]interface{})
+		normalizeSamplingParams(request, jsonData)
+		return request
+	}
+
+	numbers := decode(`{"max_tokens":256,"temperature":0.7,"top_p":1,"frequency_penalty":-0.5,"presence_penalty":0}`)
+	strs := decode(`{"max_tokens":"256","temperature":"0.7","top_p":"1","frequency_penalty":" -0.5 ","presence_penalty":"0"}`)
+
+	if !reflect.DeepEqual(numbers, strs) {
+		t.Errorf("string params normalized to %v, number params to %v", strs, numbers)
+	}
+	if got, ok := numbers["max_tokens"].(int); !ok || got != 256 {
+		t.Errorf("max_tokens = %#v, want int 256", numbers["max_tokens"])
+	}
+	if got, ok := numbers["temperature"].(float64); !ok || got != 0.7 {
+		t.Errorf("temperature = %#v, want float64 0.7", numbers["temperature"])
+	}
+}
+
+func TestNormalizeSamplingParamsFlagsInvalid(t *testing.T) {
+	request := make(map[string]interface{})
+	normalizeSamplingParams(request, map[string]interface{}{
+		"max_tokens":  "12.5",
+		"temperature": 3.0,
+		"top_p":       "high",
+		"seed":        "ignored",
+	})
+
+	want := []string{"max_tokens", "temperature", "top_p"}
+	if got := request["invalid_params"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("invalid_params = %v, want %v", got, want)
+	}
+	for _, name := range want {
+		if _, ok := request[name]; ok {
+			t.Errorf("invalid %s was kept", name)
+		}
+	}
+}
+
+func TestSamplingParamsInSignal(t *testing.T) {
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "application/json", okChatResponse))
+
+	signal := proxySignal(t, p, signalCh, chatRequest(`{"model":"gpt-4","max_tokens":"64","temperature":"0.2","messages":[{"role":"user","content":"Hello"}]}`))
+
+	if got := signal.Metadata["max_tokens"]; got != 64 {
+		t.Errorf("Metadata[max_tokens] = %#v, want 64", got)
+	}
+	if got := signal.Metadata["temperature"]; got != 0.2 {
+		t.Errorf("Metadata[temperature] = %#v, want 0.2", got)
+	}
+}
@@ -0,0 +1,36 @@
+package observer
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"axom-observer/pkg/models"
+)
+
+func TestCreatedSignalsVersioned(t *testing.T) {
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "application/json", okChatResponse))
+	signal := proxySignal(t, p, signalCh, chatRequest(`{"model": "gpt-4", "messages": [{"role": "user", "content": "Hi"}]}`))
+	if signal.SchemaVersion != models.SignalSchemaVersion {
+		t.Errorf("proxy signal SchemaVersion = %q, want %q", signal.SchemaVersion, models.SignalSchemaVersion)
+	}
+
+	d, _ := newTestTaskDetector()
+	task := &models.Task{ID: "task-1", CreatedAt: time.Now(), Metadata: map[string]interface{}{}}
+	completion := d.BuildCompletionSignal(task, []models.Signal{signal})
+	if completion.SchemaVersion != models.SignalSchemaVersion {
+		t.Errorf("task_complete SchemaVersion = %q, want %q", completion.SchemaVersion, models.SignalSchemaVersion)
+	}
+}
+
+func TestBatchSchemaVersionHeader(t *testing.T) {
+	backend := newTestBackend(t, nil)
+	s := NewSignalSender("test-key", backend.URL, 10, time.Hour)
+	if _, err := s.SendBatches(context.Background(), []models.Signal{testSignal("sig-1", nil)}); err != nil {
+		t.Fatalf("SendBatches: %v", err)
+	}
+	if got := backend.headers[0].Get("X-Axom-Schema-Version"); got != models.SignalSchemaVersion {
+		t.Errorf("X-Axom-Schema-Version = %q, want %q", got, models.SignalSchemaVersion)
+	}
+}
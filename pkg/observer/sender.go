@@ -4,63 +4,57 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"math"
 	"net/http"
 	"os"
+	"reflect"
 	"strconv"
 	"time"
 
 	"axom-observer/pkg/models"
-
-	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 // Environment variables (documented for production):
 //   AXOM_API_KEY           - Required. API key for backend authentication.
 //   AXOM_BACKEND_URL       - Optional. Override backend URL. Default: https://api.axom.ai/ingest
+//   AXOM_BACKEND_URLS      - Optional. Comma-separated backend URLs to fail over between (see failover.go). Default: AXOM_BACKEND_URL
 //   AXOM_SKIP_TLS_VERIFY   - Optional. Set to "1" to skip TLS verification (testing only!)
 //   AXOM_BATCH_SIZE        - Optional. Batch size for sending signals. Default: 50
 //   AXOM_FLUSH_INTERVAL    - Optional. Flush interval in seconds. Default: 10
 //   AXOM_METRICS_ENABLED   - Optional. Set to "0" to disable Prometheus metrics server. Default: enabled.
-
-var (
-	signalsSent = prometheus.NewCounter(prometheus.CounterOpts{
-		Name: "axom_signals_sent_total",
-		Help: "Total number of signals sent to backend",
-	})
-	signalsDropped = prometheus.NewCounter(prometheus.CounterOpts{
-		Name: "axom_signals_dropped_total",
-		Help: "Total number of signals dropped after retries",
-	})
-	metricsServerStarted = false
-)
-
-func init() {
-	prometheus.MustRegister(signalsSent, signalsDropped)
-	// Only start metrics server if enabled (default: true)
-	if os.Getenv("AXOM_METRICS_ENABLED") != "0" && !metricsServerStarted {
-		metricsServerStarted = true
-		go func() {
-			mux := http.NewServeMux()
-			mux.Handle("/metrics", promhttp.Handler())
-			server := &http.Server{Addr: ":2112", Handler: mux}
-			if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-				log.Printf("Prometheus metrics server error: %v", err)
-			}
-		}()
-	}
-	log.Println("[observer] SignalSender initialized. Prometheus metrics enabled:", os.Getenv("AXOM_METRICS_ENABLED") != "0")
-}
+//   AXOM_METRICS_PORT      - Optional. Port for the Prometheus metrics server. Default: 2112
+//   AXOM_SANITIZE_SIGNALS  - Optional. Set to "0" to drop (rather than sanitize and retry) signals that fail to marshal. Default: enabled.
+//   AXOM_STARTUP_GRACE     - Optional. Seconds after Start during which backend failures are not counted. Default: 30
+//   AXOM_REDACT_PII        - Optional. Set to "1" to mask emails, phone numbers, card numbers and SSNs in signal metadata. Default: disabled
+//   AXOM_REQUEST_TIMEOUT   - Optional. Timeout in seconds for each batch send attempt, independent of the retry budget. Default: 5
+//   AXOM_DLQ_PATH          - Optional. Dead-letter file for batches dropped after retries (see dlq.go). Default: disabled
+//   AXOM_BREAKER_THRESHOLD - Optional. Consecutive failed batches before sends are short-circuited (see breaker.go). Default: 5
+//   AXOM_CLIENT_CERT       - Optional. PEM client certificate for mutual TLS with the backend; requires AXOM_CLIENT_KEY. Default: none
+//   AXOM_CLIENT_KEY        - Optional. PEM private key for AXOM_CLIENT_CERT. Default: none
+//   AXOM_CA_BUNDLE         - Optional. PEM CA bundle used instead of the system roots to verify the backend. Default: system roots
+//   AXOM_SIGN_BATCHES      - Optional. Set to "1" to sign each batch with HMAC-SHA256 under the agent secret (see batch_signature.go). Default: disabled
 
 type SignalSender struct {
 	apiKey        string
-	url           string
+	endpoints     *backendEndpoints
 	client        *http.Client
 	batchSize     int
 	flushInterval time.Duration
+	sanitize      bool
+	startupGrace  time.Duration
+	startedAt     time.Time
+	redactPII     bool
+	dlq           *deadLetterQueue
+	reqTimeout    time.Duration
+	breaker       *circuitBreaker
+	configErr     error
+	hooks         []SignalHook
+	signBatches   bool
 }
 
 // NewSignalSender creates a new SignalSender with config values.
@@ -71,12 +65,11 @@ func NewSignalSender(apiKey, url string, batchSize int, flushInterval time.Durat
 			url = "http://localhost:8000/ingest"
 		}
 	}
-	skipTLS := os.Getenv("AXOM_SKIP_TLS_VERIFY") == "1"
 	client := &http.Client{Timeout: 10 * time.Second}
-	if skipTLS {
-		tr := &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		}
+	tlsConfig, configErr := backendTLSConfigFromEnv()
+	if tlsConfig != nil {
+		tr := http.DefaultTransport.(*http.Transport).Clone()
+		tr.TLSClientConfig = tlsConfig
 		client.Transport = tr
 	}
 	if batchSize <= 0 {
@@ -99,133 +92,649 @@ func NewSignalSender(apiKey, url string, batchSize int, flushInterval time.Durat
 			flushInterval = 10 * time.Second
 		}
 	}
+	startupGrace := 30 * time.Second
+	if v := os.Getenv("AXOM_STARTUP_GRACE"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			startupGrace = time.Duration(n) * time.Second
+		}
+	}
+	reqTimeout := 5 * time.Second
+	if v := os.Getenv("AXOM_REQUEST_TIMEOUT"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			reqTimeout = time.Duration(n) * time.Second
+		}
+	}
+	if reqTimeout > client.Timeout {
+		// The client timeout caps every request, so keep it above the attempt timeout
+		client.Timeout = reqTimeout
+	}
 	return &SignalSender{
 		apiKey:        apiKey,
-		url:           url,
+		endpoints:     backendEndpointsFromEnv(url),
 		client:        client,
 		batchSize:     batchSize,
 		flushInterval: flushInterval,
+		sanitize:      os.Getenv("AXOM_SANITIZE_SIGNALS") != "0",
+		startupGrace:  startupGrace,
+		redactPII:     os.Getenv("AXOM_REDACT_PII") == "1",
+		dlq:           deadLetterQueueFromEnv(),
+		reqTimeout:    reqTimeout,
+		breaker:       circuitBreakerFromEnv(),
+		configErr:     configErr,
+		signBatches:   signBatchesFromEnv(),
+	}
+}
+
+// EffectiveConfig returns the sender settings in effect, for RuntimeConfig
+func (s *SignalSender) EffectiveConfig() map[string]interface{} {
+	config := map[string]interface{}{
+		"backends":        s.endpoints.status(),
+		"api_key":         MaskSecret(s.apiKey),
+		"batch_size":      s.batchSize,
+		"flush_interval":  s.flushInterval.String(),
+		"request_timeout": s.reqTimeout.String(),
+		"startup_grace":   s.startupGrace.String(),
+		"sanitize":        s.sanitize,
+		"redact_pii":      s.redactPII,
+		"sign_batches":    s.signBatches,
+	}
+	// A nil breaker is disabled
+	if s.breaker != nil {
+		config["breaker_threshold"] = s.breaker.threshold
+		config["breaker_cooldown"] = s.breaker.cooldown.String()
+	}
+	if s.dlq != nil {
+		config["dlq_path"] = s.dlq.path
+	}
+	return config
+}
+
+// ConfigError returns the error from loading the backend TLS settings, if
+// any. Callers should check it before Start rather than send with a client
+// that lacks the configured certificates.
+func (s *SignalSender) ConfigError() error {
+	return s.configErr
+}
+
+// backendTLSConfigFromEnv builds the TLS settings for the backend client
+// from AXOM_SKIP_TLS_VERIFY, AXOM_CLIENT_CERT/AXOM_CLIENT_KEY and
+// AXOM_CA_BUNDLE. It returns nil when none are set.
+func backendTLSConfigFromEnv() (*tls.Config, error) {
+	skipTLS := os.Getenv("AXOM_SKIP_TLS_VERIFY") == "1"
+	certFile := os.Getenv("AXOM_CLIENT_CERT")
+	keyFile := os.Getenv("AXOM_CLIENT_KEY")
+	caFile := os.Getenv("AXOM_CA_BUNDLE")
+	if !skipTLS && certFile == "" && keyFile == "" && caFile == "" {
+		return nil, nil
+	}
+
+	config := &tls.Config{InsecureSkipVerify: skipTLS}
+	if certFile != "" || keyFile != "" {
+		if certFile == "" || keyFile == "" {
+			return nil, errors.New("AXOM_CLIENT_CERT and AXOM_CLIENT_KEY must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate %s: %w", certFile, err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA bundle %s contains no certificates", caFile)
+		}
+		config.RootCAs = pool
 	}
+	return config, nil
 }
 
+// Start batches and sends the signals from ch until ctx is cancelled,
+// running the hooks and redaction of Prepare on each first
 func (s *SignalSender) Start(ctx context.Context, ch <-chan models.Signal) {
+	s.run(ctx, ch, true)
+}
+
+// StartPrepared is Start for signals that have already been through Prepare,
+// e.g. by a pipeline that fans them out to other exporters as well
+func (s *SignalSender) StartPrepared(ctx context.Context, ch <-chan models.Signal) {
+	s.run(ctx, ch, false)
+}
+
+// Prepare runs the registered hooks on a copy of sig and redacts it the way
+// the sender does before sending, reporting false if a hook dropped it. The
+// copy shares no maps with sig, so whoever else holds sig never sees the
+// hooks' changes or the redaction.
+func (s *SignalSender) Prepare(sig models.Signal) (models.Signal, bool) {
+	sig.Metadata = copyMap(sig.Metadata)
+	sig.OutcomeData = copyMap(sig.OutcomeData)
+	sig.Alerts = append([]models.Alert(nil), sig.Alerts...)
+	if !s.applyHooks(&sig) {
+		return sig, false
+	}
+	sig.Redact("authorization", "api_key")
+	if s.redactPII {
+		sig.RedactPII()
+	}
+	return sig, true
+}
+
+// run is the batching loop of Start and StartPrepared
+func (s *SignalSender) run(ctx context.Context, ch <-chan models.Signal, prepare bool) {
+	s.startedAt = time.Now()
+	s.waitForBackend(ctx)
+	s.replayDeadLetters(ctx)
+
 	batch := make([]models.Signal, 0, s.batchSize)
 	ticker := time.NewTicker(s.flushInterval)
 	defer ticker.Stop()
-	flush := func() {
+	flush := func(ctx context.Context) {
 		if len(batch) > 0 {
-			s.sendBatchWithRetry(batch)
+			s.sendBatchWithRetry(ctx, batch)
 			batch = batch[:0]
 		}
 	}
+	add := func(ctx context.Context, sig models.Signal) {
+		if prepare {
+			var ok bool
+			if sig, ok = s.Prepare(sig); !ok {
+				return
+			}
+		}
+		batch = append(batch, sig)
+		if len(batch) >= s.batchSize {
+			flush(ctx)
+		}
+	}
 	for {
 		select {
 		case sig := <-ch:
-			sig.Redact("authorization", "api_key")
-			batch = append(batch, sig)
-			if len(batch) >= s.batchSize {
-				flush()
-			}
+			add(ctx, sig)
 		case <-ticker.C:
-			flush()
+			flush(ctx)
+		case <-ctx.Done():
+			// Batch up whatever is still buffered in ch and give each final
+			// batch one attempt; if it fails it is dead-lettered
+			finalCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.reqTimeout)
+			defer cancel()
+			for {
+				select {
+				case sig := <-ch:
+					add(finalCtx, sig)
+				default:
+					flush(finalCtx)
+					return
+				}
+			}
+		}
+	}
+}
+
+// inStartupGrace reports whether the sender is still within its startup grace
+// period, during which backend failures are expected (DNS warmup, sidecars
+// not ready) and are not counted against the batch
+func (s *SignalSender) inStartupGrace() bool {
+	return !s.startedAt.IsZero() && time.Since(s.startedAt) < s.startupGrace
+}
+
+// waitForBackend polls the backend until it responds or the startup grace
+// period elapses. Signals keep buffering in the channel meanwhile.
+func (s *SignalSender) waitForBackend(ctx context.Context) {
+	delay := 500 * time.Millisecond
+	for {
+		url := s.endpoints.current()
+		err := s.checkBackend(ctx)
+		if err == nil {
+			log.Printf("[observer] Backend %s is reachable", url)
+			return
+		}
+		if !s.inStartupGrace() {
+			log.Printf("[observer] Backend %s still unreachable after startup grace period: %v", url, err)
+			return
+		}
+		log.Printf("[observer] Waiting for backend %s: %v", url, err)
+		select {
 		case <-ctx.Done():
-			flush()
 			return
+		case <-time.After(delay):
+		}
+		if delay < 5*time.Second {
+			delay *= 2
 		}
 	}
 }
 
-// sendBatchWithRetry sends a batch with exponential backoff on 429/5xx errors.
-func (s *SignalSender) sendBatchWithRetry(signals []models.Signal) {
+// checkBackend probes the preferred backend URL and records the result, so
+// that after a failure the next probe goes to the next URL
+func (s *SignalSender) checkBackend(ctx context.Context) error {
+	url := s.endpoints.current()
+	if err := s.probeBackend(ctx, url); err != nil {
+		s.endpoints.failure(url)
+		return err
+	}
+	s.endpoints.success(url)
+	return nil
+}
+
+// probeBackend checks whether the backend at url is up, without touching
+// the failover state. Any response below 500 means it is, even if it
+// rejects the probe method.
+func (s *SignalSender) probeBackend(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return &httpStatusError{StatusCode: resp.StatusCode}
+	}
+	return nil
+}
+
+// sendBatchWithRetry sends a batch with exponential backoff on 429/5xx errors,
+// waiting at least as long as the backend's Retry-After. Retrying stops early
+// once ctx is cancelled. It returns the last error for a batch that was
+// dead-lettered or dropped.
+func (s *SignalSender) sendBatchWithRetry(ctx context.Context, signals []models.Signal) error {
 	const maxRetries = 5
 	const baseDelay = 2 * time.Second
-	var attempt int
-	log.Printf("[observer] Attempting to send batch of %d signals to %s", len(signals), s.url)
+	var attempt, failovers int
+	body, count := s.encodeBatch(signals)
+	if count == 0 {
+		return errNoEncodableSignals
+	}
+	allowed, probe := s.breaker.allow()
+	if !allowed {
+		// The backend keeps failing; don't wait on it, keep the batch for replay
+		log.Printf("[observer] Circuit breaker open, not sending batch of %d signals", count)
+		if !s.deadLetter(body) {
+			eachMetrics(func(m *observerMetrics) { m.signalsDropped.Add(float64(count)) })
+		}
+		return errBreakerOpen
+	}
+	log.Printf("[observer] Attempting to send batch of %d signals to %s", count, s.endpoints.current())
 	for {
-		err, retry, status := s.sendBatchOnce(signals)
+		err, retry, status := s.sendBatchOnce(ctx, body, count)
+		if err == nil || !retry {
+			// A rejection still means the backend is up
+			s.breaker.success()
+		}
 		if err == nil {
-			log.Printf("[observer] Successfully sent batch of %d signals", len(signals))
-			return
+			log.Printf("[observer] Successfully sent batch of %d signals", count)
+			if probe {
+				// The backend is back; send what was dead-lettered meanwhile
+				log.Printf("[observer] Circuit breaker closed for backend %s", s.endpoints.current())
+				s.replayDeadLetters(ctx)
+			}
+			return nil
+		}
+		// Try every other backend URL before counting a retry
+		if retry && failovers < s.endpoints.count()-1 && ctx.Err() == nil {
+			failovers++
+			continue
 		}
-		if !retry || attempt >= maxRetries {
+		failovers = 0
+		// A half-open probe gets a single attempt
+		if !retry || attempt >= maxRetries || probe || ctx.Err() != nil {
 			log.Printf("[observer] Failed to send batch after %d attempts (last status: %d): %v", attempt+1, status, err)
-			signalsDropped.Add(float64(len(signals)))
-			return
+			if retry {
+				if !s.inStartupGrace() {
+					s.breaker.failure()
+					if state := s.breaker.currentState(); state == breakerOpen {
+						log.Printf("[observer] Circuit breaker open for backend %s", s.endpoints.current())
+					}
+				}
+				if s.deadLetter(body) {
+					return err
+				}
+			}
+			eachMetrics(func(m *observerMetrics) { m.signalsDropped.Add(float64(count)) })
+			return err
+		}
+		if s.inStartupGrace() {
+			// Failures during startup don't count toward the retry budget
+			delay := max(baseDelay, retryAfter(err))
+			log.Printf("[observer] Batch send failed with status %d during startup grace, retrying in %v...", status, delay)
+			sleepCtx(ctx, delay)
+			continue
 		}
-		delay := time.Duration(math.Pow(2, float64(attempt))) * baseDelay
+		// The backend's Retry-After is a floor for the exponential backoff
+		delay := max(time.Duration(math.Pow(2, float64(attempt)))*baseDelay, retryAfter(err))
 		log.Printf("[observer] Batch send failed with status %d, retrying in %v (attempt %d/%d)...", status, delay, attempt+1, maxRetries)
-		time.Sleep(delay)
+		sleepCtx(ctx, delay)
 		attempt++
 	}
 }
 
-// sendBatchOnce sends a batch and returns (error, shouldRetry, statusCode)
-func (s *SignalSender) sendBatchOnce(signals []models.Signal) (error, bool, int) {
-	body, err := json.Marshal(signals)
+// sleepCtx waits for d or until ctx is cancelled
+func sleepCtx(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
+// deadLetter appends a batch that exhausted its retries to the dead-letter
+// file, reporting whether it was persisted
+func (s *SignalSender) deadLetter(body []byte) bool {
+	if s.dlq == nil {
+		return false
+	}
+	count, err := s.dlq.write(body)
 	if err != nil {
-		log.Printf("Failed to marshal batch: %v", err)
-		return err, false, 0
+		log.Printf("[observer] Failed to write batch to dead-letter file %s: %v", s.dlq.path, err)
+		return false
+	}
+	eachMetrics(func(m *observerMetrics) { m.signalsDeadLettered.Add(float64(count)) })
+	log.Printf("[observer] Wrote %d signals to dead-letter file %s", count, s.dlq.path)
+	return true
+}
+
+// replayDeadLetters sends signals left in the dead-letter file by earlier
+// outages or runs before normal batching starts
+func (s *SignalSender) replayDeadLetters(ctx context.Context) {
+	if s.dlq == nil {
+		return
+	}
+	sent, err := s.dlq.replay(s.batchSize, func(body []byte, count int) error {
+		err, retry, status := s.sendBatchOnce(ctx, body, count)
+		if err != nil && !retry {
+			// Rejected outright; replaying again would not help
+			log.Printf("[observer] Backend rejected %d dead-lettered signals with status %d, dropping", count, status)
+			return nil
+		}
+		return err
+	})
+	if sent > 0 {
+		log.Printf("[observer] Replayed %d dead-lettered signals to %s", sent, s.endpoints.current())
 	}
-	req, err := http.NewRequest("POST", s.url, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("[observer] Dead-letter replay stopped, remaining signals kept in %s: %v", s.dlq.path, err)
+	}
+}
+
+// encodeBatch marshals each signal individually so that a single signal with
+// an unserializable value (NaN, channel, ...) doesn't sink the whole batch.
+// It returns the JSON array body and the number of signals it contains.
+func (s *SignalSender) encodeBatch(signals []models.Signal) ([]byte, int) {
+	var buf bytes.Buffer
+	count := 0
+	buf.WriteByte('[')
+	for i := range signals {
+		data, err := json.Marshal(signals[i])
+		if err != nil && s.sanitize {
+			sanitized := signals[i]
+			sanitized.Metadata = sanitizeMap(signals[i].Metadata)
+			sanitized.OutcomeData = sanitizeMap(signals[i].OutcomeData)
+			sanitized.Alerts = append([]models.Alert(nil), signals[i].Alerts...)
+			for j := range sanitized.Alerts {
+				sanitized.Alerts[j].Metadata = sanitizeMap(sanitized.Alerts[j].Metadata)
+			}
+			data, err = json.Marshal(sanitized)
+		}
+		if err != nil {
+			log.Printf("[observer] Dropping signal %s: failed to marshal: %v", signals[i].ID, err)
+			eachMetrics(func(m *observerMetrics) { m.signalsMarshalFailed.Inc() })
+			continue
+		}
+		if count > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(data)
+		count++
+	}
+	buf.WriteByte(']')
+	return buf.Bytes(), count
+}
+
+// sendBatchOnce sends an encoded batch to the preferred backend URL, bounded
+// by the per-attempt timeout, and returns (error, shouldRetry, statusCode). A
+// retryable failure moves the preference on to the next URL.
+func (s *SignalSender) sendBatchOnce(ctx context.Context, body []byte, count int) (error, bool, int) {
+	ctx, cancel := context.WithTimeout(ctx, s.reqTimeout)
+	defer cancel()
+	url := s.endpoints.current()
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
 	if err != nil {
 		log.Printf("Failed to create batch request: %v", err)
 		return err, false, 0
 	}
-	req.Header.Set("Authorization", "Bearer "+s.apiKey)
-	req.Header.Set("X-Client-ID", os.Getenv("CLIENT_ID"))
-	req.Header.Set("Content-Type", "application/json")
+	s.setBatchHeaders(req, body)
 	resp, err := s.client.Do(req)
 	if err != nil {
 		log.Printf("Failed to send batch: %v", err)
+		s.endpoints.failure(url)
 		return err, true, 0
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		signalsSent.Add(float64(len(signals)))
+		s.endpoints.success(url)
+		eachMetrics(func(m *observerMetrics) { m.signalsSent.Add(float64(count)) })
 		return nil, false, resp.StatusCode
 	}
 	log.Printf("Batch HTTP error: %s", resp.Status)
 	// Retry on 429 and 5xx
 	if resp.StatusCode == 429 || (resp.StatusCode >= 500 && resp.StatusCode < 600) {
-		return &httpStatusError{StatusCode: resp.StatusCode}, true, resp.StatusCode
+		s.endpoints.failure(url)
+		retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		return &httpStatusError{StatusCode: resp.StatusCode, RetryAfter: retryAfter}, true, resp.StatusCode
 	}
-	signalsDropped.Add(float64(len(signals)))
+	eachMetrics(func(m *observerMetrics) { m.signalsDropped.Add(float64(count)) })
 	return &httpStatusError{StatusCode: resp.StatusCode}, false, resp.StatusCode
 }
 
+// maxRetryAfter caps how long a backend Retry-After can hold up a batch
+const maxRetryAfter = 2 * time.Minute
+
+// parseRetryAfter returns the delay from a Retry-After header in either
+// delay-seconds or HTTP-date form, capped at maxRetryAfter, or 0 if absent
+// or invalid
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	var delay time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil {
+		delay = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(value); err == nil {
+		delay = date.Sub(now)
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return min(delay, maxRetryAfter)
+}
+
+// retryAfter returns the Retry-After delay carried by err, if any
+func retryAfter(err error) time.Duration {
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.RetryAfter
+	}
+	return 0
+}
+
 // For compatibility with main.go (single send, not used in batch mode)
 func (s *SignalSender) Send(sig models.Signal) error {
-	sig.Redact()
+	sig, ok := s.Prepare(sig)
+	if !ok {
+		return nil
+	}
 	return s.SendBatchCompat([]models.Signal{sig})
 }
 
-func (s *SignalSender) SendBatchCompat(signals []models.Signal) error {
-	body, err := json.Marshal(signals)
-	if err != nil {
-		return err
+// Export sends signals to the backend in batches with retries, failover and
+// dead-lettering, implementing export.SignalExporter. The observer itself
+// queues signals for Start instead, which batches across calls.
+func (s *SignalSender) Export(ctx context.Context, signals []models.Signal) error {
+	prepared := make([]models.Signal, 0, len(signals))
+	for _, sig := range signals {
+		if sig, ok := s.Prepare(sig); ok {
+			prepared = append(prepared, sig)
+		}
 	}
-	req, err := http.NewRequest("POST", s.url, bytes.NewReader(body))
-	if err != nil {
-		return err
+	if len(prepared) == 0 {
+		return nil
+	}
+	_, err := s.sendBatches(ctx, prepared)
+	return err
+}
+
+// BatchSize returns the number of signals sent per batch
+func (s *SignalSender) BatchSize() int {
+	return s.batchSize
+}
+
+// SendBatches sends signals in batches of the configured size, each with
+// the retry, backoff and failover of the sender's own batches, and returns
+// how many signals were accepted along with the last batch error. Failed
+// batches are dead-lettered when AXOM_DLQ_PATH is set. Signals are redacted
+// on a copy, leaving the caller's untouched.
+func (s *SignalSender) SendBatches(ctx context.Context, signals []models.Signal) (int, error) {
+	redacted := make([]models.Signal, len(signals))
+	for i, sig := range signals {
+		redacted[i] = redactedCopy(sig, s.redactPII)
+	}
+	return s.sendBatches(ctx, redacted)
+}
+
+// sendBatches sends already redacted signals the way SendBatches does
+func (s *SignalSender) sendBatches(ctx context.Context, signals []models.Signal) (int, error) {
+	var sent int
+	var lastErr error
+	for start := 0; start < len(signals); start += s.batchSize {
+		batch := signals[start:min(start+s.batchSize, len(signals))]
+		if err := s.sendBatchWithRetry(ctx, batch); err != nil {
+			lastErr = err
+			continue
+		}
+		sent += len(batch)
 	}
+	return sent, lastErr
+}
+
+// setBatchHeaders sets the authentication and content headers of a batch
+// request, signing the body if enabled
+func (s *SignalSender) setBatchHeaders(req *http.Request, body []byte) {
 	req.Header.Set("Authorization", "Bearer "+s.apiKey)
 	req.Header.Set("X-Client-ID", os.Getenv("CLIENT_ID"))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Axom-Schema-Version", models.SignalSchemaVersion)
+	if s.signBatches {
+		signBatch(req, s.apiKey, body)
+	}
+}
+
+// SendBatchCompat sends signals in a single attempt per backend URL, failing
+// over to the next URL on a network error, 429 or 5xx
+func (s *SignalSender) SendBatchCompat(signals []models.Signal) error {
+	body, count := s.encodeBatch(signals)
+	if count == 0 {
+		return errNoEncodableSignals
+	}
+	var err error
+	for i := 0; i < max(s.endpoints.count(), 1); i++ {
+		var retry bool
+		if err, retry = s.sendCompatOnce(s.endpoints.current(), body); !retry {
+			return err
+		}
+	}
+	return err
+}
+
+// sendCompatOnce posts body to url and reports whether another URL should
+// be tried
+func (s *SignalSender) sendCompatOnce(url string, body []byte) (error, bool) {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err, false
+	}
+	s.setBatchHeaders(req, body)
 	resp, err := s.client.Do(req)
 	if err != nil {
-		return err
+		s.endpoints.failure(url)
+		return err, true
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode == 429 || resp.StatusCode >= 500 {
+		s.endpoints.failure(url)
+		return &httpStatusError{StatusCode: resp.StatusCode}, true
+	}
+	s.endpoints.success(url)
 	if resp.StatusCode >= 300 {
-		return &httpStatusError{StatusCode: resp.StatusCode}
+		return &httpStatusError{StatusCode: resp.StatusCode}, false
 	}
-	return nil
+	return nil, false
 }
 
+// errNoEncodableSignals is returned when every signal in a batch failed to marshal
+var errNoEncodableSignals = errors.New("no signals could be encoded")
+
+// errBreakerOpen is returned for a batch not sent because the circuit breaker
+// is open
+var errBreakerOpen = errors.New("circuit breaker open")
+
 type httpStatusError struct {
 	StatusCode int
+	// RetryAfter is the delay the backend asked for on a 429 or 5xx
+	RetryAfter time.Duration
 }
 
 func (e *httpStatusError) Error() string {
 	return "HTTP error: " + http.StatusText(e.StatusCode)
 }
+
+// sanitizeMap returns a copy of m with values that encoding/json cannot
+// represent (NaN/Inf floats, channels, funcs, complex numbers) replaced by nil
+func sanitizeMap(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = sanitizeValue(v)
+	}
+	return out
+}
+
+// sanitizeValue recursively sanitizes a single metadata value
+func sanitizeValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case nil:
+		return nil
+	case float64:
+		if math.IsNaN(val) || math.IsInf(val, 0) {
+			return nil
+		}
+		return val
+	case float32:
+		if math.IsNaN(float64(val)) || math.IsInf(float64(val), 0) {
+			return nil
+		}
+		return val
+	case map[string]interface{}:
+		return sanitizeMap(val)
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = sanitizeValue(item)
+		}
+		return out
+	}
+	switch reflect.TypeOf(v).Kind() {
+	case reflect.Chan, reflect.Func, reflect.Complex64, reflect.Complex128, reflect.UnsafePointer:
+		return nil
+	}
+	return v
+}
@@ -0,0 +1,269 @@
+package observer
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"axom-observer/pkg/models"
+)
+
+// testBackend is an httptest ingest server recording the batches it accepts
+type testBackend struct {
+	*httptest.Server
+	mu      sync.Mutex
+	batches [][]models.Signal
+	headers []http.Header
+}
+
+// newTestBackend starts a backend answering each batch with status(), or 200
+// if status is nil. It is closed when the test ends.
+func newTestBackend(t *testing.T, status func() int) *testBackend {
+	t.Helper()
+	b := &testBackend{}
+	b.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			return
+		}
+		code := http.StatusOK
+		if status != nil {
+			code = status()
+		}
+		if code == http.StatusOK {
+			body, _ := io.ReadAll(r.Body)
+			var batch []models.Signal
+			if err := json.Unmarshal(body, &batch); err != nil {
+				t.Errorf("backend received invalid batch: %v", err)
+			}
+			b.mu.Lock()
+			b.batches = append(b.batches, batch)
+			b.headers = append(b.headers, r.Header.Clone())
+			b.mu.Unlock()
+		}
+		w.WriteHeader(code)
+	}))
+	t.Cleanup(b.Close)
+	return b
+}
+
+// received returns every signal the backend has accepted
+func (b *testBackend) received() []models.Signal {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var signals []models.Signal
+	for _, batch := range b.batches {
+		signals = append(signals, batch...)
+	}
+	return signals
+}
+
+// testSignal returns a minimal signal with the given ID and metadata
+func testSignal(id string, metadata map[string]interface{}) models.Signal {
+	return models.Signal{
+		ID:        id,
+		Timestamp: time.Now(),
+		Protocol:  "http",
+		Operation: "chat_completion",
+		Status:    http.StatusOK,
+		Metadata:  metadata,
+	}
+}
+
+func TestEncodeBatchSanitizesUnmarshalableSignal(t *testing.T) {
+	backend := newTestBackend(t, nil)
+	s := NewSignalSender("test-key", backend.URL, 10, time.Hour)
+
+	signals := []models.Signal{
+		testSignal("sig-1", map[string]interface{}{"score": 0.5}),
+		testSignal("sig-2", map[string]interface{}{"score": math.NaN()}),
+		testSignal("sig-3", map[string]interface{}{"score": 1.5}),
+	}
+	if err := s.sendBatchWithRetry(context.Background(), signals); err != nil {
+		t.Fatalf("sendBatchWithRetry: %v", err)
+	}
+
+	got := backend.received()
+	if len(got) != 3 {
+		t.Fatalf("backend received %d signals, want 3", len(got))
+	}
+	if got[1].ID != "sig-2" || got[1].Metadata["score"] != nil {
+		t.Errorf("NaN signal = %s %v, want sig-2 with a null score", got[1].ID, got[1].Metadata["score"])
+	}
+	if got[2].Metadata["score"] != 1.5 {
+		t.Errorf("sig-3 score = %v, want 1.5", got[2].Metadata["score"])
+	}
+	if !math.IsNaN(signals[1].Metadata["score"].(float64)) {
+		t.Errorf("sanitizing changed the caller's signal")
+	}
+}
+
+func TestEncodeBatchDropsUnmarshalableSignalWithoutSanitizing(t *testing.T) {
+	t.Setenv("AXOM_SANITIZE_SIGNALS", "0")
+	backend := newTestBackend(t, nil)
+	s := NewSignalSender("test-key", backend.URL, 10, time.Hour)
+
+	signals := []models.Signal{
+		testSignal("sig-1", map[string]interface{}{"score": 0.5}),
+		testSignal("sig-2", map[string]interface{}{"score": math.Inf(1)}),
+		testSignal("sig-3", map[string]interface{}{"ch": make(chan int)}),
+		testSignal("sig-4", map[string]interface{}{"score": 1.5}),
+	}
+	if err := s.sendBatchWithRetry(context.Background(), signals); err != nil {
+		t.Fatalf("sendBatchWithRetry: %v", err)
+	}
+
+	got := backend.received()
+	if len(got) != 2 || got[0].ID != "sig-1" || got[1].ID != "sig-4" {
+		t.Fatalf("backend received %+v, want sig-1 and sig-4", got)
+	}
+}
+
+func TestEncodeBatchWithNoEncodableSignals(t *testing.T) {
+	t.Setenv("AXOM_SANITIZE_SIGNALS", "0")
+	s := NewSignalSender("test-key", "http://127.0.0.1:0", 10, time.Hour)
+	err := s.sendBatchWithRetry(context.Background(), []models.Signal{
+		testSignal("sig-1", map[string]interface{}{"score": math.NaN()}),
+	})
+	if err != errNoEncodableSignals {
+		t.Errorf("sendBatchWithRetry = %v, want errNoEncodableSignals", err)
+	}
+}
+
+// waitForSignals polls until the backend has accepted n signals
+func waitForSignals(t *testing.T, b *testBackend, n int, timeout time.Duration) []models.Signal {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if got := b.received(); len(got) >= n {
+			return got
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("backend received %d signals, want %d", len(b.received()), n)
+	return nil
+}
+
+func TestStartupGraceRidesOutUnavailableBackend(t *testing.T) {
+	t.Setenv("AXOM_BREAKER_THRESHOLD", "1")
+	healthyAt := time.Now().Add(time.Second)
+	backend := newTestBackend(t, func() int {
+		if time.Now().Before(healthyAt) {
+			return http.StatusServiceUnavailable
+		}
+		return http.StatusOK
+	})
+	s := NewSignalSender("test-key", backend.URL, 10, 50*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := make(chan models.Signal, 1)
+	ch <- testSignal("sig-1", nil)
+	go s.Start(ctx, ch)
+
+	got := waitForSignals(t, backend, 1, 10*time.Second)
+	if got[0].ID != "sig-1" {
+		t.Errorf("backend received %s, want sig-1", got[0].ID)
+	}
+	if state := s.breaker.currentState(); state != breakerClosed {
+		t.Errorf("breaker %s after the backend recovered within the grace period, want closed", state)
+	}
+}
+
+func TestStartupGraceFailuresDoNotOpenBreaker(t *testing.T) {
+	t.Setenv("AXOM_BREAKER_THRESHOLD", "1")
+	healthyAt := time.Now().Add(time.Second)
+	backend := newTestBackend(t, func() int {
+		if time.Now().Before(healthyAt) {
+			return http.StatusServiceUnavailable
+		}
+		return http.StatusOK
+	})
+	s := NewSignalSender("test-key", backend.URL, 10, time.Hour)
+	s.startedAt = time.Now()
+
+	if err := s.sendBatchWithRetry(context.Background(), []models.Signal{testSignal("sig-1", nil)}); err != nil {
+		t.Fatalf("sendBatchWithRetry: %v", err)
+	}
+	if state := s.breaker.currentState(); state != breakerClosed {
+		t.Errorf("breaker %s after failures during startup grace, want closed", state)
+	}
+	if got := backend.received(); len(got) != 1 {
+		t.Errorf("backend received %d signals, want 1", len(got))
+	}
+}
+
+func TestPrepareRedactsPIIOnACopy(t *testing.T) {
+	t.Setenv("AXOM_REDACT_PII", "1")
+	s := NewSignalSender("test-key", "http://127.0.0.1:0", 10, time.Hour)
+
+	original := testSignal("sig-1", map[string]interface{}{"prompt_preview": "Email me at amy@example.com"})
+	prepared, ok := s.Prepare(original)
+	if !ok {
+		t.Fatalf("Prepare dropped the signal")
+	}
+	if got := prepared.Metadata["prompt_preview"]; got != "Email me at [EMAIL]" {
+		t.Errorf("prepared prompt_preview = %q, want the email masked", got)
+	}
+	if got := original.Metadata["prompt_preview"]; got != "Email me at amy@example.com" {
+		t.Errorf("original prompt_preview = %q, want it untouched", got)
+	}
+}
+
+// slowBackend returns a backend that holds every request until the client
+// gives up on it
+func slowBackend(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The server only notices the client going away once the body is read
+		io.Copy(io.Discard, r.Body)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(10 * time.Second):
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestSendBatchOnceTimesOutPerAttempt(t *testing.T) {
+	t.Setenv("AXOM_REQUEST_TIMEOUT", "1")
+	backend := slowBackend(t)
+	s := NewSignalSender("test-key", backend.URL, 10, time.Hour)
+	if s.reqTimeout != time.Second {
+		t.Fatalf("reqTimeout = %v, want AXOM_REQUEST_TIMEOUT's 1s", s.reqTimeout)
+	}
+
+	body, count := s.encodeBatch([]models.Signal{testSignal("sig-1", nil)})
+	start := time.Now()
+	err, retry, _ := s.sendBatchOnce(context.Background(), body, count)
+	elapsed := time.Since(start)
+
+	if err == nil || !retry {
+		t.Fatalf("sendBatchOnce = %v, retry %v; want a retryable timeout", err, retry)
+	}
+	if elapsed < 900*time.Millisecond || elapsed > 3*time.Second {
+		t.Errorf("attempt gave up after %v, want about the 1s attempt timeout", elapsed)
+	}
+}
+
+func TestSendBatchWithRetryStopsOnCancel(t *testing.T) {
+	t.Setenv("AXOM_REQUEST_TIMEOUT", "1")
+	backend := slowBackend(t)
+	s := NewSignalSender("test-key", backend.URL, 10, time.Hour)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := s.sendBatchWithRetry(ctx, []models.Signal{testSignal("sig-1", nil)}); err == nil {
+		t.Fatalf("sendBatchWithRetry succeeded against a backend that never answers")
+	}
+	if elapsed := time.Since(start); elapsed > 900*time.Millisecond {
+		t.Errorf("retrying continued for %v after the context was cancelled", elapsed)
+	}
+}
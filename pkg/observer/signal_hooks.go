@@ -0,0 +1,26 @@
+package observer
+
+import "axom-observer/pkg/models"
+
+// SignalHook inspects or modifies a signal before it is sent, e.g. to add
+// tenant tags or computed fields. Returning false drops the signal.
+type SignalHook func(sig *models.Signal) bool
+
+// OnSignal registers a hook run on every signal, in registration order,
+// before it is redacted and batched. Hooks must be registered before the
+// sender is started or used.
+func (s *SignalSender) OnSignal(hook SignalHook) {
+	s.hooks = append(s.hooks, hook)
+}
+
+// applyHooks runs the registered hooks on sig, reporting whether it should
+// still be sent. Hooks after one that drops the signal are not run.
+func (s *SignalSender) applyHooks(sig *models.Signal) bool {
+	for _, hook := range s.hooks {
+		if !hook(sig) {
+			eachMetrics(func(m *observerMetrics) { m.signalsHookDropped.Inc() })
+			return false
+		}
+	}
+	return true
+}
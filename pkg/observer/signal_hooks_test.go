@@ -0,0 +1,111 @@
+package observer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"axom-observer/pkg/models"
+)
+
+// startSender runs s.Start on a new channel until the test ends
+func startSender(t *testing.T, s *SignalSender) chan<- models.Signal {
+	t.Helper()
+	ch := make(chan models.Signal, 16)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Start(ctx, ch)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return ch
+}
+
+func TestEnrichingHook(t *testing.T) {
+	t.Setenv("AXOM_STARTUP_GRACE", "0")
+	backend := newTestBackend(t, nil)
+	s := NewSignalSender("test-key", backend.URL, 2, time.Hour)
+	s.OnSignal(func(sig *models.Signal) bool {
+		sig.Metadata["tenant"] = "acme"
+		return true
+	})
+	s.OnSignal(func(sig *models.Signal) bool {
+		// Hooks run in order, so this one sees the first's change
+		sig.Metadata["tenant_region"] = sig.Metadata["tenant"].(string) + "-eu"
+		return true
+	})
+	ch := startSender(t, s)
+
+	original := testSignal("sig-1", map[string]interface{}{"model": "gpt-4"})
+	ch <- original
+	ch <- testSignal("sig-2", map[string]interface{}{"model": "gpt-4"})
+
+	got := waitForSignals(t, backend, 2, 5*time.Second)
+	for _, sig := range got {
+		if sig.Metadata["tenant"] != "acme" || sig.Metadata["tenant_region"] != "acme-eu" {
+			t.Errorf("signal %s metadata = %v, want the hooks' tenant fields", sig.ID, sig.Metadata)
+		}
+	}
+	if _, ok := original.Metadata["tenant"]; ok {
+		t.Errorf("hook changed the caller's signal, want it run on a copy")
+	}
+}
+
+func TestDroppingHook(t *testing.T) {
+	t.Setenv("AXOM_STARTUP_GRACE", "0")
+	reg := newTestRegistry(t)
+	backend := newTestBackend(t, nil)
+	s := NewSignalSender("test-key", backend.URL, 2, time.Hour)
+	ran := 0
+	s.OnSignal(func(sig *models.Signal) bool {
+		return sig.Operation != "health_check"
+	})
+	s.OnSignal(func(sig *models.Signal) bool {
+		ran++
+		return true
+	})
+	ch := startSender(t, s)
+
+	dropped := testSignal("sig-1", nil)
+	dropped.Operation = "health_check"
+	ch <- dropped
+	ch <- testSignal("sig-2", nil)
+	ch <- testSignal("sig-3", nil)
+
+	got := waitForSignals(t, backend, 2, 5*time.Second)
+	for _, sig := range got {
+		if sig.ID == "sig-1" {
+			t.Errorf("backend received the dropped signal")
+		}
+	}
+	if got := metricValue(t, reg, "axom_signals_hook_dropped_total", nil); got != 1 {
+		t.Errorf("axom_signals_hook_dropped_total = %v, want 1", got)
+	}
+	if ran != 2 {
+		t.Errorf("hook after the dropping one ran %d times, want 2 (not for the dropped signal)", ran)
+	}
+}
+
+func TestPrepareRunsHooks(t *testing.T) {
+	s := NewSignalSender("test-key", "https://backend.example.com", 10, time.Hour)
+	s.OnSignal(func(sig *models.Signal) bool {
+		sig.Metadata["api_key"] = "sk-secret-from-hook"
+		return sig.ID != "drop-me"
+	})
+
+	prepared, ok := s.Prepare(testSignal("sig-1", map[string]interface{}{}))
+	if !ok {
+		t.Fatalf("Prepare dropped a signal the hook kept")
+	}
+	// Redaction runs after the hooks
+	if prepared.Metadata["api_key"] == "sk-secret-from-hook" {
+		t.Errorf("api_key added by a hook was not redacted")
+	}
+	if _, ok := s.Prepare(testSignal("drop-me", map[string]interface{}{})); ok {
+		t.Errorf("Prepare kept a signal the hook dropped")
+	}
+}
@@ -0,0 +1,57 @@
+package observer
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"axom-observer/pkg/models"
+)
+
+// Environment variables:
+//   AXOM_PROXY_BLOCK_ON_FULL    - Optional. Set to "1" to wait for room in the signal channel instead of dropping. Default: disabled
+//   AXOM_PROXY_BLOCK_TIMEOUT_MS - Optional. Maximum time in milliseconds to wait when blocking. Default: 500
+
+// signalEnqueuer hands captured signals to the signal channel, either
+// dropping immediately when it is full or waiting up to a timeout
+type signalEnqueuer struct {
+	block   bool
+	timeout time.Duration
+}
+
+// signalEnqueuerFromEnv reads the backpressure mode from the environment
+func signalEnqueuerFromEnv() signalEnqueuer {
+	q := signalEnqueuer{
+		block:   os.Getenv("AXOM_PROXY_BLOCK_ON_FULL") == "1",
+		timeout: 500 * time.Millisecond,
+	}
+	if v := os.Getenv("AXOM_PROXY_BLOCK_TIMEOUT_MS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			q.timeout = time.Duration(n) * time.Millisecond
+		}
+	}
+	return q
+}
+
+// send queues signal on ch, returning false (and counting the drop against
+// provider) if the channel stayed full
+func (q signalEnqueuer) send(ch chan<- models.Signal, signal models.Signal, provider string) bool {
+	select {
+	case ch <- signal:
+		return true
+	default:
+	}
+
+	if q.block {
+		timer := time.NewTimer(q.timeout)
+		defer timer.Stop()
+		select {
+		case ch <- signal:
+			return true
+		case <-timer.C:
+		}
+	}
+
+	eachMetrics(func(m *observerMetrics) { m.proxySignalsDropped.WithLabelValues(provider).Inc() })
+	return false
+}
@@ -0,0 +1,74 @@
+package observer
+
+import (
+	"testing"
+	"time"
+
+	"axom-observer/pkg/models"
+)
+
+// fullSignalChannel returns a signal channel with no room left
+func fullSignalChannel() chan models.Signal {
+	ch := make(chan models.Signal, 1)
+	ch <- models.Signal{ID: "queued"}
+	return ch
+}
+
+func TestSignalEnqueuerDropsWhenFull(t *testing.T) {
+	reg := newTestRegistry(t)
+	q := signalEnqueuerFromEnv()
+	if q.block {
+		t.Fatalf("enqueuer blocks without AXOM_PROXY_BLOCK_ON_FULL, want it to drop")
+	}
+
+	start := time.Now()
+	if q.send(fullSignalChannel(), models.Signal{ID: "sig-1"}, "OpenAI") {
+		t.Errorf("send to a full channel = true, want the signal dropped")
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("dropping took %v, want no wait", elapsed)
+	}
+	if got := metricValue(t, reg, "axom_proxy_signals_dropped_total", map[string]string{"provider": "OpenAI"}); got != 1 {
+		t.Errorf("axom_proxy_signals_dropped_total = %v, want 1", got)
+	}
+}
+
+func TestSignalEnqueuerBlockTimeout(t *testing.T) {
+	t.Setenv("AXOM_PROXY_BLOCK_ON_FULL", "1")
+	t.Setenv("AXOM_PROXY_BLOCK_TIMEOUT_MS", "50")
+	reg := newTestRegistry(t)
+	q := signalEnqueuerFromEnv()
+
+	start := time.Now()
+	if q.send(fullSignalChannel(), models.Signal{ID: "sig-1"}, "Anthropic") {
+		t.Errorf("send to a channel that stayed full = true, want the signal dropped")
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("dropped after %v, want a wait of the 50ms timeout", elapsed)
+	}
+	if got := metricValue(t, reg, "axom_proxy_signals_dropped_total", map[string]string{"provider": "Anthropic"}); got != 1 {
+		t.Errorf("axom_proxy_signals_dropped_total = %v, want 1", got)
+	}
+}
+
+func TestSignalEnqueuerBlockUntilRoom(t *testing.T) {
+	t.Setenv("AXOM_PROXY_BLOCK_ON_FULL", "1")
+	t.Setenv("AXOM_PROXY_BLOCK_TIMEOUT_MS", "5000")
+	reg := newTestRegistry(t)
+	q := signalEnqueuerFromEnv()
+
+	ch := fullSignalChannel()
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		<-ch
+	}()
+	if !q.send(ch, models.Signal{ID: "sig-1"}, "OpenAI") {
+		t.Fatalf("send = false, want it queued once the channel has room")
+	}
+	if got := (<-ch).ID; got != "sig-1" {
+		t.Errorf("queued signal %q, want sig-1", got)
+	}
+	if got := metricValue(t, reg, "axom_proxy_signals_dropped_total", map[string]string{"provider": "OpenAI"}); got != 0 {
+		t.Errorf("axom_proxy_signals_dropped_total = %v, want no drops", got)
+	}
+}
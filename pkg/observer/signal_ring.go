@@ -0,0 +1,137 @@
+package observer
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+
+	"axom-observer/pkg/models"
+)
+
+// Environment variables:
+//   AXOM_DEBUG_SIGNALS - Optional. Number of recent signals kept for the /debug/signals view; 0 disables. Default: 100
+
+// SignalRing keeps the most recent signals, redacted as they would be sent,
+// for in-process debugging. It implements export.SignalExporter so it can be
+// fed alongside the other exporters, and http.Handler to serve the retained
+// signals as JSON, oldest first. Memory is bounded by the fixed capacity.
+type SignalRing struct {
+	logger    *log.Logger
+	redactPII bool
+
+	mu      sync.Mutex
+	signals []models.Signal // fixed-size ring
+	next    int             // slot the next signal is written to
+	full    bool            // whether every slot holds a signal
+}
+
+// NewSignalRing creates a ring holding the last size signals. If size is 0
+// it is read from AXOM_DEBUG_SIGNALS (default 100); a ring of size 0 keeps
+// nothing.
+func NewSignalRing(size int, logger *log.Logger) *SignalRing {
+	if size == 0 {
+		size = 100
+		if v := os.Getenv("AXOM_DEBUG_SIGNALS"); v != "" {
+			if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+				size = n
+			}
+		}
+	}
+	if size < 0 {
+		size = 0
+	}
+	return &SignalRing{
+		logger:    logger,
+		redactPII: os.Getenv("AXOM_REDACT_PII") == "1",
+		signals:   make([]models.Signal, size),
+	}
+}
+
+// Export records redacted copies of signals, overwriting the oldest once
+// the ring is full
+func (r *SignalRing) Export(ctx context.Context, signals []models.Signal) error {
+	if len(r.signals) == 0 {
+		return nil
+	}
+	for _, sig := range signals {
+		sig = redactedCopy(sig, r.redactPII)
+
+		r.mu.Lock()
+		r.signals[r.next] = sig
+		r.next = (r.next + 1) % len(r.signals)
+		if r.next == 0 {
+			r.full = true
+		}
+		r.mu.Unlock()
+	}
+	return nil
+}
+
+// Snapshot returns the retained signals, oldest first
+func (r *SignalRing) Snapshot() []models.Signal {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.full {
+		return append([]models.Signal(nil), r.signals[:r.next]...)
+	}
+	snapshot := make([]models.Signal, 0, len(r.signals))
+	snapshot = append(snapshot, r.signals[r.next:]...)
+	return append(snapshot, r.signals[:r.next]...)
+}
+
+// ServeHTTP serves the retained signals as a JSON array, oldest first
+func (r *SignalRing) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(r.Snapshot()); err != nil {
+		r.logger.Printf("Failed to write debug signals: %v", err)
+	}
+}
+
+// redactedCopy returns sig with its metadata copied and then redacted the
+// way SignalSender redacts before sending. The copy leaves the caller's
+// signal, which other exporters share, untouched.
+func redactedCopy(sig models.Signal, redactPII bool) models.Signal {
+	sig.Metadata = copyMap(sig.Metadata)
+	sig.OutcomeData = copyMap(sig.OutcomeData)
+	sig.Redact("authorization", "api_key")
+	if redactPII {
+		sig.RedactPII()
+	}
+	return sig
+}
+
+// copyMap deep-copies the maps and slices within m, so redacting the copy
+// in place can't reach the original
+func copyMap(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	c := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		c[k] = copyValue(v)
+	}
+	return c
+}
+
+func copyValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		return copyMap(val)
+	case []interface{}:
+		c := make([]interface{}, len(val))
+		for i, item := range val {
+			c[i] = copyValue(item)
+		}
+		return c
+	default:
+		return v
+	}
+}
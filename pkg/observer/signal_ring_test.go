@@ -0,0 +1,99 @@
+package observer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"axom-observer/pkg/models"
+)
+
+func TestSignalRingKeepsLatest(t *testing.T) {
+	r := NewSignalRing(3, testLogger())
+	if got := r.Snapshot(); len(got) != 0 {
+		t.Fatalf("empty ring holds %d signals", len(got))
+	}
+	for i := 1; i <= 5; i++ {
+		r.Export(context.Background(), []models.Signal{testSignal(fmt.Sprintf("sig-%d", i), nil)})
+	}
+
+	got := r.Snapshot()
+	var ids []string
+	for _, sig := range got {
+		ids = append(ids, sig.ID)
+	}
+	if fmt.Sprint(ids) != "[sig-3 sig-4 sig-5]" {
+		t.Errorf("retained %v, want the latest 3 oldest first", ids)
+	}
+}
+
+func TestSignalRingSize(t *testing.T) {
+	if got := len(NewSignalRing(0, testLogger()).signals); got != 100 {
+		t.Errorf("default size = %d, want 100", got)
+	}
+	t.Setenv("AXOM_DEBUG_SIGNALS", "7")
+	if got := len(NewSignalRing(0, testLogger()).signals); got != 7 {
+		t.Errorf("AXOM_DEBUG_SIGNALS=7 size = %d, want 7", got)
+	}
+	// A disabled ring keeps nothing
+	t.Setenv("AXOM_DEBUG_SIGNALS", "0")
+	r := NewSignalRing(0, testLogger())
+	r.Export(context.Background(), []models.Signal{testSignal("sig-1", nil)})
+	if got := r.Snapshot(); len(got) != 0 {
+		t.Errorf("disabled ring holds %d signals", len(got))
+	}
+}
+
+func TestSignalRingConcurrentExport(t *testing.T) {
+	r := NewSignalRing(10, testLogger())
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				r.Export(context.Background(), []models.Signal{testSignal(fmt.Sprintf("sig-%d-%d", i, j), nil)})
+				r.Snapshot()
+			}
+		}(i)
+	}
+	wg.Wait()
+	if got := r.Snapshot(); len(got) != 10 {
+		t.Errorf("ring holds %d signals, want 10", len(got))
+	}
+}
+
+func TestDebugSignalsRedacted(t *testing.T) {
+	r := NewSignalRing(5, testLogger())
+	original := testSignal("sig-1", map[string]interface{}{
+		"api_key": "sk-live-secret",
+		"model":   "gpt-4",
+	})
+	r.Export(context.Background(), []models.Signal{original})
+	if original.Metadata["api_key"] != "sk-live-secret" {
+		t.Errorf("ring redacted the caller's signal, want a copy")
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/signals", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("/debug/signals = %d %s, want 200 JSON", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	var got []models.Signal
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode /debug/signals: %v", err)
+	}
+	if len(got) != 1 || got[0].Metadata["api_key"] != "[REDACTED]" || got[0].Metadata["model"] != "gpt-4" {
+		t.Errorf("/debug/signals = %+v, want the signal with api_key redacted", got)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/debug/signals", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /debug/signals = %d, want 405", rec.Code)
+	}
+}
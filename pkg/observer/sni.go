@@ -0,0 +1,91 @@
+package observer
+
+import "encoding/binary"
+
+// parseClientHelloSNI returns the server name from a TLS ClientHello at the
+// start of data. complete is false while more data is needed to decide;
+// once true, an empty name means the data is not a ClientHello or carries
+// no SNI.
+func parseClientHelloSNI(data []byte) (sni string, complete bool) {
+	// Record header: type (22 = handshake), version, length
+	if len(data) < 5 {
+		return "", false
+	}
+	if data[0] != 22 {
+		return "", true
+	}
+	recordLen := int(binary.BigEndian.Uint16(data[3:5]))
+	if len(data) < 5+recordLen {
+		return "", false
+	}
+	msg := data[5 : 5+recordLen]
+
+	// Handshake header: type (1 = ClientHello), 24-bit length
+	if len(msg) < 4 || msg[0] != 1 {
+		return "", true
+	}
+	helloLen := int(msg[1])<<16 | int(msg[2])<<8 | int(msg[3])
+	if len(msg) < 4+helloLen {
+		// The hello spans several records; not worth reassembling
+		return "", true
+	}
+	hello := msg[4 : 4+helloLen]
+
+	// client_version and random
+	pos := 2 + 32
+	// session_id
+	if pos+1 > len(hello) {
+		return "", true
+	}
+	pos += 1 + int(hello[pos])
+	// cipher_suites
+	if pos+2 > len(hello) {
+		return "", true
+	}
+	pos += 2 + int(binary.BigEndian.Uint16(hello[pos:]))
+	// compression_methods
+	if pos+1 > len(hello) {
+		return "", true
+	}
+	pos += 1 + int(hello[pos])
+	// extensions
+	if pos+2 > len(hello) {
+		return "", true
+	}
+	extEnd := pos + 2 + int(binary.BigEndian.Uint16(hello[pos:]))
+	pos += 2
+	if extEnd > len(hello) {
+		return "", true
+	}
+	for pos+4 <= extEnd {
+		extType := binary.BigEndian.Uint16(hello[pos:])
+		extLen := int(binary.BigEndian.Uint16(hello[pos+2:]))
+		pos += 4
+		if pos+extLen > extEnd {
+			return "", true
+		}
+		if extType != 0 { // server_name
+			pos += extLen
+			continue
+		}
+		ext := hello[pos : pos+extLen]
+		// server_name_list: length, then entries of type, length, name
+		if len(ext) < 2 {
+			return "", true
+		}
+		list := ext[2:]
+		for len(list) >= 3 {
+			nameType := list[0]
+			nameLen := int(binary.BigEndian.Uint16(list[1:3]))
+			if 3+nameLen > len(list) {
+				return "", true
+			}
+			if nameType == 0 { // host_name
+				return string(list[3 : 3+nameLen]), true
+			}
+			list = list[3+nameLen:]
+		}
+		return "", true
+	}
+	return "", true
+}
@@ -0,0 +1,99 @@
+package observer
+
+import (
+	"crypto/tls"
+	"net"
+	"testing"
+	"time"
+)
+
+// clientHello returns the first flight a TLS client sends for serverName
+func clientHello(t testing.TB, serverName string) []byte {
+	t.Helper()
+	client, server := net.Pipe()
+	defer server.Close()
+	go func() {
+		tls.Client(client, &tls.Config{ServerName: serverName, InsecureSkipVerify: true}).Handshake()
+		client.Close()
+	}()
+
+	buf := make([]byte, maxClientHelloBytes)
+	server.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := server.Read(buf)
+	if err != nil {
+		t.Fatalf("read ClientHello: %v", err)
+	}
+	return buf[:n]
+}
+
+func TestParseClientHelloSNI(t *testing.T) {
+	hello := clientHello(t, "api.anthropic.com")
+
+	if sni, complete := parseClientHelloSNI(hello); sni != "api.anthropic.com" || !complete {
+		t.Errorf("parseClientHelloSNI = %q, %v, want api.anthropic.com", sni, complete)
+	}
+	if sni, complete := parseClientHelloSNI(hello[:len(hello)/2]); sni != "" || complete {
+		t.Errorf("partial ClientHello = %q, %v, want more data needed", sni, complete)
+	}
+	if sni, complete := parseClientHelloSNI([]byte("GET / HTTP/1.1\r\n")); sni != "" || !complete {
+		t.Errorf("plain HTTP = %q, %v, want no SNI", sni, complete)
+	}
+	if sni, complete := parseClientHelloSNI(clientHello(t, "")); sni != "" || !complete {
+		t.Errorf("ClientHello without SNI = %q, %v, want no SNI", sni, complete)
+	}
+}
+
+func TestDetectProviderBySNI(t *testing.T) {
+	t.Setenv("AXOM_INTERCEPT_HOSTS", "")
+	p := NewProductionProxy("0", nil, testLogger(), "test-customer", "test-agent")
+
+	tests := []struct {
+		sni, addr, want string
+	}{
+		{"api.anthropic.com", "104.18.6.192:443", "Anthropic"},
+		{"api.openai.com", "api.openai.com:443", "OpenAI"},
+		{"", "api.openai.com:443", "OpenAI"},
+		{"www.example.com", "93.184.216.34:443", ""},
+	}
+	for _, tt := range tests {
+		got := ""
+		if provider := p.detectProviderBySNI(tt.sni, tt.addr); provider != nil {
+			got = provider.Name
+		}
+		if got != tt.want {
+			t.Errorf("detectProviderBySNI(%q, %q) = %q, want %q", tt.sni, tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestTunnelConnReportsSNI(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			if _, err := server.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+
+	stats := make(chan tunnelStats, 1)
+	conn := &tunnelConn{Conn: client, start: time.Now(), onClose: func(s tunnelStats) { stats <- s }}
+	hello := clientHello(t, "generativelanguage.googleapis.com")
+	// The hello may arrive split across writes
+	conn.Write(hello[:10])
+	conn.Write(hello[10:])
+	conn.Close()
+	conn.Close()
+
+	got := <-stats
+	if got.sni != "generativelanguage.googleapis.com" || got.bytesSent != int64(len(hello)) {
+		t.Errorf("tunnel stats = %+v, want the SNI and %d bytes sent", got, len(hello))
+	}
+	select {
+	case <-stats:
+		t.Errorf("stats reported more than once")
+	default:
+	}
+}
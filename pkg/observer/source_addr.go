@@ -0,0 +1,74 @@
+package observer
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"axom-observer/pkg/models"
+)
+
+// endpointFromAddr converts a "host:port" address such as
+// http.Request.RemoteAddr into a signal endpoint. IPv6 hosts are unbracketed.
+// An address without a port is kept whole as the IP; an empty address gives
+// an empty endpoint.
+func endpointFromAddr(addr string) models.Endpoint {
+	if addr == "" {
+		return models.Endpoint{}
+	}
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return models.Endpoint{IP: addr}
+	}
+	port, _ := strconv.Atoi(portStr)
+	return models.Endpoint{IP: host, Port: port}
+}
+
+// hostEndpoint converts a "host[:port]" authority into a signal endpoint.
+// An IP literal goes in IP and a name, normalized, in Hostname; defaultPort
+// is used when the authority has no port.
+func hostEndpoint(hostport string, defaultPort int) models.Endpoint {
+	host, port := strings.TrimSuffix(strings.TrimPrefix(hostport, "["), "]"), defaultPort
+	if h, portStr, err := net.SplitHostPort(hostport); err == nil {
+		host = h
+		if n, err := strconv.Atoi(portStr); err == nil {
+			port = n
+		}
+	}
+	if host == "" {
+		return models.Endpoint{}
+	}
+	if net.ParseIP(host) != nil {
+		return models.Endpoint{IP: host, Port: port}
+	}
+	return models.Endpoint{Hostname: normalizeHostname(host), Port: port}
+}
+
+// normalizeHostname lowercases a hostname and drops the trailing dot of a
+// fully qualified name
+func normalizeHostname(host string) string {
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// destinationEndpoint returns the upstream endpoint of a proxied request.
+// Without an explicit port, https requests default to 443 and others to 80.
+func destinationEndpoint(r *http.Request) models.Endpoint {
+	host := r.URL.Host
+	if host == "" {
+		host = r.Host
+	}
+	defaultPort := 80
+	if r.URL.Scheme == "https" || r.TLS != nil {
+		defaultPort = 443
+	}
+	return hostEndpoint(host, defaultPort)
+}
+
+// endpointHost returns the hostname of an endpoint, or its IP when it has none
+func endpointHost(e models.Endpoint) string {
+	if e.Hostname != "" {
+		return e.Hostname
+	}
+	return e.IP
+}
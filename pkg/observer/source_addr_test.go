@@ -0,0 +1,77 @@
+package observer
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"axom-observer/pkg/models"
+)
+
+func TestEndpointFromAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want models.Endpoint
+	}{
+		{"10.2.3.4:51234", models.Endpoint{IP: "10.2.3.4", Port: 51234}},
+		{"[fd00::1]:8080", models.Endpoint{IP: "fd00::1", Port: 8080}},
+		{"10.2.3.4", models.Endpoint{IP: "10.2.3.4"}},
+		{"", models.Endpoint{}},
+	}
+	for _, tt := range tests {
+		if got := endpointFromAddr(tt.addr); got != tt.want {
+			t.Errorf("endpointFromAddr(%q) = %+v, want %+v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestSignalSourceFromRemoteAddr(t *testing.T) {
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "application/json", okChatResponse))
+	req := chatRequest(`{"model": "gpt-4", "messages": [{"role": "user", "content": "Hi"}]}`)
+	req.RemoteAddr = "10.42.0.17:40312"
+	signal := proxySignal(t, p, signalCh, req)
+
+	if signal.Source.IP != "10.42.0.17" || signal.Source.Port != 40312 {
+		t.Errorf("source = %+v, want 10.42.0.17:40312", signal.Source)
+	}
+}
+
+func TestDestinationEndpoint(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		tls  bool
+		want models.Endpoint
+	}{
+		{"http", "http://api.openai.com/v1/models", false, models.Endpoint{Hostname: "api.openai.com", Port: 80}},
+		{"https", "https://api.openai.com/v1/models", false, models.Endpoint{Hostname: "api.openai.com", Port: 443}},
+		{"tls connection", "/v1/models", true, models.Endpoint{Hostname: "example.com", Port: 443}},
+		{"host with port", "http://llm.internal:8080/v1/chat/completions", false, models.Endpoint{Hostname: "llm.internal", Port: 8080}},
+		{"https with port", "https://API.Example.com.:8443/v1", false, models.Endpoint{Hostname: "api.example.com", Port: 8443}},
+		{"ip address", "http://10.0.0.9:11434/api/chat", false, models.Endpoint{IP: "10.0.0.9", Port: 11434}},
+		{"ipv6 address", "https://[fd00::2]/v1", false, models.Endpoint{IP: "fd00::2", Port: 443}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if !tt.tls {
+				req.TLS = nil
+			} else if req.TLS == nil {
+				req.TLS = &tls.ConnectionState{}
+			}
+			if got := destinationEndpoint(req); got != tt.want {
+				t.Errorf("destinationEndpoint(%s) = %+v, want %+v", tt.url, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignalDestinationFromURL(t *testing.T) {
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "application/json", okChatResponse))
+	signal := proxySignal(t, p, signalCh, chatRequest(`{"model": "gpt-4", "messages": [{"role": "user", "content": "Hi"}]}`))
+
+	if signal.Destination.Hostname != "api.openai.com" || signal.Destination.Port != 80 {
+		t.Errorf("destination = %+v, want api.openai.com:80", signal.Destination)
+	}
+}
@@ -0,0 +1,115 @@
+package observer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"mime"
+	"strings"
+)
+
+// eventStream is a reassembled server-sent-events response
+type eventStream struct {
+	Content string                 // Concatenated text deltas
+	Usage   map[string]interface{} // Final usage block, if the provider sent one
+	ID      string                 // Response/message ID
+	Chunks  int                    // Number of data events
+	// FinishReason is why generation stopped: OpenAI finish_reason or
+	// Anthropic stop_reason
+	FinishReason string
+}
+
+// isEventStream reports whether the content type is text/event-stream
+func isEventStream(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.HasPrefix(contentType, "text/event-stream")
+	}
+	return mediaType == "text/event-stream"
+}
+
+// parseEventStream reassembles an OpenAI- or Anthropic-style SSE body into the
+// full response text and usage. OpenAI sends choices[].delta.content chunks
+// with usage on the terminating chunk; Anthropic sends content_block_delta
+// text deltas with input tokens on message_start and output tokens on
+// message_delta.
+func parseEventStream(bodyBytes []byte) eventStream {
+	var stream eventStream
+	var content strings.Builder
+
+	scanner := bufio.NewScanner(bytes.NewReader(bodyBytes))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "" || data == "[DONE]" {
+			continue
+		}
+		var chunk map[string]interface{}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			continue
+		}
+		stream.Chunks++
+
+		// OpenAI chat completion chunks
+		if id, ok := chunk["id"].(string); ok && stream.ID == "" {
+			stream.ID = id
+		}
+		if choices, ok := chunk["choices"].([]interface{}); ok && len(choices) > 0 {
+			if choice, ok := choices[0].(map[string]interface{}); ok {
+				if delta, ok := choice["delta"].(map[string]interface{}); ok {
+					if text, ok := delta["content"].(string); ok {
+						content.WriteString(text)
+					}
+				}
+				if reason, ok := choice["finish_reason"].(string); ok && reason != "" {
+					stream.FinishReason = reason
+				}
+			}
+		}
+		if usage, ok := chunk["usage"].(map[string]interface{}); ok {
+			if stream.Usage == nil {
+				stream.Usage = make(map[string]interface{})
+			}
+			for k, v := range usage {
+				stream.Usage[k] = v
+			}
+		}
+
+		// Anthropic message events
+		switch chunk["type"] {
+		case "message_start":
+			if message, ok := chunk["message"].(map[string]interface{}); ok {
+				if id, ok := message["id"].(string); ok {
+					stream.ID = id
+				}
+				if usage, ok := message["usage"].(map[string]interface{}); ok {
+					if stream.Usage == nil {
+						stream.Usage = make(map[string]interface{})
+					}
+					for k, v := range usage {
+						stream.Usage[k] = v
+					}
+				}
+			}
+		case "content_block_delta":
+			if delta, ok := chunk["delta"].(map[string]interface{}); ok {
+				if text, ok := delta["text"].(string); ok {
+					content.WriteString(text)
+				}
+			}
+		case "message_delta":
+			if delta, ok := chunk["delta"].(map[string]interface{}); ok {
+				if reason, ok := delta["stop_reason"].(string); ok && reason != "" {
+					stream.FinishReason = reason
+				}
+			}
+		}
+	}
+
+	stream.Content = content.String()
+	return stream
+}
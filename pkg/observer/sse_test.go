@@ -0,0 +1,102 @@
+package observer
+
+import (
+	"net/http"
+	"testing"
+)
+
+const openAIStream = `data: {"id":"chatcmpl-1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"role":"assistant","content":""}}]}
+
+data: {"id":"chatcmpl-1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"Hello"}}]}
+
+data: {"id":"chatcmpl-1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":" world"},"finish_reason":"stop"}]}
+
+data: {"id":"chatcmpl-1","object":"chat.completion.chunk","choices":[],"usage":{"prompt_tokens":8,"completion_tokens":2,"total_tokens":10}}
+
+data: [DONE]
+
+`
+
+const anthropicStream = `event: message_start
+data: {"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","usage":{"input_tokens":12,"output_tokens":1}}}
+
+event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hi"}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":" there"}}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":3}}
+
+event: message_stop
+data: {"type":"message_stop"}
+
+`
+
+func TestParseEventStreamOpenAI(t *testing.T) {
+	stream := parseEventStream([]byte(openAIStream))
+	if stream.Content != "Hello world" {
+		t.Errorf("Content = %q, want %q", stream.Content, "Hello world")
+	}
+	if stream.ID != "chatcmpl-1" {
+		t.Errorf("ID = %q, want chatcmpl-1", stream.ID)
+	}
+	if stream.Chunks != 4 {
+		t.Errorf("Chunks = %d, want 4", stream.Chunks)
+	}
+	if stream.FinishReason != "stop" {
+		t.Errorf("FinishReason = %q, want stop", stream.FinishReason)
+	}
+	if stream.Usage["total_tokens"] != 10.0 {
+		t.Errorf("Usage = %v, want total_tokens 10", stream.Usage)
+	}
+}
+
+func TestParseEventStreamAnthropic(t *testing.T) {
+	stream := parseEventStream([]byte(anthropicStream))
+	if stream.Content != "Hi there" {
+		t.Errorf("Content = %q, want %q", stream.Content, "Hi there")
+	}
+	if stream.ID != "msg_1" {
+		t.Errorf("ID = %q, want msg_1", stream.ID)
+	}
+	if stream.FinishReason != "end_turn" {
+		t.Errorf("FinishReason = %q, want end_turn", stream.FinishReason)
+	}
+	if stream.Usage["input_tokens"] != 12.0 || stream.Usage["output_tokens"] != 3.0 {
+		t.Errorf("Usage = %v, want input_tokens 12 and output_tokens 3", stream.Usage)
+	}
+}
+
+func TestIsEventStream(t *testing.T) {
+	for contentType, want := range map[string]bool{
+		"text/event-stream":                true,
+		"text/event-stream; charset=utf-8": true,
+		"application/json":                 false,
+		"":                                 false,
+	} {
+		if got := isEventStream(contentType); got != want {
+			t.Errorf("isEventStream(%q) = %v, want %v", contentType, got, want)
+		}
+	}
+}
+
+func TestStreamedResponsePreview(t *testing.T) {
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "text/event-stream", openAIStream))
+
+	signal := proxySignal(t, p, signalCh, chatRequest(`{"model":"gpt-4","stream":true,"messages":[{"role":"user","content":"Say hello"}]}`))
+
+	if got := signal.Metadata["response_preview"]; got != "Hello world" {
+		t.Errorf("Metadata[response_preview] = %v, want %q", got, "Hello world")
+	}
+	if got := signal.Metadata["stream_chunks"]; got != 4 {
+		t.Errorf("Metadata[stream_chunks] = %v, want 4", got)
+	}
+	if got := signal.Metadata["total_tokens"]; got != 10 {
+		t.Errorf("Metadata[total_tokens] = %v, want 10", got)
+	}
+}
@@ -0,0 +1,41 @@
+package observer
+
+import (
+	"io"
+	"net/http"
+	"time"
+)
+
+// firstByteTimer wraps a response body and notes when its first bytes
+// arrive. For a streamed response that is when the first SSE chunk does,
+// well before the full body completes.
+type firstByteTimer struct {
+	io.ReadCloser
+	first time.Time
+}
+
+// timeFirstByte replaces resp.Body with a firstByteTimer and returns it
+func timeFirstByte(resp *http.Response) *firstByteTimer {
+	timer := &firstByteTimer{ReadCloser: resp.Body}
+	resp.Body = timer
+	return timer
+}
+
+func (t *firstByteTimer) Read(b []byte) (int, error) {
+	n, err := t.ReadCloser.Read(b)
+	if n > 0 && t.first.IsZero() {
+		t.first = time.Now()
+	}
+	return n, err
+}
+
+// recordTTFB sets fields["ttfb_ms"] to the time from start until the first
+// chunk of a streamed response arrived. latency_ms stays the time to the
+// full completion. Non-streamed responses are left alone, as their first
+// byte and completion are nearly the same.
+func recordTTFB(fields map[string]interface{}, header http.Header, start time.Time, timer *firstByteTimer) {
+	if timer == nil || timer.first.IsZero() || !isEventStream(header.Get("Content-Type")) {
+		return
+	}
+	fields["ttfb_ms"] = float64(timer.first.Sub(start).Milliseconds())
+}
@@ -0,0 +1,67 @@
+package observer
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// streamedResponse returns a Forwarder answering with an SSE stream that
+// sends its first chunk at once and the rest after delay
+func streamedResponse(delay time.Duration) Forwarder {
+	return ForwarderFunc(func(req *http.Request) (*http.Response, error) {
+		body, w := io.Pipe()
+		go func() {
+			io.WriteString(w, `data: {"choices":[{"delta":{"content":"Hel"}}]}`+"\n\n")
+			time.Sleep(delay)
+			io.WriteString(w, `data: {"choices":[{"delta":{"content":"lo"}}]}`+"\n\n")
+			io.WriteString(w, "data: [DONE]\n\n")
+			w.Close()
+		}()
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"text/event-stream"}},
+			Body:       body,
+			Request:    req,
+		}, nil
+	})
+}
+
+func TestStreamedResponseTTFB(t *testing.T) {
+	p, signalCh := newTestHTTPProxy(t, streamedResponse(200*time.Millisecond))
+	signal := proxySignal(t, p, signalCh, chatRequest(`{"model": "gpt-4", "stream": true, "messages": [{"role": "user", "content": "Hi"}]}`))
+
+	ttfb, ok := signal.Metadata["ttfb_ms"].(float64)
+	if !ok {
+		t.Fatalf("ttfb_ms = %v, want it recorded for a streamed response", signal.Metadata["ttfb_ms"])
+	}
+	if signal.LatencyMS < 200 {
+		t.Errorf("latency_ms = %v, want the full stream of at least 200ms", signal.LatencyMS)
+	}
+	if ttfb >= signal.LatencyMS-150 {
+		t.Errorf("ttfb_ms = %v with latency_ms %v, want the first chunk well before completion", ttfb, signal.LatencyMS)
+	}
+}
+
+func TestNonStreamedResponseHasNoTTFB(t *testing.T) {
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "application/json", okChatResponse))
+	signal := proxySignal(t, p, signalCh, chatRequest(`{"model": "gpt-4", "messages": [{"role": "user", "content": "Hi"}]}`))
+	if got, ok := signal.Metadata["ttfb_ms"]; ok {
+		t.Errorf("ttfb_ms = %v for a JSON response, want it absent", got)
+	}
+}
+
+func TestFirstByteTimer(t *testing.T) {
+	resp := &http.Response{Body: io.NopCloser(strings.NewReader("x"))}
+	timer := timeFirstByte(resp)
+	if !timer.first.IsZero() {
+		t.Fatalf("first byte time set before reading")
+	}
+	before := time.Now()
+	io.ReadAll(resp.Body)
+	if timer.first.Before(before) {
+		t.Errorf("first byte time %v, want it set by the read", timer.first)
+	}
+}
@@ -1,12 +1,17 @@
 package observer
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"os"
 	"regexp"
+	"sync"
 	"time"
 
 	"axom-observer/pkg/models"
+
+	"gopkg.in/yaml.v3"
 )
 
 // TaskDetector provides comprehensive AI task detection
@@ -16,50 +21,161 @@ type TaskDetector struct {
 	signalCh   chan<- models.Signal
 	customerID string
 	agentID    string
+
+	patternCache sync.Map // regex string -> *regexp.Regexp
+
+	tasksMu     sync.Mutex
+	activeTasks map[taskKey]*activeTask
+}
+
+// defaultTaskTimeout applies to rules that don't set a timeout
+const defaultTaskTimeout = 10 * time.Minute
+
+// taskKey identifies an in-progress task. A customer/agent pair has at most
+// one active task of each type.
+type taskKey struct {
+	customerID string
+	agentID    string
+	taskType   string
 }
 
+// activeTask is an in-progress task and the signals collected for it so far
+type activeTask struct {
+	task     *models.Task
+	signals  []models.Signal
+	timeout  time.Duration
+	lastSeen time.Time
+}
+
+// Environment variables:
+//   AXOM_TASK_RULES      - Optional. Path to a YAML file of task rules
+//   AXOM_TASK_RULES_MODE - Optional. "merge" (default) adds file rules ahead of the built-in ones, replacing same-named rules; "replace" uses only the file rules
+
 // TaskRule defines a pattern for detecting tasks
 type TaskRule struct {
-	Name        string            `json:"name"`
-	Description string            `json:"description"`
-	Provider    string            `json:"provider"`
-	Patterns    []TaskPattern     `json:"patterns"`
-	Outcomes    []OutcomeRule     `json:"outcomes"`
-	Timeout     time.Duration     `json:"timeout"`
-	Metadata    map[string]string `json:"metadata"`
+	Name        string            `json:"name" yaml:"name"`
+	Description string            `json:"description" yaml:"description"`
+	Provider    string            `json:"provider" yaml:"provider"`
+	Patterns    []TaskPattern     `json:"patterns" yaml:"patterns"`
+	Outcomes    []OutcomeRule     `json:"outcomes" yaml:"outcomes"`
+	Timeout     time.Duration     `json:"timeout" yaml:"timeout"`
+	Metadata    map[string]string `json:"metadata" yaml:"metadata"`
 }
 
 // TaskPattern defines how to detect a task
 type TaskPattern struct {
-	Type       string            `json:"type"`       // "prompt", "response", "model", "endpoint"
-	Conditions map[string]string `json:"conditions"` // field -> regex pattern
-	Confidence float64           `json:"confidence"` // 0.0 to 1.0
-	Required   bool              `json:"required"`   // if true, must match
+	Type       string            `json:"type" yaml:"type"`             // "prompt", "response", "model", "endpoint"
+	Conditions map[string]string `json:"conditions" yaml:"conditions"` // field -> regex pattern
+	Confidence float64           `json:"confidence" yaml:"confidence"` // 0.0 to 1.0
+	Required   bool              `json:"required" yaml:"required"`     // if true, must match
 }
 
 // OutcomeRule defines how to determine task outcome
 type OutcomeRule struct {
-	Name       string            `json:"name"`
-	Conditions map[string]string `json:"conditions"`
-	Outcome    string            `json:"outcome"` // "success", "failure", "partial"
-	Score      float64           `json:"score"`   // 0.0 to 1.0
+	Name       string            `json:"name" yaml:"name"`
+	Conditions map[string]string `json:"conditions" yaml:"conditions"`
+	Outcome    string            `json:"outcome" yaml:"outcome"` // "success", "failure", "partial"
+	Score      float64           `json:"score" yaml:"score"`     // 0.0 to 1.0
 }
 
-// NewTaskDetector creates a new task detector
+// NewTaskDetector creates a new task detector, loading extra rules from
+// AXOM_TASK_RULES if set
 func NewTaskDetector(signalCh chan<- models.Signal, logger *log.Logger, customerID, agentID string) *TaskDetector {
+	return NewTaskDetectorWithRules(signalCh, logger, customerID, agentID, "")
+}
+
+// NewTaskDetectorWithRules creates a task detector with rules loaded from a
+// YAML file. If rulesPath is empty AXOM_TASK_RULES is used; if neither is
+// set only the built-in rules are used. A missing or malformed file falls
+// back to the built-in rules with a warning.
+func NewTaskDetectorWithRules(signalCh chan<- models.Signal, logger *log.Logger, customerID, agentID, rulesPath string) *TaskDetector {
 	detector := &TaskDetector{
-		logger:     logger,
-		signalCh:   signalCh,
-		customerID: customerID,
-		agentID:    agentID,
+		logger:      logger,
+		signalCh:    signalCh,
+		customerID:  customerID,
+		agentID:     agentID,
+		activeTasks: make(map[taskKey]*activeTask),
 	}
 
 	// Initialize with comprehensive task rules
 	detector.initializeTaskRules()
 
+	if rulesPath == "" {
+		rulesPath = os.Getenv("AXOM_TASK_RULES")
+	}
+	if rulesPath != "" {
+		rules, err := LoadTaskRules(rulesPath)
+		if err != nil {
+			logger.Printf("⚠️ Failed to load task rules from %s, using built-in rules: %v", rulesPath, err)
+		} else {
+			detector.applyCustomRules(rules, os.Getenv("AXOM_TASK_RULES_MODE") == "replace")
+			logger.Printf("📋 Loaded %d task rules from %s", len(rules), rulesPath)
+		}
+	}
+
 	return detector
 }
 
+// LoadTaskRules reads and validates a YAML list of task rules
+func LoadTaskRules(path string) ([]TaskRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules []TaskRule
+	if err := yaml.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse task rules: %w", err)
+	}
+	for _, rule := range rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("task rule missing name")
+		}
+		if len(rule.Patterns) == 0 {
+			return nil, fmt.Errorf("task rule %s has no patterns", rule.Name)
+		}
+		for _, pattern := range rule.Patterns {
+			for field, expr := range pattern.Conditions {
+				if _, err := regexp.Compile(expr); err != nil {
+					return nil, fmt.Errorf("task rule %s: invalid regex for %s: %w", rule.Name, field, err)
+				}
+			}
+		}
+		for _, outcome := range rule.Outcomes {
+			for field, expr := range outcome.Conditions {
+				if _, err := regexp.Compile(expr); err != nil {
+					return nil, fmt.Errorf("task rule %s outcome %s: invalid regex for %s: %w", rule.Name, outcome.Name, field, err)
+				}
+			}
+		}
+	}
+	return rules, nil
+}
+
+// applyCustomRules merges custom rules into the detector. Custom rules are
+// evaluated before built-in ones and replace built-in rules of the same name.
+func (d *TaskDetector) applyCustomRules(rules []TaskRule, replace bool) {
+	for i := range rules {
+		if rules[i].Provider == "" {
+			rules[i].Provider = "any"
+		}
+	}
+	if replace {
+		d.taskRules = rules
+		return
+	}
+	custom := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		custom[rule.Name] = true
+	}
+	merged := append([]TaskRule(nil), rules...)
+	for _, rule := range d.taskRules {
+		if !custom[rule.Name] {
+			merged = append(merged, rule)
+		}
+	}
+	d.taskRules = merged
+}
+
 // initializeTaskRules initializes comprehensive task detection rules
 func (d *TaskDetector) initializeTaskRules() {
 	d.taskRules = []TaskRule{
@@ -459,36 +575,164 @@ func (d *TaskDetector) initializeTaskRules() {
 	}
 }
 
-// DetectTask detects if a signal represents a task
+// DetectTask detects if a signal belongs to a task. Signals matching a rule
+// for which the customer/agent already has an active task are appended to
+// it; otherwise a new task is started. If the signal matches one of the
+// rule's terminal (success/failure) outcomes the task is completed and its
+// completion signal emitted.
 func (d *TaskDetector) DetectTask(signal models.Signal) *models.Task {
-	for _, rule := range d.taskRules {
-		if d.matchesTaskRule(signal, rule) {
-			task := &models.Task{
-				ID:         d.generateTaskID(signal.CustomerID, signal.AgentID, rule.Name),
-				CustomerID: signal.CustomerID,
-				AgentID:    signal.AgentID,
-				Type:       rule.Name,
-				Status:     "in_progress",
-				CreatedAt:  signal.Timestamp,
-				Metadata: map[string]interface{}{
-					"description": rule.Description,
-					"provider":    signal.Metadata["provider"],
-					"model":       signal.Metadata["model"],
-					"confidence":  d.calculateConfidence(signal, rule),
+	for i := range d.taskRules {
+		rule := &d.taskRules[i]
+		if !d.matchesTaskRule(signal, *rule) {
+			continue
+		}
+
+		key := taskKey{customerID: signal.CustomerID, agentID: signal.AgentID, taskType: rule.Name}
+		confidence := d.calculateConfidence(signal, *rule)
+		terminal := d.matchesTerminalOutcome(signal, *rule)
+
+		d.tasksMu.Lock()
+		active, ok := d.activeTasks[key]
+		if ok {
+			active.task.Signals = append(active.task.Signals, signal.ID)
+			active.signals = append(active.signals, taskSignal(signal, rule))
+			active.lastSeen = time.Now()
+			d.logger.Printf("🔗 Signal %s added to task %s (%d signals)", signal.ID, active.task.ID, len(active.task.Signals))
+		} else {
+			active = &activeTask{
+				task: &models.Task{
+					ID:         models.NewTaskID(),
+					CustomerID: signal.CustomerID,
+					AgentID:    signal.AgentID,
+					Type:       rule.Name,
+					Status:     "in_progress",
+					CreatedAt:  signal.Timestamp,
+					Metadata: map[string]interface{}{
+						"description": rule.Description,
+						"provider":    signal.Metadata["provider"],
+						"model":       signal.Metadata["model"],
+						"confidence":  confidence,
+					},
+					Signals: []string{signal.ID},
 				},
-				Signals: []string{signal.ID},
+				signals:  []models.Signal{taskSignal(signal, rule)},
+				timeout:  rule.Timeout,
+				lastSeen: time.Now(),
 			}
-
+			if active.timeout <= 0 {
+				active.timeout = defaultTaskTimeout
+			}
+			d.activeTasks[key] = active
 			d.logger.Printf("🎯 Task detected: %s (%s) - Confidence: %.2f",
-				rule.Name, rule.Description, task.Metadata["confidence"])
+				rule.Name, rule.Description, confidence)
+		}
+		if terminal {
+			delete(d.activeTasks, key)
+		}
+		task := active.task
+		signals := active.signals
+		d.tasksMu.Unlock()
 
-			return task
+		if terminal {
+			d.emitCompletion(task, signals, "outcome_matched")
 		}
+		return task
 	}
 
 	return nil
 }
 
+// taskUsageFields are the metadata fields a task's usage is aggregated from
+var taskUsageFields = []string{"total_tokens", "prompt_tokens", "completion_tokens", "estimated_cost_usd", "response_preview"}
+
+// taskSignal returns what an active task keeps of a signal: its ID, timing
+// and a copy of the metadata that usage aggregation and the rule's outcomes
+// read. The signal itself carries on downstream, where its metadata is
+// changed while Run may be completing the task on another goroutine.
+func taskSignal(signal models.Signal, rule *TaskRule) models.Signal {
+	metadata := make(map[string]interface{}, len(taskUsageFields))
+	keep := func(key string) {
+		if value, ok := signal.Metadata[key]; ok {
+			metadata[key] = value
+		}
+	}
+	for _, key := range taskUsageFields {
+		keep(key)
+	}
+	for _, outcome := range rule.Outcomes {
+		for field := range outcome.Conditions {
+			if key, known := conditionFields[field]; known {
+				keep(key)
+			} else {
+				keep(field)
+			}
+		}
+	}
+	return models.Signal{
+		ID:        signal.ID,
+		Timestamp: signal.Timestamp,
+		LatencyMS: signal.LatencyMS,
+		Metadata:  metadata,
+	}
+}
+
+// matchesTerminalOutcome reports whether the signal matches one of the rule's
+// success or failure outcomes, which end the task
+func (d *TaskDetector) matchesTerminalOutcome(signal models.Signal, rule TaskRule) bool {
+	response, ok := signal.Metadata["response_preview"].(string)
+	if !ok {
+		return false
+	}
+	for _, outcome := range rule.Outcomes {
+		if outcome.Outcome != "success" && outcome.Outcome != "failure" {
+			continue
+		}
+		if d.matchesConditions(signal, response, outcome.Conditions) {
+			return true
+		}
+	}
+	return false
+}
+
+// Run expires active tasks that have seen no signals within their rule's
+// timeout, emitting a completion signal for each, until ctx is cancelled
+func (d *TaskDetector) Run(ctx context.Context) {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			d.expireTasks(now)
+		}
+	}
+}
+
+// expireTasks completes the active tasks that have timed out as of now
+func (d *TaskDetector) expireTasks(now time.Time) {
+	var expired []*activeTask
+	d.tasksMu.Lock()
+	for key, active := range d.activeTasks {
+		if now.Sub(active.lastSeen) >= active.timeout {
+			expired = append(expired, active)
+			delete(d.activeTasks, key)
+		}
+	}
+	d.tasksMu.Unlock()
+
+	for _, active := range expired {
+		d.emitCompletion(active.task, active.signals, "timeout")
+	}
+}
+
+// ActiveTaskCount returns the number of tasks still in progress
+func (d *TaskDetector) ActiveTaskCount() int {
+	d.tasksMu.Lock()
+	defer d.tasksMu.Unlock()
+	return len(d.activeTasks)
+}
+
 // matchesTaskRule checks if a signal matches a task rule
 func (d *TaskDetector) matchesTaskRule(signal models.Signal, rule TaskRule) bool {
 	// Check provider if specified
@@ -516,40 +760,87 @@ func (d *TaskDetector) matchesPattern(signal models.Signal, pattern TaskPattern)
 	switch pattern.Type {
 	case "prompt":
 		if prompt, ok := signal.Metadata["prompt_preview"].(string); ok {
-			return d.matchesConditions(prompt, pattern.Conditions)
+			return d.matchesConditions(signal, prompt, pattern.Conditions)
 		}
 	case "response":
 		if response, ok := signal.Metadata["response_preview"].(string); ok {
-			return d.matchesConditions(response, pattern.Conditions)
+			return d.matchesConditions(signal, response, pattern.Conditions)
 		}
 	case "model":
 		if model, ok := signal.Metadata["model"].(string); ok {
-			return d.matchesConditions(model, pattern.Conditions)
+			return d.matchesConditions(signal, model, pattern.Conditions)
 		}
 	case "endpoint":
 		if endpoint, ok := signal.Metadata["endpoint"].(string); ok {
-			return d.matchesConditions(endpoint, pattern.Conditions)
+			return d.matchesConditions(signal, endpoint, pattern.Conditions)
 		}
 	}
 
 	return false
 }
 
-// matchesConditions checks if text matches all conditions
-func (d *TaskDetector) matchesConditions(text string, conditions map[string]string) bool {
-	for pattern := range conditions {
-		matched, err := regexp.MatchString(pattern, text)
+// conditionFields maps condition keys to the signal metadata they match
+// against. "content" means the text selected by the pattern type.
+var conditionFields = map[string]string{
+	"prompt":   "prompt_preview",
+	"response": "response_preview",
+	"path":     "endpoint",
+	"endpoint": "endpoint",
+	"model":    "model",
+	// finish_reason lets outcome rules spot truncated ("length") or
+	// filtered ("content_filter") completions
+	"finish_reason": "finish_reason",
+}
+
+// matchesConditions checks that every condition matches. Each condition maps
+// a field to a regex; the field selects which text is matched ("content"
+// is the pattern's own text, other keys select signal metadata).
+func (d *TaskDetector) matchesConditions(signal models.Signal, text string, conditions map[string]string) bool {
+	for field, pattern := range conditions {
+		re, err := d.compilePattern(pattern)
 		if err != nil {
-			d.logger.Printf("Invalid regex pattern %s: %v", pattern, err)
+			d.logger.Printf("Invalid regex pattern %s for %s: %v", pattern, field, err)
 			continue
 		}
-		if !matched {
+		if !re.MatchString(d.conditionText(signal, text, field)) {
 			return false
 		}
 	}
 	return true
 }
 
+// conditionText selects the text a condition field is matched against
+func (d *TaskDetector) conditionText(signal models.Signal, text, field string) string {
+	if field == "content" {
+		return text
+	}
+	key, known := conditionFields[field]
+	if !known {
+		key = field
+	}
+	if value, ok := signal.Metadata[key].(string); ok {
+		return value
+	}
+	if !known {
+		// Unknown field with no matching metadata: match the pattern's own text
+		return text
+	}
+	return ""
+}
+
+// compilePattern compiles a condition regex, caching the result
+func (d *TaskDetector) compilePattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := d.patternCache.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	d.patternCache.Store(pattern, re)
+	return re, nil
+}
+
 // calculateConfidence calculates confidence score for task detection
 func (d *TaskDetector) calculateConfidence(signal models.Signal, rule TaskRule) float64 {
 	totalConfidence := 0.0
@@ -604,9 +895,106 @@ func (d *TaskDetector) DetermineOutcome(task *models.Task, signals []models.Sign
 	outcomeData["total_signals"] = len(signals)
 	outcomeData["duration_minutes"] = time.Since(task.CreatedAt).Minutes()
 
+	// Add usage aggregated across all of the task's signals
+	for k, v := range aggregateTaskUsage(task, signals) {
+		outcomeData[k] = v
+	}
+
 	return bestOutcome, outcomeData
 }
 
+// aggregateTaskUsage sums token usage, estimated cost and latency across a
+// task's signals. task_duration is the wall-clock span in milliseconds from
+// task creation to the end of the last signal.
+func aggregateTaskUsage(task *models.Task, signals []models.Signal) map[string]interface{} {
+	totalTokens := 0
+	totalCost := 0.0
+	totalLatency := 0.0
+	end := task.CreatedAt
+
+	for _, signal := range signals {
+		if tokens, ok := signal.Metadata["total_tokens"].(int); ok {
+			totalTokens += tokens
+		} else {
+			promptTokens, _ := signal.Metadata["prompt_tokens"].(int)
+			completionTokens, _ := signal.Metadata["completion_tokens"].(int)
+			totalTokens += promptTokens + completionTokens
+		}
+		if cost, ok := signal.Metadata["estimated_cost_usd"].(float64); ok {
+			totalCost += cost
+		}
+		totalLatency += signal.LatencyMS
+
+		signalEnd := signal.Timestamp.Add(time.Duration(signal.LatencyMS * float64(time.Millisecond)))
+		if signalEnd.After(end) {
+			end = signalEnd
+		}
+	}
+
+	return map[string]interface{}{
+		"task_total_tokens":     totalTokens,
+		"task_total_cost":       totalCost,
+		"task_total_latency_ms": totalLatency,
+		"task_duration":         float64(end.Sub(task.CreatedAt).Milliseconds()),
+	}
+}
+
+// BuildCompletionSignal creates the terminal task-complete signal for a task,
+// carrying the outcome and the usage aggregated across all of its signals
+func (d *TaskDetector) BuildCompletionSignal(task *models.Task, signals []models.Signal) models.Signal {
+	outcome, outcomeData := d.DetermineOutcome(task, signals)
+
+	completedAt := time.Now()
+	task.Status = "completed"
+	task.Outcome = outcome
+	task.CompletedAt = &completedAt
+
+	signal := models.Signal{
+		ID:            models.NewSignalID(),
+		SchemaVersion: models.SignalSchemaVersion,
+		CustomerID:    task.CustomerID,
+		AgentID:       task.AgentID,
+		TaskID:        task.ID,
+		TaskType:      task.Type,
+		Timestamp:     completedAt,
+		Protocol:      "internal",
+		Operation:     "task_complete",
+		Metadata: map[string]interface{}{
+			"provider":     task.Metadata["provider"],
+			"model":        task.Metadata["model"],
+			"signal_ids":   task.Signals,
+			"created_at":   task.CreatedAt,
+			"completed_at": completedAt,
+		},
+	}
+	if latency, ok := outcomeData["task_total_latency_ms"].(float64); ok {
+		signal.LatencyMS = latency
+	}
+	signal.SetOutcome(outcome, outcomeData)
+	return signal
+}
+
+// EmitCompletion builds the completion signal for a task and sends it on the
+// detector's signal channel, dropping it if the channel is full
+func (d *TaskDetector) EmitCompletion(task *models.Task, signals []models.Signal) {
+	d.emitCompletion(task, signals, "")
+}
+
+// emitCompletion sends the completion signal for a task, recording why it
+// completed ("outcome_matched" or "timeout") in the outcome data
+func (d *TaskDetector) emitCompletion(task *models.Task, signals []models.Signal, reason string) {
+	signal := d.BuildCompletionSignal(task, signals)
+	if reason != "" {
+		signal.OutcomeData["completion_reason"] = reason
+	}
+	select {
+	case d.signalCh <- signal:
+		d.logger.Printf("🏁 Task completed: %s (%s) - Outcome: %s", task.ID, task.Type, signal.Outcome)
+	default:
+		d.logger.Printf("Signal channel full, dropping task completion signal for %s", task.ID)
+	}
+}
+
 // evaluateOutcomeRule evaluates how well signals match an outcome rule
 func (d *TaskDetector) evaluateOutcomeRule(signals []models.Signal, rule OutcomeRule) float64 {
 	matches := 0
@@ -615,7 +1003,7 @@ func (d *TaskDetector) evaluateOutcomeRule(signals []models.Signal, rule Outcome
 	for _, signal := range signals {
 		if response, ok := signal.Metadata["response_preview"].(string); ok {
 			total++
-			if d.matchesConditions(response, rule.Conditions) {
+			if d.matchesConditions(signal, response, rule.Conditions) {
 				matches++
 			}
 		}
@@ -627,8 +1015,3 @@ func (d *TaskDetector) evaluateOutcomeRule(signals []models.Signal, rule Outcome
 
 	return float64(matches) / float64(total) * rule.Score
 }
-
-// generateTaskID generates a unique task ID
-func (d *TaskDetector) generateTaskID(customerID, agentID, taskType string) string {
-	return fmt.Sprintf("%s_%s_%s_%d", customerID, agentID, taskType, time.Now().Unix())
-}
@@ -0,0 +1,359 @@
+package observer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"axom-observer/pkg/models"
+)
+
+// reviewRule is a task rule started by prompts asking for a review, which
+// succeeds once a response approves
+var reviewRule = TaskRule{
+	Name:     "code_review",
+	Provider: "any",
+	Patterns: []TaskPattern{{
+		Type:       "prompt",
+		Conditions: map[string]string{"content": `(?i)review`},
+		Confidence: 0.9,
+		Required:   true,
+	}},
+	Outcomes: []OutcomeRule{{
+		Name:       "approved",
+		Conditions: map[string]string{"content": `LGTM`},
+		Outcome:    "success",
+		Score:      1.0,
+	}},
+}
+
+// newTestTaskDetector creates a detector using only rules, whose completion
+// signals are buffered on the returned channel
+func newTestTaskDetector(rules ...TaskRule) (*TaskDetector, chan models.Signal) {
+	signalCh := make(chan models.Signal, 16)
+	d := NewTaskDetectorWithRules(signalCh, testLogger(), "test-customer", "test-agent", "")
+	d.applyCustomRules(append([]TaskRule(nil), rules...), true)
+	return d, signalCh
+}
+
+// taskTestSignal returns a request signal from the test agent with the given
+// previews, usage and latency
+func taskTestSignal(id, prompt, response string, tokens int, cost, latencyMS float64) models.Signal {
+	return models.Signal{
+		ID:         id,
+		CustomerID: "test-customer",
+		AgentID:    "test-agent",
+		Timestamp:  time.Now(),
+		Protocol:   "http",
+		LatencyMS:  latencyMS,
+		Metadata: map[string]interface{}{
+			"provider":           "OpenAI",
+			"model":              "gpt-4",
+			"prompt_preview":     prompt,
+			"response_preview":   response,
+			"total_tokens":       tokens,
+			"estimated_cost_usd": cost,
+		},
+	}
+}
+
+// completionSignal returns the completion signal emitted on signalCh
+func completionSignal(t *testing.T, signalCh chan models.Signal) models.Signal {
+	t.Helper()
+	select {
+	case signal := <-signalCh:
+		if signal.Operation != "task_complete" {
+			t.Fatalf("emitted %q signal, want task_complete", signal.Operation)
+		}
+		return signal
+	default:
+		t.Fatalf("no task completion signal emitted")
+		return models.Signal{}
+	}
+}
+
+func TestTaskCompletionTotalsSumSignals(t *testing.T) {
+	d, signalCh := newTestTaskDetector(reviewRule)
+
+	signals := []models.Signal{
+		taskTestSignal("sig-1", "Please review this diff", "Line 3 has a bug", 100, 0.003, 250),
+		taskTestSignal("sig-2", "Review the fixed diff", "Still missing a test", 150, 0.0045, 300),
+		taskTestSignal("sig-3", "Review again with the test", "LGTM", 50, 0.0015, 120),
+	}
+	for _, signal := range signals {
+		if task := d.DetectTask(signal); task == nil {
+			t.Fatalf("signal %s matched no task", signal.ID)
+		}
+	}
+
+	completion := completionSignal(t, signalCh)
+	if completion.Outcome != "success" {
+		t.Errorf("Outcome = %q, want success", completion.Outcome)
+	}
+	if got := completion.OutcomeData["total_signals"]; got != 3 {
+		t.Errorf("total_signals = %v, want 3", got)
+	}
+	if got := completion.OutcomeData["task_total_tokens"]; got != 300 {
+		t.Errorf("task_total_tokens = %v, want 300", got)
+	}
+	if got := completion.OutcomeData["task_total_cost"].(float64); got < 0.00899 || got > 0.00901 {
+		t.Errorf("task_total_cost = %v, want 0.009", got)
+	}
+	if got := completion.OutcomeData["task_total_latency_ms"]; got != 670.0 {
+		t.Errorf("task_total_latency_ms = %v, want 670", got)
+	}
+	if completion.LatencyMS != 670 {
+		t.Errorf("LatencyMS = %v, want 670", completion.LatencyMS)
+	}
+}
+
+func TestAggregateTaskUsageFallsBackToPromptAndCompletion(t *testing.T) {
+	task := &models.Task{CreatedAt: time.Now()}
+	usage := aggregateTaskUsage(task, []models.Signal{
+		{Metadata: map[string]interface{}{"prompt_tokens": 10, "completion_tokens": 5}},
+		{Metadata: map[string]interface{}{"total_tokens": 20}},
+		{Metadata: map[string]interface{}{}},
+	})
+	if got := usage["task_total_tokens"]; got != 35 {
+		t.Errorf("task_total_tokens = %v, want 35", got)
+	}
+}
+
+func TestLoadTaskRulesFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	rules := `- name: invoice_extraction
+  description: Extract fields from an invoice
+  patterns:
+    - type: prompt
+      conditions:
+        content: "(?i)invoice"
+      confidence: 0.8
+      required: true
+  outcomes:
+    - name: extracted
+      conditions:
+        content: "(?i)total"
+      outcome: success
+      score: 1.0
+  timeout: 5m
+`
+	if err := os.WriteFile(path, []byte(rules), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	d := NewTaskDetectorWithRules(make(chan models.Signal, 1), testLogger(), "test-customer", "test-agent", path)
+
+	task := d.DetectTask(taskTestSignal("sig-1", "Read this invoice for me", "Working on it", 10, 0, 100))
+	if task == nil {
+		t.Fatalf("signal matched no task")
+	}
+	if task.Type != "invoice_extraction" {
+		t.Errorf("task type = %q, want invoice_extraction", task.Type)
+	}
+	// Built-in rules are still loaded alongside the file's in merge mode
+	if len(d.taskRules) < 2 || d.taskRules[0].Name != "invoice_extraction" {
+		t.Errorf("custom rule not merged ahead of the built-in rules")
+	}
+}
+
+func TestLoadTaskRulesReplaceMode(t *testing.T) {
+	t.Setenv("AXOM_TASK_RULES_MODE", "replace")
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	rules := `- name: only_rule
+  patterns:
+    - type: model
+      conditions:
+        content: "^gpt-"
+      required: true
+`
+	if err := os.WriteFile(path, []byte(rules), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	d := NewTaskDetectorWithRules(make(chan models.Signal, 1), testLogger(), "", "", path)
+	if len(d.taskRules) != 1 || d.taskRules[0].Name != "only_rule" || d.taskRules[0].Provider != "any" {
+		t.Errorf("rules = %+v, want only_rule for any provider", d.taskRules)
+	}
+}
+
+func TestLoadTaskRulesInvalidFileFallsBack(t *testing.T) {
+	builtin := len(NewTaskDetectorWithRules(nil, testLogger(), "", "", "").taskRules)
+
+	for name, content := range map[string]string{
+		"malformed":     "- name: [unterminated",
+		"invalid regex": "- name: bad\n  patterns:\n    - type: prompt\n      conditions:\n        content: \"(\"\n",
+		"no patterns":   "- name: empty\n",
+	} {
+		path := filepath.Join(t.TempDir(), "rules.yaml")
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadTaskRules(path); err == nil {
+			t.Errorf("%s: LoadTaskRules succeeded", name)
+		}
+		d := NewTaskDetectorWithRules(nil, testLogger(), "", "", path)
+		if len(d.taskRules) != builtin {
+			t.Errorf("%s: %d rules loaded, want the %d built-in rules", name, len(d.taskRules), builtin)
+		}
+	}
+
+	d := NewTaskDetectorWithRules(nil, testLogger(), "", "", filepath.Join(t.TempDir(), "missing.yaml"))
+	if len(d.taskRules) != builtin {
+		t.Errorf("missing file: %d rules loaded, want the %d built-in rules", len(d.taskRules), builtin)
+	}
+}
+
+func TestMatchesConditionsUsesValueAsRegex(t *testing.T) {
+	d, _ := newTestTaskDetector()
+	signal := taskTestSignal("sig-1", "Schedule a cold call with Acme", "", 0, 0, 0)
+	signal.Metadata["endpoint"] = "/v1/chat/completions"
+
+	tests := []struct {
+		name       string
+		conditions map[string]string
+		want       bool
+	}{
+		{"value matches content", map[string]string{"content": `(?i)cold call`}, true},
+		{"value does not match content", map[string]string{"content": `(?i)invoice`}, false},
+		// Matching the key instead of the value would pass this
+		{"key is not the regex", map[string]string{"content": `^nomatch$`}, false},
+		{"field selects metadata", map[string]string{"path": `/chat/completions$`}, true},
+		{"field selects other metadata", map[string]string{"model": `^claude`}, false},
+		{"all conditions must match", map[string]string{"content": `cold call`, "model": `^claude`}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prompt := signal.Metadata["prompt_preview"].(string)
+			if got := d.matchesConditions(signal, prompt, tt.conditions); got != tt.want {
+				t.Errorf("matchesConditions(%v) = %v, want %v", tt.conditions, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuleMatchesOnPatternValue(t *testing.T) {
+	d, _ := newTestTaskDetector(reviewRule)
+	if task := d.DetectTask(taskTestSignal("sig-1", "What's the weather?", "", 0, 0, 0)); task != nil {
+		t.Errorf("unrelated prompt started a %s task", task.Type)
+	}
+	if task := d.DetectTask(taskTestSignal("sig-2", "Please review my PR", "", 0, 0, 0)); task == nil || task.Type != "code_review" {
+		t.Errorf("review prompt did not start a code_review task")
+	}
+}
+
+func TestDetectTaskAppendsThenCompletes(t *testing.T) {
+	d, signalCh := newTestTaskDetector(reviewRule)
+
+	first := d.DetectTask(taskTestSignal("sig-1", "Please review this diff", "Line 3 has a bug", 100, 0.003, 250))
+	if first == nil {
+		t.Fatalf("first signal matched no task")
+	}
+	second := d.DetectTask(taskTestSignal("sig-2", "Review the fixed diff", "Still missing a test", 150, 0.0045, 300))
+	if second == nil || second.ID != first.ID {
+		t.Fatalf("second signal started a new task, want it appended to %s", first.ID)
+	}
+	if d.ActiveTaskCount() != 1 {
+		t.Fatalf("ActiveTaskCount() = %d, want 1", d.ActiveTaskCount())
+	}
+	select {
+	case signal := <-signalCh:
+		t.Fatalf("task completed early with %s signal", signal.Operation)
+	default:
+	}
+
+	last := d.DetectTask(taskTestSignal("sig-3", "Review again with the test", "LGTM", 50, 0.0015, 120))
+	if last == nil || last.ID != first.ID {
+		t.Fatalf("terminal signal did not complete task %s", first.ID)
+	}
+	if d.ActiveTaskCount() != 0 {
+		t.Errorf("ActiveTaskCount() = %d after completion, want 0", d.ActiveTaskCount())
+	}
+
+	completion := completionSignal(t, signalCh)
+	if completion.TaskID != first.ID || completion.TaskType != "code_review" {
+		t.Errorf("completion is for %s/%s, want %s/code_review", completion.TaskID, completion.TaskType, first.ID)
+	}
+	if completion.Protocol != "internal" {
+		t.Errorf("Protocol = %q, want internal", completion.Protocol)
+	}
+	if got := completion.Metadata["signal_ids"].([]string); len(got) != 3 || got[0] != "sig-1" || got[2] != "sig-3" {
+		t.Errorf("signal_ids = %v, want sig-1..sig-3", got)
+	}
+	if got := completion.OutcomeData["completion_reason"]; got != "outcome_matched" {
+		t.Errorf("completion_reason = %v, want outcome_matched", got)
+	}
+	if last.Status != "completed" || last.CompletedAt == nil {
+		t.Errorf("task status = %q, CompletedAt = %v, want completed with a time", last.Status, last.CompletedAt)
+	}
+
+	// The next matching signal starts a fresh task
+	next := d.DetectTask(taskTestSignal("sig-4", "Review this other diff", "Looks odd", 10, 0.0001, 50))
+	if next == nil || next.ID == first.ID {
+		t.Errorf("signal after completion joined the completed task")
+	}
+}
+
+func TestExpireTasksCompletesTimedOutTasks(t *testing.T) {
+	rule := reviewRule
+	rule.Timeout = time.Minute
+	d, signalCh := newTestTaskDetector(rule)
+
+	task := d.DetectTask(taskTestSignal("sig-1", "Please review this diff", "Line 3 has a bug", 100, 0.003, 250))
+	if task == nil {
+		t.Fatalf("signal matched no task")
+	}
+
+	d.expireTasks(time.Now())
+	if d.ActiveTaskCount() != 1 {
+		t.Fatalf("task expired before its timeout")
+	}
+
+	d.expireTasks(time.Now().Add(2 * time.Minute))
+	if d.ActiveTaskCount() != 0 {
+		t.Fatalf("ActiveTaskCount() = %d after timeout, want 0", d.ActiveTaskCount())
+	}
+	completion := completionSignal(t, signalCh)
+	if completion.TaskID != task.ID {
+		t.Errorf("completion TaskID = %s, want %s", completion.TaskID, task.ID)
+	}
+	if got := completion.OutcomeData["completion_reason"]; got != "timeout" {
+		t.Errorf("completion_reason = %v, want timeout", got)
+	}
+}
+
+func TestDetectTaskConcurrentSignals(t *testing.T) {
+	d, _ := newTestTaskDetector(reviewRule)
+
+	const n = 50
+	var wg sync.WaitGroup
+	ids := make(chan string, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			signal := taskTestSignal(fmt.Sprintf("sig-%d", i), "Please review this diff", "Needs work", 10, 0.0001, 10)
+			if task := d.DetectTask(signal); task != nil {
+				ids <- task.ID
+			}
+		}(i)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		d.expireTasks(time.Now())
+	}()
+	wg.Wait()
+	close(ids)
+
+	taskIDs := make(map[string]bool)
+	for id := range ids {
+		taskIDs[id] = true
+	}
+	if len(taskIDs) != 1 {
+		t.Errorf("concurrent signals were spread over %d tasks, want 1", len(taskIDs))
+	}
+	if d.ActiveTaskCount() != 1 {
+		t.Errorf("ActiveTaskCount() = %d, want 1", d.ActiveTaskCount())
+	}
+}
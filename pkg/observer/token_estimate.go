@@ -0,0 +1,103 @@
+package observer
+
+import (
+	"os"
+
+	"axom-observer/pkg/tokens"
+)
+
+// Environment variables:
+//   AXOM_ESTIMATE_TOKENS - Optional. Set to 0 to leave token counts empty when a provider reports no usage, instead of estimating them from the captured text. Default: enabled
+
+// tokenEstimationFromEnv reports whether missing usage is estimated
+func tokenEstimationFromEnv() bool {
+	return os.Getenv("AXOM_ESTIMATE_TOKENS") != "0"
+}
+
+// recordPromptEstimate sets request["estimated_prompt_tokens"] from the text
+// of a chat, completion or Gemini request: its system prompt, messages,
+// "prompt" and "contents" parts
+func recordPromptEstimate(request map[string]interface{}, jsonData map[string]interface{}) {
+	var texts []string
+	add := func(text string) {
+		if text != "" {
+			texts = append(texts, text)
+		}
+	}
+
+	add(messageText(jsonData["system"]))
+	if messages, ok := jsonData["messages"].([]interface{}); ok {
+		for _, msg := range messages {
+			if msg, ok := msg.(map[string]interface{}); ok {
+				add(messageText(msg["content"]))
+			}
+		}
+	}
+	if prompt, ok := jsonData["prompt"].(string); ok {
+		add(prompt)
+	}
+	if instruction, ok := jsonData["systemInstruction"].(map[string]interface{}); ok {
+		add(messageText(instruction["parts"]))
+	}
+	if contents, ok := jsonData["contents"].([]interface{}); ok {
+		for _, content := range contents {
+			if content, ok := content.(map[string]interface{}); ok {
+				add(messageText(content["parts"]))
+			}
+		}
+	}
+	if len(texts) == 0 {
+		return
+	}
+
+	model, _ := jsonData["model"].(string)
+	request["estimated_prompt_tokens"] = tokens.EstimateMessages(model, texts)
+}
+
+// recordCompletionEstimate sets response["estimated_completion_tokens"] from
+// the generated text, e.g. a streamed response's concatenated deltas
+func recordCompletionEstimate(response map[string]interface{}, model, text string) {
+	if text == "" {
+		return
+	}
+	response["estimated_completion_tokens"] = tokens.Estimate(model, text)
+}
+
+// completionText returns the generated text of a non-streamed response:
+// OpenAI choices, Anthropic content blocks, Gemini candidates or Bedrock
+// output
+func completionText(jsonData map[string]interface{}) string {
+	if choices, ok := jsonData["choices"].([]interface{}); ok && len(choices) > 0 {
+		if choice, ok := choices[0].(map[string]interface{}); ok {
+			if message, ok := choice["message"].(map[string]interface{}); ok {
+				return messageText(message["content"])
+			}
+			text, _ := choice["text"].(string)
+			return text
+		}
+	}
+	if candidates, ok := jsonData["candidates"].([]interface{}); ok && len(candidates) > 0 {
+		if candidate, ok := candidates[0].(map[string]interface{}); ok {
+			if content, ok := candidate["content"].(map[string]interface{}); ok {
+				return messageText(content["parts"])
+			}
+		}
+	}
+	if content, ok := jsonData["content"].([]interface{}); ok {
+		return messageText(content)
+	}
+	return bedrockResponseText(jsonData)
+}
+
+// takeTokenEstimate removes the prompt and completion estimates from
+// metadata, returning them as usage if there were any
+func takeTokenEstimate(metadata map[string]interface{}) (tokenUsage, bool) {
+	prompt, hasPrompt := metadata["estimated_prompt_tokens"].(int)
+	completion, hasCompletion := metadata["estimated_completion_tokens"].(int)
+	delete(metadata, "estimated_prompt_tokens")
+	delete(metadata, "estimated_completion_tokens")
+	if !hasPrompt && !hasCompletion {
+		return tokenUsage{}, false
+	}
+	return tokenUsage{Prompt: prompt, Completion: completion, Total: prompt + completion}, true
+}
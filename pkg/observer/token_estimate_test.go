@@ -0,0 +1,60 @@
+package observer
+
+import (
+	"net/http"
+	"testing"
+
+	"axom-observer/pkg/tokens"
+)
+
+// noUsageResponse is a chat completion from a provider that reports no usage
+const noUsageResponse = `{"id":"chatcmpl-1","choices":[{"index":0,"message":{"role":"assistant","content":"Paris is the capital of France."},"finish_reason":"stop"}]}`
+
+func TestTokensEstimatedWithoutUsage(t *testing.T) {
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "application/json", noUsageResponse))
+	signal := proxySignal(t, p, signalCh, chatRequest(`{"model": "gpt-4", "messages": [{"role": "user", "content": "What is the capital of France?"}]}`))
+
+	if signal.Metadata["tokens_estimated"] != true {
+		t.Fatalf("tokens_estimated = %v, want true", signal.Metadata["tokens_estimated"])
+	}
+	prompt := tokens.EstimateMessages("gpt-4", []string{"What is the capital of France?"})
+	completion := tokens.Estimate("", "Paris is the capital of France.")
+	for key, want := range map[string]int{
+		"prompt_tokens":     prompt,
+		"completion_tokens": completion,
+		"total_tokens":      prompt + completion,
+	} {
+		if got := signal.Metadata[key]; got != want {
+			t.Errorf("%s = %v, want %d", key, got, want)
+		}
+	}
+	for _, key := range []string{"estimated_prompt_tokens", "estimated_completion_tokens"} {
+		if _, ok := signal.Metadata[key]; ok {
+			t.Errorf("%s left in the signal metadata", key)
+		}
+	}
+}
+
+func TestReportedUsageNotEstimated(t *testing.T) {
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "application/json", okChatResponse))
+	signal := proxySignal(t, p, signalCh, chatRequest(`{"model": "gpt-4", "messages": [{"role": "user", "content": "Hi"}]}`))
+
+	if _, ok := signal.Metadata["tokens_estimated"]; ok {
+		t.Errorf("tokens_estimated set although the provider reported usage")
+	}
+	if got := signal.Metadata["prompt_tokens"]; got != 5 {
+		t.Errorf("prompt_tokens = %v, want the reported 5", got)
+	}
+}
+
+func TestTokenEstimationDisabled(t *testing.T) {
+	t.Setenv("AXOM_ESTIMATE_TOKENS", "0")
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "application/json", noUsageResponse))
+	signal := proxySignal(t, p, signalCh, chatRequest(`{"model": "gpt-4", "messages": [{"role": "user", "content": "Hi"}]}`))
+
+	for _, key := range []string{"tokens_estimated", "prompt_tokens"} {
+		if got, ok := signal.Metadata[key]; ok {
+			t.Errorf("%s = %v with AXOM_ESTIMATE_TOKENS=0, want it absent", key, got)
+		}
+	}
+}
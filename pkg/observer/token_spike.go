@@ -0,0 +1,147 @@
+package observer
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"axom-observer/pkg/models"
+)
+
+// Environment variables:
+//   AXOM_SPIKE_THRESHOLD - Optional. Standard deviations above an agent's mean tokens per request that raise a spike alert; "0" disables detection. Default: 3
+//   AXOM_SPIKE_WINDOW    - Optional. Recent requests per agent the mean and deviation are computed over. Default: 100
+
+const (
+	// spikeMinSamples is how many requests an agent's window needs before
+	// requests are compared against it
+	spikeMinSamples = 20
+	// spikeMaxAgents bounds how many agents are tracked; further agents are
+	// not checked
+	spikeMaxAgents = 10000
+	// spikeMinStddevRatio floors the deviation at a fraction of the mean so
+	// that an agent with near-constant usage doesn't alert on small changes
+	spikeMinStddevRatio = 0.1
+)
+
+// TokenSpikeDetector flags requests whose token usage is far above their
+// agent's recent usage, e.g. a runaway loop generating huge completions. It
+// keeps a fixed-size window of tokens per request for each agent and raises
+// a high-severity alert when a request exceeds mean + threshold·σ of the
+// window before it.
+type TokenSpikeDetector struct {
+	threshold float64
+	window    int
+
+	mu     sync.Mutex
+	agents map[string]*tokenWindow
+}
+
+// tokenWindow is a ring buffer of one agent's recent tokens per request
+type tokenWindow struct {
+	values []float64
+	next   int
+}
+
+// NewTokenSpikeDetector creates a detector from AXOM_SPIKE_THRESHOLD and
+// AXOM_SPIKE_WINDOW. It returns nil when detection is disabled.
+func NewTokenSpikeDetector() *TokenSpikeDetector {
+	threshold := 3.0
+	if v := os.Getenv("AXOM_SPIKE_THRESHOLD"); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f >= 0 {
+			threshold = f
+		}
+	}
+	if threshold == 0 {
+		return nil
+	}
+	window := 100
+	if v := os.Getenv("AXOM_SPIKE_WINDOW"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= spikeMinSamples {
+			window = n
+		}
+	}
+	return &TokenSpikeDetector{
+		threshold: threshold,
+		window:    window,
+		agents:    make(map[string]*tokenWindow),
+	}
+}
+
+// Apply compares the signal's total tokens against its agent's window,
+// appending an alert to the signal on a spike, then adds it to the window.
+// Signals without token usage are ignored.
+func (d *TokenSpikeDetector) Apply(signal *models.Signal) {
+	if d == nil {
+		return
+	}
+	tokens := usageInt(signal.Metadata, "total_tokens")
+	if tokens <= 0 {
+		return
+	}
+	value := float64(tokens)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	w := d.agents[signal.AgentID]
+	if w == nil {
+		if len(d.agents) >= spikeMaxAgents {
+			return
+		}
+		w = &tokenWindow{values: make([]float64, 0, d.window)}
+		d.agents[signal.AgentID] = w
+	}
+
+	if len(w.values) >= spikeMinSamples {
+		mean, stddev := w.stats()
+		stddev = max(stddev, mean*spikeMinStddevRatio)
+		if z := (value - mean) / stddev; z > d.threshold {
+			signal.Alerts = append(signal.Alerts, models.Alert{
+				Type: "warning",
+				Message: fmt.Sprintf("Agent %s used %d tokens in one request, %.1fσ above its mean of %.0f",
+					signal.AgentID, tokens, z, mean),
+				Severity: "high",
+				Metadata: map[string]interface{}{
+					"agent_id":  signal.AgentID,
+					"anomaly":   "token_spike",
+					"tokens":    tokens,
+					"mean":      mean,
+					"stddev":    stddev,
+					"z_score":   z,
+					"threshold": d.threshold,
+					"samples":   len(w.values),
+				},
+				Timestamp: time.Now(),
+			})
+		}
+	}
+	w.add(value, d.window)
+}
+
+// add records value, overwriting the oldest once the window is full
+func (w *tokenWindow) add(value float64, size int) {
+	if len(w.values) < size {
+		w.values = append(w.values, value)
+		return
+	}
+	w.values[w.next] = value
+	w.next = (w.next + 1) % size
+}
+
+// stats returns the mean and population standard deviation of the window
+func (w *tokenWindow) stats() (mean, stddev float64) {
+	for _, v := range w.values {
+		mean += v
+	}
+	mean /= float64(len(w.values))
+	var variance float64
+	for _, v := range w.values {
+		variance += (v - mean) * (v - mean)
+	}
+	variance /= float64(len(w.values))
+	return mean, math.Sqrt(variance)
+}
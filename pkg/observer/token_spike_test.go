@@ -0,0 +1,116 @@
+package observer
+
+import (
+	"testing"
+
+	"axom-observer/pkg/models"
+)
+
+// tokenSignal returns a signal from agent that used tokens in total
+func tokenSignal(agent string, tokens int) *models.Signal {
+	return &models.Signal{AgentID: agent, Metadata: map[string]interface{}{"total_tokens": tokens}}
+}
+
+// feedBaseline applies n requests alternating between 90 and 110 tokens,
+// a mean of 100 and a deviation of 10, and fails on any alert
+func feedBaseline(t *testing.T, d *TokenSpikeDetector, agent string, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		signal := tokenSignal(agent, 90+20*(i%2))
+		d.Apply(signal)
+		if len(signal.Alerts) != 0 {
+			t.Fatalf("baseline request %d raised %v", i, signal.Alerts)
+		}
+	}
+}
+
+func TestTokenSpikeAlert(t *testing.T) {
+	d := NewTokenSpikeDetector()
+	feedBaseline(t, d, "agent-1", 40)
+
+	// 3σ above the mean is 130; 125 is within it
+	normal := tokenSignal("agent-1", 125)
+	d.Apply(normal)
+	if len(normal.Alerts) != 0 {
+		t.Errorf("125 tokens raised %v, want no alert within 3σ", normal.Alerts)
+	}
+
+	spike := tokenSignal("agent-1", 5000)
+	d.Apply(spike)
+	if len(spike.Alerts) != 1 {
+		t.Fatalf("5000 tokens raised %d alerts, want 1", len(spike.Alerts))
+	}
+	alert := spike.Alerts[0]
+	if alert.Severity != "high" || alert.Metadata["anomaly"] != "token_spike" || alert.Metadata["agent_id"] != "agent-1" {
+		t.Errorf("alert = %+v, want a high-severity token_spike for agent-1", alert)
+	}
+	if z, _ := alert.Metadata["z_score"].(float64); z < 400 || z > 500 {
+		t.Errorf("z_score = %v, want about (5000-100)/10", alert.Metadata["z_score"])
+	}
+}
+
+func TestTokenSpikeNeedsBaseline(t *testing.T) {
+	d := NewTokenSpikeDetector()
+	feedBaseline(t, d, "agent-1", spikeMinSamples-1)
+	spike := tokenSignal("agent-1", 5000)
+	d.Apply(spike)
+	if len(spike.Alerts) != 0 {
+		t.Errorf("spike raised %v before the window had %d samples", spike.Alerts, spikeMinSamples)
+	}
+}
+
+func TestTokenSpikePerAgent(t *testing.T) {
+	d := NewTokenSpikeDetector()
+	feedBaseline(t, d, "small", 40)
+	for i := 0; i < 40; i++ {
+		d.Apply(tokenSignal("large", 5000+i%2))
+	}
+
+	// Usual for one agent, a spike for the other
+	large := tokenSignal("large", 5000)
+	d.Apply(large)
+	if len(large.Alerts) != 0 {
+		t.Errorf("5000 tokens for the large agent raised %v", large.Alerts)
+	}
+	small := tokenSignal("small", 5000)
+	d.Apply(small)
+	if len(small.Alerts) != 1 {
+		t.Errorf("5000 tokens for the small agent raised %d alerts, want 1", len(small.Alerts))
+	}
+}
+
+func TestTokenSpikeWindowBounded(t *testing.T) {
+	t.Setenv("AXOM_SPIKE_WINDOW", "25")
+	d := NewTokenSpikeDetector()
+	feedBaseline(t, d, "agent-1", 100)
+	w := d.agents["agent-1"]
+	if len(w.values) != 25 || cap(w.values) != 25 {
+		t.Errorf("window holds %d values (cap %d), want 25", len(w.values), cap(w.values))
+	}
+
+	// Once the usage level moves, the window follows it
+	for i := 0; i < 25; i++ {
+		d.Apply(tokenSignal("agent-1", 1000+i%2))
+	}
+	moved := tokenSignal("agent-1", 1000)
+	d.Apply(moved)
+	if len(moved.Alerts) != 0 {
+		t.Errorf("1000 tokens raised %v after the window filled with that level", moved.Alerts)
+	}
+}
+
+func TestTokenSpikeDisabledAndIgnored(t *testing.T) {
+	t.Setenv("AXOM_SPIKE_THRESHOLD", "0")
+	if d := NewTokenSpikeDetector(); d != nil {
+		t.Errorf("AXOM_SPIKE_THRESHOLD=0 gave a detector, want disabled")
+	}
+	var disabled *TokenSpikeDetector
+	disabled.Apply(tokenSignal("agent-1", 5000))
+
+	t.Setenv("AXOM_SPIKE_THRESHOLD", "")
+	d := NewTokenSpikeDetector()
+	d.Apply(&models.Signal{AgentID: "agent-1", Metadata: map[string]interface{}{}})
+	if len(d.agents) != 0 {
+		t.Errorf("a signal without token usage was tracked")
+	}
+}
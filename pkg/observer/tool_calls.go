@@ -0,0 +1,101 @@
+package observer
+
+import (
+	"encoding/json"
+)
+
+// toolCall is a tool invocation requested by the model. Arguments are kept as
+// the JSON string the model produced.
+type toolCall struct {
+	ID        string `json:"id,omitempty"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// extractOpenAIToolCalls records choices[0].message.tool_calls (and the
+// legacy function_call) as response["tool_calls"]
+func extractOpenAIToolCalls(response map[string]interface{}, jsonData map[string]interface{}) {
+	choices, ok := jsonData["choices"].([]interface{})
+	if !ok || len(choices) == 0 {
+		return
+	}
+	choice, ok := choices[0].(map[string]interface{})
+	if !ok {
+		return
+	}
+	message, ok := choice["message"].(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	var calls []toolCall
+	if toolCalls, ok := message["tool_calls"].([]interface{}); ok {
+		for _, item := range toolCalls {
+			tc, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			fn, ok := tc["function"].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			call := toolCall{}
+			call.ID, _ = tc["id"].(string)
+			call.Name, _ = fn["name"].(string)
+			call.Arguments, _ = fn["arguments"].(string)
+			calls = append(calls, call)
+		}
+	} else if fn, ok := message["function_call"].(map[string]interface{}); ok {
+		call := toolCall{}
+		call.Name, _ = fn["name"].(string)
+		call.Arguments, _ = fn["arguments"].(string)
+		calls = append(calls, call)
+	}
+	recordToolCalls(response, calls)
+}
+
+// extractAnthropicToolUse records tool_use content blocks as
+// response["tool_calls"], encoding each block's input object as arguments
+func extractAnthropicToolUse(response map[string]interface{}, jsonData map[string]interface{}) {
+	content, ok := jsonData["content"].([]interface{})
+	if !ok {
+		return
+	}
+	var calls []toolCall
+	for _, item := range content {
+		block, ok := item.(map[string]interface{})
+		if !ok || block["type"] != "tool_use" {
+			continue
+		}
+		call := toolCall{}
+		call.ID, _ = block["id"].(string)
+		call.Name, _ = block["name"].(string)
+		if input, ok := block["input"]; ok {
+			if args, err := json.Marshal(input); err == nil {
+				call.Arguments = string(args)
+			}
+		}
+		calls = append(calls, call)
+	}
+	recordToolCalls(response, calls)
+}
+
+// recordToolCalls stores calls and the invoked tool names in response
+func recordToolCalls(response map[string]interface{}, calls []toolCall) {
+	if len(calls) == 0 {
+		return
+	}
+	names := make([]string, 0, len(calls))
+	for _, call := range calls {
+		names = append(names, call.Name)
+	}
+	response["tool_calls"] = calls
+	response["tool_names"] = names
+	response["tool_call_count"] = len(calls)
+}
+
+// hasToolCalls reports whether the response invoked any tools
+func hasToolCalls(response map[string]interface{}) bool {
+	_, ok := response["tool_calls"]
+	return ok
+}
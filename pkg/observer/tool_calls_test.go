@@ -0,0 +1,137 @@
+package observer
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+const openAIToolCallResponse = `{
+	"id": "chatcmpl-1",
+	"model": "gpt-4o",
+	"choices": [{
+		"index": 0,
+		"finish_reason": "tool_calls",
+		"message": {
+			"role": "assistant",
+			"content": null,
+			"tool_calls": [
+				{"id": "call_1", "type": "function", "function": {"name": "get_weather", "arguments": "{\"city\":\"Paris\"}"}},
+				{"id": "call_2", "type": "function", "function": {"name": "get_time", "arguments": "{\"tz\":\"CET\"}"}}
+			]
+		}
+	}],
+	"usage": {"prompt_tokens": 20, "completion_tokens": 8, "total_tokens": 28}
+}`
+
+const anthropicToolUseResponse = `{
+	"id": "msg_1",
+	"type": "message",
+	"role": "assistant",
+	"model": "claude-3-5-sonnet-20241022",
+	"stop_reason": "tool_use",
+	"content": [
+		{"type": "text", "text": "Let me look that up."},
+		{"type": "tool_use", "id": "toolu_1", "name": "search", "input": {"query": "weather paris"}}
+	],
+	"usage": {"input_tokens": 30, "output_tokens": 12}
+}`
+
+func decodeJSON(t *testing.T, body string) map[string]interface{} {
+	t.Helper()
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(body), &data); err != nil {
+		t.Fatalf("invalid JSON fixture: %v", err)
+	}
+	return data
+}
+
+func TestExtractOpenAIToolCalls(t *testing.T) {
+	response := map[string]interface{}{}
+	extractOpenAIToolCalls(response, decodeJSON(t, openAIToolCallResponse))
+
+	want := []toolCall{
+		{ID: "call_1", Name: "get_weather", Arguments: `{"city":"Paris"}`},
+		{ID: "call_2", Name: "get_time", Arguments: `{"tz":"CET"}`},
+	}
+	if got := response["tool_calls"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("tool_calls = %+v, want %+v", got, want)
+	}
+	if got := response["tool_names"]; !reflect.DeepEqual(got, []string{"get_weather", "get_time"}) {
+		t.Errorf("tool_names = %v", got)
+	}
+	if got := response["tool_call_count"]; got != 2 {
+		t.Errorf("tool_call_count = %v, want 2", got)
+	}
+}
+
+func TestExtractOpenAILegacyFunctionCall(t *testing.T) {
+	response := map[string]interface{}{}
+	extractOpenAIToolCalls(response, decodeJSON(t, `{"choices": [{"message": {
+		"role": "assistant",
+		"function_call": {"name": "lookup", "arguments": "{\"id\":7}"}
+	}}]}`))
+
+	want := []toolCall{{Name: "lookup", Arguments: `{"id":7}`}}
+	if got := response["tool_calls"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("tool_calls = %+v, want %+v", got, want)
+	}
+}
+
+func TestExtractAnthropicToolUse(t *testing.T) {
+	response := map[string]interface{}{}
+	extractAnthropicToolUse(response, decodeJSON(t, anthropicToolUseResponse))
+
+	want := []toolCall{{ID: "toolu_1", Name: "search", Arguments: `{"query":"weather paris"}`}}
+	if got := response["tool_calls"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("tool_calls = %+v, want %+v", got, want)
+	}
+}
+
+func TestNoToolCalls(t *testing.T) {
+	response := map[string]interface{}{}
+	extractOpenAIToolCalls(response, decodeJSON(t, okChatResponse))
+	extractAnthropicToolUse(response, decodeJSON(t, `{"content": [{"type": "text", "text": "hi"}]}`))
+	if hasToolCalls(response) {
+		t.Errorf("responses without tool calls recorded %v", response["tool_calls"])
+	}
+}
+
+func TestToolCallResponsesSetOperation(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		body     string
+		response string
+		tools    []string
+	}{
+		{
+			name:     "openai",
+			url:      "http://api.openai.com/v1/chat/completions",
+			body:     `{"model": "gpt-4o", "messages": [{"role": "user", "content": "Weather and time in Paris?"}]}`,
+			response: openAIToolCallResponse,
+			tools:    []string{"get_weather", "get_time"},
+		},
+		{
+			name:     "anthropic",
+			url:      "http://api.anthropic.com/v1/messages",
+			body:     `{"model": "claude-3-5-sonnet-20241022", "max_tokens": 100, "messages": [{"role": "user", "content": "Weather in Paris?"}]}`,
+			response: anthropicToolUseResponse,
+			tools:    []string{"search"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "application/json", tt.response))
+			signal := proxySignal(t, p, signalCh, jsonRequest(tt.url, tt.body))
+
+			if signal.Operation != "tool_call" {
+				t.Errorf("Operation = %q, want tool_call", signal.Operation)
+			}
+			if got := signal.Metadata["tool_names"]; !reflect.DeepEqual(got, tt.tools) {
+				t.Errorf("tool_names = %v, want %v", got, tt.tools)
+			}
+		})
+	}
+}
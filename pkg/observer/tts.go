@@ -0,0 +1,103 @@
+package observer
+
+import (
+	"encoding/binary"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ttsProviders are the providers whose responses are synthesized audio
+var ttsProviders = map[string]bool{
+	"ElevenLabs":   true,
+	"PlayHT":       true,
+	"Amazon Polly": true,
+	"Azure TTS":    true,
+}
+
+// azureSampleRatePattern matches the sample rate in Azure output formats
+// such as "audio-16khz-128kbitrate-mono-mp3" or "riff-24khz-16bit-mono-pcm"
+var azureSampleRatePattern = regexp.MustCompile(`(\d+)khz`)
+
+// isTTSProvider reports whether the provider returns synthesized audio
+func isTTSProvider(name string) bool {
+	return ttsProviders[name]
+}
+
+// extractTTSRequestFormat records the requested audio output format and
+// sample rate. jsonData may be nil when the body isn't JSON (e.g. Azure SSML).
+func extractTTSRequestFormat(request map[string]interface{}, r *http.Request, jsonData map[string]interface{}, provider *AIProvider) {
+	switch provider.Name {
+	case "ElevenLabs":
+		// output_format is a query param like "mp3_44100_128" (codec_rate_bitrate)
+		format := r.URL.Query().Get("output_format")
+		if format == "" {
+			format, _ = jsonData["output_format"].(string)
+		}
+		if format == "" {
+			return
+		}
+		request["audio_output_format"] = format
+		parts := strings.Split(format, "_")
+		if len(parts) >= 2 {
+			if rate, err := strconv.Atoi(parts[1]); err == nil {
+				request["audio_sample_rate"] = rate
+			}
+		}
+		if len(parts) >= 3 {
+			if bitrate, err := strconv.Atoi(parts[2]); err == nil {
+				request["audio_bitrate_kbps"] = bitrate
+			}
+		}
+	case "PlayHT":
+		if format, ok := jsonData["output_format"].(string); ok {
+			request["audio_output_format"] = format
+		}
+		if rate, ok := jsonData["sample_rate"].(float64); ok {
+			request["audio_sample_rate"] = int(rate)
+		}
+	case "Amazon Polly":
+		if format, ok := jsonData["OutputFormat"].(string); ok {
+			request["audio_output_format"] = format
+		}
+		// Polly sends the sample rate as a string
+		if rate, ok := jsonData["SampleRate"].(string); ok {
+			if n, err := strconv.Atoi(rate); err == nil {
+				request["audio_sample_rate"] = n
+			}
+		}
+	case "Azure TTS":
+		format := r.Header.Get("X-Microsoft-OutputFormat")
+		if format == "" {
+			return
+		}
+		request["audio_output_format"] = format
+		if m := azureSampleRatePattern.FindStringSubmatch(strings.ToLower(format)); m != nil {
+			if khz, err := strconv.Atoi(m[1]); err == nil {
+				request["audio_sample_rate"] = khz * 1000
+			}
+		}
+	}
+}
+
+// recordTTSResponseAudio records the size and content type of a synthesized
+// audio response, and the sample rate when the audio is a WAV container
+func recordTTSResponseAudio(response map[string]interface{}, provider *AIProvider, header http.Header, bodyBytes []byte) {
+	if !isTTSProvider(provider.Name) {
+		return
+	}
+	contentType := header.Get("Content-Type")
+	if strings.HasPrefix(contentType, "application/json") {
+		// Error or job-status payload rather than audio
+		return
+	}
+	response["audio_bytes"] = len(bodyBytes)
+	if contentType != "" {
+		response["audio_content_type"] = contentType
+	}
+	// RIFF/WAVE header: sample rate is a little-endian uint32 at offset 24
+	if len(bodyBytes) >= 28 && string(bodyBytes[0:4]) == "RIFF" && string(bodyBytes[8:12]) == "WAVE" {
+		response["audio_sample_rate"] = int(binary.LittleEndian.Uint32(bodyBytes[24:28]))
+	}
+}
@@ -0,0 +1,55 @@
+package observer
+
+import (
+	"encoding/binary"
+	"net/http"
+	"testing"
+)
+
+func TestElevenLabsOutputFormatIsRecorded(t *testing.T) {
+	audio := "ID3\x04\x00\x00\x00\x00\x00\x00mp3 frames"
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "audio/mpeg", audio))
+
+	signal := proxySignal(t, p, signalCh, jsonRequest(
+		"http://api.elevenlabs.io/v1/text-to-speech/21m00Tcm4TlvDq8ikWAM?output_format=mp3_44100_128",
+		`{"text":"Hello there","model_id":"eleven_multilingual_v2"}`))
+
+	for key, want := range map[string]interface{}{
+		"audio_output_format": "mp3_44100_128",
+		"audio_sample_rate":   44100,
+		"audio_bitrate_kbps":  128,
+		"audio_bytes":         len(audio),
+		"audio_content_type":  "audio/mpeg",
+	} {
+		if got := signal.Metadata[key]; got != want {
+			t.Errorf("Metadata[%q] = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestTTSResponseWAVSampleRate(t *testing.T) {
+	wav := make([]byte, 44)
+	copy(wav[0:4], "RIFF")
+	copy(wav[8:12], "WAVE")
+	binary.LittleEndian.PutUint32(wav[24:28], 24000)
+
+	response := make(map[string]interface{})
+	header := http.Header{"Content-Type": []string{"audio/wav"}}
+	recordTTSResponseAudio(response, &AIProvider{Name: "Azure TTS"}, header, wav)
+
+	if got := response["audio_sample_rate"]; got != 24000 {
+		t.Errorf("audio_sample_rate = %v, want 24000", got)
+	}
+	if got := response["audio_bytes"]; got != len(wav) {
+		t.Errorf("audio_bytes = %v, want %d", got, len(wav))
+	}
+}
+
+func TestTTSResponseJSONIsNotAudio(t *testing.T) {
+	response := make(map[string]interface{})
+	header := http.Header{"Content-Type": []string{"application/json"}}
+	recordTTSResponseAudio(response, &AIProvider{Name: "ElevenLabs"}, header, []byte(`{"detail":"quota exceeded"}`))
+	if len(response) != 0 {
+		t.Errorf("JSON response recorded as audio: %v", response)
+	}
+}
@@ -0,0 +1,88 @@
+package observer
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+)
+
+// maxFormValueBytes bounds the value read from a non-file multipart field
+const maxFormValueBytes = 1 << 10
+
+// uploadFormFields are the multipart text fields copied into request metadata
+var uploadFormFields = map[string]bool{
+	"model":           true,
+	"language":        true,
+	"response_format": true,
+}
+
+// parseUploadRequest records metadata for request bodies that aren't JSON:
+// multipart/form-data uploads (e.g. OpenAI /v1/audio/transcriptions) get
+// their form field names, model and file part details; raw audio bodies
+// (e.g. Deepgram /v1/listen) get their size and mime type. bodyBytes may be
+// only the head of a streamed upload, in which case fields after the cut
+// are missing.
+func parseUploadRequest(request map[string]interface{}, r *http.Request, bodyBytes []byte) {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return
+	}
+
+	switch {
+	case mediaType == "multipart/form-data":
+		if boundary := params["boundary"]; boundary != "" {
+			parseMultipartHead(request, multipart.NewReader(bytes.NewReader(bodyBytes), boundary))
+		}
+	case strings.HasPrefix(mediaType, "audio/"):
+		request["audio_mime_type"] = mediaType
+		if r.ContentLength >= 0 {
+			request["audio_bytes"] = r.ContentLength
+		}
+	default:
+		return
+	}
+
+	// Deepgram and similar APIs take the model as a query parameter
+	if _, ok := request["model"]; !ok {
+		if model := r.URL.Query().Get("model"); model != "" {
+			request["model"] = model
+		}
+	}
+}
+
+// parseMultipartHead reads the parts of a multipart body until it ends or is
+// cut off
+func parseMultipartHead(request map[string]interface{}, reader *multipart.Reader) {
+	var fields []string
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			break
+		}
+		name := part.FormName()
+		if name == "" {
+			continue
+		}
+		fields = append(fields, name)
+
+		if part.FileName() != "" {
+			request["audio_filename"] = part.FileName()
+			if contentType := part.Header.Get("Content-Type"); contentType != "" {
+				request["audio_mime_type"] = contentType
+			}
+			continue
+		}
+		if uploadFormFields[name] {
+			value, err := io.ReadAll(io.LimitReader(part, maxFormValueBytes))
+			if err == nil && len(value) > 0 {
+				request[name] = string(value)
+			}
+		}
+	}
+	if len(fields) > 0 {
+		request["form_fields"] = fields
+	}
+}
@@ -0,0 +1,63 @@
+package observer
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestMultipartTranscriptionRequest(t *testing.T) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	file, _ := form.CreateFormFile("file", "meeting.mp3")
+	file.Write(bytes.Repeat([]byte{0xff}, 4096))
+	form.WriteField("model", "whisper-1")
+	form.WriteField("language", "en")
+	form.WriteField("prompt", "Quarterly planning")
+	form.Close()
+
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "application/json", `{"text": "Let's begin."}`))
+	req := httptest.NewRequest(http.MethodPost, "http://api.openai.com/v1/audio/transcriptions", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	signal := proxySignal(t, p, signalCh, req)
+
+	if got := signal.Metadata["model"]; got != "whisper-1" {
+		t.Errorf("model = %v, want whisper-1", got)
+	}
+	if got := signal.Metadata["language"]; got != "en" {
+		t.Errorf("language = %v, want en", got)
+	}
+	if got := signal.Metadata["audio_filename"]; got != "meeting.mp3" {
+		t.Errorf("audio_filename = %v, want meeting.mp3", got)
+	}
+	if got, want := signal.Metadata["form_fields"], []string{"file", "model", "language", "prompt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("form_fields = %v, want %v", got, want)
+	}
+	if _, ok := signal.Metadata["prompt"]; ok {
+		t.Errorf("free-text form field copied into metadata")
+	}
+}
+
+func TestRawAudioRequest(t *testing.T) {
+	audio := bytes.Repeat([]byte{0x52}, 32000)
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "application/json", `{"results": {}}`))
+	req := httptest.NewRequest(http.MethodPost, "http://api.deepgram.com/v1/listen?model=nova-2", bytes.NewReader(audio))
+	req.Header.Set("Content-Type", "audio/wav")
+	signal := proxySignal(t, p, signalCh, req)
+
+	if got := signal.Metadata["provider"]; got != "Deepgram" {
+		t.Errorf("provider = %v, want Deepgram", got)
+	}
+	if got := signal.Metadata["audio_mime_type"]; got != "audio/wav" {
+		t.Errorf("audio_mime_type = %v, want audio/wav", got)
+	}
+	if got := signal.Metadata["audio_bytes"]; got != int64(len(audio)) {
+		t.Errorf("audio_bytes = %v, want %d", got, len(audio))
+	}
+	if got := signal.Metadata["model"]; got != "nova-2" {
+		t.Errorf("model = %v, want nova-2 from the query", got)
+	}
+}
@@ -0,0 +1,186 @@
+package observer
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// Environment variables:
+//   AXOM_UPSTREAM_PROXY - Optional. Proxy URL for outbound AI requests, overriding HTTPS_PROXY/HTTP_PROXY/NO_PROXY. Default: taken from the environment
+
+// upstreamProxyFromEnv returns the proxy selection function for forwarded
+// requests: AXOM_UPSTREAM_PROXY if set and valid, else the standard proxy
+// environment variables. Loopback destinations bypass AXOM_UPSTREAM_PROXY,
+// as they do the environment variables.
+func upstreamProxyFromEnv(logger *log.Logger) func(*http.Request) (*url.URL, error) {
+	raw := os.Getenv("AXOM_UPSTREAM_PROXY")
+	if raw == "" {
+		return http.ProxyFromEnvironment
+	}
+	proxyURL, err := url.Parse(raw)
+	if err != nil || proxyURL.Host == "" {
+		logger.Printf("⚠️ Ignoring invalid AXOM_UPSTREAM_PROXY %q, using proxy environment variables", raw)
+		return http.ProxyFromEnvironment
+	}
+	return func(req *http.Request) (*url.URL, error) {
+		// Local services (e.g. the mock provider) are never proxied
+		host := req.URL.Hostname()
+		if host == "localhost" {
+			return nil, nil
+		}
+		if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+			return nil, nil
+		}
+		return proxyURL, nil
+	}
+}
+
+// upstreamTimeout bounds a whole forwarded exchange, including reading the
+// response body
+const upstreamTimeout = 30 * time.Second
+
+// newUpstreamTransport creates the transport used to forward requests to AI
+// providers, routed through the configured upstream proxy. Idle connections
+// are kept per provider host so repeat calls skip the TLS handshake.
+func newUpstreamTransport(logger *log.Logger) *http.Transport {
+	return &http.Transport{
+		Proxy:                 upstreamProxyFromEnv(logger),
+		TLSClientConfig:       &tls.Config{InsecureSkipVerify: false},
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          256,
+		MaxIdleConnsPerHost:   32,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+// newUpstreamClient creates the client a proxy reuses for all forwarded
+// requests
+func newUpstreamClient(logger *log.Logger) *http.Client {
+	return &http.Client{
+		Timeout:   upstreamTimeout,
+		Transport: newUpstreamTransport(logger),
+	}
+}
+
+// Forwarder sends a request on to the AI provider. Proxies forward with the
+// shared upstream client unless another Forwarder is injected, e.g. a fake
+// returning canned responses.
+type Forwarder interface {
+	Forward(req *http.Request) (*http.Response, error)
+}
+
+// ForwarderFunc adapts a function to a Forwarder
+type ForwarderFunc func(req *http.Request) (*http.Response, error)
+
+// Forward calls f(req)
+func (f ForwarderFunc) Forward(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// clientForwarder forwards requests with an HTTP client
+type clientForwarder struct {
+	client *http.Client
+}
+
+// Forward sends req with the client. WebSocket upgrades go straight to its
+// transport, as the client timeout would cut the upgraded connection off.
+func (f clientForwarder) Forward(req *http.Request) (*http.Response, error) {
+	if isWebSocketUpgrade(req) && f.client.Transport != nil {
+		return f.client.Transport.RoundTrip(req)
+	}
+	return f.client.Do(req)
+}
+
+// newUpstreamForwarder creates the default Forwarder, backed by the upstream
+// client
+func newUpstreamForwarder(logger *log.Logger) Forwarder {
+	return clientForwarder{client: newUpstreamClient(logger)}
+}
+
+// upstreamDialer opens the raw connections CONNECT tunnels are relayed
+// over, through the upstream proxy when one applies to the destination, so
+// tunnels leave the host the same way forwarded requests do
+type upstreamDialer struct {
+	proxy   func(*http.Request) (*url.URL, error)
+	timeout time.Duration
+}
+
+// newUpstreamDialer creates the dialer for tunnels, routed through the
+// configured upstream proxy
+func newUpstreamDialer(logger *log.Logger) *upstreamDialer {
+	return &upstreamDialer{proxy: upstreamProxyFromEnv(logger), timeout: tunnelDialTimeout}
+}
+
+// dial connects to addr, a host:port, either directly or by a CONNECT
+// through the upstream proxy
+func (d *upstreamDialer) dial(network, addr string) (net.Conn, error) {
+	proxyURL, err := d.proxy(&http.Request{URL: &url.URL{Scheme: "https", Host: addr}})
+	if err != nil {
+		return nil, err
+	}
+	if proxyURL == nil {
+		return net.DialTimeout(network, addr, d.timeout)
+	}
+
+	proxyAddr := proxyURL.Host
+	if proxyURL.Port() == "" {
+		port := "80"
+		if proxyURL.Scheme == "https" {
+			port = "443"
+		}
+		proxyAddr = net.JoinHostPort(proxyURL.Hostname(), port)
+	}
+	conn, err := net.DialTimeout("tcp", proxyAddr, d.timeout)
+	if err != nil {
+		return nil, err
+	}
+	if proxyURL.Scheme == "https" {
+		conn = tls.Client(conn, &tls.Config{ServerName: proxyURL.Hostname()})
+	}
+	conn.SetDeadline(time.Now().Add(d.timeout))
+
+	connectReq := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if user := proxyURL.User; user != nil {
+		password, _ := user.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		connectReq.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := connectReq.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, connectReq)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("upstream proxy refused CONNECT to %s: %s", addr, resp.Status)
+	}
+	conn.SetDeadline(time.Time{})
+
+	if br.Buffered() > 0 {
+		// Bytes the proxy already relayed from the destination
+		peeked, _ := br.Peek(br.Buffered())
+		return newPrefixConn(conn, peeked), nil
+	}
+	return conn, nil
+}
@@ -0,0 +1,99 @@
+package observer
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Environment variables:
+//   AXOM_UPSTREAM_BASE_URLS - Optional. Comma-separated provider=URL or host=URL pairs that redirect forwarded AI requests to another base URL, e.g. "OpenAI=https://gateway.internal/openai,api.anthropic.com=https://eu.example.com". Default: none
+//   AXOM_DEMO_MODE          - Optional. Set to "1" to forward requests for localhost to the demo mock provider on 127.0.0.1:9999. Default: disabled
+
+// demoUpstreamURL is the mock AI provider of the demo setup
+const demoUpstreamURL = "http://127.0.0.1:9999"
+
+// upstreamOverrides maps lowercased provider names and hosts to the base URL
+// their requests are forwarded to instead
+type upstreamOverrides map[string]*url.URL
+
+// upstreamOverridesFromEnv parses AXOM_UPSTREAM_BASE_URLS, adding the demo
+// mock provider for localhost when AXOM_DEMO_MODE is set. Invalid entries
+// are logged and skipped.
+func upstreamOverridesFromEnv(logger *log.Logger) upstreamOverrides {
+	overrides := make(upstreamOverrides)
+	if os.Getenv("AXOM_DEMO_MODE") == "1" {
+		demo, _ := url.Parse(demoUpstreamURL)
+		overrides["localhost"] = demo
+		overrides["127.0.0.1"] = demo
+	}
+	for _, entry := range strings.Split(os.Getenv("AXOM_UPSTREAM_BASE_URLS"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, raw, ok := strings.Cut(entry, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		base, err := url.Parse(strings.TrimSpace(raw))
+		if !ok || key == "" || err != nil || base.Scheme == "" || base.Host == "" {
+			logger.Printf("⚠️ Ignoring invalid AXOM_UPSTREAM_BASE_URLS entry %q", entry)
+			continue
+		}
+		overrides[key] = base
+	}
+	return overrides
+}
+
+// lookup returns the base URL for a request to host (with or without port)
+// from provider. Hosts take precedence over provider names.
+func (o upstreamOverrides) lookup(host, provider string) *url.URL {
+	if len(o) == 0 {
+		return nil
+	}
+	host = strings.ToLower(host)
+	if base, ok := o[host]; ok {
+		return base
+	}
+	if hostname, _, ok := strings.Cut(host, ":"); ok {
+		if base, ok := o[hostname]; ok {
+			return base
+		}
+	}
+	return o[strings.ToLower(provider)]
+}
+
+// rewrite points u at the override base URL for its host or provider,
+// appending its path to the base path, and reports whether it did
+func (o upstreamOverrides) rewrite(u *url.URL, provider string) bool {
+	base := o.lookup(u.Host, provider)
+	if base == nil {
+		return false
+	}
+	u.Scheme = base.Scheme
+	u.Host = base.Host
+	u.Path = strings.TrimSuffix(base.Path, "/") + u.Path
+	u.RawPath = ""
+	return true
+}
+
+// target returns the URL to forward r to: its own URL, or the override base
+// URL for its host or provider. Direct requests to the proxy, e.g. from a
+// client using it as base URL, have no destination of their own and need an
+// override.
+func (o upstreamOverrides) target(r *http.Request, provider string) (*url.URL, error) {
+	target := *r.URL
+	if target.Host == "" {
+		target.Scheme = "http"
+		target.Host = r.Host
+	}
+	if o.rewrite(&target, provider) {
+		return &target, nil
+	}
+	if !r.URL.IsAbs() {
+		return nil, fmt.Errorf("no upstream for direct request to %s; map it in AXOM_UPSTREAM_BASE_URLS", r.Host)
+	}
+	return &target, nil
+}
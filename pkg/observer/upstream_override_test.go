@@ -0,0 +1,83 @@
+package observer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// forwardedURL proxies req and returns the URL it was forwarded to, or ""
+// if it wasn't forwarded
+func forwardedURL(t *testing.T, req *http.Request) string {
+	t.Helper()
+	var forwarded string
+	p, _ := newTestHTTPProxy(t, ForwarderFunc(func(out *http.Request) (*http.Response, error) {
+		forwarded = out.URL.String()
+		return cannedResponse(http.StatusOK, "application/json", okChatResponse).Forward(out)
+	}))
+	p.handleRequest(httptest.NewRecorder(), req)
+	return forwarded
+}
+
+func TestUpstreamOverrideByProvider(t *testing.T) {
+	t.Setenv("AXOM_UPSTREAM_BASE_URLS", "OpenAI=https://gateway.internal/openai/")
+	got := forwardedURL(t, chatRequest(`{"model": "gpt-4", "messages": [{"role": "user", "content": "Hi"}]}`))
+	if want := "https://gateway.internal/openai/v1/chat/completions"; got != want {
+		t.Errorf("forwarded to %s, want %s", got, want)
+	}
+}
+
+func TestUpstreamOverrideHostBeforeProvider(t *testing.T) {
+	t.Setenv("AXOM_UPSTREAM_BASE_URLS", "openai=https://gateway.internal, api.openai.com=https://eu.example.com")
+	req := jsonRequest("http://api.openai.com/v1/chat/completions?api-version=1", `{"model": "gpt-4", "messages": []}`)
+	if got, want := forwardedURL(t, req), "https://eu.example.com/v1/chat/completions?api-version=1"; got != want {
+		t.Errorf("forwarded to %s, want %s", got, want)
+	}
+}
+
+func TestNoUpstreamOverride(t *testing.T) {
+	t.Setenv("AXOM_UPSTREAM_BASE_URLS", "api.anthropic.com=https://eu.example.com")
+	got := forwardedURL(t, chatRequest(`{"model": "gpt-4", "messages": []}`))
+	if want := "http://api.openai.com/v1/chat/completions"; got != want {
+		t.Errorf("forwarded to %s, want the request's own URL", got)
+	}
+}
+
+func TestLocalhostNeedsDemoMode(t *testing.T) {
+	req := func() *http.Request {
+		return jsonRequest("http://localhost:8080/v1/chat/completions", `{"model": "gpt-4", "messages": []}`)
+	}
+	if got := forwardedURL(t, req()); got != "http://localhost:8080/v1/chat/completions" {
+		t.Errorf("forwarded to %s without AXOM_DEMO_MODE, want the request's own URL", got)
+	}
+
+	t.Setenv("AXOM_DEMO_MODE", "1")
+	if got, want := forwardedURL(t, req()), demoUpstreamURL+"/v1/chat/completions"; got != want {
+		t.Errorf("forwarded to %s in demo mode, want %s", got, want)
+	}
+}
+
+func TestUpstreamOverridesFromEnv(t *testing.T) {
+	t.Setenv("AXOM_UPSTREAM_BASE_URLS", "OpenAI=https://gateway.internal, no-equals, =https://x.example.com, anthropic=gateway.internal, azure=https://")
+	overrides := upstreamOverridesFromEnv(testLogger())
+	if len(overrides) != 1 || overrides["openai"] == nil {
+		t.Errorf("overrides = %v, want only the valid OpenAI entry", overrides)
+	}
+}
+
+func TestUpstreamTargetOfDirectRequest(t *testing.T) {
+	overrides := upstreamOverrides{}
+	// A client using the proxy as its base URL
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	req.Host = "observer:8080"
+	if _, err := overrides.target(req, "OpenAI"); err == nil {
+		t.Errorf("direct request without an override got a target")
+	}
+
+	t.Setenv("AXOM_UPSTREAM_BASE_URLS", "observer=https://api.openai.com")
+	overrides = upstreamOverridesFromEnv(testLogger())
+	target, err := overrides.target(req, "OpenAI")
+	if err != nil || target.String() != "https://api.openai.com/v1/chat/completions" {
+		t.Errorf("target = %v, %v, want the overridden OpenAI URL", target, err)
+	}
+}
@@ -0,0 +1,144 @@
+package observer
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"axom-observer/pkg/models"
+)
+
+func TestHTTPProxyEmitsSignalForChatCompletion(t *testing.T) {
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(http.StatusOK, "application/json",
+		`{"id":"chatcmpl-1","model":"gpt-4","choices":[{"message":{"role":"assistant","content":"Hi there"},"finish_reason":"stop"}],"usage":{"prompt_tokens":9,"completion_tokens":3,"total_tokens":12}}`))
+
+	signal := proxySignal(t, p, signalCh, chatRequest(`{"model":"gpt-4","messages":[{"role":"user","content":"Hello"}]}`))
+
+	if signal.Operation != "chat_completion" {
+		t.Errorf("Operation = %q, want chat_completion", signal.Operation)
+	}
+	if signal.Status != http.StatusOK {
+		t.Errorf("Status = %d, want 200", signal.Status)
+	}
+	if signal.CustomerID != "test-customer" || signal.AgentID != "test-agent" {
+		t.Errorf("identity = %q/%q, want test-customer/test-agent", signal.CustomerID, signal.AgentID)
+	}
+	for key, want := range map[string]interface{}{
+		"provider":          "OpenAI",
+		"model":             "gpt-4",
+		"prompt_preview":    "Hello",
+		"response_preview":  "Hi there",
+		"prompt_tokens":     9,
+		"completion_tokens": 3,
+		"total_tokens":      12,
+	} {
+		if got := signal.Metadata[key]; got != want {
+			t.Errorf("Metadata[%q] = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestFakeForwarderReceivesRequestAndRelaysResponse(t *testing.T) {
+	var forwarded *http.Request
+	upstream := ForwarderFunc(func(req *http.Request) (*http.Response, error) {
+		forwarded = req
+		return cannedResponse(http.StatusOK, "application/json", okChatResponse).Forward(req)
+	})
+	p, _ := newTestHTTPProxy(t, upstream)
+
+	rec := httptest.NewRecorder()
+	req := chatRequest(`{"model":"gpt-4","messages":[{"role":"user","content":"Hello"}]}`)
+	req.Header.Set("Authorization", "Bearer sk-test")
+	p.handleRequest(rec, req)
+
+	if forwarded == nil {
+		t.Fatalf("the forwarder was never called")
+	}
+	if forwarded.URL.Host != "api.openai.com" || forwarded.URL.Path != "/v1/chat/completions" {
+		t.Errorf("forwarded to %s, want the OpenAI chat completions URL", forwarded.URL)
+	}
+	if got := forwarded.Header.Get("Authorization"); got != "Bearer sk-test" {
+		t.Errorf("forwarded Authorization = %q, want the client's", got)
+	}
+	if rec.Code != http.StatusOK || rec.Body.String() != okChatResponse {
+		t.Errorf("client got %d %q, want the canned response", rec.Code, rec.Body.String())
+	}
+}
+
+func TestForwarderErrorAnswersUnavailable(t *testing.T) {
+	p, signalCh := newTestHTTPProxy(t, ForwarderFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	}))
+	rec := httptest.NewRecorder()
+	p.handleRequest(rec, chatRequest(`{"model":"gpt-4","messages":[{"role":"user","content":"Hello"}]}`))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("client got %d, want 503 when forwarding fails", rec.Code)
+	}
+	select {
+	case signal := <-signalCh:
+		if signal.Protocol != "internal" {
+			t.Errorf("signal %s emitted for a request that was never answered", signal.Operation)
+		}
+	default:
+	}
+}
+
+// newConnCountingServer starts a chat completion backend that counts the
+// connections opened to it
+func newConnCountingServer(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var conns atomic.Int32
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, okChatResponse)
+	}))
+	backend.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	backend.Start()
+	t.Cleanup(backend.Close)
+	return backend, &conns
+}
+
+func TestHTTPProxyReusesUpstreamConnections(t *testing.T) {
+	backend, conns := newConnCountingServer(t)
+	signalCh := make(chan models.Signal, 64)
+	p := NewHTTPProxy("0", signalCh, testLogger(), "test-customer", "test-agent", false, "")
+
+	for i := 0; i < 20; i++ {
+		rec := httptest.NewRecorder()
+		p.handleRequest(rec, jsonRequest(backend.URL+"/v1/chat/completions", `{"model": "gpt-4", "messages": [{"role": "user", "content": "Hi"}]}`))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("forward %d answered %d, want 200", i, rec.Code)
+		}
+	}
+	if got := conns.Load(); got != 1 {
+		t.Errorf("%d upstream connections for 20 sequential forwards, want 1 reused", got)
+	}
+}
+
+func TestHTTPSProxyReusesUpstreamConnections(t *testing.T) {
+	backend, conns := newConnCountingServer(t)
+	p := NewHTTPSProxy("0", make(chan models.Signal, 64), testLogger(), "test-customer", "test-agent")
+
+	for i := 0; i < 20; i++ {
+		req, _ := http.NewRequest(http.MethodPost, backend.URL+"/v1/chat/completions", strings.NewReader(`{"model": "gpt-4", "messages": []}`))
+		resp, err := p.inflight.forward(p.forwarder, req)
+		if err != nil {
+			t.Fatalf("forward %d: %v", i, err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	if got := conns.Load(); got != 1 {
+		t.Errorf("%d upstream connections for 20 sequential forwards, want 1 reused", got)
+	}
+}
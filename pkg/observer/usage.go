@@ -0,0 +1,61 @@
+package observer
+
+// tokenUsage is the provider-independent token count for one request
+type tokenUsage struct {
+	Prompt     int
+	Completion int
+	Total      int
+}
+
+// normalizeUsage maps the usage shapes reported by providers onto one
+// schema: OpenAI-style usage.prompt_tokens/completion_tokens/total_tokens,
+// Anthropic-style usage.input_tokens/output_tokens and Gemini
+// usageMetadata.promptTokenCount/candidatesTokenCount/totalTokenCount.
+// The total is derived when the provider omits it. ok is false when the
+// response carries no usage at all.
+func normalizeUsage(provider string, response map[string]interface{}) (usage tokenUsage, ok bool) {
+	if usageMetadata, found := response["usageMetadata"].(map[string]interface{}); found {
+		usage.Prompt = usageInt(usageMetadata, "promptTokenCount")
+		usage.Completion = usageInt(usageMetadata, "candidatesTokenCount")
+		usage.Total = usageInt(usageMetadata, "totalTokenCount")
+		ok = true
+	} else if raw, found := response["usage"].(map[string]interface{}); found {
+		if provider == "Anthropic" || hasUsageKey(raw, "input_tokens") && !hasUsageKey(raw, "prompt_tokens") {
+			// Anthropic counts cached prompt tokens separately from input_tokens
+			usage.Prompt = usageInt(raw, "input_tokens") +
+				usageInt(raw, "cache_creation_input_tokens") +
+				usageInt(raw, "cache_read_input_tokens")
+			usage.Completion = usageInt(raw, "output_tokens")
+		} else {
+			usage.Prompt = usageInt(raw, "prompt_tokens")
+			usage.Completion = usageInt(raw, "completion_tokens")
+		}
+		usage.Total = usageInt(raw, "total_tokens")
+		ok = true
+	}
+	if !ok {
+		return usage, false
+	}
+
+	if usage.Total == 0 {
+		usage.Total = usage.Prompt + usage.Completion
+	}
+	return usage, true
+}
+
+// hasUsageKey reports whether a usage object contains key
+func hasUsageKey(usage map[string]interface{}, key string) bool {
+	_, ok := usage[key]
+	return ok
+}
+
+// usageInt reads a JSON number from a usage object as an int
+func usageInt(usage map[string]interface{}, key string) int {
+	switch v := usage[key].(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	}
+	return 0
+}
@@ -0,0 +1,83 @@
+package observer
+
+import "testing"
+
+func TestNormalizeUsage(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider string
+		response string
+		want     tokenUsage
+		ok       bool
+	}{
+		{
+			name:     "openai",
+			provider: "OpenAI",
+			response: `{"usage": {"prompt_tokens": 12, "completion_tokens": 30, "total_tokens": 42}}`,
+			want:     tokenUsage{Prompt: 12, Completion: 30, Total: 42},
+			ok:       true,
+		},
+		{
+			name:     "openai without total",
+			provider: "OpenAI",
+			response: `{"usage": {"prompt_tokens": 12, "completion_tokens": 30}}`,
+			want:     tokenUsage{Prompt: 12, Completion: 30, Total: 42},
+			ok:       true,
+		},
+		{
+			name:     "anthropic",
+			provider: "Anthropic",
+			response: `{"usage": {"input_tokens": 25, "output_tokens": 10}}`,
+			want:     tokenUsage{Prompt: 25, Completion: 10, Total: 35},
+			ok:       true,
+		},
+		{
+			name:     "anthropic with prompt caching",
+			provider: "Anthropic",
+			response: `{"usage": {"input_tokens": 5, "cache_creation_input_tokens": 100, "cache_read_input_tokens": 200, "output_tokens": 10}}`,
+			want:     tokenUsage{Prompt: 305, Completion: 10, Total: 315},
+			ok:       true,
+		},
+		{
+			name:     "anthropic shape from another provider",
+			provider: "Amazon Bedrock",
+			response: `{"usage": {"input_tokens": 7, "output_tokens": 3}}`,
+			want:     tokenUsage{Prompt: 7, Completion: 3, Total: 10},
+			ok:       true,
+		},
+		{
+			name:     "gemini",
+			provider: "Google AI",
+			response: `{"usageMetadata": {"promptTokenCount": 8, "candidatesTokenCount": 7, "totalTokenCount": 15}}`,
+			want:     tokenUsage{Prompt: 8, Completion: 7, Total: 15},
+			ok:       true,
+		},
+		{
+			name:     "no usage",
+			provider: "OpenAI",
+			response: `{"choices": []}`,
+			ok:       false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := normalizeUsage(tt.provider, decodeJSON(t, tt.response))
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("normalizeUsage = %+v, %v, want %+v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestAnthropicUsageInSignal(t *testing.T) {
+	p, signalCh := newTestHTTPProxy(t, cannedResponse(200, "application/json",
+		`{"type": "message", "content": [{"type": "text", "text": "Hi"}], "usage": {"input_tokens": 25, "output_tokens": 10}}`))
+	signal := proxySignal(t, p, signalCh, jsonRequest("http://api.anthropic.com/v1/messages",
+		`{"model": "claude-3-5-sonnet-20241022", "max_tokens": 100, "messages": [{"role": "user", "content": "Hello"}]}`))
+
+	for key, want := range map[string]int{"prompt_tokens": 25, "completion_tokens": 10, "total_tokens": 35} {
+		if got := signal.Metadata[key]; got != want {
+			t.Errorf("%s = %v, want %d", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package observer
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	"axom-observer/pkg/models"
+	"axom-observer/pkg/protocols"
+)
+
+// isWebSocketUpgrade reports whether r asks to switch the connection to the
+// WebSocket protocol, as realtime APIs do over wss://
+func isWebSocketUpgrade(r *http.Request) bool {
+	if !strings.EqualFold(strings.TrimSpace(r.Header.Get("Upgrade")), "websocket") {
+		return false
+	}
+	for _, value := range r.Header.Values("Connection") {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// wsFrameTap reassembles the frames in one direction of a relayed WebSocket
+// connection and passes each complete frame to emit. Writes never fail: a
+// frame that can't be parsed or is larger than max stops the tap, not the
+// relay.
+type wsFrameTap struct {
+	buf     []byte
+	max     int
+	stopped bool
+	emit    func(frame []byte)
+}
+
+func (t *wsFrameTap) Write(b []byte) (int, error) {
+	if t.stopped {
+		return len(b), nil
+	}
+	t.buf = append(t.buf, b...)
+	for {
+		_, n, err := protocols.ParseWebSocketFrame(t.buf)
+		if errors.Is(err, protocols.ErrIncompleteFrame) && len(t.buf) <= t.max {
+			return len(b), nil
+		}
+		if err != nil {
+			t.stopped = true
+			t.buf = nil
+			return len(b), nil
+		}
+		t.emit(t.buf[:n])
+		t.buf = append(t.buf[:0], t.buf[n:]...)
+	}
+}
+
+// relayWebSocket copies bytes both ways between the client and upstream,
+// teeing what each side sends into its tap, until either side closes.
+// clientIn reads from client, including anything already buffered.
+func relayWebSocket(client net.Conn, clientIn io.Reader, upstream io.ReadWriteCloser, clientTap, upstreamTap io.Writer) {
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(upstream, io.TeeReader(clientIn, clientTap))
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(client, io.TeeReader(upstream, upstreamTap))
+		done <- struct{}{}
+	}()
+
+	// Closing both ends unblocks the other direction
+	<-done
+	upstream.Close()
+	client.Close()
+	<-done
+}
+
+// writeSwitchingProtocols writes the upstream's 101 response head to the
+// client; Response.Write would also try to copy the upgraded body
+func writeSwitchingProtocols(w io.Writer, resp *http.Response) error {
+	var head bytes.Buffer
+	fmt.Fprintf(&head, "HTTP/1.1 %s\r\n", resp.Status)
+	resp.Header.Write(&head)
+	head.WriteString("\r\n")
+	_, err := w.Write(head.Bytes())
+	return err
+}
+
+// proxyWebSocket forwards a WebSocket upgrade read off an intercepted TLS
+// connection and, once upstream switches protocols, relays frames between
+// the client and upstream. On connections to AI providers each data frame
+// becomes a signal via protocols.ProcessWebSocket; others are relayed
+// without signals.
+func (p *HTTPSProxy) proxyWebSocket(req *http.Request, clientConn net.Conn, clientIn *bufio.Reader) {
+	provider := p.providers.MatchHost(req.URL.Host)
+	customerID, agentID := p.identity.resolve(req, p.customerID, p.agentID)
+	client := endpointFromAddr(req.RemoteAddr)
+	server := p.resolver.enrich(destinationEndpoint(req))
+	path := req.URL.Path
+
+	if provider != nil {
+		p.upstreamOverrides.rewrite(req.URL, provider.Name)
+	}
+	req.RequestURI = ""
+	resp, err := p.forwarder.Forward(req)
+	if err != nil {
+		p.logger.Printf("Failed to forward WebSocket upgrade: %v", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		// Upstream refused the upgrade; pass its answer on as-is
+		resp.Write(clientConn)
+		return
+	}
+	upstream, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok {
+		p.logger.Printf("WebSocket upgrade to %s returned a non-writable body", req.URL.Host)
+		return
+	}
+	if err := writeSwitchingProtocols(clientConn, resp); err != nil {
+		p.logger.Printf("Failed to write WebSocket handshake: %v", err)
+		return
+	}
+
+	tap := func(src, dst models.Endpoint, direction string) io.Writer {
+		if provider == nil {
+			return io.Discard
+		}
+		return &wsFrameTap{max: int(p.bodyLimit), emit: func(frame []byte) {
+			p.emitWebSocketFrame(frame, src, dst, direction, path, provider.Name, customerID, agentID)
+		}}
+	}
+	if provider != nil {
+		p.logger.Printf("🔌 WebSocket opened: %s %s%s", provider.Name, req.URL.Host, path)
+	}
+	relayWebSocket(clientConn, clientIn, upstream,
+		tap(client, server, "sent"), tap(server, client, "received"))
+}
+
+// emitWebSocketFrame turns a relayed data frame into a signal. direction is
+// "sent" for client frames and "received" for upstream ones; control frames
+// produce no signal.
+func (p *HTTPSProxy) emitWebSocketFrame(frame []byte, src, dst models.Endpoint, direction, path, providerName, customerID, agentID string) {
+	signal, err := protocols.ProcessWebSocket(frame, nil, nil)
+	if err != nil || signal == nil {
+		return
+	}
+	signal.CustomerID = customerID
+	signal.AgentID = agentID
+	signal.Source = src
+	signal.Destination = dst
+	signal.Metadata["provider"] = providerName
+	signal.Metadata["endpoint"] = path
+	signal.Metadata["ws_direction"] = direction
+	recordSignalMetrics(*signal, providerName)
+
+	p.metadataFilter.apply(signal.Metadata)
+	p.metadataLimits.apply(signal.Metadata)
+
+	if !p.sampler.keep(signal, providerName) {
+		return
+	}
+	if !p.enqueuer.send(p.signalCh, *signal, providerName) {
+		p.logger.Printf("Signal channel full, dropping signal")
+	}
+}
@@ -0,0 +1,198 @@
+package observer
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"axom-observer/pkg/models"
+)
+
+// maskedHelloFrame is a masked client text frame carrying "Hello" (RFC 6455
+// section 5.7)
+var maskedHelloFrame = []byte{0x81, 0x85, 0x37, 0xfa, 0x21, 0x3d, 0x7f, 0x9f, 0x4d, 0x51, 0x58}
+
+// textFrame returns an unmasked server text frame carrying payload, which
+// must be shorter than 126 bytes
+func textFrame(payload string) []byte {
+	return append([]byte{0x81, byte(len(payload))}, payload...)
+}
+
+func TestWebSocketUpgradeRelayed(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath := filepath.Join(dir, "ca.crt"), filepath.Join(dir, "ca.key")
+	writeTestCA(t, certPath, keyPath, "test proxy CA")
+	signalCh := make(chan models.Signal, 16)
+	p := NewHTTPSProxy("0", signalCh, testLogger(), "test-customer", "test-agent")
+	if err := p.reloadCA(certPath, keyPath); err != nil {
+		t.Fatalf("load CA: %v", err)
+	}
+
+	// Upstream switches protocols and hands back one end of a pipe
+	upstreamConn, relayConn := net.Pipe()
+	defer upstreamConn.Close()
+	upgraded := make(chan *http.Request, 1)
+	p.SetForwarder(ForwarderFunc(func(req *http.Request) (*http.Response, error) {
+		upgraded <- req
+		return &http.Response{
+			StatusCode: http.StatusSwitchingProtocols,
+			Status:     "101 Switching Protocols",
+			Header: http.Header{
+				"Upgrade":              {"websocket"},
+				"Connection":           {"Upgrade"},
+				"Sec-Websocket-Accept": {"s3pPLMBiTxaQ9kYGzzhZRbK+xOo="},
+			},
+			Body: relayConn,
+		}, nil
+	}))
+	server := httptest.NewServer(http.HandlerFunc(p.handleRequest))
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("dial proxy: %v", err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "CONNECT api.openai.com:443 HTTP/1.1\r\nHost: api.openai.com:443\r\n\r\n")
+	connectResp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil || connectResp.StatusCode != http.StatusOK {
+		t.Fatalf("CONNECT = %v, %v, want 200", connectResp, err)
+	}
+	tlsConn := tls.Client(conn, &tls.Config{ServerName: "api.openai.com", InsecureSkipVerify: true})
+	io.WriteString(tlsConn, "GET /v1/realtime?model=gpt-4o-realtime-preview HTTP/1.1\r\n"+
+		"Host: api.openai.com\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n"+
+		"Sec-WebSocket-Version: 13\r\n\r\n")
+
+	clientIn := bufio.NewReader(tlsConn)
+	resp, err := http.ReadResponse(clientIn, nil)
+	if err != nil {
+		t.Fatalf("read handshake: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-Websocket-Accept") == "" {
+		t.Fatalf("handshake = %d %v, want upstream's 101", resp.StatusCode, resp.Header)
+	}
+	if req := <-upgraded; req.URL.Hostname() != "api.openai.com" || req.Header.Get("Upgrade") != "websocket" {
+		t.Errorf("forwarded upgrade to %s with Upgrade %q", req.URL.Host, req.Header.Get("Upgrade"))
+	}
+
+	// Client to upstream
+	if _, err := tlsConn.Write(maskedHelloFrame); err != nil {
+		t.Fatalf("write client frame: %v", err)
+	}
+	got := make([]byte, len(maskedHelloFrame))
+	if _, err := io.ReadFull(upstreamConn, got); err != nil || !bytes.Equal(got, maskedHelloFrame) {
+		t.Fatalf("upstream read %x, %v, want the client's frame", got, err)
+	}
+
+	// Upstream to client
+	reply := textFrame(`{"type":"response.text.delta","text":"Hi there"}`)
+	go upstreamConn.Write(reply)
+	got = make([]byte, len(reply))
+	if _, err := io.ReadFull(clientIn, got); err != nil || !bytes.Equal(got, reply) {
+		t.Fatalf("client read %x, %v, want upstream's frame", got, err)
+	}
+
+	byDirection := make(map[string]models.Signal)
+	for len(byDirection) < 2 {
+		select {
+		case signal := <-signalCh:
+			byDirection[signal.Metadata["ws_direction"].(string)] = signal
+		case <-time.After(5 * time.Second):
+			t.Fatalf("got signals for %v, want sent and received", byDirection)
+		}
+	}
+	for direction, signal := range byDirection {
+		if signal.Protocol != "websocket" || signal.Operation != "ws_text" {
+			t.Errorf("%s signal = %s %s, want websocket ws_text", direction, signal.Protocol, signal.Operation)
+		}
+		if signal.Metadata["provider"] != "OpenAI" || signal.Metadata["endpoint"] != "/v1/realtime" {
+			t.Errorf("%s signal metadata = %v, want OpenAI /v1/realtime", direction, signal.Metadata)
+		}
+	}
+	if got := byDirection["received"].Metadata["transcript"]; got != "Hi there" {
+		t.Errorf("received transcript = %v, want Hi there", got)
+	}
+	if got := byDirection["sent"].Metadata["payload_bytes"]; got != uint64(5) {
+		t.Errorf("sent payload_bytes = %v, want 5", got)
+	}
+}
+
+func TestWebSocketUpgradeRefused(t *testing.T) {
+	p := NewHTTPSProxy("0", make(chan models.Signal, 1), testLogger(), "test-customer", "test-agent")
+	p.SetForwarder(cannedResponse(http.StatusUnauthorized, "application/json", `{"error":"bad key"}`))
+
+	client, proxyEnd := net.Pipe()
+	defer client.Close()
+	req := httptest.NewRequest(http.MethodGet, "https://api.openai.com/v1/realtime", nil)
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	go func() {
+		p.proxyWebSocket(req, proxyEnd, bufio.NewReader(proxyEnd))
+		proxyEnd.Close()
+	}()
+
+	resp, err := http.ReadResponse(bufio.NewReader(client), nil)
+	if err != nil {
+		t.Fatalf("read response: %v", err)
+	}
+	defer resp.Body.Close()
+	if body, _ := io.ReadAll(resp.Body); resp.StatusCode != http.StatusUnauthorized || string(body) != `{"error":"bad key"}` {
+		t.Errorf("response = %d %q, want upstream's 401 passed on", resp.StatusCode, body)
+	}
+}
+
+func TestIsWebSocketUpgrade(t *testing.T) {
+	tests := []struct {
+		upgrade, connection string
+		want                bool
+	}{
+		{"websocket", "Upgrade", true},
+		{"WebSocket", "keep-alive, Upgrade", true},
+		{"websocket", "keep-alive", false},
+		{"h2c", "Upgrade", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Upgrade", tt.upgrade)
+		req.Header.Set("Connection", tt.connection)
+		if got := isWebSocketUpgrade(req); got != tt.want {
+			t.Errorf("Upgrade %q Connection %q = %v, want %v", tt.upgrade, tt.connection, got, tt.want)
+		}
+	}
+}
+
+func TestWebSocketFrameTapReassembles(t *testing.T) {
+	var frames [][]byte
+	tap := &wsFrameTap{max: 1024, emit: func(frame []byte) {
+		frames = append(frames, append([]byte(nil), frame...))
+	}}
+	first, second := textFrame("one"), textFrame("two")
+	stream := append(append([]byte(nil), first...), second...)
+	// Split mid-frame, as reads off a connection can be
+	tap.Write(stream[:3])
+	tap.Write(stream[3:7])
+	tap.Write(stream[7:])
+	if len(frames) != 2 || !bytes.Equal(frames[0], first) || !bytes.Equal(frames[1], second) {
+		t.Errorf("emitted %x, want the two frames whole", frames)
+	}
+
+	// A frame larger than max stops the tap without failing the write
+	big := &wsFrameTap{max: 4, emit: func([]byte) { t.Errorf("emitted a frame over max") }}
+	if n, err := big.Write(textFrame("too long")[:6]); n != 6 || err != nil {
+		t.Errorf("Write = %d, %v, want it to accept the bytes", n, err)
+	}
+	if !big.stopped {
+		t.Errorf("tap over max didn't stop")
+	}
+}
@@ -0,0 +1,182 @@
+package pricing
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Environment variables:
+//   AXOM_PRICING_FILE - Optional. Path to a YAML pricing table. Default: /etc/axom/pricing.yaml
+
+// DefaultPricingFile is where the pricing table is loaded from when neither
+// a path nor AXOM_PRICING_FILE is given
+const DefaultPricingFile = "/etc/axom/pricing.yaml"
+
+// ModelPrice is the per-1K-token price (USD) for a model. Model is matched
+// as a prefix, so "gpt-4" also prices "gpt-4-0613"; the longest match wins.
+type ModelPrice struct {
+	Provider        string  `yaml:"provider" json:"provider"` // Empty matches any provider
+	Model           string  `yaml:"model" json:"model"`
+	PromptPer1K     float64 `yaml:"prompt_per_1k" json:"prompt_per_1k"`
+	CompletionPer1K float64 `yaml:"completion_per_1k" json:"completion_per_1k"`
+}
+
+// pricingFile is the on-disk layout of a pricing table
+type pricingFile struct {
+	Models []ModelPrice `yaml:"models"`
+}
+
+// defaultPrices are used for models not present in the pricing file
+var defaultPrices = []ModelPrice{
+	// OpenAI
+	{Provider: "OpenAI", Model: "gpt-4", PromptPer1K: 0.03, CompletionPer1K: 0.06},
+	{Provider: "OpenAI", Model: "gpt-4-32k", PromptPer1K: 0.06, CompletionPer1K: 0.12},
+	{Provider: "OpenAI", Model: "gpt-4-turbo", PromptPer1K: 0.01, CompletionPer1K: 0.03},
+	{Provider: "OpenAI", Model: "gpt-4o", PromptPer1K: 0.0025, CompletionPer1K: 0.01},
+	{Provider: "OpenAI", Model: "gpt-4o-mini", PromptPer1K: 0.00015, CompletionPer1K: 0.0006},
+	{Provider: "OpenAI", Model: "gpt-3.5-turbo", PromptPer1K: 0.0005, CompletionPer1K: 0.0015},
+	{Provider: "OpenAI", Model: "text-embedding-3-small", PromptPer1K: 0.00002},
+	{Provider: "OpenAI", Model: "text-embedding-3-large", PromptPer1K: 0.00013},
+	{Provider: "OpenAI", Model: "text-embedding-ada-002", PromptPer1K: 0.0001},
+	// Anthropic
+	{Provider: "Anthropic", Model: "claude-3-opus", PromptPer1K: 0.015, CompletionPer1K: 0.075},
+	{Provider: "Anthropic", Model: "claude-3-sonnet", PromptPer1K: 0.003, CompletionPer1K: 0.015},
+	{Provider: "Anthropic", Model: "claude-3-5-sonnet", PromptPer1K: 0.003, CompletionPer1K: 0.015},
+	{Provider: "Anthropic", Model: "claude-3-haiku", PromptPer1K: 0.00025, CompletionPer1K: 0.00125},
+	{Provider: "Anthropic", Model: "claude-3-5-haiku", PromptPer1K: 0.0008, CompletionPer1K: 0.004},
+	// Google
+	{Provider: "Google AI", Model: "gemini-1.5-pro", PromptPer1K: 0.00125, CompletionPer1K: 0.005},
+	{Provider: "Google AI", Model: "gemini-1.5-flash", PromptPer1K: 0.000075, CompletionPer1K: 0.0003},
+	{Provider: "Vertex AI", Model: "gemini-1.5-pro", PromptPer1K: 0.00125, CompletionPer1K: 0.005},
+	{Provider: "Vertex AI", Model: "gemini-1.5-flash", PromptPer1K: 0.000075, CompletionPer1K: 0.0003},
+	// OpenAI-compatible providers
+	{Provider: "Mistral", Model: "mistral-large-latest", PromptPer1K: 0.002, CompletionPer1K: 0.006},
+	{Provider: "Mistral", Model: "mistral-small-latest", PromptPer1K: 0.0002, CompletionPer1K: 0.0006},
+	{Provider: "Mistral", Model: "mistral-embed", PromptPer1K: 0.0001},
+	{Provider: "DeepSeek", Model: "deepseek-chat", PromptPer1K: 0.00027, CompletionPer1K: 0.0011},
+	{Provider: "DeepSeek", Model: "deepseek-reasoner", PromptPer1K: 0.00055, CompletionPer1K: 0.00219},
+	{Provider: "xAI", Model: "grok-2", PromptPer1K: 0.002, CompletionPer1K: 0.01},
+	{Provider: "xAI", Model: "grok-beta", PromptPer1K: 0.005, CompletionPer1K: 0.015},
+	// Amazon Bedrock (model IDs are prefixed with the vendor)
+	{Provider: "Amazon Bedrock", Model: "anthropic.claude-3-sonnet", PromptPer1K: 0.003, CompletionPer1K: 0.015},
+	{Provider: "Amazon Bedrock", Model: "anthropic.claude-3-5-sonnet", PromptPer1K: 0.003, CompletionPer1K: 0.015},
+	{Provider: "Amazon Bedrock", Model: "anthropic.claude-3-haiku", PromptPer1K: 0.00025, CompletionPer1K: 0.00125},
+}
+
+// CostEstimator maps (provider, model) to token prices
+type CostEstimator struct {
+	prices []ModelPrice
+}
+
+// NewCostEstimator creates an estimator from the built-in defaults, merged
+// with the YAML table at path (or AXOM_PRICING_FILE, or DefaultPricingFile).
+// Entries in the file override built-in entries for the same provider/model.
+// A missing or malformed file is logged and the defaults are used.
+func NewCostEstimator(path string, logger *log.Logger) *CostEstimator {
+	if path == "" {
+		path = os.Getenv("AXOM_PRICING_FILE")
+	}
+	explicit := path != ""
+	if path == "" {
+		path = DefaultPricingFile
+	}
+
+	estimator := &CostEstimator{prices: append([]ModelPrice(nil), defaultPrices...)}
+
+	prices, err := LoadPricingFile(path)
+	if err != nil {
+		// The default location is optional; only complain about explicit paths
+		if explicit || !os.IsNotExist(err) {
+			logger.Printf("⚠️ Failed to load pricing table %s, using built-in prices: %v", path, err)
+		}
+		return estimator
+	}
+	for _, price := range prices {
+		estimator.set(price)
+	}
+	logger.Printf("💰 Loaded %d model prices from %s", len(prices), path)
+	return estimator
+}
+
+// LoadPricingFile reads a YAML pricing table
+func LoadPricingFile(path string) ([]ModelPrice, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file pricingFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse pricing file: %w", err)
+	}
+	for _, price := range file.Models {
+		if price.Model == "" {
+			return nil, fmt.Errorf("pricing entry missing model name")
+		}
+	}
+	return file.Models, nil
+}
+
+// set adds or replaces the price for a provider/model pair
+func (e *CostEstimator) set(price ModelPrice) {
+	for i, existing := range e.prices {
+		if strings.EqualFold(existing.Provider, price.Provider) && existing.Model == price.Model {
+			e.prices[i] = price
+			return
+		}
+	}
+	e.prices = append(e.prices, price)
+}
+
+// lookup finds the longest model prefix match for the provider
+func (e *CostEstimator) lookup(provider, model string) (ModelPrice, bool) {
+	var best ModelPrice
+	found := false
+	for _, price := range e.prices {
+		if price.Provider != "" && !strings.EqualFold(price.Provider, provider) {
+			continue
+		}
+		if !strings.HasPrefix(model, price.Model) {
+			continue
+		}
+		if !found || len(price.Model) > len(best.Model) {
+			best = price
+			found = true
+		}
+	}
+	return best, found
+}
+
+// Estimate returns the estimated USD cost of a request, and false when the
+// model has no known price
+func (e *CostEstimator) Estimate(provider, model string, promptTokens, completionTokens int) (float64, bool) {
+	if e == nil || model == "" {
+		return 0, false
+	}
+	price, ok := e.lookup(provider, model)
+	if !ok {
+		return 0, false
+	}
+	cost := float64(promptTokens)/1000*price.PromptPer1K + float64(completionTokens)/1000*price.CompletionPer1K
+	// Round to a micro-dollar to keep metadata readable
+	return math.Round(cost*1e6) / 1e6, true
+}
+
+// Annotate writes metadata["estimated_cost_usd"] from the token counts and
+// model already present in metadata. Nothing is written when token counts
+// are missing, so unknown cost is omitted rather than reported as zero.
+func (e *CostEstimator) Annotate(provider string, metadata map[string]interface{}) {
+	promptTokens, hasPrompt := metadata["prompt_tokens"].(int)
+	completionTokens, hasCompletion := metadata["completion_tokens"].(int)
+	if !hasPrompt && !hasCompletion {
+		return
+	}
+	model, _ := metadata["model"].(string)
+	if cost, ok := e.Estimate(provider, model, promptTokens, completionTokens); ok {
+		metadata["estimated_cost_usd"] = cost
+	}
+}
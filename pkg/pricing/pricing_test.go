@@ -0,0 +1,89 @@
+package pricing
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testLogger() *log.Logger {
+	return log.New(io.Discard, "", 0)
+}
+
+func TestEstimateKnownModel(t *testing.T) {
+	e := NewCostEstimator(filepath.Join(t.TempDir(), "missing.yaml"), testLogger())
+
+	// gpt-4: $0.03 per 1K prompt tokens, $0.06 per 1K completion tokens
+	cost, ok := e.Estimate("OpenAI", "gpt-4-0613", 1500, 500)
+	if !ok {
+		t.Fatalf("Estimate found no price for gpt-4-0613")
+	}
+	if want := 0.075; cost != want {
+		t.Errorf("Estimate = %v, want %v", cost, want)
+	}
+}
+
+func TestEstimateLongestPrefixWins(t *testing.T) {
+	e := NewCostEstimator(filepath.Join(t.TempDir(), "missing.yaml"), testLogger())
+
+	// gpt-4o-mini must not be priced as gpt-4o or gpt-4
+	cost, ok := e.Estimate("OpenAI", "gpt-4o-mini-2024-07-18", 1000, 1000)
+	if !ok {
+		t.Fatalf("Estimate found no price for gpt-4o-mini")
+	}
+	if want := 0.00075; cost != want {
+		t.Errorf("Estimate = %v, want %v", cost, want)
+	}
+}
+
+func TestEstimateUnknownModel(t *testing.T) {
+	e := NewCostEstimator(filepath.Join(t.TempDir(), "missing.yaml"), testLogger())
+	if _, ok := e.Estimate("OpenAI", "some-new-model", 100, 100); ok {
+		t.Errorf("Estimate priced an unknown model")
+	}
+	if _, ok := e.Estimate("Anthropic", "gpt-4", 100, 100); ok {
+		t.Errorf("Estimate priced a model under another provider")
+	}
+}
+
+func TestPricingFileOverridesDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pricing.yaml")
+	table := `models:
+  - provider: OpenAI
+    model: gpt-4
+    prompt_per_1k: 0.01
+    completion_per_1k: 0.02
+  - model: local-llama
+    prompt_per_1k: 0.001
+`
+	if err := os.WriteFile(path, []byte(table), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	e := NewCostEstimator(path, testLogger())
+
+	if cost, _ := e.Estimate("OpenAI", "gpt-4", 1000, 1000); cost != 0.03 {
+		t.Errorf("overridden gpt-4 cost = %v, want 0.03", cost)
+	}
+	// Entries without a provider price any provider's model
+	if cost, _ := e.Estimate("Local AI Services", "local-llama-3", 2000, 0); cost != 0.002 {
+		t.Errorf("local-llama cost = %v, want 0.002", cost)
+	}
+}
+
+func TestAnnotate(t *testing.T) {
+	e := NewCostEstimator(filepath.Join(t.TempDir(), "missing.yaml"), testLogger())
+
+	metadata := map[string]interface{}{"model": "claude-3-5-sonnet-20241022", "prompt_tokens": 2000, "completion_tokens": 1000}
+	e.Annotate("Anthropic", metadata)
+	if got := metadata["estimated_cost_usd"]; got != 0.021 {
+		t.Errorf("estimated_cost_usd = %v, want 0.021", got)
+	}
+
+	noUsage := map[string]interface{}{"model": "gpt-4"}
+	e.Annotate("OpenAI", noUsage)
+	if _, ok := noUsage["estimated_cost_usd"]; ok {
+		t.Errorf("Annotate wrote a cost without token counts")
+	}
+}
@@ -0,0 +1,22 @@
+package protocols
+
+import (
+	"net"
+	"strconv"
+
+	"axom-observer/pkg/models"
+)
+
+// AddrToEndpoint converts a network address to a signal endpoint, splitting
+// host and port
+func AddrToEndpoint(addr net.Addr) models.Endpoint {
+	if addr == nil {
+		return models.Endpoint{}
+	}
+	host, portStr, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return models.Endpoint{IP: addr.String()}
+	}
+	port, _ := strconv.Atoi(portStr)
+	return models.Endpoint{IP: host, Port: port}
+}
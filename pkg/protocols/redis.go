@@ -0,0 +1,150 @@
+package protocols
+
+import (
+	"bytes"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"axom-observer/pkg/models"
+)
+
+// maxRedisPipeline bounds how many pipelined commands are parsed per packet
+const maxRedisPipeline = 64
+
+// RedisCommand is a single command parsed from a RESP request
+type RedisCommand struct {
+	Verb string   // Upper-cased command name, e.g. GET
+	Args []string // Remaining arguments
+}
+
+// Key returns the command's first argument, which for most commands is the key
+func (c RedisCommand) Key() string {
+	if len(c.Args) == 0 {
+		return ""
+	}
+	return c.Args[0]
+}
+
+// ParseRedisCommands parses the pipelined commands in data, in either
+// multi-bulk (*N\r\n$len\r\n...) or inline form. A trailing partial command
+// is ignored.
+func ParseRedisCommands(data []byte) []RedisCommand {
+	var commands []RedisCommand
+	for len(data) > 0 && len(commands) < maxRedisPipeline {
+		var args []string
+		var n int
+		if data[0] == '*' {
+			args, n = parseRESPArray(data)
+		} else {
+			args, n = parseRedisInline(data)
+		}
+		if n == 0 {
+			break
+		}
+		data = data[n:]
+		if len(args) == 0 {
+			continue
+		}
+		commands = append(commands, RedisCommand{
+			Verb: strings.ToUpper(args[0]),
+			Args: args[1:],
+		})
+	}
+	return commands
+}
+
+// ProcessRedis parses the RESP commands in packet and returns a signal for
+// the first one, with the command verb in DBOperation and its key in
+// DBTable. The verbs of any pipelined commands are recorded in metadata.
+// Responses and partial commands yield a nil signal.
+func ProcessRedis(packet []byte, src, dst net.Addr) (*models.Signal, error) {
+	commands := ParseRedisCommands(packet)
+	if len(commands) == 0 {
+		return nil, nil
+	}
+	first := commands[0]
+
+	metadata := map[string]interface{}{
+		"arg_count": len(first.Args),
+	}
+	if len(commands) > 1 {
+		verbs := make([]string, len(commands))
+		for i, cmd := range commands {
+			verbs[i] = cmd.Verb
+		}
+		metadata["pipelined_commands"] = len(commands)
+		metadata["pipeline"] = verbs
+	}
+
+	return &models.Signal{
+		ID:            models.NewSignalID(),
+		SchemaVersion: models.SignalSchemaVersion,
+		Timestamp:     time.Now(),
+		Protocol:      "redis",
+		Source:        AddrToEndpoint(src),
+		Destination:   AddrToEndpoint(dst),
+		Operation:     "db_" + strings.ToLower(first.Verb),
+		DBOperation:   first.Verb,
+		DBTable:       first.Key(),
+		Metadata:      metadata,
+	}, nil
+}
+
+// parseRESPArray parses a multi-bulk request and returns its arguments and
+// the number of bytes consumed, or 0 if the array is incomplete or malformed
+func parseRESPArray(data []byte) ([]string, int) {
+	line, pos := readRESPLine(data, 0)
+	if pos < 0 {
+		return nil, 0
+	}
+	count, err := strconv.Atoi(string(line[1:]))
+	// Each argument takes at least 6 bytes ("$0\r\n\r\n"), so a count the
+	// remaining data can't hold is incomplete or bogus; rejecting it keeps a
+	// few header bytes from sizing a large allocation
+	if err != nil || count < 0 || count > (len(data)-pos)/6 {
+		return nil, 0
+	}
+
+	args := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		line, next := readRESPLine(data, pos)
+		if next < 0 || len(line) == 0 || line[0] != '$' {
+			return nil, 0
+		}
+		size, err := strconv.Atoi(string(line[1:]))
+		// Checked before adding, as next+size can overflow
+		if err != nil || size < 0 || size > len(data)-next-2 {
+			return nil, 0
+		}
+		end := next + size
+		args = append(args, string(data[next:end]))
+		pos = end + 2
+	}
+	return args, pos
+}
+
+// parseRedisInline parses an inline command such as "PING\r\n"
+func parseRedisInline(data []byte) ([]string, int) {
+	line, pos := readRESPLine(data, 0)
+	if pos < 0 {
+		return nil, 0
+	}
+	// Responses start with a type byte and aren't commands
+	switch line[0] {
+	case '+', '-', ':', '$':
+		return nil, 0
+	}
+	return strings.Fields(string(line)), pos
+}
+
+// readRESPLine returns the line starting at pos (without CRLF) and the
+// offset just past it, or -1 if no complete line is available
+func readRESPLine(data []byte, pos int) ([]byte, int) {
+	end := bytes.Index(data[pos:], []byte("\r\n"))
+	if end <= 0 {
+		return nil, -1
+	}
+	return data[pos : pos+end], pos + end + 2
+}
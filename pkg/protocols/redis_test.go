@@ -0,0 +1,96 @@
+package protocols
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestParseRedisCommandsMultiBulk(t *testing.T) {
+	data := []byte("*3\r\n$3\r\nSET\r\n$7\r\nsession\r\n$12\r\nhello\r\nthere\r\n")
+	commands := ParseRedisCommands(data)
+	want := []RedisCommand{{Verb: "SET", Args: []string{"session", "hello\r\nthere"}}}
+	if !reflect.DeepEqual(commands, want) {
+		t.Errorf("ParseRedisCommands = %+v, want %+v", commands, want)
+	}
+}
+
+func TestParseRedisCommandsInline(t *testing.T) {
+	commands := ParseRedisCommands([]byte("get user:1\r\nPING\r\n"))
+	want := []RedisCommand{
+		{Verb: "GET", Args: []string{"user:1"}},
+		{Verb: "PING", Args: []string{}},
+	}
+	if !reflect.DeepEqual(commands, want) {
+		t.Errorf("ParseRedisCommands = %+v, want %+v", commands, want)
+	}
+}
+
+func TestParseRedisCommandsIgnoresPartialAndResponses(t *testing.T) {
+	for name, data := range map[string]string{
+		"partial array":   "*2\r\n$3\r\nGET\r\n$5\r\nus",
+		"partial line":    "GET user",
+		"simple string":   "+OK\r\n",
+		"error":           "-ERR unknown command\r\n",
+		"integer":         ":1\r\n",
+		"bulk string":     "$5\r\nhello\r\n",
+		"malformed count": "*x\r\n",
+	} {
+		if commands := ParseRedisCommands([]byte(data)); len(commands) != 0 {
+			t.Errorf("%s: ParseRedisCommands = %+v, want none", name, commands)
+		}
+	}
+}
+
+func TestProcessRedisPipeline(t *testing.T) {
+	data := []byte("*2\r\n$3\r\nGET\r\n$6\r\nuser:1\r\n*3\r\n$4\r\nINCR\r\n$4\r\nhits\r\n$1\r\n1\r\nEXPIRE hits 60\r\n")
+	signal, err := ProcessRedis(data, nil, nil)
+	if err != nil || signal == nil {
+		t.Fatalf("ProcessRedis = %v, %v", signal, err)
+	}
+	if signal.Protocol != "redis" || signal.Operation != "db_get" {
+		t.Errorf("signal = %s/%s, want redis/db_get", signal.Protocol, signal.Operation)
+	}
+	if signal.DBOperation != "GET" || signal.DBTable != "user:1" {
+		t.Errorf("DBOperation/DBTable = %s/%s, want GET/user:1", signal.DBOperation, signal.DBTable)
+	}
+	if got := signal.Metadata["pipelined_commands"]; got != 3 {
+		t.Errorf("pipelined_commands = %v, want 3", got)
+	}
+	if got := signal.Metadata["pipeline"]; !reflect.DeepEqual(got, []string{"GET", "INCR", "EXPIRE"}) {
+		t.Errorf("pipeline = %v, want GET INCR EXPIRE", got)
+	}
+}
+
+func TestProcessRedisResponse(t *testing.T) {
+	if signal, err := ProcessRedis([]byte("+OK\r\n"), nil, nil); signal != nil || err != nil {
+		t.Errorf("ProcessRedis(+OK) = %+v, %v, want no signal", signal, err)
+	}
+}
+
+func TestParseRESPArrayHugeBulkLength(t *testing.T) {
+	// next+size overflows, which once slipped past the bounds check
+	for _, data := range []string{
+		"*1\r\n$9223372036854775807\r\nGET\r\n",
+		"*1\r\n$9223372036854775806\r\n",
+		"*1\r\n$4\r\nGET\r\n",
+	} {
+		if args, n := parseRESPArray([]byte(data)); args != nil || n != 0 {
+			t.Errorf("parseRESPArray(%q) = %q, %d, want it rejected", data, args, n)
+		}
+	}
+}
+
+func TestParseRESPArrayCountBoundedByData(t *testing.T) {
+	data := []byte("*1048576\r\n$3\r\nGET\r\n")
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	args, n := parseRESPArray(data)
+	runtime.ReadMemStats(&after)
+	if args != nil || n != 0 {
+		t.Errorf("parseRESPArray = %q, %d, want a count the data can't hold rejected", args, n)
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 64*1024 {
+		t.Errorf("parsing %d bytes allocated %d, want the count not to size the allocation", len(data), allocated)
+	}
+}
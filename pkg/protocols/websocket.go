@@ -0,0 +1,170 @@
+package protocols
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net"
+	"time"
+
+	"axom-observer/pkg/models"
+)
+
+// WebSocket opcodes (RFC 6455 section 5.2)
+const (
+	WSOpContinuation = 0x0
+	WSOpText         = 0x1
+	WSOpBinary       = 0x2
+	WSOpClose        = 0x8
+	WSOpPing         = 0x9
+	WSOpPong         = 0xA
+)
+
+// ErrIncompleteFrame is returned when the buffer ends before the frame does
+var ErrIncompleteFrame = errors.New("incomplete websocket frame")
+
+// WebSocketFrame is a decoded WebSocket frame
+type WebSocketFrame struct {
+	Fin           bool
+	Opcode        byte
+	Masked        bool
+	PayloadLength uint64
+	Payload       []byte // Unmasked payload
+}
+
+// ParseWebSocketFrame decodes a single frame from the start of data and
+// returns it along with the number of bytes consumed
+func ParseWebSocketFrame(data []byte) (*WebSocketFrame, int, error) {
+	if len(data) < 2 {
+		return nil, 0, ErrIncompleteFrame
+	}
+
+	frame := &WebSocketFrame{
+		Fin:    data[0]&0x80 != 0,
+		Opcode: data[0] & 0x0F,
+		Masked: data[1]&0x80 != 0,
+	}
+
+	offset := 2
+	length := uint64(data[1] & 0x7F)
+	switch length {
+	case 126:
+		if len(data) < offset+2 {
+			return nil, 0, ErrIncompleteFrame
+		}
+		length = uint64(binary.BigEndian.Uint16(data[offset:]))
+		offset += 2
+	case 127:
+		if len(data) < offset+8 {
+			return nil, 0, ErrIncompleteFrame
+		}
+		length = binary.BigEndian.Uint64(data[offset:])
+		offset += 8
+	}
+	frame.PayloadLength = length
+
+	var mask []byte
+	if frame.Masked {
+		if len(data) < offset+4 {
+			return nil, 0, ErrIncompleteFrame
+		}
+		mask = data[offset : offset+4]
+		offset += 4
+	}
+
+	if uint64(len(data)-offset) < length {
+		return nil, 0, ErrIncompleteFrame
+	}
+	end := offset + int(length)
+
+	frame.Payload = make([]byte, length)
+	copy(frame.Payload, data[offset:end])
+	if frame.Masked {
+		for i := range frame.Payload {
+			frame.Payload[i] ^= mask[i%4]
+		}
+	}
+
+	return frame, end, nil
+}
+
+// ProcessWebSocket parses a WebSocket frame captured between src and dst.
+// Text frames carrying JSON have their transcript/message extracted; binary
+// frames (streamed audio) are recorded by size only. Control frames and
+// incomplete frames yield a nil signal.
+func ProcessWebSocket(packet []byte, src, dst net.Addr) (*models.Signal, error) {
+	frame, _, err := ParseWebSocketFrame(packet)
+	if err != nil {
+		if errors.Is(err, ErrIncompleteFrame) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	metadata := map[string]interface{}{
+		"ws_opcode":     int(frame.Opcode),
+		"ws_fin":        frame.Fin,
+		"payload_bytes": frame.PayloadLength,
+	}
+
+	var operation string
+	switch frame.Opcode {
+	case WSOpText:
+		operation = "ws_text"
+		extractWebSocketText(metadata, frame.Payload)
+	case WSOpBinary:
+		operation = "ws_audio"
+	case WSOpContinuation:
+		operation = "ws_continuation"
+	default:
+		return nil, nil
+	}
+
+	return &models.Signal{
+		ID:            models.NewSignalID(),
+		SchemaVersion: models.SignalSchemaVersion,
+		Timestamp:     time.Now(),
+		Protocol:      "websocket",
+		Source:        AddrToEndpoint(src),
+		Destination:   AddrToEndpoint(dst),
+		Operation:     operation,
+		Metadata:      metadata,
+	}, nil
+}
+
+// extractWebSocketText pulls the transcript or message out of a JSON text
+// frame. Deepgram nests transcripts under channel.alternatives[0];
+// AssemblyAI uses "text" with a "message_type".
+func extractWebSocketText(metadata map[string]interface{}, payload []byte) {
+	var msg map[string]interface{}
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		return
+	}
+
+	if msgType, ok := msg["type"].(string); ok {
+		metadata["message_type"] = msgType
+	} else if msgType, ok := msg["message_type"].(string); ok {
+		metadata["message_type"] = msgType
+	}
+
+	if transcript, ok := msg["transcript"].(string); ok {
+		metadata["transcript"] = transcript
+	} else if channel, ok := msg["channel"].(map[string]interface{}); ok {
+		if alternatives, ok := channel["alternatives"].([]interface{}); ok && len(alternatives) > 0 {
+			if alt, ok := alternatives[0].(map[string]interface{}); ok {
+				if transcript, ok := alt["transcript"].(string); ok {
+					metadata["transcript"] = transcript
+				}
+			}
+		}
+	} else if text, ok := msg["text"].(string); ok {
+		metadata["transcript"] = text
+	}
+
+	if message, ok := msg["message"].(string); ok {
+		metadata["message"] = message
+	}
+	if isFinal, ok := msg["is_final"].(bool); ok {
+		metadata["is_final"] = isFinal
+	}
+}
@@ -0,0 +1,137 @@
+package protocols
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+
+	"axom-observer/pkg/models"
+)
+
+// maskedTextFrame is a masked client text frame carrying "Hello" (RFC 6455
+// section 5.7)
+var maskedTextFrame = []byte{0x81, 0x85, 0x37, 0xfa, 0x21, 0x3d, 0x7f, 0x9f, 0x4d, 0x51, 0x58}
+
+func TestParseWebSocketFrameMaskedText(t *testing.T) {
+	frame, n, err := ParseWebSocketFrame(maskedTextFrame)
+	if err != nil {
+		t.Fatalf("ParseWebSocketFrame: %v", err)
+	}
+	if n != len(maskedTextFrame) {
+		t.Errorf("consumed %d bytes, want %d", n, len(maskedTextFrame))
+	}
+	if !frame.Fin || frame.Opcode != WSOpText || !frame.Masked {
+		t.Errorf("frame = fin %v opcode %#x masked %v, want a final masked text frame", frame.Fin, frame.Opcode, frame.Masked)
+	}
+	if string(frame.Payload) != "Hello" {
+		t.Errorf("Payload = %q, want %q", frame.Payload, "Hello")
+	}
+}
+
+func TestParseWebSocketFrameExtendedLength(t *testing.T) {
+	payload := bytes.Repeat([]byte{0xab}, 256)
+	data := append([]byte{0x82, 0x7e, 0x01, 0x00}, payload...)
+	// A second frame follows in the same buffer
+	data = append(data, 0x89, 0x00)
+
+	frame, n, err := ParseWebSocketFrame(data)
+	if err != nil {
+		t.Fatalf("ParseWebSocketFrame: %v", err)
+	}
+	if frame.Opcode != WSOpBinary || frame.PayloadLength != 256 || !bytes.Equal(frame.Payload, payload) {
+		t.Errorf("frame = opcode %#x length %d, want a 256-byte binary frame", frame.Opcode, frame.PayloadLength)
+	}
+	if n != 4+256 {
+		t.Errorf("consumed %d bytes, want %d", n, 4+256)
+	}
+
+	ping, _, err := ParseWebSocketFrame(data[n:])
+	if err != nil || ping.Opcode != WSOpPing {
+		t.Errorf("second frame = %+v, %v, want a ping", ping, err)
+	}
+}
+
+func TestParseWebSocketFrameIncomplete(t *testing.T) {
+	for n := 0; n < len(maskedTextFrame); n++ {
+		if _, _, err := ParseWebSocketFrame(maskedTextFrame[:n]); !errors.Is(err, ErrIncompleteFrame) {
+			t.Errorf("ParseWebSocketFrame(%d bytes) = %v, want ErrIncompleteFrame", n, err)
+		}
+	}
+}
+
+func TestProcessWebSocketDeepgramTranscript(t *testing.T) {
+	payload := `{"type":"Results","is_final":true,"channel":{"alternatives":[{"transcript":"hello world","confidence":0.98}]}}`
+	frame := append([]byte{0x81, 0x7e, 0x00, byte(len(payload))}, payload...)
+	src := &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 51000}
+	dst := &net.TCPAddr{IP: net.ParseIP("34.1.2.3"), Port: 443}
+
+	signal, err := ProcessWebSocket(frame, src, dst)
+	if err != nil || signal == nil {
+		t.Fatalf("ProcessWebSocket = %v, %v", signal, err)
+	}
+	if signal.Operation != "ws_text" || signal.Protocol != "websocket" {
+		t.Errorf("signal = %s/%s, want websocket/ws_text", signal.Protocol, signal.Operation)
+	}
+	for key, want := range map[string]interface{}{
+		"transcript":   "hello world",
+		"message_type": "Results",
+		"is_final":     true,
+		"ws_opcode":    WSOpText,
+	} {
+		if got := signal.Metadata[key]; got != want {
+			t.Errorf("Metadata[%q] = %v, want %v", key, got, want)
+		}
+	}
+	if signal.Source.IP != "10.0.0.2" || signal.Destination.Port != 443 {
+		t.Errorf("endpoints = %+v -> %+v", signal.Source, signal.Destination)
+	}
+}
+
+func TestProcessWebSocketAssemblyAIText(t *testing.T) {
+	payload := `{"message_type":"FinalTranscript","text":"good morning"}`
+	frame := append([]byte{0x81, byte(len(payload))}, payload...)
+
+	signal, err := ProcessWebSocket(frame, nil, nil)
+	if err != nil || signal == nil {
+		t.Fatalf("ProcessWebSocket = %v, %v", signal, err)
+	}
+	if signal.Metadata["transcript"] != "good morning" || signal.Metadata["message_type"] != "FinalTranscript" {
+		t.Errorf("Metadata = %v, want the AssemblyAI transcript", signal.Metadata)
+	}
+}
+
+func TestProcessWebSocketBinaryAndControlFrames(t *testing.T) {
+	audio := append([]byte{0x82, 0x04}, 1, 2, 3, 4)
+	signal, err := ProcessWebSocket(audio, nil, nil)
+	if err != nil || signal == nil || signal.Operation != "ws_audio" {
+		t.Fatalf("binary frame = %+v, %v, want a ws_audio signal", signal, err)
+	}
+	if signal.Metadata["payload_bytes"] != uint64(4) {
+		t.Errorf("payload_bytes = %v, want 4", signal.Metadata["payload_bytes"])
+	}
+
+	for _, frame := range [][]byte{{0x89, 0x00}, {0x8a, 0x00}, {0x88, 0x02, 0x03, 0xe8}} {
+		if signal, err := ProcessWebSocket(frame, nil, nil); signal != nil || err != nil {
+			t.Errorf("control frame %#x = %+v, %v, want no signal", frame[0], signal, err)
+		}
+	}
+}
+
+func TestProcessorsSetSchemaVersion(t *testing.T) {
+	process := map[string]func() (*models.Signal, error){
+		"websocket": func() (*models.Signal, error) { return ProcessWebSocket(maskedTextFrame, nil, nil) },
+		"redis": func() (*models.Signal, error) {
+			return ProcessRedis([]byte("*2\r\n$3\r\nGET\r\n$6\r\nuser:1\r\n"), nil, nil)
+		},
+	}
+	for name, fn := range process {
+		signal, err := fn()
+		if err != nil || signal == nil {
+			t.Fatalf("%s: got %v, %v, want a signal", name, signal, err)
+		}
+		if signal.SchemaVersion != models.SignalSchemaVersion {
+			t.Errorf("%s SchemaVersion = %q, want %q", name, signal.SchemaVersion, models.SignalSchemaVersion)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package tokens
+
+import (
+	"strings"
+	"testing"
+)
+
+// withinTenPercent reports whether got is within 10% of want, or off by one
+// for short texts
+func withinTenPercent(got, want int) bool {
+	diff := got - want
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff <= 1 || diff*10 <= want
+}
+
+func TestEstimateKnownCounts(t *testing.T) {
+	// Counts from the cl100k_base encoder
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"Hello, world!", 4},
+		{"The quick brown fox jumps over the lazy dog.", 10},
+		{"1234567890", 4},
+		{"What is the capital of France?", 7},
+		{"Summarize the following article in three bullet points.", 10},
+		{"你好世界", 4},
+	}
+	for _, tt := range tests {
+		got := Estimate("gpt-4", tt.text)
+		if !withinTenPercent(got, tt.want) {
+			t.Errorf("Estimate(%q) = %d, want about %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestEstimateProse(t *testing.T) {
+	// 10 sentences of 10 tokens each
+	text := strings.TrimSpace(strings.Repeat("The quick brown fox jumps over the lazy dog. ", 10))
+	if got := Estimate("gpt-4", text); !withinTenPercent(got, 100) {
+		t.Errorf("Estimate of 10 sentences = %d, want about 100", got)
+	}
+}
+
+func TestEstimateFamilies(t *testing.T) {
+	text := strings.Repeat("Observability for language model traffic. ", 20)
+	base := Estimate("gpt-4", text)
+	if got := Estimate("", text); got != base {
+		t.Errorf("unknown model = %d, want the cl100k estimate %d", got, base)
+	}
+	if got := Estimate("gpt-4o-mini", text); got >= base {
+		t.Errorf("gpt-4o estimate %d, want fewer than cl100k's %d for its larger vocabulary", got, base)
+	}
+	if got := Estimate("Claude-3-5-Sonnet", text); got <= base {
+		t.Errorf("claude estimate %d, want more than cl100k's %d", got, base)
+	}
+	if got := Estimate("gpt-4", ""); got != 0 {
+		t.Errorf("Estimate of empty text = %d, want 0", got)
+	}
+}
+
+func TestEstimateMessages(t *testing.T) {
+	texts := []string{"You are a helpful assistant.", "Hello, world!"}
+	want := perReplyTokens
+	for _, text := range texts {
+		want += perMessageTokens + Estimate("gpt-4", text)
+	}
+	if got := EstimateMessages("gpt-4", texts); got != want {
+		t.Errorf("EstimateMessages = %d, want %d with the chat formatting", got, want)
+	}
+	if got := EstimateMessages("gpt-4", nil); got != 0 {
+		t.Errorf("EstimateMessages of no messages = %d, want 0", got)
+	}
+}